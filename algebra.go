@@ -0,0 +1,590 @@
+package rdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SPARQL algebra, as defined in http://www.w3.org/TR/sparql11-query/#sparqlAlgebra
+//
+// The String methods of the algebra operators and expressions return the
+// SPARQL S-Expression (SSE) notation, which is handy for inspecting and
+// testing the translation of queries.
+
+// Op is an operator of the SPARQL algebra.
+type Op interface {
+	// String returns the operator in SSE notation.
+	String() string
+
+	op()
+}
+
+// BGP is a basic graph pattern; a set of triple patterns. The empty BGP is
+// the identity for joins; it has one solution which binds no variables.
+type BGP struct {
+	Patterns []TriplePattern
+}
+
+// PathPattern matches a property path between a subject and an object.
+type PathPattern struct {
+	Subj Term
+	Path Path
+	Obj  Term
+}
+
+// Join is the join of two patterns.
+type Join struct {
+	Left, Right Op
+}
+
+// LeftJoin is an OPTIONAL pattern. Expr is the filter of the optional
+// part; it is nil if there is none.
+type LeftJoin struct {
+	Left, Right Op
+	Expr        Expr
+}
+
+// Filter removes the solutions for which the expression is not true.
+type Filter struct {
+	Expr Expr
+	Sub  Op
+}
+
+// Union is the union of two patterns.
+type Union struct {
+	Left, Right Op
+}
+
+// Minus removes the solutions of Left which are compatible with a solution
+// of Right, sharing at least one variable.
+type Minus struct {
+	Left, Right Op
+}
+
+// GraphPattern matches the pattern against a named graph. Name is an IRI or a Var.
+type GraphPattern struct {
+	Name Term
+	Sub  Op
+}
+
+// Extend binds a variable to the value of an expression (BIND or a
+// SELECT expression).
+type Extend struct {
+	Sub  Op
+	Var  Var
+	Expr Expr
+}
+
+// Table is a set of solutions given inline, by VALUES. Unbound
+// variables (UNDEF) are missing from the row bindings.
+type Table struct {
+	Vars []Var
+	Rows []Binding
+}
+
+// GroupKey is an expression to group by. If Var is not empty, the
+// value of the expression is bound to it (GROUP BY (expr AS ?var)).
+type GroupKey struct {
+	Expr Expr
+	Var  Var
+}
+
+// Aggregation is an aggregate function call, bound to a variable.
+type Aggregation struct {
+	Var       Var
+	Name      string // COUNT, SUM, MIN, MAX, AVG, SAMPLE or GROUP_CONCAT
+	Distinct  bool
+	Arg       Expr // nil for COUNT(*)
+	Separator string
+}
+
+// Group partitions the solutions by the keys, and computes the aggregates
+// for each group.
+type Group struct {
+	Keys       []GroupKey
+	Aggregates []Aggregation
+	Sub        Op
+}
+
+// OrderCond is an ORDER BY condition.
+type OrderCond struct {
+	Expr Expr
+	Desc bool
+}
+
+// OrderBy sorts the solutions.
+type OrderBy struct {
+	Conds []OrderCond
+	Sub   Op
+}
+
+// Project restricts the solutions to the given variables.
+type Project struct {
+	Vars []Var
+	Sub  Op
+}
+
+// Distinct removes duplicate solutions.
+type Distinct struct {
+	Sub Op
+}
+
+// Reduced permits, but doesn't require, removing duplicate solutions.
+type Reduced struct {
+	Sub Op
+}
+
+// Slice returns the solutions starting at Offset, at most Limit of them.
+// Limit is -1 if there is no limit.
+type Slice struct {
+	Offset, Limit int
+	Sub           Op
+}
+
+func (BGP) op()          {}
+func (PathPattern) op()  {}
+func (Join) op()         {}
+func (LeftJoin) op()     {}
+func (Filter) op()       {}
+func (Union) op()        {}
+func (Minus) op()        {}
+func (GraphPattern) op() {}
+func (Extend) op()       {}
+func (Table) op()        {}
+func (Group) op()        {}
+func (OrderBy) op()      {}
+func (Project) op()      {}
+func (Distinct) op()     {}
+func (Reduced) op()      {}
+func (Slice) op()        {}
+
+func (o BGP) String() string {
+	var b strings.Builder
+	b.WriteString("(bgp")
+	for _, tp := range o.Patterns {
+		fmt.Fprintf(&b, " (triple %s %s %s)", sseTerm(tp.Subj), sseTerm(tp.Pred), sseTerm(tp.Obj))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (o PathPattern) String() string {
+	return fmt.Sprintf("(path %s %s %s)", sseTerm(o.Subj), o.Path, sseTerm(o.Obj))
+}
+
+func (o Join) String() string {
+	return fmt.Sprintf("(join %s %s)", o.Left, o.Right)
+}
+
+func (o LeftJoin) String() string {
+	if o.Expr == nil {
+		return fmt.Sprintf("(leftjoin %s %s)", o.Left, o.Right)
+	}
+	return fmt.Sprintf("(leftjoin %s %s %s)", o.Left, o.Right, o.Expr)
+}
+
+func (o Filter) String() string {
+	return fmt.Sprintf("(filter %s %s)", o.Expr, o.Sub)
+}
+
+func (o Union) String() string {
+	return fmt.Sprintf("(union %s %s)", o.Left, o.Right)
+}
+
+func (o Minus) String() string {
+	return fmt.Sprintf("(minus %s %s)", o.Left, o.Right)
+}
+
+func (o GraphPattern) String() string {
+	return fmt.Sprintf("(graph %s %s)", sseTerm(o.Name), o.Sub)
+}
+
+func (o Extend) String() string {
+	return fmt.Sprintf("(extend ((%s %s)) %s)", sseTerm(o.Var), o.Expr, o.Sub)
+}
+
+func (o Table) String() string {
+	var b strings.Builder
+	b.WriteString("(table (vars")
+	for _, v := range o.Vars {
+		b.WriteString(" " + sseTerm(v))
+	}
+	b.WriteString(")")
+	for _, row := range o.Rows {
+		b.WriteString(" (row")
+		for _, v := range o.Vars {
+			if t, ok := row[v]; ok {
+				fmt.Fprintf(&b, " [%s %s]", sseTerm(v), sseTerm(t))
+			}
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (o Group) String() string {
+	var b strings.Builder
+	b.WriteString("(group (")
+	for i, k := range o.Keys {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if k.Var != "" {
+			fmt.Fprintf(&b, "(%s %s)", sseTerm(k.Var), k.Expr)
+		} else {
+			b.WriteString(k.Expr.String())
+		}
+	}
+	b.WriteString(") (")
+	for i, a := range o.Aggregates {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "(%s %s)", sseTerm(a.Var), a)
+	}
+	fmt.Fprintf(&b, ") %s)", o.Sub)
+	return b.String()
+}
+
+// String returns the aggregate call in SSE notation.
+func (a Aggregation) String() string {
+	var b strings.Builder
+	b.WriteString("(" + strings.ToLower(a.Name))
+	if a.Distinct {
+		b.WriteString(" distinct")
+	}
+	if a.Name == "GROUP_CONCAT" && a.Separator != " " {
+		fmt.Fprintf(&b, " (separator %s)", strconv.Quote(a.Separator))
+	}
+	if a.Arg != nil {
+		b.WriteString(" " + a.Arg.String())
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (o OrderBy) String() string {
+	var b strings.Builder
+	b.WriteString("(order (")
+	for i, c := range o.Conds {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		if c.Desc {
+			fmt.Fprintf(&b, "(desc %s)", c.Expr)
+		} else {
+			b.WriteString(c.Expr.String())
+		}
+	}
+	fmt.Fprintf(&b, ") %s)", o.Sub)
+	return b.String()
+}
+
+func (o Project) String() string {
+	vars := make([]string, len(o.Vars))
+	for i, v := range o.Vars {
+		vars[i] = sseTerm(v)
+	}
+	return fmt.Sprintf("(project (%s) %s)", strings.Join(vars, " "), o.Sub)
+}
+
+func (o Distinct) String() string {
+	return fmt.Sprintf("(distinct %s)", o.Sub)
+}
+
+func (o Reduced) String() string {
+	return fmt.Sprintf("(reduced %s)", o.Sub)
+}
+
+func (o Slice) String() string {
+	limit := "_"
+	if o.Limit >= 0 {
+		limit = strconv.Itoa(o.Limit)
+	}
+	return fmt.Sprintf("(slice %d %s %s)", o.Offset, limit, o.Sub)
+}
+
+// Path is a SPARQL property path expression.
+type Path interface {
+	// String returns the path in SSE notation.
+	String() string
+
+	path()
+}
+
+// PathLink is a path of length one; an IRI.
+type PathLink struct {
+	IRI IRI
+}
+
+// PathInverse is the inverse of a path: ^path.
+type PathInverse struct {
+	Path Path
+}
+
+// PathSeq is a sequence of two paths: left/right.
+type PathSeq struct {
+	Left, Right Path
+}
+
+// PathAlt is an alternative of two paths: left|right.
+type PathAlt struct {
+	Left, Right Path
+}
+
+// PathZeroOrMore is path*.
+type PathZeroOrMore struct {
+	Path Path
+}
+
+// PathOneOrMore is path+.
+type PathOneOrMore struct {
+	Path Path
+}
+
+// PathZeroOrOne is path?.
+type PathZeroOrOne struct {
+	Path Path
+}
+
+// PathNegated is a negated property set: !(iri|^iri ...), matching any
+// predicate except the given forward and inverse IRIs.
+type PathNegated struct {
+	Fwd []IRI
+	Inv []IRI
+}
+
+func (PathLink) path()       {}
+func (PathInverse) path()    {}
+func (PathSeq) path()        {}
+func (PathAlt) path()        {}
+func (PathZeroOrMore) path() {}
+func (PathOneOrMore) path()  {}
+func (PathZeroOrOne) path()  {}
+func (PathNegated) path()    {}
+
+func (p PathLink) String() string       { return sseTerm(p.IRI) }
+func (p PathInverse) String() string    { return fmt.Sprintf("(reverse %s)", p.Path) }
+func (p PathSeq) String() string        { return fmt.Sprintf("(seq %s %s)", p.Left, p.Right) }
+func (p PathAlt) String() string        { return fmt.Sprintf("(alt %s %s)", p.Left, p.Right) }
+func (p PathZeroOrMore) String() string { return fmt.Sprintf("(path* %s)", p.Path) }
+func (p PathOneOrMore) String() string  { return fmt.Sprintf("(path+ %s)", p.Path) }
+func (p PathZeroOrOne) String() string  { return fmt.Sprintf("(path? %s)", p.Path) }
+
+func (p PathNegated) String() string {
+	var s []string
+	for _, iri := range p.Fwd {
+		s = append(s, sseTerm(iri))
+	}
+	for _, iri := range p.Inv {
+		s = append(s, "(reverse "+sseTerm(iri)+")")
+	}
+	return "(notoneof " + strings.Join(s, " ") + ")"
+}
+
+// Expr is a SPARQL expression.
+type Expr interface {
+	// String returns the expression in SSE notation.
+	String() string
+
+	expr()
+}
+
+// TermExpr is a constant term, or a variable.
+type TermExpr struct {
+	Term Term
+}
+
+// UnaryExpr is an unary operator: '!', '+' or '-'.
+type UnaryExpr struct {
+	Op  string
+	Arg Expr
+}
+
+// BinaryExpr is a binary operator: '||', '&&', '=', '!=', '<', '>', '<=',
+// '>=', '+', '-', '*' or '/'.
+type BinaryExpr struct {
+	Op          string
+	Left, Right Expr
+}
+
+// InExpr is the IN or NOT IN operator.
+type InExpr struct {
+	Arg  Expr
+	List []Expr
+	Not  bool
+}
+
+// FuncExpr is a function call. Name is the uppercased name of a built-in
+// function, e.g. "STRLEN", or empty for a call to an extension function
+// identified by IRI.
+type FuncExpr struct {
+	Name string
+	IRI  IRI
+	Args []Expr
+}
+
+// ExistsExpr is EXISTS or NOT EXISTS.
+type ExistsExpr struct {
+	Pattern Op
+	Not     bool
+}
+
+func (TermExpr) expr()   {}
+func (UnaryExpr) expr()  {}
+func (BinaryExpr) expr() {}
+func (InExpr) expr()     {}
+func (FuncExpr) expr()   {}
+func (ExistsExpr) expr() {}
+
+func (e TermExpr) String() string { return sseTerm(e.Term) }
+
+func (e UnaryExpr) String() string {
+	return fmt.Sprintf("(%s %s)", e.Op, e.Arg)
+}
+
+func (e BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Op, e.Left, e.Right)
+}
+
+func (e InExpr) String() string {
+	var b strings.Builder
+	if e.Not {
+		b.WriteString("(notin ")
+	} else {
+		b.WriteString("(in ")
+	}
+	b.WriteString(e.Arg.String())
+	for _, x := range e.List {
+		b.WriteString(" " + x.String())
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (e FuncExpr) String() string {
+	var b strings.Builder
+	if e.Name == "" {
+		b.WriteString("(" + sseTerm(e.IRI))
+	} else {
+		b.WriteString("(" + strings.ToLower(e.Name))
+	}
+	for _, x := range e.Args {
+		b.WriteString(" " + x.String())
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (e ExistsExpr) String() string {
+	if e.Not {
+		return fmt.Sprintf("(notexists %s)", e.Pattern)
+	}
+	return fmt.Sprintf("(exists %s)", e.Pattern)
+}
+
+// sseTerm returns the term in SSE notation. Numbers and booleans are
+// abbreviated as in Turtle.
+func sseTerm(t Term) string {
+	if l, ok := t.(Literal); ok {
+		switch l.DataType {
+		case xsdInteger:
+			if isIntegerLexical(l.str) {
+				return l.str
+			}
+		case xsdDecimal:
+			if isDecimalLexical(l.str) && strings.Contains(l.str, ".") {
+				return l.str
+			}
+		case xsdBoolean:
+			if l.str == "true" || l.str == "false" {
+				return l.str
+			}
+		}
+	}
+	return t.Serialize(NTriples)
+}
+
+// visibleVars returns the variables which are in-scope in the results of
+// the operator, in order of appearance. Hidden variables, which the
+// translation introduces for blank nodes, paths and aggregates, are left out.
+func visibleVars(o Op) []Var {
+	var vars []Var
+	seen := make(map[Var]bool)
+	add := func(t Term) {
+		if v, ok := t.(Var); ok && !v.hidden() && !seen[v] {
+			seen[v] = true
+			vars = append(vars, v)
+		}
+	}
+	var walk func(o Op)
+	walk = func(o Op) {
+		switch o := o.(type) {
+		case BGP:
+			for _, tp := range o.Patterns {
+				add(tp.Subj)
+				add(tp.Pred)
+				add(tp.Obj)
+			}
+		case PathPattern:
+			add(o.Subj)
+			add(o.Obj)
+		case Join:
+			walk(o.Left)
+			walk(o.Right)
+		case LeftJoin:
+			walk(o.Left)
+			walk(o.Right)
+		case Union:
+			walk(o.Left)
+			walk(o.Right)
+		case Minus:
+			walk(o.Left)
+		case Filter:
+			walk(o.Sub)
+		case GraphPattern:
+			add(o.Name)
+			walk(o.Sub)
+		case Extend:
+			walk(o.Sub)
+			add(o.Var)
+		case Table:
+			for _, v := range o.Vars {
+				add(v)
+			}
+		case Group:
+			for _, k := range o.Keys {
+				if k.Var != "" {
+					add(k.Var)
+				} else if e, ok := k.Expr.(TermExpr); ok {
+					add(e.Term)
+				}
+			}
+			for _, a := range o.Aggregates {
+				add(a.Var)
+			}
+		case OrderBy:
+			walk(o.Sub)
+		case Project:
+			for _, v := range o.Vars {
+				add(v)
+			}
+		case Distinct:
+			walk(o.Sub)
+		case Reduced:
+			walk(o.Sub)
+		case Slice:
+			walk(o.Sub)
+		}
+	}
+	walk(o)
+	return vars
+}
+
+// hidden reports whether the variable is introduced by the algebra
+// translation, rather than given in the query.
+func (v Var) hidden() bool {
+	return strings.HasPrefix(string(v), ".")
+}
@@ -0,0 +1,320 @@
+package rdf
+
+import "sort"
+
+// Var is a query variable, which can be used in place of any term in a
+// TriplePattern. The name is given without the leading '?'.
+type Var string
+
+// Serialize returns the variable in SPARQL syntax, regardless of format.
+func (v Var) Serialize(f Format) string {
+	return "?" + string(v)
+}
+
+// String returns the variable name.
+func (v Var) String() string {
+	return string(v)
+}
+
+// Type returns the TermType of a variable.
+func (v Var) Type() TermType {
+	return TermVariable
+}
+
+// TriplePattern is a triple where any of the positions may be a Var.
+type TriplePattern struct {
+	Subj Term
+	Pred Term
+	Obj  Term
+}
+
+// Binding maps variables to the terms they are bound to.
+type Binding map[Var]Term
+
+// Vars returns the names of the variables in the binding, sorted.
+func (b Binding) Vars() []Var {
+	vars := make([]Var, 0, len(b))
+	for v := range b {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i] < vars[j] })
+	return vars
+}
+
+// BindingIterator iterates over query solutions.
+type BindingIterator interface {
+	// Next returns the next solution, or false if there are no more solutions.
+	Next() (Binding, bool)
+}
+
+// TripleSource is a source of triples which can be queried; Graph is
+// the in-memory implementation.
+type TripleSource interface {
+	// Match returns the triples matching the given subject, predicate and
+	// object, where a nil value matches any term.
+	Match(s Subject, p Predicate, o Object) []Triple
+}
+
+// Counter is implemented by triple sources which can cheaply count the
+// triples matching a pattern, without retrieving them. QueryBGP uses it to
+// plan the order of joins.
+type Counter interface {
+	Count(s Subject, p Predicate, o Object) int
+}
+
+// tripleCursor returns triples one at a time.
+type tripleCursor interface {
+	// next returns the next triple, or false if there are no more triples.
+	next() (Triple, bool)
+}
+
+// cursorSource is implemented by triple sources which can return the
+// triples matching a pattern one at a time, rather than in a slice.
+// QueryBGP uses it to stream the matches of each pattern.
+type cursorSource interface {
+	cursor(s Subject, p Predicate, o Object) tripleCursor
+}
+
+// sliceCursor returns the triples of a slice.
+type sliceCursor struct {
+	ts []Triple
+	i  int
+}
+
+func (c *sliceCursor) next() (Triple, bool) {
+	if c.i == len(c.ts) {
+		return Triple{}, false
+	}
+	c.i++
+	return c.ts[c.i-1], true
+}
+
+// QueryBGP evaluates a basic graph pattern; that is, it finds the bindings
+// of the variables for which all the triple patterns match triples in src.
+// Blank nodes in the patterns are treated as constants.
+//
+// The patterns are joined in order of selectivity, using Count if the
+// source implements Counter. The solutions are computed lazily, as Next
+// is called on the returned iterator; the source must not be modified
+// until the iteration is done.
+func QueryBGP(src TripleSource, bgp []TriplePattern) BindingIterator {
+	return &bgpIterator{
+		src:     src,
+		plan:    planBGP(src, bgp),
+		binding: make(Binding),
+	}
+}
+
+// estimate returns the estimated number of matches of the pattern, given
+// the variables which are already bound.
+func estimate(src TripleSource, tp TriplePattern, bound map[Var]bool) int {
+	var s Subject
+	var p Predicate
+	var o Object
+	nBound := 0
+	if v, ok := tp.Subj.(Var); ok {
+		if bound[v] {
+			nBound++
+		}
+	} else if s, ok = tp.Subj.(Subject); !ok {
+		return 0
+	}
+	if v, ok := tp.Pred.(Var); ok {
+		if bound[v] {
+			nBound++
+		}
+	} else if p, ok = tp.Pred.(Predicate); !ok {
+		return 0
+	}
+	if v, ok := tp.Obj.(Var); ok {
+		if bound[v] {
+			nBound++
+		}
+	} else if o, ok = tp.Obj.(Object); !ok {
+		return 0
+	}
+
+	var n int
+	if c, ok := src.(Counter); ok {
+		n = c.Count(s, p, o)
+	} else {
+		// Without statistics, assume that each constant term
+		// makes the pattern more selective.
+		n = 1000
+		for _, t := range []Term{s, p, o} {
+			if t != nil {
+				n /= 10
+			}
+		}
+	}
+	// A variable bound by a previous pattern is assumed to be about as
+	// selective as a constant, which is rarely less selective than a
+	// predicate.
+	for i := 0; i < nBound; i++ {
+		n = n/10 + 1
+	}
+	return n
+}
+
+// patternVars returns the variables of a triple pattern.
+func patternVars(tp TriplePattern) []Var {
+	var vars []Var
+	for _, t := range []Term{tp.Subj, tp.Pred, tp.Obj} {
+		if v, ok := t.(Var); ok {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// planBGP orders the triple patterns for evaluation. It greedily picks the
+// pattern with the fewest estimated matches, preferring patterns which
+// share a variable with the ones already picked, to avoid cross products.
+func planBGP(src TripleSource, bgp []TriplePattern) []TriplePattern {
+	rest := make([]TriplePattern, len(bgp))
+	copy(rest, bgp)
+	plan := make([]TriplePattern, 0, len(bgp))
+	bound := make(map[Var]bool)
+	for len(rest) > 0 {
+		best, bestConnected, bestN := -1, false, 0
+		for i, tp := range rest {
+			connected := len(plan) == 0
+			for _, v := range patternVars(tp) {
+				if bound[v] {
+					connected = true
+				}
+			}
+			n := estimate(src, tp, bound)
+			if best < 0 || (connected && !bestConnected) || (connected == bestConnected && n < bestN) {
+				best, bestConnected, bestN = i, connected, n
+			}
+		}
+		plan = append(plan, rest[best])
+		for _, v := range patternVars(rest[best]) {
+			bound[v] = true
+		}
+		rest = append(rest[:best], rest[best+1:]...)
+	}
+	return plan
+}
+
+// bgpLevel holds the state of evaluating one of the patterns of a BGP.
+type bgpLevel struct {
+	ts    tripleCursor // matching triples
+	bound []Var        // variables bound at this level
+}
+
+// bgpIterator evaluates a BGP as nested loop joins, one level per pattern.
+type bgpIterator struct {
+	src     TripleSource
+	plan    []TriplePattern
+	stack   []bgpLevel
+	binding Binding
+	started bool
+}
+
+// Next returns the next solution.
+func (it *bgpIterator) Next() (Binding, bool) {
+	if !it.started {
+		it.started = true
+		if len(it.plan) == 0 {
+			// The empty pattern has exactly one solution.
+			return Binding{}, true
+		}
+		it.push()
+	}
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		it.unbind(top)
+		t, ok := top.ts.next()
+		if !ok {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		if !it.bind(top, it.plan[len(it.stack)-1], t) {
+			continue
+		}
+		if len(it.stack) == len(it.plan) {
+			res := make(Binding, len(it.binding))
+			for v, t := range it.binding {
+				res[v] = t
+			}
+			return res, true
+		}
+		it.push()
+	}
+	return nil, false
+}
+
+// push adds a level for the next pattern, with the variables bound so far
+// substituted.
+func (it *bgpIterator) push() {
+	tp := it.plan[len(it.stack)]
+	var ts tripleCursor = &sliceCursor{}
+	if s, p, o, ok := substitute(tp, it.binding); ok {
+		if cs, ok := it.src.(cursorSource); ok {
+			ts = cs.cursor(s, p, o)
+		} else {
+			ts = &sliceCursor{ts: it.src.Match(s, p, o)}
+		}
+	}
+	it.stack = append(it.stack, bgpLevel{ts: ts})
+}
+
+// substitute replaces the bound variables of the pattern with their values,
+// and the unbound with nil. It returns false if a variable is bound to a
+// term which is not valid in its position, or if a constant is not valid
+// in its position.
+func substitute(tp TriplePattern, b Binding) (s Subject, p Predicate, o Object, ok bool) {
+	resolve := func(t Term) Term {
+		if v, ok := t.(Var); ok {
+			return b[v]
+		}
+		return t
+	}
+	if t := resolve(tp.Subj); t != nil {
+		if s, ok = t.(Subject); !ok {
+			return
+		}
+	}
+	if t := resolve(tp.Pred); t != nil {
+		if p, ok = t.(Predicate); !ok {
+			return
+		}
+	}
+	if t := resolve(tp.Obj); t != nil {
+		if o, ok = t.(Object); !ok {
+			return
+		}
+	}
+	return s, p, o, true
+}
+
+// bind binds the unbound variables of the pattern to the terms of t,
+// recording them in the level. It returns false if a variable occuring more
+// than once in the pattern would be bound to different terms.
+func (it *bgpIterator) bind(l *bgpLevel, tp TriplePattern, t Triple) bool {
+	for _, x := range [...]struct{ pat, term Term }{{tp.Subj, t.Subj}, {tp.Pred, t.Pred}, {tp.Obj, t.Obj}} {
+		v, ok := x.pat.(Var)
+		if !ok {
+			continue
+		}
+		if bound, ok := it.binding[v]; ok {
+			if termKey(bound) != termKey(x.term) {
+				return false
+			}
+			continue
+		}
+		it.binding[v] = x.term
+		l.bound = append(l.bound, v)
+	}
+	return true
+}
+
+// unbind removes the bindings made at the level.
+func (it *bgpIterator) unbind(l *bgpLevel) {
+	for _, v := range l.bound {
+		delete(it.binding, v)
+	}
+	l.bound = l.bound[:0]
+}
@@ -0,0 +1,155 @@
+package rdf
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const bgpTestData = `
+@prefix dc: <http://purl.org/dc/elements/1.1/> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+@prefix : <http://example/> .
+
+:book1 dc:creator :alice ; dc:title "Book one" .
+:book2 dc:creator :alice, :bob ; dc:title "Book two" .
+:book3 dc:creator :carol .
+:alice foaf:name "Alice" ; foaf:knows :alice, :bob .
+:bob foaf:name "Bob" ; foaf:knows :alice .
+:carol a foaf:Person .
+`
+
+func mustDecodeTTL(s string) []Triple {
+	ts, err := NewTripleDecoder(bytes.NewBufferString(s), Turtle).DecodeAll()
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+// sliceSource is a TripleSource without statistics.
+type sliceSource []Triple
+
+func (ts sliceSource) Match(s Subject, p Predicate, o Object) []Triple {
+	var res []Triple
+	for _, t := range ts {
+		if (s == nil || termKey(s) == termKey(t.Subj)) &&
+			(p == nil || termKey(p) == termKey(t.Pred)) &&
+			(o == nil || termKey(o) == termKey(t.Obj)) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// formatBindings returns the solutions as sorted strings.
+func formatBindings(it BindingIterator) []string {
+	var res []string
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		var s []string
+		for _, v := range b.Vars() {
+			s = append(s, v.Serialize(NTriples)+"="+b[v].Serialize(NTriples))
+		}
+		res = append(res, strings.Join(s, " "))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestQueryBGP(t *testing.T) {
+	dc := func(s string) IRI { return IRI{str: "http://purl.org/dc/elements/1.1/" + s} }
+	foaf := func(s string) IRI { return IRI{str: "http://xmlns.com/foaf/0.1/" + s} }
+	ex := func(s string) IRI { return IRI{str: "http://example/" + s} }
+
+	tests := []struct {
+		bgp  []TriplePattern
+		want []string
+	}{
+		{
+			[]TriplePattern{
+				{Var("book"), dc("creator"), Var("a")},
+				{Var("a"), foaf("name"), Var("n")},
+			},
+			[]string{
+				`?a=<http://example/alice> ?book=<http://example/book1> ?n="Alice"`,
+				`?a=<http://example/alice> ?book=<http://example/book2> ?n="Alice"`,
+				`?a=<http://example/bob> ?book=<http://example/book2> ?n="Bob"`,
+			},
+		},
+		{
+			// Repeated variable in one pattern.
+			[]TriplePattern{{Var("x"), foaf("knows"), Var("x")}},
+			[]string{`?x=<http://example/alice>`},
+		},
+		{
+			// Cross product of unconnected patterns.
+			[]TriplePattern{
+				{Var("p"), rdfType, foaf("Person")},
+				{Var("b"), dc("title"), Literal{str: "Book one", DataType: xsdString}},
+			},
+			[]string{`?b=<http://example/book1> ?p=<http://example/carol>`},
+		},
+		{
+			// Variable in predicate position.
+			[]TriplePattern{{ex("bob"), Var("p"), ex("alice")}},
+			[]string{`?p=<http://xmlns.com/foaf/0.1/knows>`},
+		},
+		{
+			// Variable bound to a literal can't be used as subject.
+			[]TriplePattern{
+				{ex("bob"), foaf("name"), Var("n")},
+				{Var("n"), Var("p"), Var("o")},
+			},
+			nil,
+		},
+		{
+			[]TriplePattern{{Var("s"), dc("creator"), ex("dave")}},
+			nil,
+		},
+		{
+			nil,
+			[]string{""},
+		},
+	}
+
+	ts := mustDecodeTTL(bgpTestData)
+	for _, src := range []TripleSource{NewGraph(ts...), sliceSource(ts)} {
+		for _, tt := range tests {
+			if got := formatBindings(QueryBGP(src, tt.bgp)); !equalStrings(got, tt.want) {
+				t.Errorf("%T: QueryBGP(%v) =>\n%q\nwant:\n%q", src, tt.bgp, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestPlanBGP(t *testing.T) {
+	dc := func(s string) IRI { return IRI{str: "http://purl.org/dc/elements/1.1/" + s} }
+	foaf := func(s string) IRI { return IRI{str: "http://xmlns.com/foaf/0.1/" + s} }
+	g := NewGraph(mustDecodeTTL(bgpTestData)...)
+
+	// The most selective pattern goes first, and the unconnected
+	// pattern is postponed until it shares a variable.
+	bgp := []TriplePattern{
+		{Var("a"), foaf("knows"), Var("b")},
+		{Var("c"), dc("title"), Var("t")},
+		{Var("c"), dc("creator"), Var("a")},
+		{Var("a"), foaf("name"), Literal{str: "Bob", DataType: xsdString}},
+	}
+	want := []TriplePattern{bgp[3], bgp[0], bgp[2], bgp[1]}
+	if got := planBGP(g, bgp); !equalPatterns(got, want) {
+		t.Errorf("planBGP(%v) =>\n%v\nwant:\n%v", bgp, got, want)
+	}
+}
+
+func equalPatterns(a, b []TriplePattern) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlCanonicalizer writes the Exclusive XML Canonicalization, with comments,
+// of XML content given as a sequence of tokens from xml.Decoder.Token. This
+// is the lexical form of rdf:XMLLiteral values.
+//
+// Since xml.Decoder replaces the prefixes of names with their name space
+// URIs, the canonicalizer keeps track of the name space declarations in the
+// input, to find the prefixes again. The declarations are rendered on the
+// elements which visibly use them, unless already rendered on an ancestor.
+//
+// See https://www.w3.org/TR/xml-exc-c14n/
+type xmlCanonicalizer struct {
+	buf bytes.Buffer
+
+	// stack holds the open elements.
+	stack []c14nElem
+
+	// outer finds the prefix of a name space declared outside the
+	// canonicalized content; it may be nil.
+	outer func(ns string) (string, bool)
+}
+
+// c14nElem is an open element of the canonicalized content.
+type c14nElem struct {
+	name     string            // qualified name, as rendered
+	decls    map[string]string // prefix -> name space, as declared in the input
+	rendered map[string]string // prefix -> name space, as rendered
+}
+
+// declare adds the name space declarations of an element in the input to
+// the canonicalizer's scope, as an element which is not rendered.
+func (c *xmlCanonicalizer) declare(e xml.StartElement) {
+	c.stack = append(c.stack, c14nElem{decls: xmlnsDecls(e)})
+}
+
+// xmlnsDecls returns the name space declarations of an element, by prefix,
+// where the empty prefix is the default name space.
+func xmlnsDecls(e xml.StartElement) map[string]string {
+	decls := make(map[string]string)
+	for _, a := range e.Attr {
+		switch {
+		case a.Name.Space == "xmlns":
+			decls[a.Name.Local] = a.Value
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			decls[""] = a.Value
+		}
+	}
+	return decls
+}
+
+// prefix returns the prefix for the name space. If def is true, the default
+// name space can be used, for element names.
+func (c *xmlCanonicalizer) prefix(ns string, def bool) (string, error) {
+	if ns == xmlNS {
+		return "xml", nil
+	}
+	shadowed := make(map[string]bool)
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		decls := c.stack[i].decls
+		if uri, ok := decls[""]; def && ok && !shadowed[""] && uri == ns {
+			return "", nil
+		}
+		// Iterate over sorted prefixes, for deterministic results
+		// when a name space has several prefixes.
+		prefixes := make([]string, 0, len(decls))
+		for p := range decls {
+			prefixes = append(prefixes, p)
+		}
+		sort.Strings(prefixes)
+		for _, p := range prefixes {
+			if p != "" && !shadowed[p] && decls[p] == ns {
+				return p, nil
+			}
+		}
+		for p := range decls {
+			shadowed[p] = true
+		}
+	}
+	if c.outer != nil {
+		if p, ok := c.outer(ns); ok && !shadowed[p] {
+			return p, nil
+		}
+	}
+	if def && !shadowed[""] {
+		// An unprefixed name in a default name space declared outside
+		// the content.
+		return "", nil
+	}
+	return "", fmt.Errorf("no prefix found for name space: %q", ns)
+}
+
+// renderedNS returns the name space rendered for the prefix in the output
+// ancestors, or "" if none.
+func (c *xmlCanonicalizer) renderedNS(prefix string) string {
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		if ns, ok := c.stack[i].rendered[prefix]; ok {
+			return ns
+		}
+	}
+	return ""
+}
+
+// token writes the canonical form of the token.
+func (c *xmlCanonicalizer) token(tok xml.Token) error {
+	switch t := tok.(type) {
+	case xml.StartElement:
+		return c.start(t)
+	case xml.EndElement:
+		if len(c.stack) == 0 {
+			return errors.New("unexpected end element")
+		}
+		e := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		c.buf.WriteString("</" + e.name + ">")
+	case xml.CharData:
+		c14nEscape(&c.buf, string(t), false)
+	case xml.Comment:
+		c.buf.WriteString("<!--")
+		c.buf.Write(t)
+		c.buf.WriteString("-->")
+	case xml.ProcInst:
+		c.buf.WriteString("<?" + t.Target)
+		if inst := strings.TrimLeft(string(t.Inst), " \t\r\n"); inst != "" {
+			c.buf.WriteString(" " + inst)
+		}
+		c.buf.WriteString("?>")
+	case xml.Directive:
+		return errors.New("directive not allowed in XML literal")
+	}
+	return nil
+}
+
+func (c *xmlCanonicalizer) start(t xml.StartElement) error {
+	c.stack = append(c.stack, c14nElem{decls: xmlnsDecls(t), rendered: make(map[string]string)})
+	e := &c.stack[len(c.stack)-1]
+
+	// The name space declarations visibly utilized by the element:
+	// that of its name and those of its attributes.
+	used := make(map[string]string)
+	e.name = t.Name.Local
+	if t.Name.Space != "" {
+		p, err := c.prefix(t.Name.Space, true)
+		if err != nil {
+			return err
+		}
+		used[p] = t.Name.Space
+		if p != "" {
+			e.name = p + ":" + t.Name.Local
+		}
+	} else {
+		used[""] = ""
+	}
+	type attr struct {
+		ns, local, name, value string
+	}
+	var attrs []attr
+	for _, a := range t.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		name := a.Name.Local
+		if a.Name.Space != "" {
+			p, err := c.prefix(a.Name.Space, false)
+			if err != nil {
+				return err
+			}
+			if p != "xml" {
+				used[p] = a.Name.Space
+			}
+			name = p + ":" + a.Name.Local
+		}
+		attrs = append(attrs, attr{a.Name.Space, a.Name.Local, name, a.Value})
+	}
+
+	// Render the declarations not already rendered by an ancestor,
+	// sorted by prefix, and then the attributes, sorted by name space
+	// and local name.
+	var prefixes []string
+	for p, ns := range used {
+		if c.renderedNS(p) != ns {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.Strings(prefixes)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].ns != attrs[j].ns {
+			return attrs[i].ns < attrs[j].ns
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	c.buf.WriteString("<" + e.name)
+	for _, p := range prefixes {
+		if p == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(" xmlns:" + p + `="`)
+		}
+		c14nEscape(&c.buf, used[p], true)
+		c.buf.WriteString(`"`)
+		e.rendered[p] = used[p]
+	}
+	for _, a := range attrs {
+		c.buf.WriteString(" " + a.name + `="`)
+		c14nEscape(&c.buf, a.value, true)
+		c.buf.WriteString(`"`)
+	}
+	c.buf.WriteString(">")
+	return nil
+}
+
+// c14nEscape writes the text escaped as character data, or as an
+// attribute value if attr is true.
+func c14nEscape(buf *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '<':
+			buf.WriteString("&lt;")
+		case r == '>' && !attr:
+			buf.WriteString("&gt;")
+		case r == '"' && attr:
+			buf.WriteString("&quot;")
+		case r == '\t' && attr:
+			buf.WriteString("&#x9;")
+		case r == '\n' && attr:
+			buf.WriteString("&#xA;")
+		case r == '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// canonicalXML returns the Exclusive XML Canonicalization, with comments,
+// of the XML content, which may have several top-level elements and text.
+// It fails if the content is not well-formed, or uses prefixes which are
+// not declared in it.
+func canonicalXML(s string) (string, error) {
+	const root = "c14n-root"
+	dec := xml.NewDecoder(strings.NewReader("<" + root + ">" + s + "</" + root + ">"))
+	var c xmlCanonicalizer
+	for depth := 0; ; {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return c.buf.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth++; depth == 1 {
+				continue
+			}
+			// xml.Decoder leaves undeclared prefixes as they are,
+			// where name spaces are absolute IRIs.
+			if t.Name.Space != "" && !strings.Contains(t.Name.Space, ":") {
+				return "", fmt.Errorf("undeclared name space prefix: %q", t.Name.Space)
+			}
+		case xml.EndElement:
+			if depth--; depth == 0 {
+				continue
+			}
+		}
+		if err := c.token(tok); err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package rdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalXML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<br/>`, `<br></br>`},
+		{`text &amp; <b>bold</b>`, `text &amp; <b>bold</b>`},
+		{`<a z="1" b='x"y' a="&lt;&#9;&#10;&gt;"/>`, `<a a="&lt;&#x9;&#xA;>" b="x&quot;y" z="1"></a>`},
+		{`<a>1 &gt; 0&#13;</a>`, `<a>1 &gt; 0&#xD;</a>`},
+		{`<!-- note --><?pi  data ?>`, `<!-- note --><?pi data ?>`},
+		{
+			`<b:x xmlns:b="http://b/" xmlns:a="http://a/" xmlns:unused="http://u/" b:q="1" a:p="2"/>`,
+			`<b:x xmlns:a="http://a/" xmlns:b="http://b/" a:p="2" b:q="1"></b:x>`,
+		},
+		{
+			`<a:x xmlns:a="http://a/"><a:x><a:y/></a:x></a:x>`,
+			`<a:x xmlns:a="http://a/"><a:x><a:y></a:y></a:x></a:x>`,
+		},
+		{
+			`<x xmlns="http://d/"><y xmlns=""/><z/></x>`,
+			`<x xmlns="http://d/"><y xmlns=""></y><z></z></x>`,
+		},
+		{
+			`<x xml:lang="en" xmlns:a="http://a/"><a:y/><a:y/></x>`,
+			`<x xml:lang="en"><a:y xmlns:a="http://a/"></a:y><a:y xmlns:a="http://a/"></a:y></x>`,
+		},
+	}
+	for _, test := range tests {
+		got, err := canonicalXML(test.in)
+		if err != nil {
+			t.Errorf("canonicalXML(%q) failed: %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("canonicalXML(%q) =>\n%s\nwant:\n%s", test.in, got, test.want)
+		}
+		if again, _ := canonicalXML(got); again != got {
+			t.Errorf("canonicalXML(%q) not idempotent: %s", got, again)
+		}
+	}
+
+	for _, in := range []string{`<a>`, `<p:a/>`, `<a p:x="1"/>`} {
+		if got, err := canonicalXML(in); err == nil {
+			t.Errorf("canonicalXML(%q) => %q; want error", in, got)
+		}
+	}
+}
+
+func TestXMLLiteralCanonical(t *testing.T) {
+	// The same fragment, with different prefixes, attribute order,
+	// quotes and name space declarations.
+	docs := []string{
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example/" xmlns:h="http://www.w3.org/1999/xhtml">
+  <rdf:Description rdf:about="http://example/s">
+    <ex:p rdf:parseType="Literal"><h:p class='a' id="b">x &amp; y<!--c--><h:br/></h:p></ex:p>
+  </rdf:Description>
+</rdf:RDF>`,
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="http://example/s">
+    <ex:p xmlns:ex="http://example/" rdf:parseType="Literal"><h:p xmlns:h="http://www.w3.org/1999/xhtml" id="b" class="a">x &amp; y<!--c--><h:br></h:br></h:p></ex:p>
+  </rdf:Description>
+</rdf:RDF>`,
+	}
+	want := `<http://example/s> <http://example/p> "<h:p xmlns:h=\"http://www.w3.org/1999/xhtml\" class=\"a\" id=\"b\">x &amp; y<!--c--><h:br></h:br></h:p>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral> .` + "\n"
+	for _, doc := range docs {
+		ts, err := NewTripleDecoder(strings.NewReader(doc), RDFXML).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ts) != 1 || ts[0].Serialize(NTriples) != want {
+			t.Errorf("decoding %s =>\n%v\nwant:\n%s", doc, ts, want)
+		}
+	}
+
+	// Literals are canonicalized when they are built, so that equal markup
+	// is equal and encoders serialize the canonical form.
+	lit := NewTypedLiteral(`<b a='1'   c="2"/>`, xmlLiteral)
+	if !TermsEqual(lit, NewTypedLiteral(`<b c="2" a="1"></b>`, xmlLiteral)) {
+		t.Errorf("NewTypedLiteral(%q) => %v; want equal to canonical form", `<b a='1'   c="2"/>`, lit)
+	}
+	nt := `<http://example/s> <http://example/p> "<b a='1'   c=\"2\"/>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral> .`
+	for _, f := range []Format{NTriples, Turtle} {
+		ts, err := NewTripleDecoder(strings.NewReader(nt), f).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ts) != 1 || !TermsEqual(ts[0].Obj, lit) {
+			t.Errorf("format %v: decoding %s =>\n%v\nwant object %v", f, nt, ts, lit)
+		}
+	}
+	tr := Triple{Subj: IRI{str: "http://example/s"}, Pred: IRI{str: "http://example/p"}, Obj: lit}
+	if got, want := tr.Serialize(NTriples), `<http://example/s> <http://example/p> "<b a=\"1\" c=\"2\"></b>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral> .`+"\n"; got != want {
+		t.Errorf("N-Triples =>\n%s\nwant:\n%s", got, want)
+	}
+	var buf bytes.Buffer
+	enc := NewTripleEncoder(&buf, Turtle)
+	if err := enc.Encode(tr); err != nil {
+		t.Fatal(err)
+	}
+	enc.Close()
+	if !strings.Contains(buf.String(), `"<b a=\"1\" c=\"2\"></b>"^^`) {
+		t.Errorf("Turtle =>\n%s\nwant canonical XML literal", buf.String())
+	}
+}
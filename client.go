@@ -0,0 +1,245 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxGetLength is the maximum length of the URL of a GET request; longer
+// queries are sent with POST.
+const maxGetLength = 2048
+
+// Media types requested by the Client, in order of preference.
+const (
+	acceptResults = "application/sparql-results+json, application/sparql-results+xml;q=0.9, text/tab-separated-values;q=0.8, text/csv;q=0.5"
+	acceptBoolean = "application/sparql-results+json, application/sparql-results+xml;q=0.9" // CSV and TSV have no boolean results
+	acceptTriples = "application/n-triples, text/turtle;q=0.9, application/rdf+xml;q=0.8, text/plain;q=0.5"
+)
+
+// resultsMediaTypes maps media types to results formats.
+var resultsMediaTypes = map[string]ResultsFormat{
+	"application/sparql-results+json": ResultsJSON,
+	"application/json":                ResultsJSON,
+	"application/sparql-results+xml":  ResultsXML,
+	"application/xml":                 ResultsXML,
+	"text/xml":                        ResultsXML,
+	"text/csv":                        ResultsCSV,
+	"text/tab-separated-values":       ResultsTSV,
+}
+
+// Client is a SPARQL 1.1 Protocol client, sending queries and updates to
+// remote endpoints.
+//
+// Queries are sent with GET, unless Method is POST or the query is too long
+// for a URL, in which case they are sent as an URL-encoded form with POST.
+// Updates are always sent with POST.
+type Client struct {
+	// Endpoint is the URL of the query endpoint.
+	Endpoint string
+
+	// UpdateEndpoint is the URL of the update endpoint. If empty,
+	// updates are sent to Endpoint.
+	UpdateEndpoint string
+
+	// Method is the HTTP method for queries; GET (the default) or POST.
+	Method string
+
+	// DefaultGraphs and NamedGraphs are the RDF dataset of queries
+	// and updates, overriding any dataset given in them.
+	DefaultGraphs []IRI
+	NamedGraphs   []IRI
+
+	// HTTPClient is the client used for requests. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a new Client for the given query endpoint.
+func NewClient(endpoint string) *Client {
+	return &Client{Endpoint: endpoint}
+}
+
+// EndpointError is the error returned when an endpoint responds with
+// a status other than 2xx.
+type EndpointError struct {
+	StatusCode int
+	Status     string
+	Message    string // start of the response body
+}
+
+func (e *EndpointError) Error() string {
+	if e.Message == "" {
+		return "sparql endpoint: " + e.Status
+	}
+	return fmt.Sprintf("sparql endpoint: %s: %s", e.Status, e.Message)
+}
+
+// QueryResults are the results of a SELECT or ASK query, decoded from the
+// response as they are read. Close must be called when done, to release
+// the connection.
+type QueryResults struct {
+	*ResultsDecoder
+	body io.ReadCloser
+}
+
+// Close closes the response body.
+func (r *QueryResults) Close() error {
+	return r.body.Close()
+}
+
+// GraphResults are the triples returned by a CONSTRUCT or DESCRIBE query,
+// decoded from the response as they are read. Close must be called when
+// done, to release the connection.
+type GraphResults struct {
+	TripleDecoder
+	body io.ReadCloser
+}
+
+// Close closes the response body.
+func (r *GraphResults) Close() error {
+	return r.body.Close()
+}
+
+// Select sends a SELECT query, and returns its results.
+func (c *Client) Select(query string) (*QueryResults, error) {
+	return c.results(query, acceptResults)
+}
+
+// Ask sends an ASK query, and returns its result.
+func (c *Client) Ask(query string) (bool, error) {
+	res, err := c.results(query, acceptBoolean)
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	return res.Boolean()
+}
+
+// results sends a SELECT or ASK query, accepting the given media types.
+func (c *Client) results(query, accept string) (*QueryResults, error) {
+	resp, err := c.query(query, accept)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := resultsMediaTypes[mediaType(resp)]
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unsupported results content type: %q", resp.Header.Get("Content-Type"))
+	}
+	return &QueryResults{ResultsDecoder: NewResultsDecoder(resp.Body, f), body: resp.Body}, nil
+}
+
+// Construct sends a CONSTRUCT or DESCRIBE query, and returns the resulting
+// triples.
+func (c *Client) Construct(query string) (*GraphResults, error) {
+	resp, err := c.query(query, acceptTriples)
+	if err != nil {
+		return nil, err
+	}
+	f, err := FormatFromMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !decodesTriples(f) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unsupported RDF content type: %q", resp.Header.Get("Content-Type"))
+	}
+	return &GraphResults{TripleDecoder: NewTripleDecoder(resp.Body, f), body: resp.Body}, nil
+}
+
+// Update sends an update request.
+func (c *Client) Update(update string) error {
+	endpoint := c.UpdateEndpoint
+	if endpoint == "" {
+		endpoint = c.Endpoint
+	}
+	params := url.Values{}
+	for _, g := range c.DefaultGraphs {
+		params.Add("using-graph-uri", g.str)
+	}
+	for _, g := range c.NamedGraphs {
+		params.Add("using-named-graph-uri", g.str)
+	}
+	if len(params) > 0 {
+		endpoint = withParams(endpoint, params)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(update))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/sparql-update")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// query sends the query, accepting the given media types, and returns
+// the successful response.
+func (c *Client) query(query, accept string) (*http.Response, error) {
+	params := url.Values{"query": {query}}
+	for _, g := range c.DefaultGraphs {
+		params.Add("default-graph-uri", g.str)
+	}
+	for _, g := range c.NamedGraphs {
+		params.Add("named-graph-uri", g.str)
+	}
+	var req *http.Request
+	var err error
+	if u := withParams(c.Endpoint, params); c.Method != http.MethodPost && len(u) <= maxGetLength {
+		req, err = http.NewRequest(http.MethodGet, u, nil)
+	} else {
+		req, err = http.NewRequest(http.MethodPost, c.Endpoint, strings.NewReader(params.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	return c.do(req)
+}
+
+// do sends the request, and returns the response if successful.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, &EndpointError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+	return resp, nil
+}
+
+// withParams returns the URL with the given query parameters appended.
+func withParams(u string, params url.Values) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + params.Encode()
+	}
+	return u + "?" + params.Encode()
+}
+
+// mediaType returns the media type of the response, without parameters.
+func mediaType(resp *http.Response) string {
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mt
+}
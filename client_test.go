@@ -0,0 +1,171 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testEndpoint is a SPARQL endpoint over a Dataset, answering in the first
+// format of the Accept header it supports.
+type testEndpoint struct {
+	ds      *Dataset
+	methods []string // methods of received requests
+	graphs  []string // default-graph-uri parameters of the last request
+	accept  string   // Accept header of the last request
+}
+
+func (te *testEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	te.methods = append(te.methods, r.Method)
+	if r.Header.Get("Content-Type") == "application/sparql-update" {
+		body, _ := ioutil.ReadAll(r.Body)
+		u, err := ParseUpdate(string(body))
+		if err == nil {
+			_, err = u.Exec(te.ds, nil)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	te.graphs = r.Form["default-graph-uri"]
+	te.accept = r.Header.Get("Accept")
+	q, err := ParseQuery(r.Form.Get("query"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	accept := strings.Split(r.Header.Get("Accept"), ",")
+	mt := strings.TrimSpace(strings.Split(accept[0], ";")[0])
+	switch q.Form {
+	case QuerySelect, QueryAsk:
+		w.Header().Set("Content-Type", mt+"; charset=utf-8")
+		enc := NewResultsEncoder(w, resultsMediaTypes[mt])
+		if q.Form == QueryAsk {
+			ok, _ := q.Ask(te.ds)
+			enc.EncodeBoolean(ok)
+		} else {
+			it, _ := q.Select(te.ds)
+			enc.EncodeAll(q.Vars, it)
+		}
+		enc.Close()
+	default:
+		ts, _ := q.Construct(te.ds)
+		if strings.Contains(q.Algebra.String(), "turtle") {
+			w.Header().Set("Content-Type", "text/turtle")
+			fmt.Fprintln(w, "@prefix ex: <http://example/> .")
+			for _, t := range ts {
+				fmt.Fprintf(w, "%s ex:rel %s .\n", t.Subj.Serialize(NTriples), t.Obj.Serialize(NTriples))
+			}
+			return
+		}
+		w.Header().Set("Content-Type", mt)
+		for _, t := range ts {
+			io.WriteString(w, t.Serialize(NTriples))
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	te := &testEndpoint{ds: evalTestDataset()}
+	srv := httptest.NewServer(te)
+	defer srv.Close()
+	c := NewClient(srv.URL + "/sparql")
+
+	res, err := c.Select(`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+SELECT ?b ?t WHERE { ?b dc:title ?t FILTER(LANG(?t) = "en") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := res.Vars()
+	if err != nil || len(vars) != 2 {
+		t.Errorf("Vars() => %v, %v; want [b t]", vars, err)
+	}
+	bs, err := res.DecodeAll()
+	res.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`?b=<http://example/book1> ?t="SPARQL Tutorial"@en`}
+	if got := formatBindings(&sliceIterator{bs: bs}); !equalStrings(got, want) {
+		t.Errorf("Select() =>\n%q\nwant:\n%q", got, want)
+	}
+
+	if ok, err := c.Ask(`ASK { <http://example/a> <http://example/knows> <http://example/b> }`); err != nil || !ok {
+		t.Errorf("Ask() => %v, %v; want true", ok, err)
+	}
+	// CSV and TSV can't carry the result of an ASK query.
+	if strings.Contains(te.accept, "csv") || strings.Contains(te.accept, "tab-separated") {
+		t.Errorf("Ask() sent Accept: %s; want JSON or XML results only", te.accept)
+	}
+
+	for _, q := range []string{
+		`CONSTRUCT { ?x <http://example/rel> ?y } WHERE { ?x <http://example/knows> ?y }`,
+		`CONSTRUCT { ?x <http://example/rel> ?y } WHERE { ?x <http://example/knows> ?y FILTER(?y != "turtle") }`,
+	} {
+		gr, err := c.Construct(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, err := gr.DecodeAll()
+		gr.Close()
+		if err != nil {
+			t.Fatalf("Construct(%q) failed: %v", q, err)
+		}
+		want := []string{
+			"<http://example/a> <http://example/rel> <http://example/b> .\n",
+			"<http://example/b> <http://example/rel> <http://example/c> .\n",
+			"<http://example/c> <http://example/rel> <http://example/a> .\n",
+		}
+		if got := sortedNT(ts); !equalStrings(got, want) {
+			t.Errorf("Construct(%q) =>\n%q\nwant:\n%q", q, got, want)
+		}
+	}
+
+	c.DefaultGraphs = []IRI{{str: "http://example/g2"}}
+	c.Method = http.MethodPost
+	res, err = c.Select(`SELECT (COUNT(*) AS ?n) { ?s ?p ?o }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = res.DecodeAll()
+	res.Close()
+	if err != nil || len(bs) != 1 || bs[0]["n"].String() != "11" {
+		t.Errorf("Select() with POST => %v, %v", bs, err)
+	}
+	if len(te.graphs) != 1 || te.graphs[0] != "http://example/g2" {
+		t.Errorf("default-graph-uri => %v; want [http://example/g2]", te.graphs)
+	}
+	wantMethods := []string{"GET", "GET", "GET", "GET", "POST"}
+	if !equalStrings(te.methods, wantMethods) {
+		t.Errorf("request methods => %v; want %v", te.methods, wantMethods)
+	}
+}
+
+func TestClientUpdate(t *testing.T) {
+	te := &testEndpoint{ds: NewDataset()}
+	srv := httptest.NewServer(te)
+	defer srv.Close()
+	c := &Client{Endpoint: srv.URL + "/query", UpdateEndpoint: srv.URL + "/update"}
+
+	if err := c.Update(`INSERT DATA { <http://example/a> <http://example/p> "x" }`); err != nil {
+		t.Fatal(err)
+	}
+	if te.ds.Len() != 1 {
+		t.Errorf("dataset size after update => %d; want 1", te.ds.Len())
+	}
+
+	err := c.Update(`INSERT DATA { ?x <http://example/p> "x" }`)
+	if e, ok := err.(*EndpointError); !ok || e.StatusCode != http.StatusBadRequest ||
+		e.Error() != "sparql endpoint: 400 Bad Request: 1:15: variables not allowed in INSERT DATA" {
+		t.Errorf("Update() => %v; want EndpointError", err)
+	}
+}
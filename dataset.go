@@ -0,0 +1,137 @@
+package rdf
+
+import "sort"
+
+// Dataset is an in-memory RDF dataset; a default graph, and a set of
+// named graphs.
+//
+// A named graph exists in the Dataset from when it is created, either
+// explicitly with CreateGraph or by adding a quad to it, until it is
+// dropped, even if it is empty.
+//
+// A Dataset is not safe for concurrent use.
+type Dataset struct {
+	def   *Graph
+	named map[string]*Graph  // term key of name -> graph
+	names map[string]Context // term key of name -> name
+}
+
+// NewDataset returns a new Dataset, containing the given quads. Quads
+// with a nil context are added to the default graph.
+func NewDataset(qs ...Quad) *Dataset {
+	d := &Dataset{
+		def:   NewGraph(),
+		named: make(map[string]*Graph),
+		names: make(map[string]Context),
+	}
+	for _, q := range qs {
+		d.Add(q)
+	}
+	return d
+}
+
+// Default returns the default graph of the Dataset.
+func (d *Dataset) Default() *Graph {
+	return d.def
+}
+
+// Graph returns the named graph with the given name, or nil if it
+// doesn't exist.
+func (d *Dataset) Graph(name Context) *Graph {
+	return d.named[termKey(name)]
+}
+
+// CreateGraph returns the named graph with the given name, creating an
+// empty graph if it doesn't exist.
+func (d *Dataset) CreateGraph(name Context) *Graph {
+	key := termKey(name)
+	g, ok := d.named[key]
+	if !ok {
+		g = NewGraph()
+		d.named[key] = g
+		d.names[key] = name
+	}
+	return g
+}
+
+// DropGraph removes the named graph with the given name from the Dataset.
+// It returns false if the graph didn't exist.
+func (d *Dataset) DropGraph(name Context) bool {
+	key := termKey(name)
+	if _, ok := d.named[key]; !ok {
+		return false
+	}
+	delete(d.named, key)
+	delete(d.names, key)
+	return true
+}
+
+// Names returns the names of the named graphs, sorted by their
+// N-Triples serialization.
+func (d *Dataset) Names() []Context {
+	keys := make([]string, 0, len(d.names))
+	for key := range d.names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := make([]Context, len(keys))
+	for i, key := range keys {
+		names[i] = d.names[key]
+	}
+	return names
+}
+
+// Add adds a quad to the Dataset, creating its graph if needed. A quad
+// with a nil context is added to the default graph. It returns false
+// if the quad was already present.
+func (d *Dataset) Add(q Quad) bool {
+	if q.Ctx == nil {
+		return d.def.Add(q.Triple)
+	}
+	return d.CreateGraph(q.Ctx).Add(q.Triple)
+}
+
+// Remove removes a quad from the Dataset. It returns false if the quad
+// was not present. Removing the last triple of a named graph doesn't
+// drop the graph.
+func (d *Dataset) Remove(q Quad) bool {
+	if q.Ctx == nil {
+		return d.def.Remove(q.Triple)
+	}
+	g := d.Graph(q.Ctx)
+	return g != nil && g.Remove(q.Triple)
+}
+
+// Has returns true if the Dataset contains the quad.
+func (d *Dataset) Has(q Quad) bool {
+	if q.Ctx == nil {
+		return d.def.Has(q.Triple)
+	}
+	g := d.Graph(q.Ctx)
+	return g != nil && g.Has(q.Triple)
+}
+
+// Len returns the number of quads in the Dataset, in all graphs.
+func (d *Dataset) Len() int {
+	n := d.def.Len()
+	for _, g := range d.named {
+		n += g.Len()
+	}
+	return n
+}
+
+// Quads returns all the quads in the Dataset; first those of the default
+// graph, which have a nil context, then those of the named graphs in the
+// order of Names.
+func (d *Dataset) Quads() []Quad {
+	var qs []Quad
+	for _, t := range d.def.Triples() {
+		qs = append(qs, Quad{Triple: t})
+	}
+	for _, name := range d.Names() {
+		for _, t := range d.Graph(name).Triples() {
+			qs = append(qs, Quad{Triple: t, Ctx: name})
+		}
+	}
+	return qs
+}
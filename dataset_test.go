@@ -0,0 +1,47 @@
+package rdf
+
+import "testing"
+
+func TestDataset(t *testing.T) {
+	a, b := IRI{str: "http://example/a"}, IRI{str: "http://example/b"}
+	p := IRI{str: "http://example/p"}
+	g1, g2 := IRI{str: "http://example/g1"}, IRI{str: "http://example/g2"}
+
+	ds := NewDataset(
+		Quad{Triple: Triple{Subj: a, Pred: p, Obj: b}},
+		Quad{Triple: Triple{Subj: a, Pred: p, Obj: b}, Ctx: g2},
+		Quad{Triple: Triple{Subj: b, Pred: p, Obj: a}, Ctx: g2},
+	)
+	if ds.Add(Quad{Triple: Triple{Subj: a, Pred: p, Obj: b}, Ctx: g2}) {
+		t.Error("Add of existing quad => true, want false")
+	}
+	if ds.Len() != 3 || ds.Default().Len() != 1 || ds.Graph(g2).Len() != 2 {
+		t.Errorf("Len() => %d, want 3", ds.Len())
+	}
+	if ds.Graph(g1) != nil {
+		t.Error("Graph of missing graph => non-nil")
+	}
+	ds.CreateGraph(g1)
+	if got := ds.Names(); len(got) != 2 || got[0] != g1 || got[1] != g2 {
+		t.Errorf("Names() => %v, want [%v %v]", got, g1, g2)
+	}
+
+	// Removing the last triple keeps the graph.
+	if !ds.Remove(Quad{Triple: Triple{Subj: a, Pred: p, Obj: b}, Ctx: g2}) ||
+		!ds.Remove(Quad{Triple: Triple{Subj: b, Pred: p, Obj: a}, Ctx: g2}) {
+		t.Error("Remove of existing quad => false")
+	}
+	if ds.Remove(Quad{Triple: Triple{Subj: b, Pred: p, Obj: a}, Ctx: g1}) {
+		t.Error("Remove of missing quad => true")
+	}
+	if g := ds.Graph(g2); g == nil || g.Len() != 0 {
+		t.Error("graph removed with its last triple")
+	}
+	if !ds.DropGraph(g2) || ds.DropGraph(g2) {
+		t.Error("DropGraph => false for existing graph, or true for dropped graph")
+	}
+	qs := ds.Quads()
+	if len(qs) != 1 || qs[0].Ctx != nil || !ds.Has(qs[0]) {
+		t.Errorf("Quads() => %v, want the default graph triple", qs)
+	}
+}
@@ -12,9 +12,27 @@ type ParseOption int
 // Options which can configure a decoder.
 const (
 	// Base IRI to resolve relative IRIs against (for formats that support
-	// relative IRIs: Turtle, RDF/XML, TriG, JSON-LD)
+	// relative IRIs: Turtle, RDF/XML, TriG, JSON-LD). Relative IRIs are
+	// resolved as specified by RFC 3986, section 5.2.
 	Base ParseOption = iota
 
+	// ValidateLiterals makes the decoder check that the lexical form of each
+	// typed literal is valid for its datatype. An ill-typed literal makes the
+	// decoder fail with a *LiteralError.
+	ValidateLiterals
+
+	// RDFStar enables RDF-star mode, where << s p o >> denotes a triple term
+	// which can be used both as subject and object, instead of a reified triple
+	// as in RDF 1.2. Annotations {| ... |} will then have the triple term as
+	// subject, instead of a reifier.
+	RDFStar
+
+	// StrictIDs makes the RDF/XML decoder fail on an rdf:ID which is used
+	// more than once with the same base IRI, which is invalid RDF/XML. It is
+	// opt-in, since the decoder must then remember every rdf:ID in the
+	// document.
+	StrictIDs
+
 	// Strict mode determines how the decoder responds to errors.
 	// When true (the default), it will fail on any malformed input. When
 	// false, it will try to continue parsing, discarding only the malformed
@@ -35,6 +53,10 @@ const (
 //  Option      Description        Value      (default)       Format support
 //  ------------------------------------------------------------------------------
 //  Base        Base IRI           IRI        (empty IRI)     Turtle, RDF/XML
+//  ValidateLiterals
+//              Check literals     true/false (false)         All
+//  RDFStar     RDF-star mode      true/false (false)         Turtle, N-Triples, N-Quads
+//  StrictIDs   Unique rdf:IDs     true/false (false)         RDF/XML
 //  Strict      Strict mode        true/false (true)          TODO
 //  ErrOut      Error output       io.Writer  (nil)           TODO
 type TripleDecoder interface {
@@ -51,8 +73,31 @@ type TripleDecoder interface {
 	SetOption(ParseOption, interface{}) error
 }
 
+// LiteralError is the error returned by decoders with the ValidateLiterals
+// option enabled, when a literal's lexical form is not valid for its datatype.
+type LiteralError struct {
+	Line, Col int     // position of the literal in the input
+	Literal   Literal // the ill-typed literal
+	Err       error   // description of the error
+}
+
+// Error returns the error description, prefixed with the literal's position.
+func (e *LiteralError) Error() string {
+	return fmt.Sprintf("%d:%d: ill-typed literal: %v", e.Line, e.Col, e.Err)
+}
+
+// checkLiteral returns a *LiteralError if the given literal is ill-typed,
+// otherwise nil.
+func checkLiteral(l Literal, line, col int) error {
+	if err := validateLexical(l.str, l.DataType); err != nil {
+		return &LiteralError{Line: line, Col: col, Literal: l, Err: err}
+	}
+	return nil
+}
+
 // NewTripleDecoder returns a new TripleDecoder capable of parsing triples
-// from the given io.Reader in the given serialization format.
+// from the given io.Reader in the given serialization format. For formats
+// without a triple decoder, such as JSON-LD, the decoder returns an error.
 func NewTripleDecoder(r io.Reader, f Format) TripleDecoder {
 	switch f {
 	case NTriples:
@@ -62,10 +107,23 @@ func NewTripleDecoder(r io.Reader, f Format) TripleDecoder {
 	case Turtle:
 		return newTTLDecoder(r)
 	default:
-		panic(fmt.Errorf("Decoder for serialization format %v not implemented", f))
+		return unsupportedDecoder{f}
 	}
 }
 
+// unsupportedDecoder is the TripleDecoder of formats which can't be decoded.
+type unsupportedDecoder struct {
+	f Format
+}
+
+func (d unsupportedDecoder) err() error {
+	return fmt.Errorf("decoder for serialization format %v not implemented", d.f)
+}
+
+func (d unsupportedDecoder) Decode() (Triple, error)                   { return Triple{}, d.err() }
+func (d unsupportedDecoder) DecodeAll() ([]Triple, error)              { return nil, d.err() }
+func (d unsupportedDecoder) SetOption(ParseOption, interface{}) error { return d.err() }
+
 // QuadDecoder parses RDF quads in one of the following formats:
 // N-Quads.
 //
@@ -78,6 +136,8 @@ type QuadDecoder struct {
 	DefaultGraph Context  // default graph
 	tokens       [3]token // 3 token lookahead
 	peekCount    int      // number of tokens peeked at (position in tokens lookahead array)
+	validate     bool     // validate literals
+	star         bool     // RDF-star mode
 }
 
 // NewQuadDecoder returns a new QuadDecoder capable of parsing quads
@@ -90,6 +150,27 @@ func NewQuadDecoder(r io.Reader, f Format) *QuadDecoder {
 	}
 }
 
+// SetOption sets a ParseOption to the give value
+func (d *QuadDecoder) SetOption(o ParseOption, v interface{}) error {
+	switch o {
+	case ValidateLiterals:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"ValidateLiterals\" must be a bool.")
+		}
+		d.validate = b
+	case RDFStar:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"RDFStar\" must be a bool.")
+		}
+		d.star = b
+	default:
+		return fmt.Errorf("N-Quads decoder doesn't support option: %v", o)
+	}
+	return nil
+}
+
 // Decode returns the next valid Quad, or an error
 func (d *QuadDecoder) Decode() (Quad, error) {
 	return d.parseNQ()
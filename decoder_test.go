@@ -0,0 +1,59 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLiterals(t *testing.T) {
+	tests := []struct {
+		format Format
+		input  string
+		errStr string
+	}{
+		{NTriples, `<http://ex/s> <http://ex/p> "12"^^<http://www.w3.org/2001/XMLSchema#integer> .`, ""},
+		{NTriples, `<http://ex/s> <http://ex/p> "abc"^^<http://www.w3.org/2001/XMLSchema#integer> .`, `1:29: ill-typed literal: invalid xsd:integer: "abc"`},
+		{Turtle, "@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .\n<http://ex/s> <http://ex/p> \"1.5\"^^xsd:decimal .", ""},
+		{Turtle, "@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .\n<http://ex/s> <http://ex/p> \"yes\"^^xsd:boolean .", `2:29: ill-typed literal: invalid xsd:boolean: "yes"`},
+		{Turtle, `<http://ex/s> <http://ex/p> "2015-13-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .`, `1:29: ill-typed literal: invalid xsd:dateTime: "2015-13-01T00:00:00Z"`},
+		{Turtle, `<http://ex/s> <http://ex/p> "x"^^<http://example.org/unknown> .`, ""},
+		{RDFXML, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex/">
+  <rdf:Description rdf:about="http://ex/s">
+    <ex:p rdf:datatype="http://www.w3.org/2001/XMLSchema#double">1.0e</ex:p>
+  </rdf:Description>
+</rdf:RDF>`, `3:66: ill-typed literal: invalid xsd:double: "1.0e"`},
+	}
+
+	for _, tt := range tests {
+		dec := NewTripleDecoder(strings.NewReader(tt.input), tt.format)
+		if err := dec.SetOption(ValidateLiterals, true); err != nil {
+			t.Fatal(err)
+		}
+		_, err := dec.DecodeAll()
+		if tt.errStr == "" {
+			if err != nil {
+				t.Errorf("decoding %q failed with %v; want no error", tt.input, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("decoding %q => <no error>; want %v", tt.input, tt.errStr)
+			continue
+		}
+		if _, ok := err.(*LiteralError); !ok || err.Error() != tt.errStr {
+			t.Errorf("decoding %q failed with %v; want *LiteralError %v", tt.input, err, tt.errStr)
+		}
+	}
+
+	// Without the option, ill-typed literals are accepted:
+	dec := NewTripleDecoder(strings.NewReader(tests[1].input), NTriples)
+	if _, err := dec.DecodeAll(); err != nil {
+		t.Errorf("decoding without ValidateLiterals failed with %v; want no error", err)
+	}
+
+	qdec := NewQuadDecoder(strings.NewReader(`<http://ex/s> <http://ex/p> "3000000000"^^<http://www.w3.org/2001/XMLSchema#int> <http://ex/g> .`), NQuads)
+	qdec.SetOption(ValidateLiterals, true)
+	if _, err := qdec.DecodeAll(); err == nil || err.Error() != `1:29: ill-typed literal: xsd:int out of range: "3000000000"` {
+		t.Errorf("decoding N-Quads failed with %v; want xsd:int out of range", err)
+	}
+}
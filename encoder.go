@@ -229,6 +229,10 @@ func (e *TripleEncoder) Close() error {
 }
 
 func (e *TripleEncoder) prefixify(t Term) string {
+	if t.Type() == TermTriple {
+		tt := t.(TripleTerm)
+		return fmt.Sprintf("<<( %s %s %s )>>", e.prefixify(tt.Subj), e.prefixify(tt.Pred), e.prefixify(tt.Obj))
+	}
 	if t.Type() == TermIRI {
 		if t.(IRI).str == "http://www.w3.org/1999/02/22-rdf-syntax-ns#type" {
 			return "a"
@@ -256,7 +260,7 @@ func (e *TripleEncoder) prefixify(t Term) string {
 	}
 	if t.Type() == TermLiteral {
 		switch t.(Literal).DataType {
-		case xsdString, xsdInteger, xsdBoolean, xsdDouble, xsdDecimal, rdfLangString:
+		case xsdString, xsdInteger, xsdBoolean, xsdDouble, xsdDecimal, rdfLangString, rdfDirLangString:
 			// serialize normally in Literal.Serialize method
 			break
 		default:
@@ -277,7 +281,7 @@ func (e *TripleEncoder) prefixify(t Term) string {
 				e.nsCount++
 				e.OpenStatement = false
 			}
-			return fmt.Sprintf("\"%s\"^^%s:%s", t.Serialize(formatInternal), prefix, rest)
+			return fmt.Sprintf("\"%s\"^^%s:%s", escapeLiteral(t.(Literal).str), prefix, rest)
 		}
 	}
 	return t.Serialize(Turtle)
@@ -0,0 +1,761 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evaluation of the SPARQL algebra against an in-memory Dataset, as defined in
+// http://www.w3.org/TR/sparql11-query/#sparqlAlgebraEval
+//
+// Patterns are evaluated bottom-up. Basic graph patterns, filters, joins and
+// other streaming operators compute their solutions lazily; operators which
+// need all the solutions of their operand, such as ORDER BY and GROUP BY,
+// collect them first.
+
+// Select evaluates a SELECT query against the dataset, and returns the
+// solutions. The solutions bind only the result variables of the query,
+// in q.Vars.
+func (q *Query) Select(ds *Dataset) (BindingIterator, error) {
+	if q.Form != QuerySelect {
+		return nil, errors.New("not a SELECT query")
+	}
+	return newEvaluator(q.dataset(ds)).eval(q.Algebra, nil), nil
+}
+
+// Ask evaluates an ASK query against the dataset. It returns true if the
+// query pattern has a solution.
+func (q *Query) Ask(ds *Dataset) (bool, error) {
+	if q.Form != QueryAsk {
+		return false, errors.New("not an ASK query")
+	}
+	_, ok := newEvaluator(q.dataset(ds)).eval(q.Algebra, nil).Next()
+	return ok, nil
+}
+
+// Construct evaluates a CONSTRUCT or DESCRIBE query against the dataset,
+// and returns the resulting graph, without duplicate triples.
+//
+// The triples of a CONSTRUCT query are its template, instantiated with
+// each solution; instantiations which would give an invalid triple, e.g.
+// because a variable is unbound, are skipped. Blank nodes in the template
+// are replaced with fresh blank nodes for each solution.
+//
+// The description of a resource in a DESCRIBE query is its concise bounded
+// description in the default graph: the triples with the resource as
+// subject, and, recursively, the descriptions of the blank nodes in
+// their objects.
+func (q *Query) Construct(ds *Dataset) ([]Triple, error) {
+	e := newEvaluator(q.dataset(ds))
+	switch q.Form {
+	case QueryConstruct:
+		return e.construct(q.Template, e.eval(q.Algebra, nil)), nil
+	case QueryDescribe:
+		return e.describe(q.Describe, e.eval(q.Algebra, nil)), nil
+	}
+	return nil, errors.New("not a CONSTRUCT or DESCRIBE query")
+}
+
+// Eval evaluates an algebra expression against the dataset, and returns
+// the solutions. The default graph of the dataset is the active graph,
+// except inside GRAPH patterns.
+func Eval(ds *Dataset, op Op) BindingIterator {
+	return newEvaluator(ds).eval(op, nil)
+}
+
+// dataset returns the dataset of the query, which is restricted by the
+// FROM and FROM NAMED clauses, if any.
+func (q *Query) dataset(ds *Dataset) *Dataset {
+	return datasetView(ds, q.From, q.FromNamed)
+}
+
+// datasetView returns the dataset given by FROM and FROM NAMED (or USING
+// and USING NAMED) clauses: the default graph is the merge of the from
+// graphs in ds, and the named graphs are the fromNamed graphs. Graphs which
+// are not in ds are taken to be empty. If there are no clauses, it returns
+// ds itself.
+func datasetView(ds *Dataset, from, fromNamed []IRI) *Dataset {
+	if len(from) == 0 && len(fromNamed) == 0 {
+		return ds
+	}
+	res := NewDataset()
+	for _, name := range from {
+		if g := ds.Graph(name); g != nil {
+			for _, t := range g.Triples() {
+				res.def.Add(t)
+			}
+		}
+	}
+	for _, name := range fromNamed {
+		key := termKey(name)
+		if g := ds.Graph(name); g != nil {
+			res.named[key] = g
+		} else {
+			res.named[key] = NewGraph()
+		}
+		res.names[key] = name
+	}
+	return res
+}
+
+// evaluator holds the state of the evaluation of a query.
+type evaluator struct {
+	ds  *Dataset
+	now time.Time // the value of NOW(), which is constant in a query
+
+	bnodeN int              // counter for fresh blank nodes
+	bnodes map[string]Blank // BNODE(label) -> blank node, for the current solution
+}
+
+func newEvaluator(ds *Dataset) *evaluator {
+	return &evaluator{
+		ds:  ds,
+		now: time.Now(),
+	}
+}
+
+// freshBlank returns a blank node whose label isn't used in the dataset,
+// nor returned before.
+func (e *evaluator) freshBlank() Blank {
+	for {
+		e.bnodeN++
+		b := Blank{id: "_:g" + strconv.Itoa(e.bnodeN)}
+		if e.usedBlank(b) {
+			continue
+		}
+		return b
+	}
+}
+
+// usedBlank returns true if the blank node occurs in any graph of the dataset.
+func (e *evaluator) usedBlank(b Blank) bool {
+	if _, ok := e.ds.def.lookup(b); ok {
+		return true
+	}
+	for _, g := range e.ds.named {
+		if _, ok := g.lookup(b); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// eval evaluates an operator with the given active graph, where nil
+// means the default graph.
+func (e *evaluator) eval(op Op, g *Graph) BindingIterator {
+	if g == nil {
+		g = e.ds.def
+	}
+	switch o := op.(type) {
+	case BGP:
+		return QueryBGP(g, o.Patterns)
+	case PathPattern:
+		return &sliceIterator{bs: e.evalPath(g, o)}
+	case Join:
+		right := collectBindings(e.eval(o.Right, g))
+		return &flatIterator{it: e.eval(o.Left, g), fn: func(l Binding) []Binding {
+			var res []Binding
+			for _, r := range right {
+				if compatible(l, r) {
+					res = append(res, merge(l, r))
+				}
+			}
+			return res
+		}}
+	case LeftJoin:
+		right := collectBindings(e.eval(o.Right, g))
+		return &flatIterator{it: e.eval(o.Left, g), fn: func(l Binding) []Binding {
+			var res []Binding
+			for _, r := range right {
+				if !compatible(l, r) {
+					continue
+				}
+				m := merge(l, r)
+				if o.Expr == nil || e.test(o.Expr, m, g) {
+					res = append(res, m)
+				}
+			}
+			if len(res) == 0 {
+				res = append(res, l)
+			}
+			return res
+		}}
+	case Filter:
+		return &flatIterator{it: e.eval(o.Sub, g), fn: func(b Binding) []Binding {
+			if e.test(o.Expr, b, g) {
+				return []Binding{b}
+			}
+			return nil
+		}}
+	case Union:
+		return &concatIterator{its: []BindingIterator{e.eval(o.Left, g), e.eval(o.Right, g)}}
+	case Minus:
+		right := collectBindings(e.eval(o.Right, g))
+		return &flatIterator{it: e.eval(o.Left, g), fn: func(l Binding) []Binding {
+			for _, r := range right {
+				if compatible(l, r) && sharesVar(l, r) {
+					return nil
+				}
+			}
+			return []Binding{l}
+		}}
+	case GraphPattern:
+		return e.evalGraph(o)
+	case Extend:
+		// BNODE(label) gives the same blank node within a solution, and
+		// distinct ones across solutions. The solutions are extended one at
+		// a time through a chain of Extends, as for the expressions of a
+		// SELECT clause, so the labels are forgotten at its innermost one.
+		_, chained := o.Sub.(Extend)
+		return &flatIterator{it: e.eval(o.Sub, g), fn: func(b Binding) []Binding {
+			if !chained {
+				e.bnodes = nil
+			}
+			if t, err := e.expr(o.Expr, b, g); err == nil {
+				b = merge(b, Binding{o.Var: t})
+			}
+			return []Binding{b}
+		}}
+	case Table:
+		return &sliceIterator{bs: o.Rows}
+	case Group:
+		return &sliceIterator{bs: e.evalGroup(o, g)}
+	case OrderBy:
+		return &sliceIterator{bs: e.evalOrderBy(o, g)}
+	case Project:
+		return &flatIterator{it: e.eval(o.Sub, g), fn: func(b Binding) []Binding {
+			res := make(Binding, len(o.Vars))
+			for _, v := range o.Vars {
+				if t, ok := b[v]; ok {
+					res[v] = t
+				}
+			}
+			return []Binding{res}
+		}}
+	case Distinct:
+		seen := make(map[string]bool)
+		return &flatIterator{it: e.eval(o.Sub, g), fn: func(b Binding) []Binding {
+			key := bindingKey(b)
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			return []Binding{b}
+		}}
+	case Reduced:
+		// Eliminating duplicates is permitted, but not required.
+		return e.eval(o.Sub, g)
+	case Slice:
+		return &sliceOpIterator{it: e.eval(o.Sub, g), offset: o.Offset, limit: o.Limit}
+	}
+	panic(fmt.Sprintf("unknown algebra operator: %T", op))
+}
+
+// evalGraph evaluates a GRAPH pattern.
+func (e *evaluator) evalGraph(o GraphPattern) BindingIterator {
+	v, ok := o.Name.(Var)
+	if !ok {
+		name, ok := o.Name.(Context)
+		if !ok {
+			return &sliceIterator{}
+		}
+		g := e.ds.Graph(name)
+		if g == nil {
+			return &sliceIterator{}
+		}
+		return e.eval(o.Sub, g)
+	}
+	var its []BindingIterator
+	for _, name := range e.ds.Names() {
+		name := name
+		nb := Binding{v: name}
+		its = append(its, &flatIterator{it: e.eval(o.Sub, e.ds.Graph(name)), fn: func(b Binding) []Binding {
+			if !compatible(b, nb) {
+				return nil
+			}
+			return []Binding{merge(b, nb)}
+		}})
+	}
+	return &concatIterator{its: its}
+}
+
+// group is a group of solutions, with the values of the group keys.
+type group struct {
+	keys Binding
+	bs   []Binding
+}
+
+// evalGroup evaluates a GROUP BY, and the aggregates of the groups.
+func (e *evaluator) evalGroup(o Group, g *Graph) []Binding {
+	var groups []*group
+	byKey := make(map[string]*group)
+	it := e.eval(o.Sub, g)
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		keys := make(Binding)
+		var key strings.Builder
+		for i, k := range o.Keys {
+			t, err := e.expr(k.Expr, b, g)
+			if err == nil {
+				key.WriteString(termKey(t))
+				if k.Var != "" {
+					keys[k.Var] = t
+				} else if x, ok := k.Expr.(TermExpr); ok {
+					if v, ok := x.Term.(Var); ok {
+						keys[v] = t
+					}
+				}
+			}
+			key.WriteString(" " + strconv.Itoa(i) + "\n")
+		}
+		grp, ok := byKey[key.String()]
+		if !ok {
+			grp = &group{keys: keys}
+			byKey[key.String()] = grp
+			groups = append(groups, grp)
+		}
+		grp.bs = append(grp.bs, b)
+	}
+	if len(groups) == 0 && len(o.Keys) == 0 {
+		// Aggregating without GROUP BY gives one group, even if
+		// there are no solutions.
+		groups = append(groups, &group{keys: Binding{}})
+	}
+
+	res := make([]Binding, len(groups))
+	for i, grp := range groups {
+		b := grp.keys
+		for _, a := range o.Aggregates {
+			if t, err := e.aggregate(a, grp.bs, g); err == nil {
+				b[a.Var] = t
+			}
+		}
+		res[i] = b
+	}
+	return res
+}
+
+// evalOrderBy sorts the solutions by the ORDER BY conditions.
+func (e *evaluator) evalOrderBy(o OrderBy, g *Graph) []Binding {
+	bs := collectBindings(e.eval(o.Sub, g))
+	// Evaluate the conditions once per solution; errors leave the
+	// value unbound (nil), which sorts first.
+	vals := make([][]Term, len(bs))
+	for i, b := range bs {
+		vals[i] = make([]Term, len(o.Conds))
+		for j, c := range o.Conds {
+			if t, err := e.expr(c.Expr, b, g); err == nil {
+				vals[i][j] = t
+			}
+		}
+	}
+	idx := make([]int, len(bs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := vals[idx[i]], vals[idx[j]]
+		for k, c := range o.Conds {
+			n := orderCompare(a[k], b[k])
+			if c.Desc {
+				n = -n
+			}
+			if n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+	res := make([]Binding, len(bs))
+	for i, j := range idx {
+		res[i] = bs[j]
+	}
+	return res
+}
+
+// construct instantiates the template with the solutions.
+func (e *evaluator) construct(template []TriplePattern, it BindingIterator) []Triple {
+	var res []Triple
+	seen := make(map[string]bool)
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		bnodes := make(map[string]Blank)
+		for _, tp := range template {
+			t, ok := e.instantiate(tp, b, bnodes)
+			if !ok {
+				continue
+			}
+			if key := t.Serialize(NTriples); !seen[key] {
+				seen[key] = true
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+// instantiate substitutes the variables of a triple pattern with their
+// values in the solution, and its blank nodes with fresh blank nodes, which
+// are kept in bnodes. It returns false if the result is not a valid triple.
+func (e *evaluator) instantiate(tp TriplePattern, b Binding, bnodes map[string]Blank) (Triple, bool) {
+	inst := func(t Term) Term {
+		switch t := t.(type) {
+		case Var:
+			if v, ok := b[t]; ok {
+				return v
+			}
+			return nil
+		case Blank:
+			if _, ok := bnodes[t.id]; !ok {
+				bnodes[t.id] = e.freshBlank()
+			}
+			return bnodes[t.id]
+		}
+		return t
+	}
+	s, ok := inst(tp.Subj).(Subject)
+	if !ok {
+		return Triple{}, false
+	}
+	p, ok := inst(tp.Pred).(IRI)
+	if !ok {
+		return Triple{}, false
+	}
+	o, ok := inst(tp.Obj).(Object)
+	if !ok {
+		return Triple{}, false
+	}
+	return Triple{Subj: s, Pred: p, Obj: o}, true
+}
+
+// describe returns the concise bounded descriptions of the resources, which
+// are IRIs or variables bound by the solutions.
+func (e *evaluator) describe(resources []Term, it BindingIterator) []Triple {
+	var subjs []Subject
+	var vars []Var
+	for _, r := range resources {
+		if v, ok := r.(Var); ok {
+			vars = append(vars, v)
+		} else if s, ok := r.(Subject); ok {
+			subjs = append(subjs, s)
+		}
+	}
+	if len(vars) > 0 {
+		for b, ok := it.Next(); ok; b, ok = it.Next() {
+			for _, v := range vars {
+				if s, ok := b[v].(Subject); ok {
+					subjs = append(subjs, s)
+				}
+			}
+		}
+	}
+
+	var res []Triple
+	done := make(map[string]bool)
+	for len(subjs) > 0 {
+		s := subjs[0]
+		subjs = subjs[1:]
+		if done[termKey(s)] {
+			continue
+		}
+		done[termKey(s)] = true
+		for _, t := range e.ds.def.Match(s, nil, nil) {
+			res = append(res, t)
+			if b, ok := t.Obj.(Blank); ok {
+				subjs = append(subjs, b)
+			}
+		}
+	}
+	return res
+}
+
+// evalPath evaluates a property path pattern.
+func (e *evaluator) evalPath(g *Graph, pp PathPattern) []Binding {
+	sv, sIsVar := pp.Subj.(Var)
+	ov, oIsVar := pp.Obj.(Var)
+	var res []Binding
+	emit := func(s, o Term) {
+		b := make(Binding)
+		if sIsVar {
+			b[sv] = s
+		}
+		if oIsVar {
+			if sIsVar && sv == ov && termKey(s) != termKey(o) {
+				return
+			}
+			b[ov] = o
+		} else if termKey(o) != termKey(pp.Obj) {
+			return
+		}
+		res = append(res, b)
+	}
+	switch {
+	case !sIsVar:
+		for _, o := range pathFrom(g, pp.Path, pp.Subj, true) {
+			emit(pp.Subj, o)
+		}
+	case !oIsVar:
+		for _, s := range pathFrom(g, pp.Path, pp.Obj, false) {
+			emit(s, pp.Obj)
+		}
+	default:
+		for _, s := range graphNodes(g) {
+			for _, o := range pathFrom(g, pp.Path, s, true) {
+				emit(s, o)
+			}
+		}
+	}
+	return res
+}
+
+// graphNodes returns the subjects and objects of the graph.
+func graphNodes(g *Graph) []Term {
+	var nodes []Term
+	for id, t := range g.terms {
+		_, subj := g.spo[id]
+		_, obj := g.osp[id]
+		if subj || obj {
+			nodes = append(nodes, t)
+		}
+	}
+	return nodes
+}
+
+// pathFrom returns the nodes reached by following the path from x; forward,
+// from subject to object, or backwards. The results of the closure operators
+// (*, + and ?) are distinct; the others may contain duplicates, one for each
+// way to reach a node.
+func pathFrom(g *Graph, path Path, x Term, fwd bool) []Term {
+	switch p := path.(type) {
+	case PathLink:
+		return step(g, x, fwd, func(pred Term) bool { return termKey(pred) == termKey(p.IRI) })
+	case PathInverse:
+		return pathFrom(g, p.Path, x, !fwd)
+	case PathSeq:
+		first, second := p.Left, p.Right
+		if !fwd {
+			first, second = second, first
+		}
+		var res []Term
+		for _, y := range pathFrom(g, first, x, fwd) {
+			res = append(res, pathFrom(g, second, y, fwd)...)
+		}
+		return res
+	case PathAlt:
+		return append(pathFrom(g, p.Left, x, fwd), pathFrom(g, p.Right, x, fwd)...)
+	case PathZeroOrOne:
+		res := []Term{x}
+		seen := map[string]bool{termKey(x): true}
+		for _, y := range pathFrom(g, p.Path, x, fwd) {
+			if !seen[termKey(y)] {
+				seen[termKey(y)] = true
+				res = append(res, y)
+			}
+		}
+		return res
+	case PathZeroOrMore:
+		return closure(g, p.Path, x, fwd, true)
+	case PathOneOrMore:
+		return closure(g, p.Path, x, fwd, false)
+	case PathNegated:
+		excluded := func(iris []IRI) func(Term) bool {
+			return func(pred Term) bool {
+				for _, iri := range iris {
+					if termKey(pred) == termKey(iri) {
+						return false
+					}
+				}
+				return true
+			}
+		}
+		var res []Term
+		if len(p.Fwd) > 0 {
+			res = append(res, step(g, x, fwd, excluded(p.Fwd))...)
+		}
+		if len(p.Inv) > 0 {
+			res = append(res, step(g, x, !fwd, excluded(p.Inv))...)
+		}
+		return res
+	}
+	panic(fmt.Sprintf("unknown path: %T", path))
+}
+
+// step returns the nodes reached from x by one triple whose predicate
+// satisfies the condition.
+func step(g *Graph, x Term, fwd bool, cond func(Term) bool) []Term {
+	var res []Term
+	if fwd {
+		s, ok := x.(Subject)
+		if !ok {
+			return nil
+		}
+		for _, t := range g.Match(s, nil, nil) {
+			if cond(t.Pred) {
+				res = append(res, t.Obj)
+			}
+		}
+		return res
+	}
+	o, ok := x.(Object)
+	if !ok {
+		return nil
+	}
+	for _, t := range g.Match(nil, nil, o) {
+		if cond(t.Pred) {
+			res = append(res, t.Subj)
+		}
+	}
+	return res
+}
+
+// closure returns the distinct nodes reached by following the path one or
+// more times, or zero or more times if self is true. Each node is visited
+// once, so cycles in the graph are handled.
+func closure(g *Graph, path Path, x Term, fwd bool, self bool) []Term {
+	var res []Term
+	seen := make(map[string]bool)
+	if self {
+		seen[termKey(x)] = true
+		res = append(res, x)
+	}
+	queue := []Term{x}
+	for len(queue) > 0 {
+		y := queue[0]
+		queue = queue[1:]
+		for _, z := range pathFrom(g, path, y, fwd) {
+			if !seen[termKey(z)] {
+				seen[termKey(z)] = true
+				res = append(res, z)
+				queue = append(queue, z)
+			}
+		}
+	}
+	return res
+}
+
+// compatible returns true if the bindings agree on the variables they share.
+func compatible(a, b Binding) bool {
+	for v, t := range a {
+		if u, ok := b[v]; ok && termKey(t) != termKey(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// sharesVar returns true if the bindings have a variable in common.
+func sharesVar(a, b Binding) bool {
+	for v := range a {
+		if _, ok := b[v]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns the union of two compatible bindings.
+func merge(a, b Binding) Binding {
+	res := make(Binding, len(a)+len(b))
+	for v, t := range a {
+		res[v] = t
+	}
+	for v, t := range b {
+		res[v] = t
+	}
+	return res
+}
+
+// bindingKey returns a string which uniquely identifies the binding.
+func bindingKey(b Binding) string {
+	var key strings.Builder
+	for _, v := range b.Vars() {
+		key.WriteString(string(v) + "=" + termKey(b[v]) + "\n")
+	}
+	return key.String()
+}
+
+// collectBindings returns all the solutions of the iterator.
+func collectBindings(it BindingIterator) []Binding {
+	var bs []Binding
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		bs = append(bs, b)
+	}
+	return bs
+}
+
+// sliceIterator iterates over a slice of solutions.
+type sliceIterator struct {
+	bs []Binding
+}
+
+// Next returns the next solution.
+func (it *sliceIterator) Next() (Binding, bool) {
+	if len(it.bs) == 0 {
+		return nil, false
+	}
+	b := it.bs[0]
+	it.bs = it.bs[1:]
+	return b, true
+}
+
+// flatIterator maps each solution of an iterator to zero or more solutions.
+type flatIterator struct {
+	it  BindingIterator
+	fn  func(Binding) []Binding
+	buf []Binding
+}
+
+// Next returns the next solution.
+func (it *flatIterator) Next() (Binding, bool) {
+	for len(it.buf) == 0 {
+		b, ok := it.it.Next()
+		if !ok {
+			return nil, false
+		}
+		it.buf = it.fn(b)
+	}
+	b := it.buf[0]
+	it.buf = it.buf[1:]
+	return b, true
+}
+
+// concatIterator iterates over the solutions of several iterators, in turn.
+type concatIterator struct {
+	its []BindingIterator
+}
+
+// Next returns the next solution.
+func (it *concatIterator) Next() (Binding, bool) {
+	for len(it.its) > 0 {
+		if b, ok := it.its[0].Next(); ok {
+			return b, true
+		}
+		it.its = it.its[1:]
+	}
+	return nil, false
+}
+
+// sliceOpIterator skips offset solutions, and returns at most limit solutions
+// (if limit isn't negative).
+type sliceOpIterator struct {
+	it            BindingIterator
+	offset, limit int
+}
+
+// Next returns the next solution.
+func (it *sliceOpIterator) Next() (Binding, bool) {
+	for ; it.offset > 0; it.offset-- {
+		if _, ok := it.it.Next(); !ok {
+			return nil, false
+		}
+	}
+	if it.limit == 0 {
+		return nil, false
+	}
+	if it.limit > 0 {
+		it.limit--
+	}
+	return it.it.Next()
+}
@@ -0,0 +1,278 @@
+package rdf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const evalTestData = `
+<http://example/book1> <http://purl.org/dc/elements/1.1/title> "SPARQL Tutorial"@en .
+<http://example/book1> <http://example/price> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example/book1> <http://example/published> "2008-04-01T10:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+<http://example/book2> <http://purl.org/dc/elements/1.1/title> "The Semantic Web" .
+<http://example/book2> <http://example/price> "23.5"^^<http://www.w3.org/2001/XMLSchema#decimal> .
+<http://example/book3> <http://purl.org/dc/elements/1.1/title> "Turtle" .
+<http://example/a> <http://example/knows> <http://example/b> .
+<http://example/b> <http://example/knows> <http://example/c> .
+<http://example/c> <http://example/knows> <http://example/a> .
+<http://example/c> <http://example/name> _:n .
+_:n <http://example/first> "Carol" .
+<http://example/a> <http://example/age> "30"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/g1> .
+<http://example/b> <http://example/age> "25"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/g2> .
+<http://example/c> <http://example/age> "1.0E1"^^<http://www.w3.org/2001/XMLSchema#double> <http://example/g2> .
+`
+
+// evalTestDataset returns the test data as a Dataset.
+func evalTestDataset() *Dataset {
+	dec := NewQuadDecoder(bytes.NewBufferString(evalTestData), NQuads)
+	dec.DefaultGraph = nil
+	qs, err := dec.DecodeAll()
+	if err != nil {
+		panic(err)
+	}
+	return NewDataset(qs...)
+}
+
+func TestEvalSelect(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?x WHERE { ?x ex:knows/ex:knows ex:a }`,
+			[]string{`?x=<http://example/b>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?y WHERE { ex:a ex:knows+ ?y }`,
+			[]string{`?y=<http://example/a>`, `?y=<http://example/b>`, `?y=<http://example/c>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?y WHERE { ex:b ex:knows* ?y FILTER(?y != ex:a) }`,
+			[]string{`?y=<http://example/b>`, `?y=<http://example/c>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?y WHERE { ex:a ^ex:knows ?y }`,
+			[]string{`?y=<http://example/c>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?y WHERE { ex:c !(ex:knows|ex:age) ?y }`,
+			[]string{`?y=_:n`},
+		},
+		{
+			`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+PREFIX ex: <http://example/>
+SELECT ?b ?p WHERE { ?b dc:title ?t OPTIONAL { ?b ex:price ?p FILTER(?p > 30) } }`,
+			[]string{
+				`?b=<http://example/book1> ?p="42"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`?b=<http://example/book2>`,
+				`?b=<http://example/book3>`,
+			},
+		},
+		{
+			`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+SELECT ?t WHERE { ?b dc:title ?t FILTER(LANGMATCHES(LANG(?t), "EN") || REGEX(?t, "^tur", "i")) }`,
+			[]string{`?t="SPARQL Tutorial"@en`, `?t="Turtle"`},
+		},
+		{
+			`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+PREFIX ex: <http://example/>
+SELECT ?b WHERE { ?b dc:title ?t MINUS { ?b ex:price ?p } }`,
+			[]string{`?b=<http://example/book3>`},
+		},
+		{
+			`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+PREFIX ex: <http://example/>
+SELECT ?b WHERE { ?b dc:title ?t FILTER NOT EXISTS { ?b ex:price ?p } }`,
+			[]string{`?b=<http://example/book3>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?g ?x WHERE { GRAPH ?g { ?x ex:age ?a FILTER(?a < 28) } }`,
+			[]string{`?g=<http://example/g2> ?x=<http://example/b>`, `?g=<http://example/g2> ?x=<http://example/c>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?x FROM <http://example/g2> WHERE { ?x ex:age ?a FILTER(?a = 10) }`,
+			[]string{`?x=<http://example/c>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?g (SUM(?a) AS ?sum) (AVG(?a) AS ?avg) (COUNT(*) AS ?n)
+WHERE { GRAPH ?g { ?x ex:age ?a } } GROUP BY ?g`,
+			[]string{
+				`?avg="1.75E1"^^<http://www.w3.org/2001/XMLSchema#double> ?g=<http://example/g2> ?n="2"^^<http://www.w3.org/2001/XMLSchema#integer> ?sum="3.5E1"^^<http://www.w3.org/2001/XMLSchema#double>`,
+				`?avg="30.0"^^<http://www.w3.org/2001/XMLSchema#decimal> ?g=<http://example/g1> ?n="1"^^<http://www.w3.org/2001/XMLSchema#integer> ?sum="30"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+			},
+		},
+		{
+			`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+SELECT (GROUP_CONCAT(?t; SEPARATOR="|") AS ?all) (COUNT(?x) AS ?none) (MAX(STRLEN(?t)) AS ?max)
+WHERE { { SELECT ?t WHERE { ?b dc:title ?t FILTER(STRSTARTS(?t, "T")) } ORDER BY ?t } }`,
+			[]string{`?all="The Semantic Web|Turtle" ?max="16"^^<http://www.w3.org/2001/XMLSchema#integer> ?none="0"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		},
+		{
+			`PREFIX ex: <http://example/>
+SELECT ?b ?y ?h ?c WHERE {
+	?b ex:published ?d
+	BIND(YEAR(?d) AS ?y)
+	BIND(SHA1("abc") AS ?h)
+	BIND(CONCAT(UCASE(SUBSTR("sparql", 1, 3)), STR(?b)) AS ?c)
+}`,
+			[]string{`?b=<http://example/book1> ?c="SPAhttp://example/book1" ?h="a9993e364706816aba3e25717850c26c9cd0d89d" ?y="2008"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		},
+		{
+			`SELECT ?x ?y WHERE { VALUES (?x ?y) { (1 2) (3 UNDEF) } FILTER(COALESCE(?y, 0) < 3) BIND(?x / 2 AS ?z) FILTER(?z > 0.4) }`,
+			[]string{
+				`?x="1"^^<http://www.w3.org/2001/XMLSchema#integer> ?y="2"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`?x="3"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+			},
+		},
+		{
+			`SELECT ?s WHERE { { BIND(IRI("http://example/x") AS ?s) } UNION { BIND(ENCODE_FOR_URI("a b/c") AS ?s) } }`,
+			[]string{`?s="a%20b%2Fc"`, `?s=<http://example/x>`},
+		},
+		{
+			`SELECT ?r WHERE { BIND(REPLACE("abcabc", "(b)(c)", "[$2$1]") AS ?r) }`,
+			[]string{`?r="a[cb]a[cb]"`},
+		},
+		{
+			`PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+SELECT ?i ?d ?b WHERE { BIND(xsd:integer("042") AS ?i) BIND(xsd:decimal(1.5e0) AS ?d) BIND(xsd:boolean(0) AS ?b) }`,
+			[]string{`?b="false"^^<http://www.w3.org/2001/XMLSchema#boolean> ?d="1.5"^^<http://www.w3.org/2001/XMLSchema#decimal> ?i="42"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		},
+	}
+
+	ds := evalTestDataset()
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		it, err := q.Select(ds)
+		if err != nil {
+			t.Errorf("Select(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if got := formatBindings(it); !equalStrings(got, tt.want) {
+			t.Errorf("Select(%q) =>\n%q\nwant:\n%q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEvalBNODE(t *testing.T) {
+	// BNODE(label) gives the same blank node within a solution, and
+	// distinct blank nodes across solutions.
+	q, err := ParseQuery(`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+SELECT ?t (BNODE("x") AS ?x) (BNODE("x") AS ?y) WHERE { ?b dc:title ?t }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := q.Select(evalTestDataset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	n := 0
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		n++
+		x, y := b["x"].Serialize(NTriples), b["y"].Serialize(NTriples)
+		if x != y {
+			t.Errorf("BNODE(\"x\") => %s and %s in one solution; want the same blank node", x, y)
+		}
+		if seen[x] {
+			t.Errorf("BNODE(\"x\") => %s in two solutions; want distinct blank nodes", x)
+		}
+		seen[x] = true
+	}
+	if n != 3 {
+		t.Errorf("got %d solutions, want 3", n)
+	}
+}
+
+func TestEvalOrderSlice(t *testing.T) {
+	q, err := ParseQuery(`PREFIX dc: <http://purl.org/dc/elements/1.1/>
+PREFIX ex: <http://example/>
+SELECT ?b WHERE { ?b dc:title ?t OPTIONAL { ?b ex:price ?p } }
+ORDER BY DESC(?p) ?b LIMIT 2 OFFSET 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := q.Select(evalTestDataset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		got = append(got, b["b"].String())
+	}
+	want := []string{"http://example/book2", "http://example/book3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ordered solutions => %v, want %v", got, want)
+	}
+}
+
+func TestEvalAskConstructDescribe(t *testing.T) {
+	ds := evalTestDataset()
+
+	for query, want := range map[string]bool{
+		`ASK { <http://example/a> (<http://example/knows>/<http://example/knows>)+ <http://example/a> }`: true,
+		`ASK { GRAPH <http://example/g1> { ?x ?p 30 } }`:                                                 true,
+		`ASK { GRAPH <http://example/nope> { ?x ?p ?o } }`:                                               false,
+	} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			if want {
+				t.Errorf("ParseQuery(%q) failed: %v", query, err)
+			}
+			continue
+		}
+		if got, err := q.Ask(ds); err != nil || got != want {
+			t.Errorf("Ask(%q) => %v, %v; want %v", query, got, err, want)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{
+			`PREFIX ex: <http://example/>
+CONSTRUCT { ?y ex:knownBy ?x . ?x ex:friend [ ex:is ?y ] } WHERE { ?x ex:knows ?y FILTER(?x = ex:a) }`,
+			[]string{
+				"<http://example/a> <http://example/friend> _:g1 .\n",
+				"<http://example/b> <http://example/knownBy> <http://example/a> .\n",
+				"_:g1 <http://example/is> <http://example/b> .\n",
+			},
+		},
+		{
+			`DESCRIBE <http://example/c>`,
+			[]string{
+				"<http://example/c> <http://example/knows> <http://example/a> .\n",
+				"<http://example/c> <http://example/name> _:n .\n",
+				"_:n <http://example/first> \"Carol\" .\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		ts, err := q.Construct(ds)
+		if err != nil {
+			t.Errorf("Construct(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if got := sortedNT(ts); !equalStrings(got, tt.want) {
+			t.Errorf("Construct(%q) =>\n%q\nwant:\n%q", tt.query, got, tt.want)
+		}
+	}
+}
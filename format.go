@@ -0,0 +1,238 @@
+package rdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// formatInfo holds the names, media types and file extensions of formats.
+// The first media type and extension of a format are the preferred ones.
+var formatInfo = map[Format]struct {
+	name       string
+	mediaTypes []string
+	extensions []string
+}{
+	NTriples: {"N-Triples", []string{"application/n-triples", "text/plain"}, []string{".nt"}},
+	Turtle:   {"Turtle", []string{"text/turtle", "application/x-turtle"}, []string{".ttl"}},
+	RDFXML:   {"RDF/XML", []string{"application/rdf+xml"}, []string{".rdf", ".owl"}},
+	JSONLD:   {"JSON-LD", []string{"application/ld+json"}, []string{".jsonld"}},
+	NQuads:   {"N-Quads", []string{"application/n-quads", "text/x-nquads"}, []string{".nq"}},
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if info, ok := formatInfo[f]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// MediaType returns the media type of the format, or an empty string
+// for an unknown format.
+func (f Format) MediaType() string {
+	if info, ok := formatInfo[f]; ok {
+		return info.mediaTypes[0]
+	}
+	return ""
+}
+
+// Extensions returns the file extensions of the format, including the
+// leading dot; the preferred extension first.
+func (f Format) Extensions() []string {
+	return append([]string(nil), formatInfo[f].extensions...)
+}
+
+// FormatFromMediaType returns the format of the given media type, which
+// may have parameters, as in a Content-Type header. text/plain is taken
+// to be N-Triples, whose media type it was before application/n-triples.
+func FormatFromMediaType(mediaType string) (Format, error) {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return 0, fmt.Errorf("invalid media type: %q", mediaType)
+	}
+	for f, info := range formatInfo {
+		for _, s := range info.mediaTypes {
+			if s == mt {
+				return f, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown media type: %q", mt)
+}
+
+// FormatFromExtension returns the format of the given file extension,
+// with or without the leading dot, or of the extension of a file name.
+// The extension is case insensitive.
+func FormatFromExtension(ext string) (Format, error) {
+	if !strings.Contains(ext, ".") {
+		ext = "." + ext
+	}
+	e := strings.ToLower(filepath.Ext(ext))
+	for f, info := range formatInfo {
+		for _, s := range info.extensions {
+			if s == e {
+				return f, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown file extension: %q", ext)
+}
+
+// decodesTriples returns true if NewTripleDecoder supports the format.
+func decodesTriples(f Format) bool {
+	return f == NTriples || f == Turtle || f == RDFXML
+}
+
+// sniffLen is the number of bytes DetectFormat looks at.
+const sniffLen = 4096
+
+// DetectFormat detects the format of the RDF document read from r, by
+// looking at its first bytes. It returns the format, and a reader which
+// reads the whole document, including the bytes looked at.
+//
+// An XML declaration or a <rdf:RDF> element means RDF/XML, and a JSON
+// object with a "@context" member JSON-LD. Documents starting with a
+// Turtle directive are Turtle, and documents where the first statement is
+// a line of three or four terms N-Triples or N-Quads, respectively. Any
+// other document is taken to be Turtle, which is the most lenient format.
+func DetectFormat(r io.Reader) (Format, io.Reader) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	b, _ := br.Peek(sniffLen)
+	return sniffFormat(b), br
+}
+
+func sniffFormat(b []byte) Format {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	for {
+		b = bytes.TrimLeft(b, " \t\r\n")
+		if len(b) == 0 || b[0] != '#' {
+			break
+		}
+		// Skip comment line.
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return Turtle
+		}
+		b = b[i+1:]
+	}
+
+	switch {
+	case len(b) == 0:
+		return Turtle
+	case b[0] == '{' || b[0] == '[':
+		if bytes.Contains(b, []byte(`"@context"`)) {
+			return JSONLD
+		}
+	case b[0] == '<':
+		if isXMLStart(b) {
+			return RDFXML
+		}
+	case b[0] == '@':
+		return Turtle
+	}
+	for _, kw := range []string{"prefix", "base"} {
+		if len(b) > len(kw) && strings.EqualFold(string(b[:len(kw)]), kw) && (b[len(kw)] == ' ' || b[len(kw)] == '\t') {
+			return Turtle
+		}
+	}
+
+	line := b
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		line = b[:i]
+	}
+	switch countLineTerms(line) {
+	case 3:
+		return NTriples
+	case 4:
+		return NQuads
+	}
+	return Turtle
+}
+
+// isXMLStart returns true if b starts with an XML declaration, comment or
+// doctype declaration, or with a start tag with a prefixed name or
+// attributes, which a Turtle IRI can't have.
+func isXMLStart(b []byte) bool {
+	for _, prefix := range []string{"<?xml", "<!--", "<!DOCTYPE", "<rdf:RDF"} {
+		if bytes.HasPrefix(b, []byte(prefix)) {
+			return true
+		}
+	}
+	i := 1
+	for i < len(b) && (isAlphaOrDigit(rune(b[i])) || b[i] == '_' || b[i] == '-' || b[i] == '.' || b[i] == ':') {
+		i++
+	}
+	return i > 1 && i < len(b) && isAlpha(rune(b[1])) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\r' || b[i] == '\n')
+}
+
+// countLineTerms returns the number of terms in a N-Triples or N-Quads
+// statement on the line, or -1 if the line is not such a statement.
+func countLineTerms(line []byte) int {
+	n := 0
+	for i := 0; ; {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t' || line[i] == '\r') {
+			i++
+		}
+		if i == len(line) {
+			return -1
+		}
+		var end int
+		switch c := line[i]; {
+		case c == '.':
+			return n
+		case bytes.HasPrefix(line[i:], []byte("<<(")):
+			if j := bytes.LastIndex(line[i:], []byte(")>>")); j > 0 {
+				end = i + j + 3
+			}
+		case c == '<':
+			if j := bytes.IndexByte(line[i:], '>'); j > 0 {
+				end = i + j + 1
+			}
+		case bytes.HasPrefix(line[i:], []byte("_:")):
+			if j := bytes.IndexAny(line[i:], " \t"); j > 0 {
+				end = i + j
+			}
+		case c == '"':
+			end = literalEnd(line, i)
+		default:
+			return -1
+		}
+		if end <= i {
+			return -1
+		}
+		i = end
+		n++
+	}
+}
+
+// literalEnd returns the end of the N-Triples literal starting at i,
+// including a language tag or datatype, or -1 if it doesn't end on the
+// line.
+func literalEnd(line []byte, i int) int {
+	for i++; i < len(line) && line[i] != '"'; i++ {
+		if line[i] == '\\' {
+			i++
+		}
+	}
+	if i >= len(line) {
+		return -1
+	}
+	i++
+	switch {
+	case bytes.HasPrefix(line[i:], []byte("^^<")):
+		if j := bytes.IndexByte(line[i:], '>'); j >= 0 {
+			return i + j + 1
+		}
+		return -1
+	case i < len(line) && line[i] == '@':
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+	}
+	return i
+}
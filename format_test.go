@@ -0,0 +1,128 @@
+package rdf
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFormatNames(t *testing.T) {
+	tests := []struct {
+		f          Format
+		name       string
+		mediaType  string
+		extensions []string
+	}{
+		{NTriples, "N-Triples", "application/n-triples", []string{".nt"}},
+		{Turtle, "Turtle", "text/turtle", []string{".ttl"}},
+		{RDFXML, "RDF/XML", "application/rdf+xml", []string{".rdf", ".owl"}},
+		{JSONLD, "JSON-LD", "application/ld+json", []string{".jsonld"}},
+		{NQuads, "N-Quads", "application/n-quads", []string{".nq"}},
+		{Format(42), "Format(42)", "", nil},
+	}
+
+	for _, tt := range tests {
+		if got := tt.f.String(); got != tt.name {
+			t.Errorf("String() => %q; want %q", got, tt.name)
+		}
+		if got := tt.f.MediaType(); got != tt.mediaType {
+			t.Errorf("%v.MediaType() => %q; want %q", tt.f, got, tt.mediaType)
+		}
+		if got := tt.f.Extensions(); !equalStrings(got, tt.extensions) {
+			t.Errorf("%v.Extensions() => %q; want %q", tt.f, got, tt.extensions)
+		}
+	}
+}
+
+func TestFormatFromMediaTypeAndExtension(t *testing.T) {
+	for mt, want := range map[string]Format{
+		"text/turtle":                    Turtle,
+		"Text/Turtle; charset=utf-8":     Turtle,
+		"application/n-triples":          NTriples,
+		"text/plain":                     NTriples,
+		"application/rdf+xml":            RDFXML,
+		"application/ld+json":            JSONLD,
+		"application/n-quads;charset=x ": NQuads,
+	} {
+		if got, err := FormatFromMediaType(mt); err != nil || got != want {
+			t.Errorf("FormatFromMediaType(%q) => %v, %v; want %v", mt, got, err, want)
+		}
+	}
+	if _, err := FormatFromMediaType("text/html"); err == nil || err.Error() != `unknown media type: "text/html"` {
+		t.Errorf("FormatFromMediaType(text/html) => %v; want error", err)
+	}
+
+	for ext, want := range map[string]Format{
+		".ttl":            Turtle,
+		"ttl":             Turtle,
+		"data/file.NT":    NTriples,
+		"ontology.owl":    RDFXML,
+		"x.rdf":           RDFXML,
+		"dump.nq":         NQuads,
+		"context.jsonld":  JSONLD,
+		"/a.b/c.d.e/f.nq": NQuads,
+	} {
+		if got, err := FormatFromExtension(ext); err != nil || got != want {
+			t.Errorf("FormatFromExtension(%q) => %v, %v; want %v", ext, got, err, want)
+		}
+	}
+	if _, err := FormatFromExtension("file.txt"); err == nil {
+		t.Error("FormatFromExtension(file.txt) succeeded")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Format
+	}{
+		{`<?xml version="1.0"?><rdf:RDF/>`, RDFXML},
+		{"\xef\xbb\xbf<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\"/>", RDFXML},
+		{"<!-- comment -->\n<RDF xmlns=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\"/>", RDFXML},
+		{`{ "@context": "http://schema.org/", "name": "x" }`, JSONLD},
+		{`[{"@id": "http://example/a"}]`, Turtle},
+		{"@prefix ex: <http://example/> .\nex:a ex:p ex:b .", Turtle},
+		{"# comment\n\nPREFIX ex: <http://example/>", Turtle},
+		{"base <http://example/>", Turtle},
+		{"<http://example/a> <http://example/p> \"x\\\"y\"@en-GB .\n", NTriples},
+		{"# header\n_:b <http://example/p> \"1\"^^<http://www.w3.org/2001/XMLSchema#integer>. # comment", NTriples},
+		{"<http://example/a> <http://example/p> <<( <http://example/a> <http://example/p> _:x )>> .", NTriples},
+		{"<http://example/a> <http://example/p> <http://example/b> <http://example/g> .\n", NQuads},
+		{"<http://example/a> <http://example/p> _:o _:g .", NQuads},
+		{"<http://example/a> <http://example/p> [ <http://example/q> 1 ] .", Turtle},
+		{"<a> <p> <b> ;\n <q> <c> .", Turtle},
+		{"", Turtle},
+	}
+
+	for _, tt := range tests {
+		f, r := DetectFormat(strings.NewReader(tt.input))
+		if f != tt.want {
+			t.Errorf("DetectFormat(%q) => %v; want %v", tt.input, f, tt.want)
+		}
+		if b, err := ioutil.ReadAll(r); err != nil || string(b) != tt.input {
+			t.Errorf("DetectFormat(%q) reader => %q, %v", tt.input, b, err)
+		}
+	}
+
+	// The detected format can be decoded.
+	f, r := DetectFormat(strings.NewReader(strings.Repeat("<http://example/a> <http://example/p> \"x\" .\n", 500)))
+	ts, err := NewTripleDecoder(r, f).DecodeAll()
+	if err != nil || len(ts) != 500 {
+		t.Errorf("decoding detected %v => %d triples, %v", f, len(ts), err)
+	}
+
+	// JSON-LD is detected, but decoding it is an error.
+	f, r = DetectFormat(strings.NewReader(`{"@context": {}, "@id": "http://example/a"}`))
+	if _, err := NewTripleDecoder(r, f).DecodeAll(); err == nil || err.Error() != "decoder for serialization format JSON-LD not implemented" {
+		t.Errorf("decoding detected %v => error %v", f, err)
+	}
+}
+
+func TestFormatValues(t *testing.T) {
+	// The values of the formats are stable; new formats are added last.
+	for f, want := range map[Format]int{NTriples: 0, Turtle: 1, RDFXML: 2, NQuads: 3, JSONLD: 4} {
+		if int(f) != want {
+			t.Errorf("%v = %d; want %d", f, int(f), want)
+		}
+	}
+}
@@ -0,0 +1,1312 @@
+package rdf
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	mrand "math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Evaluation of SPARQL expressions and functions, as defined in
+// http://www.w3.org/TR/sparql11-query/#expressions
+//
+// An expression which cannot be evaluated, e.g. because a variable is
+// unbound, or an argument has the wrong type, gives an error. Filters
+// treat errors as false, and BIND leaves the variable unbound.
+
+// errUnbound is the error of evaluating an unbound variable.
+var errUnbound = errors.New("unbound variable")
+
+// typeError returns an error for an argument of the wrong type.
+func typeError(format string, args ...interface{}) error {
+	return fmt.Errorf("type error: "+format, args...)
+}
+
+var (
+	trueLiteral  = Literal{str: "true", DataType: xsdBoolean}
+	falseLiteral = Literal{str: "false", DataType: xsdBoolean}
+
+	xsdDayTimeDuration = IRI{str: "http://www.w3.org/2001/XMLSchema#dayTimeDuration"}
+)
+
+func boolLiteral(b bool) Literal {
+	if b {
+		return trueLiteral
+	}
+	return falseLiteral
+}
+
+// test evaluates a filter expression; errors are false.
+func (e *evaluator) test(x Expr, b Binding, g *Graph) bool {
+	t, err := e.expr(x, b, g)
+	if err != nil {
+		return false
+	}
+	v, err := ebv(t)
+	return err == nil && v
+}
+
+// ebv returns the effective boolean value of a term.
+func ebv(t Term) (bool, error) {
+	l, ok := t.(Literal)
+	if !ok {
+		return false, typeError("no boolean value of %s", t.Serialize(NTriples))
+	}
+	if l.DataType == xsdBoolean {
+		v, err := parseBoolean(l.str)
+		return err == nil && v, nil
+	}
+	if _, ok := numericDataType(l.DataType); ok {
+		n, ok := literalNumber(l)
+		if !ok {
+			return false, nil
+		}
+		if n.kind >= numFloat {
+			return n.f != 0 && !math.IsNaN(n.f), nil
+		}
+		return n.rat.Sign() != 0, nil
+	}
+	if l.DataType == xsdString {
+		return l.str != "", nil
+	}
+	return false, typeError("no boolean value of %s", l.Serialize(NTriples))
+}
+
+// expr evaluates an expression with the given solution and active graph.
+func (e *evaluator) expr(x Expr, b Binding, g *Graph) (Term, error) {
+	switch x := x.(type) {
+	case TermExpr:
+		if v, ok := x.Term.(Var); ok {
+			if t, ok := b[v]; ok {
+				return t, nil
+			}
+			return nil, errUnbound
+		}
+		return x.Term, nil
+	case UnaryExpr:
+		t, err := e.expr(x.Arg, b, g)
+		if err != nil {
+			return nil, err
+		}
+		if x.Op == "!" {
+			v, err := ebv(t)
+			if err != nil {
+				return nil, err
+			}
+			return boolLiteral(!v), nil
+		}
+		n, err := numberArg(t)
+		if err != nil {
+			return nil, err
+		}
+		if x.Op == "-" {
+			n = n.neg()
+		}
+		return n.literal(), nil
+	case BinaryExpr:
+		return e.binary(x, b, g)
+	case InExpr:
+		t, err := e.expr(x.Arg, b, g)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, y := range x.List {
+			u, err := e.expr(y, b, g)
+			if err == nil {
+				var eq bool
+				if eq, err = equalTerms(t, u); err == nil && eq {
+					return boolLiteral(!x.Not), nil
+				}
+			}
+			if err != nil {
+				lastErr = err
+			}
+		}
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return boolLiteral(x.Not), nil
+	case ExistsExpr:
+		it := e.eval(substituteOp(x.Pattern, b), g)
+		exists := false
+		for s, ok := it.Next(); ok; s, ok = it.Next() {
+			if compatible(s, b) {
+				exists = true
+				break
+			}
+		}
+		return boolLiteral(exists != x.Not), nil
+	case FuncExpr:
+		return e.call(x, b, g)
+	}
+	return nil, fmt.Errorf("unknown expression: %T", x)
+}
+
+// binary evaluates a binary operator.
+func (e *evaluator) binary(x BinaryExpr, b Binding, g *Graph) (Term, error) {
+	l, errL := e.expr(x.Left, b, g)
+	switch x.Op {
+	case "||", "&&":
+		// An error is only an error if the other operand doesn't
+		// determine the result.
+		var vl, vr bool
+		if errL == nil {
+			vl, errL = ebv(l)
+		}
+		r, errR := e.expr(x.Right, b, g)
+		if errR == nil {
+			vr, errR = ebv(r)
+		}
+		short := x.Op == "||" // the value which determines the result
+		switch {
+		case errL == nil && vl == short, errR == nil && vr == short:
+			return boolLiteral(short), nil
+		case errL != nil:
+			return nil, errL
+		case errR != nil:
+			return nil, errR
+		}
+		return boolLiteral(!short), nil
+	}
+	if errL != nil {
+		return nil, errL
+	}
+	r, err := e.expr(x.Right, b, g)
+	if err != nil {
+		return nil, err
+	}
+	switch x.Op {
+	case "=", "!=":
+		eq, err := equalTerms(l, r)
+		if err != nil {
+			return nil, err
+		}
+		return boolLiteral(eq == (x.Op == "=")), nil
+	case "<", ">", "<=", ">=":
+		c, err := compareTerms(l, r)
+		if err == errIncomparable {
+			return falseLiteral, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch x.Op {
+		case "<":
+			return boolLiteral(c < 0), nil
+		case ">":
+			return boolLiteral(c > 0), nil
+		case "<=":
+			return boolLiteral(c <= 0), nil
+		}
+		return boolLiteral(c >= 0), nil
+	}
+	nl, err := numberArg(l)
+	if err != nil {
+		return nil, err
+	}
+	nr, err := numberArg(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := arith(x.Op, nl, nr)
+	if err != nil {
+		return nil, err
+	}
+	return n.literal(), nil
+}
+
+// substituteOp replaces the variables bound by b in the triple and path
+// patterns, graph names and expressions of the operator with their values,
+// for the evaluation of EXISTS.
+func substituteOp(op Op, b Binding) Op {
+	sub := func(t Term) Term {
+		if v, ok := t.(Var); ok {
+			if u, ok := b[v]; ok {
+				return u
+			}
+		}
+		return t
+	}
+	var subExpr func(x Expr) Expr
+	subExpr = func(x Expr) Expr {
+		switch x := x.(type) {
+		case TermExpr:
+			return TermExpr{Term: sub(x.Term)}
+		case UnaryExpr:
+			return UnaryExpr{Op: x.Op, Arg: subExpr(x.Arg)}
+		case BinaryExpr:
+			return BinaryExpr{Op: x.Op, Left: subExpr(x.Left), Right: subExpr(x.Right)}
+		case InExpr:
+			list := make([]Expr, len(x.List))
+			for i, y := range x.List {
+				list[i] = subExpr(y)
+			}
+			return InExpr{Arg: subExpr(x.Arg), List: list, Not: x.Not}
+		case FuncExpr:
+			if x.Name == "BOUND" {
+				return x
+			}
+			args := make([]Expr, len(x.Args))
+			for i, y := range x.Args {
+				args[i] = subExpr(y)
+			}
+			return FuncExpr{Name: x.Name, IRI: x.IRI, Args: args}
+		case ExistsExpr:
+			return ExistsExpr{Pattern: substituteOp(x.Pattern, b), Not: x.Not}
+		}
+		return x
+	}
+	switch o := op.(type) {
+	case BGP:
+		tps := make([]TriplePattern, len(o.Patterns))
+		for i, tp := range o.Patterns {
+			tps[i] = TriplePattern{Subj: sub(tp.Subj), Pred: sub(tp.Pred), Obj: sub(tp.Obj)}
+		}
+		return BGP{Patterns: tps}
+	case PathPattern:
+		return PathPattern{Subj: sub(o.Subj), Path: o.Path, Obj: sub(o.Obj)}
+	case Join:
+		return Join{Left: substituteOp(o.Left, b), Right: substituteOp(o.Right, b)}
+	case LeftJoin:
+		var x Expr
+		if o.Expr != nil {
+			x = subExpr(o.Expr)
+		}
+		return LeftJoin{Left: substituteOp(o.Left, b), Right: substituteOp(o.Right, b), Expr: x}
+	case Filter:
+		return Filter{Expr: subExpr(o.Expr), Sub: substituteOp(o.Sub, b)}
+	case Union:
+		return Union{Left: substituteOp(o.Left, b), Right: substituteOp(o.Right, b)}
+	case Minus:
+		return Minus{Left: substituteOp(o.Left, b), Right: substituteOp(o.Right, b)}
+	case GraphPattern:
+		return GraphPattern{Name: sub(o.Name), Sub: substituteOp(o.Sub, b)}
+	case Extend:
+		return Extend{Sub: substituteOp(o.Sub, b), Var: o.Var, Expr: subExpr(o.Expr)}
+	}
+	// Subqueries and VALUES are left as they are; their solutions are
+	// checked for compatibility with b.
+	return op
+}
+
+// String literals
+
+// stringArg returns the literal, if it is a simple literal, an xsd:string
+// or a language-tagged string.
+func stringArg(t Term) (Literal, error) {
+	if l, ok := t.(Literal); ok {
+		switch l.DataType {
+		case xsdString, rdfLangString, rdfDirLangString:
+			return l, nil
+		}
+	}
+	return Literal{}, typeError("not a string: %s", t.Serialize(NTriples))
+}
+
+// simpleArg returns the string of a simple literal or xsd:string.
+func simpleArg(t Term) (string, error) {
+	if l, ok := t.(Literal); ok && l.DataType == xsdString {
+		return l.str, nil
+	}
+	return "", typeError("not a simple literal: %s", t.Serialize(NTriples))
+}
+
+// compatibleArgs checks that two string arguments are compatible: they
+// are both simple, or have the same language tag, or the second is simple.
+func compatibleArgs(a, b Literal) error {
+	if b.lang == "" || strings.EqualFold(a.lang, b.lang) {
+		return nil
+	}
+	return typeError("incompatible strings: %s, %s", a.Serialize(NTriples), b.Serialize(NTriples))
+}
+
+// withString returns a string literal like l, with the given string.
+func withString(l Literal, s string) Literal {
+	l.str = s
+	l.val = nil
+	return l
+}
+
+func simpleLiteral(s string) Literal {
+	return Literal{str: s, DataType: xsdString}
+}
+
+// Numbers
+
+// numberArg returns the value of a numeric literal.
+func numberArg(t Term) (number, error) {
+	if l, ok := t.(Literal); ok {
+		if n, ok := literalNumber(l); ok {
+			return n, nil
+		}
+	}
+	return number{}, typeError("not a number: %s", t.Serialize(NTriples))
+}
+
+// intArg returns the value of a numeric literal, rounded to an int.
+func intArg(t Term) (int, error) {
+	n, err := numberArg(t)
+	if err != nil {
+		return 0, err
+	}
+	f := math.Floor(n.float() + 0.5)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, typeError("not a finite number: %s", t.Serialize(NTriples))
+	}
+	return int(f), nil
+}
+
+func integerNumber(i int64) number {
+	return number{kind: numInteger, rat: new(big.Rat).SetInt64(i)}
+}
+
+// neg returns -n.
+func (n number) neg() number {
+	if n.kind >= numFloat {
+		n.f = -n.f
+	} else {
+		n.rat = new(big.Rat).Neg(n.rat)
+	}
+	return n
+}
+
+// literal returns the number as a literal of its kind.
+func (n number) literal() Literal {
+	switch n.kind {
+	case numInteger:
+		return Literal{str: n.rat.Num().String(), DataType: xsdInteger}
+	case numDecimal:
+		s, err := decimalString(n.rat)
+		if err != nil {
+			// Not a finite decimal, e.g. 1/3; round it.
+			s = strings.TrimRight(n.rat.FloatString(20), "0")
+			if strings.HasSuffix(s, ".") {
+				s += "0"
+			}
+		}
+		return Literal{str: s, DataType: xsdDecimal}
+	case numFloat:
+		return Literal{str: doubleString(n.f, 32), DataType: xsdFloat}
+	}
+	return Literal{str: doubleString(n.f, 64), DataType: xsdDouble}
+}
+
+// arith applies an arithmetic operator, after numeric type promotion. The
+// division of integers gives a decimal.
+func arith(op string, a, b number) (number, error) {
+	kind := a.kind
+	if b.kind > kind {
+		kind = b.kind
+	}
+	if kind >= numFloat {
+		x, y := a.float(), b.float()
+		var f float64
+		switch op {
+		case "+":
+			f = x + y
+		case "-":
+			f = x - y
+		case "*":
+			f = x * y
+		case "/":
+			f = x / y
+		}
+		if kind == numFloat {
+			f = float64(float32(f))
+		}
+		return number{kind: kind, f: f}, nil
+	}
+	r := new(big.Rat)
+	switch op {
+	case "+":
+		r.Add(a.rat, b.rat)
+	case "-":
+		r.Sub(a.rat, b.rat)
+	case "*":
+		r.Mul(a.rat, b.rat)
+	case "/":
+		if b.rat.Sign() == 0 {
+			return number{}, errors.New("division by zero")
+		}
+		r.Quo(a.rat, b.rat)
+		kind = numDecimal
+	}
+	return number{kind: kind, rat: r}, nil
+}
+
+// round applies a rounding function to a number, keeping its kind.
+func (n number) round(fn func(float64) float64, rat func(*big.Rat) *big.Int) number {
+	if n.kind >= numFloat {
+		n.f = fn(n.f)
+		return n
+	}
+	n.rat = new(big.Rat).SetInt(rat(n.rat))
+	return n
+}
+
+// ratFloor returns the greatest integer not greater than r.
+func ratFloor(r *big.Rat) *big.Int {
+	// Euclidean division rounds towards negative infinity, since the
+	// denominator is positive.
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+// ratCeil returns the least integer not less than r.
+func ratCeil(r *big.Rat) *big.Int {
+	return new(big.Int).Neg(ratFloor(new(big.Rat).Neg(r)))
+}
+
+// ratRound returns the integer nearest to r, rounding halves up.
+func ratRound(r *big.Rat) *big.Int {
+	return ratFloor(new(big.Rat).Add(r, big.NewRat(1, 2)))
+}
+
+// Comparisons
+
+// errIncomparable is returned when comparing values, one of which is NaN.
+var errIncomparable = errors.New("incomparable values")
+
+// equalTerms implements the SPARQL '=' operator: literals of known datatypes
+// are compared by value, and other terms must be the same term. Comparing
+// literals of different, unknown datatypes is an error, since they may
+// have the same value.
+func equalTerms(a, b Term) (bool, error) {
+	la, okA := a.(Literal)
+	lb, okB := b.(Literal)
+	if !okA || !okB {
+		return sameTerm(a, b), nil
+	}
+	if c, err := compareTerms(la, lb); err == nil {
+		return c == 0, nil
+	} else if err == errIncomparable {
+		return false, nil
+	}
+	if LiteralsValueEqual(la, lb) {
+		return true, nil
+	}
+	if knownDataType(la) && knownDataType(lb) {
+		return false, nil
+	}
+	return false, typeError("cannot compare %s and %s", la.Serialize(NTriples), lb.Serialize(NTriples))
+}
+
+// knownDataType returns true for literals whose datatype the operators
+// know the value space of, and which are well-typed.
+func knownDataType(l Literal) bool {
+	switch l.DataType {
+	case xsdString, rdfLangString, rdfDirLangString:
+		return true
+	case xsdBoolean, xsdDateTime:
+		_, err := l.Typed()
+		return err == nil
+	}
+	_, ok := literalNumber(l)
+	return ok
+}
+
+// compareTerms compares two literals by value: numbers, simple literals,
+// booleans and dateTimes. It returns an error for other terms, or literals
+// of different types.
+func compareTerms(a, b Term) (int, error) {
+	la, okA := a.(Literal)
+	lb, okB := b.(Literal)
+	if !okA || !okB {
+		return 0, typeError("cannot compare %s and %s", a.Serialize(NTriples), b.Serialize(NTriples))
+	}
+	if na, ok := literalNumber(la); ok {
+		if nb, ok := literalNumber(lb); ok {
+			c, ok := na.cmp(nb)
+			if !ok {
+				return 0, errIncomparable
+			}
+			return c, nil
+		}
+	}
+	if la.DataType == lb.DataType {
+		switch la.DataType {
+		case xsdString:
+			return strings.Compare(la.str, lb.str), nil
+		case xsdBoolean, xsdDateTime:
+			va, errA := la.Typed()
+			vb, errB := lb.Typed()
+			if errA != nil || errB != nil {
+				break
+			}
+			switch va := va.(type) {
+			case bool:
+				vb := vb.(bool)
+				switch {
+				case va == vb:
+					return 0, nil
+				case !va:
+					return -1, nil
+				}
+				return 1, nil
+			case time.Time:
+				vb := vb.(time.Time)
+				switch {
+				case va.Before(vb):
+					return -1, nil
+				case va.After(vb):
+					return 1, nil
+				}
+				return 0, nil
+			}
+		}
+	}
+	return 0, typeError("cannot compare %s and %s", la.Serialize(NTriples), lb.Serialize(NTriples))
+}
+
+// orderCompare orders terms for ORDER BY: unbound (nil) first, then blank
+// nodes, IRIs and literals. Literals which can be compared by value are,
+// the others are ordered by their lexical form, and then datatype and
+// language tag.
+func orderCompare(a, b Term) int {
+	rank := func(t Term) int {
+		if t == nil {
+			return 0
+		}
+		switch t.Type() {
+		case TermBlank:
+			return 1
+		case TermIRI:
+			return 2
+		case TermLiteral:
+			return 3
+		}
+		return 4
+	}
+	if ra, rb := rank(a), rank(b); ra != rb || ra == 0 {
+		return ra - rb
+	}
+	if c, err := compareTerms(a, b); err == nil {
+		return c
+	}
+	la, okA := a.(Literal)
+	lb, okB := b.(Literal)
+	if okA && okB {
+		if c := strings.Compare(la.str, lb.str); c != 0 {
+			return c
+		}
+		if c := strings.Compare(la.DataType.str, lb.DataType.str); c != 0 {
+			return c
+		}
+		return strings.Compare(la.lang+la.dir, lb.lang+lb.dir)
+	}
+	return strings.Compare(termKey(a), termKey(b))
+}
+
+// Function calls
+
+// call evaluates a function call.
+func (e *evaluator) call(x FuncExpr, b Binding, g *Graph) (Term, error) {
+	// Functions which don't evaluate all their arguments:
+	switch x.Name {
+	case "BOUND":
+		_, ok := b[x.Args[0].(TermExpr).Term.(Var)]
+		return boolLiteral(ok), nil
+	case "IF":
+		t, err := e.expr(x.Args[0], b, g)
+		if err != nil {
+			return nil, err
+		}
+		v, err := ebv(t)
+		if err != nil {
+			return nil, err
+		}
+		if v {
+			return e.expr(x.Args[1], b, g)
+		}
+		return e.expr(x.Args[2], b, g)
+	case "COALESCE":
+		for _, arg := range x.Args {
+			if t, err := e.expr(arg, b, g); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errors.New("no argument of COALESCE has a value")
+	}
+
+	args := make([]Term, len(x.Args))
+	for i, arg := range x.Args {
+		t, err := e.expr(arg, b, g)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = t
+	}
+	if x.Name == "" {
+		return cast(x.IRI, args)
+	}
+	if fn, ok := stringFuncs[x.Name]; ok {
+		return fn(args)
+	}
+	if fn, ok := termFuncs[x.Name]; ok {
+		return fn(args)
+	}
+	if fn, ok := dateFuncs[x.Name]; ok {
+		return fn(args)
+	}
+
+	switch x.Name {
+	case "BNODE":
+		if len(args) == 0 {
+			return e.freshBlank(), nil
+		}
+		s, err := simpleArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if e.bnodes == nil {
+			e.bnodes = make(map[string]Blank)
+		}
+		if _, ok := e.bnodes[s]; !ok {
+			e.bnodes[s] = e.freshBlank()
+		}
+		return e.bnodes[s], nil
+	case "RAND":
+		return number{kind: numDouble, f: mrand.Float64()}.literal(), nil
+	case "NOW":
+		return Literal{str: e.now.Format(dateTimeLayoutTZ), DataType: xsdDateTime}, nil
+	case "UUID":
+		return IRI{str: "urn:uuid:" + newUUID()}, nil
+	case "STRUUID":
+		return simpleLiteral(newUUID()), nil
+	case "ABS", "CEIL", "FLOOR", "ROUND":
+		n, err := numberArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch x.Name {
+		case "ABS":
+			if n.kind >= numFloat && n.f < 0 || n.kind < numFloat && n.rat.Sign() < 0 {
+				n = n.neg()
+			}
+		case "CEIL":
+			n = n.round(math.Ceil, ratCeil)
+		case "FLOOR":
+			n = n.round(math.Floor, ratFloor)
+		case "ROUND":
+			n = n.round(func(f float64) float64 { return math.Floor(f + 0.5) }, ratRound)
+		}
+		return n.literal(), nil
+	case "MD5", "SHA1", "SHA256", "SHA384", "SHA512":
+		s, err := simpleArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		var h hash.Hash
+		switch x.Name {
+		case "MD5":
+			h = md5.New()
+		case "SHA1":
+			h = sha1.New()
+		case "SHA256":
+			h = sha256.New()
+		case "SHA384":
+			h = sha512.New384()
+		case "SHA512":
+			h = sha512.New()
+		}
+		h.Write([]byte(s))
+		return simpleLiteral(fmt.Sprintf("%x", h.Sum(nil))), nil
+	}
+	return nil, fmt.Errorf("unknown function: %s", x.Name)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// termFuncs are the functions on RDF terms.
+var termFuncs = map[string]func(args []Term) (Term, error){
+	"STR": func(args []Term) (Term, error) {
+		switch t := args[0].(type) {
+		case IRI:
+			return simpleLiteral(t.str), nil
+		case Literal:
+			return simpleLiteral(t.str), nil
+		}
+		return nil, typeError("no string value of %s", args[0].Serialize(NTriples))
+	},
+	"LANG": func(args []Term) (Term, error) {
+		l, ok := args[0].(Literal)
+		if !ok {
+			return nil, typeError("not a literal: %s", args[0].Serialize(NTriples))
+		}
+		return simpleLiteral(l.lang), nil
+	},
+	"DATATYPE": func(args []Term) (Term, error) {
+		l, ok := args[0].(Literal)
+		if !ok {
+			return nil, typeError("not a literal: %s", args[0].Serialize(NTriples))
+		}
+		return l.DataType, nil
+	},
+	"IRI": func(args []Term) (Term, error) {
+		switch t := args[0].(type) {
+		case IRI:
+			return t, nil
+		case Literal:
+			if t.DataType == xsdString {
+				return NewIRI(t.str)
+			}
+		}
+		return nil, typeError("cannot make an IRI of %s", args[0].Serialize(NTriples))
+	},
+	"STRLANG": func(args []Term) (Term, error) {
+		s, err := simpleArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		lang, err := simpleArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewLangLiteral(s, lang)
+	},
+	"STRDT": func(args []Term) (Term, error) {
+		s, err := simpleArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		dt, ok := args[1].(IRI)
+		if !ok {
+			return nil, typeError("not an IRI: %s", args[1].Serialize(NTriples))
+		}
+		return NewTypedLiteral(s, dt), nil
+	},
+	"SAMETERM": func(args []Term) (Term, error) {
+		return boolLiteral(sameTerm(args[0], args[1])), nil
+	},
+	"ISIRI": func(args []Term) (Term, error) {
+		return boolLiteral(args[0].Type() == TermIRI), nil
+	},
+	"ISBLANK": func(args []Term) (Term, error) {
+		return boolLiteral(args[0].Type() == TermBlank), nil
+	},
+	"ISLITERAL": func(args []Term) (Term, error) {
+		return boolLiteral(args[0].Type() == TermLiteral), nil
+	},
+	"ISNUMERIC": func(args []Term) (Term, error) {
+		_, err := numberArg(args[0])
+		return boolLiteral(err == nil), nil
+	},
+	"LANGMATCHES": func(args []Term) (Term, error) {
+		tag, err := simpleArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		rng, err := simpleArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return boolLiteral(langMatches(tag, rng)), nil
+	},
+}
+
+// langMatches implements basic language range matching, as defined
+// in RFC 4647.
+func langMatches(tag, rng string) bool {
+	if rng == "*" {
+		return tag != ""
+	}
+	tag, rng = strings.ToLower(tag), strings.ToLower(rng)
+	return tag == rng || strings.HasPrefix(tag, rng+"-")
+}
+
+// stringFuncs are the functions on strings.
+var stringFuncs = map[string]func(args []Term) (Term, error){
+	"STRLEN": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return integerNumber(int64(utf8.RuneCountInString(l.str))).literal(), nil
+	},
+	"SUBSTR": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		// Positions are 1-based, in characters.
+		start, err := intArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		rs := []rune(l.str)
+		end := len(rs) + 1
+		if len(args) == 3 {
+			n, err := intArg(args[2])
+			if err != nil {
+				return nil, err
+			}
+			end = start + n
+		}
+		if start < 1 {
+			start = 1
+		}
+		if end > len(rs)+1 {
+			end = len(rs) + 1
+		}
+		if start >= end {
+			return withString(l, ""), nil
+		}
+		return withString(l, string(rs[start-1:end-1])), nil
+	},
+	"UCASE": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return withString(l, strings.ToUpper(l.str)), nil
+	},
+	"LCASE": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return withString(l, strings.ToLower(l.str)), nil
+	},
+	"ENCODE_FOR_URI": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for i := 0; i < len(l.str); i++ {
+			c := l.str[i]
+			if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		return simpleLiteral(b.String()), nil
+	},
+	"CONCAT": func(args []Term) (Term, error) {
+		var b strings.Builder
+		var res Literal
+		for i, arg := range args {
+			l, err := stringArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(l.str)
+			if i == 0 {
+				res = l
+			} else if res.DataType != l.DataType || res.lang != l.lang || res.dir != l.dir {
+				res = simpleLiteral("")
+			}
+		}
+		if len(args) == 0 {
+			res = simpleLiteral("")
+		}
+		return withString(res, b.String()), nil
+	},
+	"CONTAINS":  stringTest(strings.Contains),
+	"STRSTARTS": stringTest(strings.HasPrefix),
+	"STRENDS":   stringTest(strings.HasSuffix),
+	"STRBEFORE": func(args []Term) (Term, error) {
+		a, b, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		i := strings.Index(a.str, b.str)
+		if i < 0 {
+			return simpleLiteral(""), nil
+		}
+		return withString(a, a.str[:i]), nil
+	},
+	"STRAFTER": func(args []Term) (Term, error) {
+		a, b, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		i := strings.Index(a.str, b.str)
+		if i < 0 {
+			return simpleLiteral(""), nil
+		}
+		return withString(a, a.str[i+len(b.str):]), nil
+	},
+	"REGEX": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexArg(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return boolLiteral(re.MatchString(l.str)), nil
+	},
+	"REPLACE": func(args []Term) (Term, error) {
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexArg(append([]Term{args[1]}, args[3:]...))
+		if err != nil {
+			return nil, err
+		}
+		repl, err := simpleArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if re.MatchString("") {
+			return nil, errors.New("REPLACE pattern matches the empty string")
+		}
+		return withString(l, re.ReplaceAllString(l.str, xpathReplacement(repl))), nil
+	},
+}
+
+// stringArgs returns two compatible string arguments.
+func stringArgs(args []Term) (Literal, Literal, error) {
+	a, err := stringArg(args[0])
+	if err != nil {
+		return a, a, err
+	}
+	b, err := stringArg(args[1])
+	if err != nil {
+		return a, b, err
+	}
+	return a, b, compatibleArgs(a, b)
+}
+
+// stringTest returns a function testing two compatible string arguments.
+func stringTest(fn func(s, t string) bool) func(args []Term) (Term, error) {
+	return func(args []Term) (Term, error) {
+		a, b, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return boolLiteral(fn(a.str, b.str)), nil
+	}
+}
+
+// regexArg compiles a regular expression, with optional flags, as given to
+// REGEX and REPLACE. The flags are those of XPath: s, m, i, x and q.
+func regexArg(args []Term) (*regexp.Regexp, error) {
+	pattern, err := simpleArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var flags string
+	if len(args) > 1 {
+		if flags, err = simpleArg(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	var goFlags string
+	for _, f := range flags {
+		switch f {
+		case 's', 'm', 'i':
+			goFlags += string(f)
+		case 'x':
+			pattern = strings.Join(strings.Fields(pattern), "")
+		case 'q':
+			pattern = regexp.QuoteMeta(pattern)
+		default:
+			return nil, fmt.Errorf("invalid regular expression flag: %q", f)
+		}
+	}
+	if goFlags != "" {
+		pattern = "(?" + goFlags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// xpathReplacement converts an XPath replacement string, where $N refers to
+// a group and \$ and \\ are escapes, to the syntax of regexp.Expand.
+func xpathReplacement(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == '$' {
+				b.WriteString("$$")
+			} else {
+				b.WriteByte(s[i])
+			}
+		case c == '$':
+			j := i + 1
+			for j < len(s) && '0' <= s[j] && s[j] <= '9' {
+				j++
+			}
+			b.WriteString("${" + s[i+1:j] + "}")
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// dateFuncs are the functions on xsd:dateTime values.
+var dateFuncs = map[string]func(args []Term) (Term, error){
+	"YEAR":  dateField(func(t time.Time) int { return t.Year() }),
+	"MONTH": dateField(func(t time.Time) int { return int(t.Month()) }),
+	"DAY":   dateField(func(t time.Time) int { return t.Day() }),
+	"HOURS": dateField(func(t time.Time) int { return t.Hour() }),
+	"MINUTES": dateField(func(t time.Time) int {
+		return t.Minute()
+	}),
+	"SECONDS": func(args []Term) (Term, error) {
+		t, _, err := dateTimeArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		r := new(big.Rat).SetFrac64(int64(t.Second())*1e9+int64(t.Nanosecond()), 1e9)
+		return number{kind: numDecimal, rat: r}.literal(), nil
+	},
+	"TIMEZONE": func(args []Term) (Term, error) {
+		t, tz, err := dateTimeArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if !tz {
+			return nil, errors.New("dateTime has no timezone")
+		}
+		_, off := t.Zone()
+		var b strings.Builder
+		if off < 0 {
+			b.WriteByte('-')
+			off = -off
+		}
+		b.WriteString("PT")
+		if h := off / 3600; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m := off % 3600 / 60; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if off == 0 {
+			b.WriteString("0S")
+		}
+		return Literal{str: b.String(), DataType: xsdDayTimeDuration}, nil
+	},
+	"TZ": func(args []Term) (Term, error) {
+		t, tz, err := dateTimeArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if !tz {
+			return simpleLiteral(""), nil
+		}
+		if _, off := t.Zone(); off == 0 {
+			return simpleLiteral("Z"), nil
+		}
+		return simpleLiteral(t.Format("-07:00")), nil
+	},
+}
+
+// dateTimeArg returns the value of an xsd:dateTime literal, and whether
+// it has a timezone.
+func dateTimeArg(t Term) (time.Time, bool, error) {
+	if l, ok := t.(Literal); ok && l.DataType == xsdDateTime {
+		return parseDateTime(l.str)
+	}
+	return time.Time{}, false, typeError("not a dateTime: %s", t.Serialize(NTriples))
+}
+
+// dateField returns a function extracting an integer field of a dateTime.
+func dateField(fn func(time.Time) int) func(args []Term) (Term, error) {
+	return func(args []Term) (Term, error) {
+		t, _, err := dateTimeArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return integerNumber(int64(fn(t))).literal(), nil
+	}
+}
+
+// cast implements the XPath constructor functions for the XSD datatypes,
+// such as xsd:integer(?x), which are the only supported extension functions.
+// Strings, numbers, booleans and dateTimes can be cast, as far as the value
+// fits the target datatype; IRIs can only be cast to xsd:string.
+func cast(dt IRI, args []Term) (Term, error) {
+	switch dt {
+	case xsdString, xsdInteger, xsdInt, xsdDecimal, xsdDouble, xsdFloat, xsdBoolean, xsdDateTime:
+	default:
+		return nil, fmt.Errorf("unknown function: %s", dt.Serialize(NTriples))
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments to %s: %d", dt.Serialize(NTriples), len(args))
+	}
+	fail := typeError("cannot cast %s to %s", args[0].Serialize(NTriples), dt.Serialize(NTriples))
+	if iri, ok := args[0].(IRI); ok && dt == xsdString {
+		return simpleLiteral(iri.str), nil
+	}
+	l, ok := args[0].(Literal)
+	if !ok || !knownDataType(l) || l.lang != "" {
+		return nil, fail
+	}
+	if dt == xsdString {
+		return simpleLiteral(l.str), nil
+	}
+
+	s := strings.TrimSpace(l.str)
+	if n, ok := literalNumber(l); ok {
+		finite := n.kind < numFloat || !math.IsNaN(n.f) && !math.IsInf(n.f, 0)
+		switch dt {
+		case xsdBoolean:
+			v, _ := ebv(l)
+			return boolLiteral(v), nil
+		case xsdInteger, xsdInt:
+			if !finite {
+				return nil, fail
+			}
+			if n.kind >= numFloat {
+				s = strconv.FormatFloat(math.Trunc(n.f), 'f', 0, 64)
+			} else {
+				s = new(big.Int).Quo(n.rat.Num(), n.rat.Denom()).String()
+			}
+		case xsdDecimal:
+			if !finite {
+				return nil, fail
+			}
+			if n.kind >= numFloat {
+				n.rat = new(big.Rat).SetFloat64(n.f)
+			}
+			n.kind = numDecimal
+			return n.literal(), nil
+		case xsdDouble, xsdFloat:
+			s = strconv.FormatFloat(n.float(), 'E', -1, 64)
+		case xsdDateTime:
+			return nil, fail
+		}
+	} else if l.DataType == xsdBoolean {
+		v, _ := parseBoolean(s)
+		switch dt {
+		case xsdInteger, xsdInt, xsdDecimal:
+			s = "0"
+			if v {
+				s = "1"
+			}
+		case xsdDouble, xsdFloat:
+			s = "0.0E0"
+			if v {
+				s = "1.0E0"
+			}
+		case xsdDateTime:
+			return nil, fail
+		}
+	} else if l.DataType == xsdDateTime && dt != xsdDateTime {
+		return nil, fail
+	}
+
+	if err := validateLexical(s, dt); err != nil {
+		return nil, typeError("%v", err)
+	}
+	if dt != xsdDateTime {
+		s, _ = canonicalLexical(s, dt)
+	}
+	return Literal{str: s, DataType: dt}, nil
+}
+
+// Aggregates
+
+// aggregate computes an aggregate over the solutions of a group.
+func (e *evaluator) aggregate(a Aggregation, bs []Binding, g *Graph) (Term, error) {
+	if a.Arg == nil {
+		// COUNT(*)
+		n := len(bs)
+		if a.Distinct {
+			seen := make(map[string]bool)
+			for _, b := range bs {
+				seen[bindingKey(b)] = true
+			}
+			n = len(seen)
+		}
+		return integerNumber(int64(n)).literal(), nil
+	}
+
+	// Errors are skipped, except by SUM and AVG, where they make
+	// the result an error.
+	var vals []Term
+	seen := make(map[string]bool)
+	for _, b := range bs {
+		t, err := e.expr(a.Arg, b, g)
+		if err != nil {
+			if a.Name == "SUM" || a.Name == "AVG" {
+				return nil, err
+			}
+			continue
+		}
+		if a.Distinct {
+			if seen[termKey(t)] {
+				continue
+			}
+			seen[termKey(t)] = true
+		}
+		vals = append(vals, t)
+	}
+
+	switch a.Name {
+	case "COUNT":
+		return integerNumber(int64(len(vals))).literal(), nil
+	case "SUM", "AVG":
+		sum := integerNumber(0)
+		for _, t := range vals {
+			n, err := numberArg(t)
+			if err != nil {
+				return nil, err
+			}
+			sum, _ = arith("+", sum, n)
+		}
+		if a.Name == "AVG" && len(vals) > 0 {
+			var err error
+			if sum, err = arith("/", sum, integerNumber(int64(len(vals)))); err != nil {
+				return nil, err
+			}
+		}
+		return sum.literal(), nil
+	case "MIN", "MAX":
+		if len(vals) == 0 {
+			return nil, errors.New("no values to aggregate")
+		}
+		res := vals[0]
+		for _, t := range vals[1:] {
+			c := orderCompare(t, res)
+			if a.Name == "MIN" && c < 0 || a.Name == "MAX" && c > 0 {
+				res = t
+			}
+		}
+		return res, nil
+	case "SAMPLE":
+		if len(vals) == 0 {
+			return nil, errors.New("no values to aggregate")
+		}
+		return vals[0], nil
+	case "GROUP_CONCAT":
+		strs := make([]string, len(vals))
+		for i, t := range vals {
+			l, err := stringArg(t)
+			if err != nil {
+				return nil, err
+			}
+			strs[i] = l.str
+		}
+		return simpleLiteral(strings.Join(strs, a.Separator)), nil
+	}
+	return nil, fmt.Errorf("unknown aggregate: %s", a.Name)
+}
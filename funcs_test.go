@@ -0,0 +1,102 @@
+package rdf
+
+import "testing"
+
+func TestEvalExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // empty if the expression is an error
+	}{
+		{`1 + 2 * 3`, `7`},
+		{`7 / 2`, `3.5`},
+		{`1 / 3`, `0.33333333333333333333`},
+		{`1 / 0`, ``},
+		{`1.0e0 / 0`, `"INF"^^<http://www.w3.org/2001/XMLSchema#double>`},
+		{`-(2.5)`, `-2.5`},
+		{`1 = 1.0`, `true`},
+		{`"a" = "a"@en`, `false`},
+		{`"a"^^<http://e/t> = "b"^^<http://e/t>`, ``},
+		{`<http://e/a> = <http://e/a>`, `true`},
+		{`"b" > "a"`, `true`},
+		{`"2008-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> < "2009-01-01T00:00:00+01:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>`, `true`},
+		{`true > false`, `true`},
+		{`1 < "2"`, ``},
+		{`?unbound || true`, `true`},
+		{`?unbound && false`, `false`},
+		{`?unbound || false`, ``},
+		{`!""`, `true`},
+		{`2 IN (1, 2.0)`, `true`},
+		{`2 NOT IN (1, ?unbound)`, ``},
+		{`IF(1 > 2, "yes", "no")`, `"no"`},
+		{`COALESCE(?unbound, 1/0, "x")`, `"x"`},
+		{`BOUND(?unbound)`, `false`},
+		{`STRLEN("chat"@fr)`, `4`},
+		{`SUBSTR("foobar"@en, 4)`, `"bar"@en`},
+		{`SUBSTR("12345", 0, 3)`, `"12"`},
+		{`UCASE("été"@fr)`, `"ÉTÉ"@fr`},
+		{`CONCAT("a"@en, "b"@en)`, `"ab"@en`},
+		{`CONCAT("a"@en, "b")`, `"ab"`},
+		{`CONTAINS("abc"@en, "b")`, `true`},
+		{`CONTAINS("abc", "b"@en)`, ``},
+		{`STRBEFORE("abc"@en, "c")`, `"ab"@en`},
+		{`STRAFTER("abc", "x")`, `""`},
+		{`REGEX("Alice", "^ali", "i")`, `true`},
+		{`REGEX("a.c", ".", "q")`, `true`},
+		{`REPLACE("banana", "a", "o")`, `"bonono"`},
+		{`REPLACE("abc", ".*", "x")`, ``},
+		{`ABS(-1.5)`, `1.5`},
+		{`ROUND(-2.5)`, `-2.0`},
+		{`CEIL(1.2e0)`, `"2.0E0"^^<http://www.w3.org/2001/XMLSchema#double>`},
+		{`FLOOR(-1.5)`, `-2.0`},
+		{`LANG("a"@en-GB)`, `"en-GB"`},
+		{`DATATYPE("a"@en)`, `<http://www.w3.org/1999/02/22-rdf-syntax-ns#langString>`},
+		{`DATATYPE(1.0)`, `<http://www.w3.org/2001/XMLSchema#decimal>`},
+		{`LANGMATCHES("en-GB", "en")`, `true`},
+		{`LANGMATCHES("", "*")`, `false`},
+		{`STRLANG("chat", "fr")`, `"chat"@fr`},
+		{`STRDT("1", <http://www.w3.org/2001/XMLSchema#integer>)`, `1`},
+		{`SAMETERM(1, 1.0)`, `false`},
+		{`ISNUMERIC("1"^^<http://www.w3.org/2001/XMLSchema#integer>)`, `true`},
+		{`ISNUMERIC("x"^^<http://www.w3.org/2001/XMLSchema#integer>)`, `false`},
+		{`ISIRI(<http://e/a>)`, `true`},
+		{`ISBLANK(BNODE())`, `true`},
+		{`MD5("abc")`, `"900150983cd24fb0d6963f7d28e17f72"`},
+		{`SHA256("abc")`, `"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"`},
+		{`MD5("abc"@en)`, ``},
+		{`SECONDS("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `13.815`},
+		{`HOURS("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `14`},
+		{`TIMEZONE("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `"-PT5H"^^<http://www.w3.org/2001/XMLSchema#dayTimeDuration>`},
+		{`TIMEZONE("2011-01-10T14:45:13Z"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `"PT0S"^^<http://www.w3.org/2001/XMLSchema#dayTimeDuration>`},
+		{`TIMEZONE("2011-01-10T14:45:13"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, ``},
+		{`TZ("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `"-05:00"`},
+		{`STRLEN(STRUUID())`, `36`},
+		{`STRSTARTS(STR(UUID()), "urn:uuid:")`, `true`},
+		{`<http://www.w3.org/2001/XMLSchema#integer>(" 12 ")`, `12`},
+		{`<http://www.w3.org/2001/XMLSchema#integer>(2.7)`, `2`},
+		{`<http://www.w3.org/2001/XMLSchema#double>(true)`, `"1.0E0"^^<http://www.w3.org/2001/XMLSchema#double>`},
+		{`<http://www.w3.org/2001/XMLSchema#integer>("x")`, ``},
+		{`<http://www.w3.org/2001/XMLSchema#string>(<http://e/a>)`, `"http://e/a"`},
+		{`<http://e/unknown>(1)`, ``},
+	}
+
+	ds := NewDataset()
+	for _, tt := range tests {
+		q, err := ParseQuery("SELECT (" + tt.expr + " AS ?v) {}")
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		it, err := q.Select(ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := it.Next()
+		got := ""
+		if v, ok := b["v"]; ok {
+			got = sseTerm(v)
+		}
+		if got != tt.want {
+			t.Errorf("%s => %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package rdf
+
+// Graph is an in-memory set of triples, indexed for matching by any
+// combination of subject, predicate and object.
+//
+// A Graph is not safe for concurrent use; if it is modified by one goroutine
+// while read by others, access must be synchronized.
+type Graph struct {
+	ids   map[string]int // term key -> term id
+	terms []Term         // term id -> term, or nil if the id is free
+	refs  []int          // term id -> number of triple positions holding the term
+	free  []int          // ids of terms no longer in any triple, for reuse
+
+	// Three indexes cover all access patterns:
+	spo index
+	pos index
+	osp index
+
+	// Number of triples per subject, predicate and object, used
+	// to estimate the selectivity of patterns:
+	nSubj map[int]int
+	nPred map[int]int
+	nObj  map[int]int
+
+	n int // number of triples
+}
+
+// index is a three-level index of term ids.
+type index map[int]map[int]map[int]struct{}
+
+func (idx index) add(a, b, c int) bool {
+	ab, ok := idx[a]
+	if !ok {
+		ab = make(map[int]map[int]struct{})
+		idx[a] = ab
+	}
+	abc, ok := ab[b]
+	if !ok {
+		abc = make(map[int]struct{})
+		ab[b] = abc
+	}
+	if _, ok := abc[c]; ok {
+		return false
+	}
+	abc[c] = struct{}{}
+	return true
+}
+
+func (idx index) remove(a, b, c int) {
+	delete(idx[a][b], c)
+	if len(idx[a][b]) == 0 {
+		delete(idx[a], b)
+		if len(idx[a]) == 0 {
+			delete(idx, a)
+		}
+	}
+}
+
+// NewGraph returns a new Graph, containing the given triples.
+func NewGraph(ts ...Triple) *Graph {
+	g := &Graph{
+		ids:   make(map[string]int),
+		spo:   make(index),
+		pos:   make(index),
+		osp:   make(index),
+		nSubj: make(map[int]int),
+		nPred: make(map[int]int),
+		nObj:  make(map[int]int),
+	}
+	for _, t := range ts {
+		g.Add(t)
+	}
+	return g
+}
+
+// termKey returns a string which uniquely identifies an RDF term.
+func termKey(t Term) string {
+	return t.Serialize(NTriples)
+}
+
+// intern returns the id of the term, allocating a new one if needed.
+func (g *Graph) intern(t Term) int {
+	key := termKey(t)
+	if id, ok := g.ids[key]; ok {
+		return id
+	}
+	var id int
+	if n := len(g.free); n > 0 {
+		id = g.free[n-1]
+		g.free = g.free[:n-1]
+		g.terms[id] = t
+	} else {
+		id = len(g.terms)
+		g.terms = append(g.terms, t)
+		g.refs = append(g.refs, 0)
+	}
+	g.ids[key] = id
+	return id
+}
+
+// release drops a reference to the term with the given id, freeing the id
+// when the term is no longer in any triple, so that the term table doesn't
+// grow as triples are removed and added.
+func (g *Graph) release(id int) {
+	if g.refs[id]--; g.refs[id] == 0 {
+		delete(g.ids, termKey(g.terms[id]))
+		g.terms[id] = nil
+		g.free = append(g.free, id)
+	}
+}
+
+// lookup returns the id of the term, or false if the term is not in the Graph.
+func (g *Graph) lookup(t Term) (int, bool) {
+	id, ok := g.ids[termKey(t)]
+	return id, ok
+}
+
+// Add adds a triple to the Graph. It returns false if the triple was
+// already present.
+func (g *Graph) Add(t Triple) bool {
+	s, p, o := g.intern(t.Subj), g.intern(t.Pred), g.intern(t.Obj)
+	if !g.spo.add(s, p, o) {
+		return false
+	}
+	g.pos.add(p, o, s)
+	g.osp.add(o, s, p)
+	g.refs[s]++
+	g.refs[p]++
+	g.refs[o]++
+	g.nSubj[s]++
+	g.nPred[p]++
+	g.nObj[o]++
+	g.n++
+	return true
+}
+
+// Remove removes a triple from the Graph. It returns false if the triple
+// was not present.
+func (g *Graph) Remove(t Triple) bool {
+	if !g.Has(t) {
+		return false
+	}
+	s, _ := g.lookup(t.Subj)
+	p, _ := g.lookup(t.Pred)
+	o, _ := g.lookup(t.Obj)
+	g.spo.remove(s, p, o)
+	g.pos.remove(p, o, s)
+	g.osp.remove(o, s, p)
+	decr(g.nSubj, s)
+	decr(g.nPred, p)
+	decr(g.nObj, o)
+	g.release(s)
+	g.release(p)
+	g.release(o)
+	g.n--
+	return true
+}
+
+func decr(m map[int]int, id int) {
+	if m[id]--; m[id] == 0 {
+		delete(m, id)
+	}
+}
+
+// Has returns true if the Graph contains the triple.
+func (g *Graph) Has(t Triple) bool {
+	s, ok := g.lookup(t.Subj)
+	if !ok {
+		return false
+	}
+	p, ok := g.lookup(t.Pred)
+	if !ok {
+		return false
+	}
+	o, ok := g.lookup(t.Obj)
+	if !ok {
+		return false
+	}
+	_, ok = g.spo[s][p][o]
+	return ok
+}
+
+// Len returns the number of triples in the Graph.
+func (g *Graph) Len() int {
+	return g.n
+}
+
+// Clear removes all triples from the Graph.
+func (g *Graph) Clear() {
+	*g = *NewGraph()
+}
+
+// Triples returns all the triples in the Graph, in no particular order.
+func (g *Graph) Triples() []Triple {
+	return g.Match(nil, nil, nil)
+}
+
+// pattern resolves the ids of the given terms, where nil means any term.
+// It returns false if any of the terms are not in the Graph, in which case
+// nothing can match.
+func (g *Graph) pattern(s, p, o Term) (si, pi, oi int, ok bool) {
+	si, pi, oi = -1, -1, -1
+	if s != nil {
+		if si, ok = g.lookup(s); !ok {
+			return
+		}
+	}
+	if p != nil {
+		if pi, ok = g.lookup(p); !ok {
+			return
+		}
+	}
+	if o != nil {
+		if oi, ok = g.lookup(o); !ok {
+			return
+		}
+	}
+	return si, pi, oi, true
+}
+
+// Match returns the triples in the Graph which matches the given subject,
+// predicate and object, in no particular order. A nil value matches any term.
+func (g *Graph) Match(s Subject, p Predicate, o Object) []Triple {
+	var ts []Triple
+	g.match(s, p, o, func(si, pi, oi int) {
+		ts = append(ts, Triple{
+			Subj: g.terms[si].(Subject),
+			Pred: g.terms[pi].(Predicate),
+			Obj:  g.terms[oi].(Object),
+		})
+	})
+	return ts
+}
+
+// cursor returns the triples matching the pattern one at a time.
+func (g *Graph) cursor(s Subject, p Predicate, o Object) tripleCursor {
+	c := &graphCursor{g: g, depth: -1}
+	si, pi, oi, ok := g.pattern(s, p, o)
+	if !ok {
+		return c
+	}
+	// Pick the index in which the bound terms are the first keys.
+	switch {
+	case pi < 0 && oi >= 0:
+		c.idx, c.key, c.perm = g.osp, [3]int{oi, si, -1}, [3]int{1, 2, 0}
+	case si < 0 && pi >= 0:
+		c.idx, c.key, c.perm = g.pos, [3]int{pi, oi, -1}, [3]int{2, 0, 1}
+	default:
+		c.idx, c.key, c.perm = g.spo, [3]int{si, pi, oi}, [3]int{0, 1, 2}
+	}
+	for c.fixed < 3 && c.key[c.fixed] >= 0 {
+		c.fixed++
+	}
+	if c.fixed == 3 {
+		if _, ok := g.spo[si][pi][oi]; !ok {
+			return c
+		}
+	}
+	c.depth = c.fixed
+	return c
+}
+
+// graphCursor iterates over the triples of a Graph matching a pattern, by
+// walking an index where the bound terms are the first keys. The keys of a
+// level are collected when the level is reached, so that only one branch of
+// the index is held at a time. The Graph must not be modified meanwhile.
+type graphCursor struct {
+	g      *Graph
+	idx    index
+	perm   [3]int   // positions of the subject, predicate and object in key
+	key    [3]int   // keys of the current entry
+	fixed  int      // number of keys bound by the pattern
+	depth  int      // number of keys set; less than fixed when done
+	keys   [3][]int // keys of each level
+	pos    [3]int   // position in keys
+	loaded [3]bool  // whether keys is collected
+}
+
+func (c *graphCursor) next() (Triple, bool) {
+	for c.depth >= c.fixed {
+		if c.depth == 3 {
+			c.depth--
+			return Triple{
+				Subj: c.g.terms[c.key[c.perm[0]]].(Subject),
+				Pred: c.g.terms[c.key[c.perm[1]]].(Predicate),
+				Obj:  c.g.terms[c.key[c.perm[2]]].(Object),
+			}, true
+		}
+		l := c.depth
+		if !c.loaded[l] {
+			c.keys[l] = c.keys[l][:0]
+			switch l {
+			case 0:
+				for k := range c.idx {
+					c.keys[l] = append(c.keys[l], k)
+				}
+			case 1:
+				for k := range c.idx[c.key[0]] {
+					c.keys[l] = append(c.keys[l], k)
+				}
+			case 2:
+				for k := range c.idx[c.key[0]][c.key[1]] {
+					c.keys[l] = append(c.keys[l], k)
+				}
+			}
+			c.pos[l], c.loaded[l] = 0, true
+		}
+		if c.pos[l] == len(c.keys[l]) {
+			c.loaded[l] = false
+			c.depth--
+			continue
+		}
+		c.key[l] = c.keys[l][c.pos[l]]
+		c.pos[l]++
+		c.depth++
+	}
+	return Triple{}, false
+}
+
+// match calls fn with the ids of each triple matching the pattern.
+func (g *Graph) match(s Subject, p Predicate, o Object, fn func(s, p, o int)) {
+	si, pi, oi, ok := g.pattern(s, p, o)
+	if !ok {
+		return
+	}
+	switch {
+	case si >= 0 && pi >= 0 && oi >= 0:
+		if _, ok := g.spo[si][pi][oi]; ok {
+			fn(si, pi, oi)
+		}
+	case si >= 0 && pi >= 0:
+		for o := range g.spo[si][pi] {
+			fn(si, pi, o)
+		}
+	case si >= 0 && oi >= 0:
+		for p := range g.osp[oi][si] {
+			fn(si, p, oi)
+		}
+	case pi >= 0 && oi >= 0:
+		for s := range g.pos[pi][oi] {
+			fn(s, pi, oi)
+		}
+	case si >= 0:
+		for p, os := range g.spo[si] {
+			for o := range os {
+				fn(si, p, o)
+			}
+		}
+	case pi >= 0:
+		for o, ss := range g.pos[pi] {
+			for s := range ss {
+				fn(s, pi, o)
+			}
+		}
+	case oi >= 0:
+		for s, ps := range g.osp[oi] {
+			for p := range ps {
+				fn(s, p, oi)
+			}
+		}
+	default:
+		for s, pos := range g.spo {
+			for p, os := range pos {
+				for o := range os {
+					fn(s, p, o)
+				}
+			}
+		}
+	}
+}
+
+// Count returns the number of triples in the Graph matching the given subject,
+// predicate and object, where a nil value matches any term. It is cheaper than
+// counting the results of Match.
+func (g *Graph) Count(s Subject, p Predicate, o Object) int {
+	si, pi, oi, ok := g.pattern(s, p, o)
+	if !ok {
+		return 0
+	}
+	switch {
+	case si >= 0 && pi >= 0 && oi >= 0:
+		if _, ok := g.spo[si][pi][oi]; ok {
+			return 1
+		}
+		return 0
+	case si >= 0 && pi >= 0:
+		return len(g.spo[si][pi])
+	case si >= 0 && oi >= 0:
+		return len(g.osp[oi][si])
+	case pi >= 0 && oi >= 0:
+		return len(g.pos[pi][oi])
+	case si >= 0:
+		return g.nSubj[si]
+	case pi >= 0:
+		return g.nPred[pi]
+	case oi >= 0:
+		return g.nObj[oi]
+	}
+	return g.n
+}
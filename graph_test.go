@@ -0,0 +1,130 @@
+package rdf
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// sortedNT returns the triples in N-Triples format, sorted.
+func sortedNT(ts []Triple) []string {
+	var res []string
+	for _, t := range ts {
+		res = append(res, t.Serialize(NTriples))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestGraph(t *testing.T) {
+	a, b := IRI{str: "http://example/a"}, IRI{str: "http://example/b"}
+	p, q := IRI{str: "http://example/p"}, IRI{str: "http://example/q"}
+	one := Literal{str: "1", DataType: xsdInteger}
+	oneStr := Literal{str: "1", DataType: xsdString}
+
+	g := NewGraph(
+		Triple{Subj: a, Pred: p, Obj: b},
+		Triple{Subj: a, Pred: p, Obj: one},
+		Triple{Subj: a, Pred: q, Obj: oneStr},
+		Triple{Subj: b, Pred: p, Obj: one},
+	)
+	if g.Add(Triple{Subj: a, Pred: p, Obj: b}) {
+		t.Error("Add of existing triple returned true")
+	}
+	if g.Len() != 4 {
+		t.Fatalf("Len() => %d, want 4", g.Len())
+	}
+
+	tests := []struct {
+		s    Subject
+		p    Predicate
+		o    Object
+		want int
+	}{
+		{nil, nil, nil, 4},
+		{a, nil, nil, 3},
+		{nil, p, nil, 3},
+		{nil, nil, one, 2},
+		{nil, nil, oneStr, 1},
+		{a, p, nil, 2},
+		{a, nil, one, 1},
+		{nil, p, one, 2},
+		{a, p, one, 1},
+		{b, q, nil, 0},
+		{IRI{str: "http://example/c"}, nil, nil, 0},
+	}
+	for _, tt := range tests {
+		if n := len(g.Match(tt.s, tt.p, tt.o)); n != tt.want {
+			t.Errorf("Match(%v, %v, %v) => %d triples, want %d", tt.s, tt.p, tt.o, n, tt.want)
+		}
+		n := 0
+		for c := g.cursor(tt.s, tt.p, tt.o); ; n++ {
+			if _, ok := c.next(); !ok {
+				break
+			}
+		}
+		if n != tt.want {
+			t.Errorf("cursor(%v, %v, %v) => %d triples, want %d", tt.s, tt.p, tt.o, n, tt.want)
+		}
+		if n := g.Count(tt.s, tt.p, tt.o); n != tt.want {
+			t.Errorf("Count(%v, %v, %v) => %d, want %d", tt.s, tt.p, tt.o, n, tt.want)
+		}
+	}
+
+	if !g.Remove(Triple{Subj: a, Pred: p, Obj: one}) {
+		t.Error("Remove of existing triple returned false")
+	}
+	if g.Remove(Triple{Subj: a, Pred: p, Obj: one}) {
+		t.Error("Remove of removed triple returned true")
+	}
+	if g.Has(Triple{Subj: a, Pred: p, Obj: one}) {
+		t.Error("Has of removed triple returned true")
+	}
+	want := []string{
+		"<http://example/a> <http://example/p> <http://example/b> .\n",
+		"<http://example/a> <http://example/q> \"1\" .\n",
+		"<http://example/b> <http://example/p> \"1\"^^<http://www.w3.org/2001/XMLSchema#integer> .\n",
+	}
+	if got := sortedNT(g.Triples()); !equalStrings(got, want) {
+		t.Errorf("Triples() => %q, want %q", got, want)
+	}
+	if n := g.Count(nil, nil, one); n != 1 {
+		t.Errorf("Count(nil, nil, %v) after Remove => %d, want 1", one, n)
+	}
+
+	// Removing the triples frees the terms, which are reused as triples
+	// are added.
+	for i := 0; i < 100; i++ {
+		c := IRI{str: fmt.Sprintf("http://example/c%d", i)}
+		g.Add(Triple{Subj: c, Pred: p, Obj: c})
+		g.Add(Triple{Subj: c, Pred: q, Obj: a})
+		g.Remove(Triple{Subj: c, Pred: p, Obj: c})
+		if !g.Has(Triple{Subj: c, Pred: q, Obj: a}) {
+			t.Fatalf("Has of %v %v %v returned false", c, q, a)
+		}
+		g.Remove(Triple{Subj: c, Pred: q, Obj: a})
+	}
+	if len(g.ids) != 6 || len(g.terms) != 7 {
+		t.Errorf("term table has %d terms in %d slots after removals, want 6 in 7", len(g.ids), len(g.terms))
+	}
+	if got := sortedNT(g.Triples()); !equalStrings(got, want) {
+		t.Errorf("Triples() after removals => %q, want %q", got, want)
+	}
+
+	g.Clear()
+	if g.Len() != 0 || g.Has(Triple{Subj: a, Pred: p, Obj: b}) || len(g.Triples()) != 0 {
+		t.Errorf("Clear() left %d triples", g.Len())
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
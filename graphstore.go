@@ -0,0 +1,245 @@
+package rdf
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// graphStoreFormats are the formats served by GraphStore, by media type,
+// in order of preference.
+var graphStoreFormats = []struct {
+	mediaType string
+	format    Format
+}{
+	{"text/turtle", Turtle},
+	{"application/n-triples", NTriples},
+	{"text/plain", NTriples},
+}
+
+// GraphStore is an http.Handler implementing the SPARQL 1.1 Graph Store
+// HTTP Protocol over a Dataset.
+//
+// The graph of a request is given by the query string; "?default" for the
+// default graph, or "?graph=" followed by the IRI of a named graph. Without
+// either, the graph is identified directly, by the request URL.
+//
+// GET and HEAD return the graph, in the format negotiated by the Accept
+// header (Turtle or N-Triples). PUT replaces the graph with the request
+// body, and POST merges the request body into the graph, where the body
+// is in any format of NewTripleDecoder, according to the Content-Type
+// header. DELETE removes the graph. The request body is parsed completely
+// before the graph is modified, so a syntax error leaves it unchanged.
+//
+// Requests are serialized; the Dataset may not be modified by other means
+// while the GraphStore is in use.
+type GraphStore struct {
+	mu sync.RWMutex
+	ds *Dataset
+}
+
+// NewGraphStore returns a new GraphStore serving the given Dataset.
+func NewGraphStore(ds *Dataset) *GraphStore {
+	return &GraphStore{ds: ds}
+}
+
+// ServeHTTP serves a Graph Store Protocol request.
+func (gs *GraphStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, err := graphName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		gs.get(w, r, name)
+	case http.MethodPut, http.MethodPost:
+		gs.put(w, r, name)
+	case http.MethodDelete:
+		gs.mu.Lock()
+		defer gs.mu.Unlock()
+		switch {
+		case name == nil:
+			gs.ds.Default().Clear()
+		case !gs.ds.DropGraph(name):
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// graphName returns the name of the graph of the request, or nil for
+// the default graph.
+func graphName(r *http.Request) (Context, error) {
+	q := r.URL.Query()
+	_, def := q["default"]
+	graph, named := q["graph"]
+	switch {
+	case def && named:
+		return nil, fmt.Errorf("both default and graph given")
+	case def:
+		return nil, nil
+	case named:
+		if len(graph) != 1 {
+			return nil, fmt.Errorf("graph given more than once")
+		}
+		return NewIRI(graph[0])
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return NewIRI(scheme + "://" + r.Host + r.URL.Path)
+}
+
+// get serves the graph for GET and HEAD.
+func (gs *GraphStore) get(w http.ResponseWriter, r *http.Request, name Context) {
+	mt, f, ok := negotiateFormat(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, "no acceptable format", http.StatusNotAcceptable)
+		return
+	}
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	g := gs.ds.Default()
+	if name != nil {
+		if g = gs.ds.Graph(name); g == nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", mt+"; charset=utf-8")
+	w.Header().Set("Vary", "Accept")
+	if r.Method == http.MethodHead {
+		return
+	}
+	enc := NewTripleEncoder(w, f)
+	if err := enc.EncodeAll(g.Triples()); err != nil {
+		return
+	}
+	enc.Close()
+}
+
+// put serves PUT, which replaces the graph, and POST, which merges
+// into it.
+func (gs *GraphStore) put(w http.ResponseWriter, r *http.Request, name Context) {
+	f, err := FormatFromMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !decodesTriples(f) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	ts, err := NewTripleDecoder(r.Body, f).DecodeAll()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	g := gs.ds.Default()
+	created := false
+	if name != nil {
+		created = gs.ds.Graph(name) == nil
+		g = gs.ds.CreateGraph(name)
+	}
+	if r.Method == http.MethodPut {
+		g.Clear()
+	} else {
+		// Blank nodes of the request are distinct from those in the dataset.
+		e := newEvaluator(gs.ds)
+		bnodes := make(map[string]Blank)
+		for i, t := range ts {
+			ts[i].Subj = relabel(t.Subj, e, bnodes).(Subject)
+			ts[i].Obj = relabel(t.Obj, e, bnodes).(Object)
+		}
+	}
+	for _, t := range ts {
+		g.Add(t)
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// relabel returns a fresh blank node for a blank node, the same one for
+// each label, or the term itself for other terms.
+func relabel(t Term, e *evaluator, bnodes map[string]Blank) Term {
+	b, ok := t.(Blank)
+	if !ok {
+		return t
+	}
+	if fresh, ok := bnodes[b.id]; ok {
+		return fresh
+	}
+	fresh := e.freshBlank()
+	bnodes[b.id] = fresh
+	return fresh
+}
+
+// negotiateFormat returns the media type and format of graphStoreFormats
+// which is most acceptable according to the Accept header. The quality of
+// a format is given by the most specific media range matching it, and an
+// empty header accepts any format.
+func negotiateFormat(accept string) (string, Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+	type mediaRange struct {
+		mt string
+		q  float64
+	}
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mt, q})
+	}
+	best, bestQ := -1, 0.0
+	for i, f := range graphStoreFormats {
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if s := mediaRangeMatch(r.mt, f.mediaType); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return "", 0, false
+	}
+	return graphStoreFormats[best].mediaType, graphStoreFormats[best].format, true
+}
+
+// mediaRangeMatch returns how specifically the media range matches the
+// media type; 2 for the type itself, 1 for "type/*" and 0 for "*/*", or
+// -1 if it doesn't match.
+func mediaRangeMatch(rng, mt string) int {
+	switch {
+	case rng == mt:
+		return 2
+	case rng == "*/*":
+		return 0
+	case strings.HasSuffix(rng, "/*") && strings.HasPrefix(mt, rng[:len(rng)-1]):
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,95 @@
+package rdf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGraphStore(t *testing.T) {
+	ds := NewDataset()
+	srv := httptest.NewServer(NewGraphStore(ds))
+	defer srv.Close()
+	named := srv.URL + "/store?graph=http%3A%2F%2Fexample%2Fg"
+
+	tests := []struct {
+		method      string
+		url         string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		wantType    string
+		wantBody    string
+	}{
+		{"GET", named, "", "", "", 404, "", ""},
+		{"PUT", named, "text/turtle", "", `@prefix ex: <http://example/> . ex:a ex:p [ ex:q 1 ] .`, 201, "", ""},
+		{"PUT", named, "text/turtle", "", `<http://example/a> <http://example/p> _:x . _:x <http://example/q> 1 .`, 204, "", ""},
+		{"POST", named, "application/n-triples", "", "<http://example/a> <http://example/p> _:x .\n", 204, "", ""},
+		{"PUT", named, "text/turtle", "", `<http://example/a> <http://example/p> .`, 400, "", ""},
+		{"PUT", named, "application/json", "", `{}`, 415, "", ""},
+		{
+			"GET", named, "", "application/n-triples, text/turtle;q=0.5", "", 200,
+			"application/n-triples; charset=utf-8",
+			"<http://example/a> <http://example/p> _:g1 .\n" +
+				"<http://example/a> <http://example/p> _:x .\n" +
+				"_:x <http://example/q> \"1\"^^<http://www.w3.org/2001/XMLSchema#integer> .\n",
+		},
+		{"HEAD", named, "", "text/*", "", 200, "text/turtle; charset=utf-8", ""},
+		{"GET", named, "", "text/turtle;q=0, */*", "", 200, "application/n-triples; charset=utf-8", ""},
+		{"GET", named, "", "application/ld+json", "", 406, "", ""},
+		{"POST", srv.URL + "/store?default", "application/rdf+xml", "", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example/"><rdf:Description rdf:about="http://example/b"><ex:p>x</ex:p></rdf:Description></rdf:RDF>`, 204, "", ""},
+		{"GET", srv.URL + "/store?default", "", "text/plain", "", 200, "text/plain; charset=utf-8", "<http://example/b> <http://example/p> \"x\" .\n"},
+		{"PUT", srv.URL + "/graphs/direct", "text/plain", "", "<http://example/c> <http://example/p> <http://example/d> .\n", 201, "", ""},
+		{"DELETE", named, "", "", "", 204, "", ""},
+		{"DELETE", named, "", "", "", 404, "", ""},
+		{"PATCH", named, "", "", "", 405, "", ""},
+		{"GET", srv.URL + "/store?default&graph=http://example/g", "", "", "", 400, "", ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s => %d %s; want %d", tt.method, tt.url, resp.StatusCode, body, tt.status)
+			continue
+		}
+		if tt.wantType != "" && resp.Header.Get("Content-Type") != tt.wantType {
+			t.Errorf("%s %s => Content-Type %q; want %q", tt.method, tt.url, resp.Header.Get("Content-Type"), tt.wantType)
+		}
+		if tt.wantBody == "" {
+			continue
+		}
+		ts, err := NewTripleDecoder(strings.NewReader(string(body)), NTriples).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(sortedNT(ts), ""); got != tt.wantBody {
+			t.Errorf("%s %s => \n%s\nwant:\n%s", tt.method, tt.url, got, tt.wantBody)
+		}
+	}
+
+	direct, _ := NewIRI(srv.URL + "/graphs/direct")
+	if g := ds.Graph(direct); g == nil || g.Len() != 1 {
+		t.Errorf("directly identified graph %v not stored", direct)
+	}
+	if names := ds.Names(); len(names) != 1 {
+		t.Errorf("dataset graphs => %v; want only %v", names, direct)
+	}
+}
@@ -0,0 +1,447 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// HTMLNodeType is the type of a HTMLNode.
+type HTMLNodeType int
+
+// Types of HTML nodes.
+const (
+	HTMLFragment HTMLNodeType = iota // root of a document fragment
+	HTMLElement
+	HTMLText
+	HTMLComment
+)
+
+// HTMLNode is a node of a HTML document fragment, which is the value of a
+// rdf:HTML literal.
+type HTMLNode struct {
+	Type     HTMLNodeType
+	Data     string     // element name (in lower case), text or comment
+	Attr     []HTMLAttr // attributes of an element
+	Children []*HTMLNode
+}
+
+// HTMLAttr is an attribute of a HTML element.
+type HTMLAttr struct {
+	Name  string // in lower case
+	Value string
+}
+
+// String returns the canonical serialization of the node; elements with
+// implied end tags closed explicitly, attributes sorted by name and with
+// double-quoted values, and characters escaped as by the HTML fragment
+// serialization algorithm. It is the canonical form of rdf:HTML literals.
+func (n *HTMLNode) String() string {
+	var b bytes.Buffer
+	n.render(&b, false)
+	return b.String()
+}
+
+func (n *HTMLNode) render(b *bytes.Buffer, raw bool) {
+	switch n.Type {
+	case HTMLFragment:
+		for _, c := range n.Children {
+			c.render(b, false)
+		}
+	case HTMLElement:
+		b.WriteString("<" + n.Data)
+		attrs := append([]HTMLAttr(nil), n.Attr...)
+		sort.SliceStable(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+		for _, a := range attrs {
+			b.WriteString(" " + a.Name + `="`)
+			htmlEscape(b, a.Value, true)
+			b.WriteString(`"`)
+		}
+		b.WriteString(">")
+		if htmlVoid[n.Data] {
+			return
+		}
+		for _, c := range n.Children {
+			c.render(b, htmlRawText[n.Data])
+		}
+		b.WriteString("</" + n.Data + ">")
+	case HTMLText:
+		if raw {
+			b.WriteString(n.Data)
+		} else {
+			htmlEscape(b, n.Data, false)
+		}
+	case HTMLComment:
+		b.WriteString("<!--" + n.Data + "-->")
+	}
+}
+
+// htmlEscape writes the text escaped as character data, or as an attribute
+// value if attr is true.
+func htmlEscape(b *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '\u00a0':
+			b.WriteString("&nbsp;")
+		case r == '"' && attr:
+			b.WriteString("&quot;")
+		case r == '<' && !attr:
+			b.WriteString("&lt;")
+		case r == '>' && !attr:
+			b.WriteString("&gt;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+var (
+	// htmlVoid are the elements without content or end tag.
+	htmlVoid = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true,
+		"hr": true, "img": true, "input": true, "link": true, "meta": true,
+		"source": true, "track": true, "wbr": true,
+	}
+
+	// htmlRawText are the elements whose content is text, ending at the
+	// element's end tag, without character references.
+	htmlRawText = map[string]bool{"script": true, "style": true}
+
+	// htmlEscapableRawText are the elements whose content is text,
+	// ending at the element's end tag, with character references.
+	htmlEscapableRawText = map[string]bool{"textarea": true, "title": true}
+
+	// htmlClosesP are the elements whose start tag implies the end of an
+	// open p element.
+	htmlClosesP = map[string]bool{
+		"address": true, "article": true, "aside": true, "blockquote": true,
+		"details": true, "div": true, "dl": true, "fieldset": true,
+		"figcaption": true, "figure": true, "footer": true, "form": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"header": true, "hr": true, "main": true, "menu": true, "nav": true,
+		"ol": true, "p": true, "pre": true, "section": true, "table": true,
+		"ul": true,
+	}
+)
+
+// htmlParser parses HTML document fragments. It follows the HTML
+// tokenization rules for tags, comments, character references and raw text,
+// and a simplified form of tree construction, which handles void elements,
+// implied end tags, and end tags without a matching start tag. Any string
+// is a HTML document fragment.
+type htmlParser struct {
+	s    string
+	pos  int
+	open []*HTMLNode // stack of open elements, the fragment root first
+}
+
+// parseHTML parses a HTML document fragment.
+func parseHTML(s string) *HTMLNode {
+	root := &HTMLNode{Type: HTMLFragment}
+	p := htmlParser{s: s, open: []*HTMLNode{root}}
+	var text bytes.Buffer
+	for p.pos < len(p.s) {
+		rest := p.s[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			p.flushText(&text)
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				p.append(&HTMLNode{Type: HTMLComment, Data: rest[4:]})
+				p.pos = len(p.s)
+				continue
+			}
+			p.append(&HTMLNode{Type: HTMLComment, Data: rest[4 : 4+end]})
+			p.pos += 4 + end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			// Doctype declarations are ignored in fragments, while
+			// processing instructions are bogus comments.
+			p.flushText(&text)
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				end = len(rest)
+			}
+			if rest[1] == '?' {
+				p.append(&HTMLNode{Type: HTMLComment, Data: rest[1:end]})
+			}
+			if p.pos += end; p.pos < len(p.s) {
+				p.pos++
+			}
+		case strings.HasPrefix(rest, "</") && len(rest) > 2 && isAlpha(rune(rest[2])):
+			p.flushText(&text)
+			p.pos += 2
+			name := p.tagName()
+			p.attrs()
+			p.end(name)
+		case rest[0] == '<' && len(rest) > 1 && isAlpha(rune(rest[1])):
+			p.flushText(&text)
+			p.pos++
+			name := p.tagName()
+			attrs, ok := p.attrs()
+			if !ok {
+				// A tag ending the input is dropped.
+				continue
+			}
+			p.start(name, attrs)
+		default:
+			end := strings.IndexByte(rest[1:], '<')
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end++
+			}
+			text.WriteString(htmlUnescape(rest[:end]))
+			p.pos += end
+		}
+	}
+	p.flushText(&text)
+	return root
+}
+
+// append appends the node to the current element.
+func (p *htmlParser) append(n *HTMLNode) {
+	cur := p.open[len(p.open)-1]
+	cur.Children = append(cur.Children, n)
+}
+
+func (p *htmlParser) flushText(text *bytes.Buffer) {
+	if text.Len() == 0 {
+		return
+	}
+	cur := p.open[len(p.open)-1]
+	if last := len(cur.Children) - 1; last >= 0 && cur.Children[last].Type == HTMLText {
+		cur.Children[last].Data += text.String()
+	} else {
+		p.append(&HTMLNode{Type: HTMLText, Data: text.String()})
+	}
+	text.Reset()
+}
+
+// tagName returns the name of the tag at the current position.
+func (p *htmlParser) tagName() string {
+	start := p.pos
+	for p.pos < len(p.s) && !isHTMLSpace(p.s[p.pos]) && p.s[p.pos] != '/' && p.s[p.pos] != '>' {
+		p.pos++
+	}
+	return strings.ToLower(p.s[start:p.pos])
+}
+
+// attrs returns the attributes of the tag at the current position, and
+// consumes the end of the tag. It returns false if the input ends first.
+func (p *htmlParser) attrs() ([]HTMLAttr, bool) {
+	var attrs []HTMLAttr
+	seen := make(map[string]bool)
+	for {
+		for p.pos < len(p.s) && (isHTMLSpace(p.s[p.pos]) || p.s[p.pos] == '/') {
+			p.pos++
+		}
+		if p.pos == len(p.s) {
+			return nil, false
+		}
+		if p.s[p.pos] == '>' {
+			p.pos++
+			return attrs, true
+		}
+		start := p.pos
+		for p.pos++; p.pos < len(p.s); p.pos++ {
+			if c := p.s[p.pos]; isHTMLSpace(c) || c == '/' || c == '>' || c == '=' {
+				break
+			}
+		}
+		a := HTMLAttr{Name: strings.ToLower(p.s[start:p.pos])}
+		for p.pos < len(p.s) && isHTMLSpace(p.s[p.pos]) {
+			p.pos++
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == '=' {
+			p.pos++
+			for p.pos < len(p.s) && isHTMLSpace(p.s[p.pos]) {
+				p.pos++
+			}
+			if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+				q := p.s[p.pos]
+				end := strings.IndexByte(p.s[p.pos+1:], q)
+				if end < 0 {
+					return nil, false
+				}
+				a.Value = htmlUnescape(p.s[p.pos+1 : p.pos+1+end])
+				p.pos += end + 2
+			} else {
+				start := p.pos
+				for p.pos < len(p.s) && !isHTMLSpace(p.s[p.pos]) && p.s[p.pos] != '>' {
+					p.pos++
+				}
+				a.Value = htmlUnescape(p.s[start:p.pos])
+			}
+		}
+		if !seen[a.Name] {
+			seen[a.Name] = true
+			attrs = append(attrs, a)
+		}
+	}
+}
+
+// start handles a start tag.
+func (p *htmlParser) start(name string, attrs []HTMLAttr) {
+	switch name {
+	case "li":
+		p.closeImplied([]string{"li"}, "ul", "ol")
+	case "dt", "dd":
+		p.closeImplied([]string{"dt", "dd"}, "dl")
+	case "tr":
+		p.closeImplied([]string{"tr", "td", "th"}, "table", "thead", "tbody", "tfoot")
+	case "td", "th":
+		p.closeImplied([]string{"td", "th"}, "tr", "table")
+	case "option":
+		p.closeImplied([]string{"option"}, "select")
+	}
+	if htmlClosesP[name] {
+		p.closeImplied([]string{"p"}, "button", "table", "td", "th")
+	}
+
+	n := &HTMLNode{Type: HTMLElement, Data: name, Attr: attrs}
+	p.append(n)
+	switch {
+	case htmlVoid[name]:
+	case htmlRawText[name], htmlEscapableRawText[name]:
+		end := p.rawTextEnd(name)
+		if text := p.s[p.pos:end]; text != "" {
+			if htmlEscapableRawText[name] {
+				text = htmlUnescape(text)
+			}
+			n.Children = append(n.Children, &HTMLNode{Type: HTMLText, Data: text})
+		}
+		p.pos = end
+		if p.pos < len(p.s) {
+			p.pos += 2
+			p.tagName()
+			p.attrs()
+		}
+	default:
+		p.open = append(p.open, n)
+	}
+}
+
+// rawTextEnd returns the position of the end tag of the raw text element,
+// or the end of the input.
+func (p *htmlParser) rawTextEnd(name string) int {
+	for i := p.pos; ; i++ {
+		j := strings.Index(p.s[i:], "</")
+		if j < 0 {
+			return len(p.s)
+		}
+		i += j
+		if end := i + 2 + len(name); end <= len(p.s) && strings.EqualFold(p.s[i+2:end], name) &&
+			(end == len(p.s) || isHTMLSpace(p.s[end]) || p.s[end] == '/' || p.s[end] == '>') {
+			return i
+		}
+	}
+}
+
+// end handles an end tag, closing the innermost open element with the
+// name; end tags without an open element are ignored.
+func (p *htmlParser) end(name string) {
+	for i := len(p.open) - 1; i > 0; i-- {
+		if p.open[i].Data == name {
+			p.open = p.open[:i]
+			return
+		}
+	}
+}
+
+// closeImplied closes the outermost open element with one of the names,
+// which is inside the innermost open element with one of the scope names.
+func (p *htmlParser) closeImplied(names []string, scope ...string) {
+	found := -1
+search:
+	for i := len(p.open) - 1; i > 0; i-- {
+		for _, s := range scope {
+			if p.open[i].Data == s {
+				break search
+			}
+		}
+		for _, n := range names {
+			if p.open[i].Data == n {
+				found = i
+			}
+		}
+	}
+	if found > 0 {
+		p.open = p.open[:found]
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// htmlUnescape replaces the character references in s. Named references
+// must end with a semicolon, and unknown references are left as they are.
+func htmlUnescape(s string) string {
+	if !strings.Contains(s, "&") {
+		return s
+	}
+	var b bytes.Buffer
+	for {
+		i := strings.IndexByte(s, '&')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+		end := strings.IndexByte(s, ';')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		ref := s[1:end]
+		if r, ok := htmlCharRef(ref); ok {
+			b.WriteString(r)
+			s = s[end+1:]
+		} else {
+			b.WriteByte('&')
+			s = s[1:]
+		}
+	}
+}
+
+// htmlCharRef returns the replacement of the character reference, without
+// the leading '&' and trailing ';'.
+func htmlCharRef(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "#") {
+		var n uint64
+		var err error
+		if len(ref) > 1 && (ref[1] == 'x' || ref[1] == 'X') {
+			n, err = strconv.ParseUint(ref[2:], 16, 32)
+		} else {
+			n, err = strconv.ParseUint(ref[1:], 10, 32)
+		}
+		if err != nil {
+			return "", false
+		}
+		r := rune(n)
+		if r == 0 || !utf8.ValidRune(r) {
+			r = utf8.RuneError
+		}
+		return string(r), true
+	}
+	switch ref {
+	case "amp":
+		return "&", true
+	case "lt":
+		return "<", true
+	case "gt":
+		return ">", true
+	case "quot":
+		return `"`, true
+	case "apos":
+		return "'", true
+	}
+	s, ok := xml.HTMLEntity[ref]
+	return s, ok
+}
@@ -0,0 +1,73 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, ``},
+		{`plain text`, `plain text`},
+		{`a < b &amp; c &lt; d &unknown; &#65;&#x42;&eacute;`, `a &lt; b &amp; c &lt; d &amp;unknown; ABé`},
+		{`<P CLASS=x id='y' hidden>a&nbsp;b</p>`, `<p class="x" hidden="" id="y">a&nbsp;b</p>`},
+		{`<p title="a &quot;b&quot; &amp; c">x`, `<p title="a &quot;b&quot; &amp; c">x</p>`},
+		{`<br/><img src="a.png"></img>x`, `<br><img src="a.png">x`},
+		{`<ul><li>a<li>b<ul><li>c<li>d</ul><li>e</ul>`, `<ul><li>a</li><li>b<ul><li>c</li><li>d</li></ul></li><li>e</li></ul>`},
+		{`<p>a<div>b</div>`, `<p>a</p><div>b</div>`},
+		{`<table><tr><td>1<td>2<tr><td>3</table>`, `<table><tr><td>1</td><td>2</td></tr><tr><td>3</td></tr></table>`},
+		{`<b>a<i>b</b>c</i>`, `<b>a<i>b</i></b>c`},
+		{`</span>x`, `x`},
+		{`<script>if (a<b && c) x("</p>")</script>`, `<script>if (a<b && c) x("</p>")</script>`},
+		{`<title>a &amp; <b></title>`, `<title>a &amp; &lt;b&gt;</title>`},
+		{`<!DOCTYPE html><!-- c --><?pi x?>`, `<!-- c --><!--?pi x?-->`},
+		{`<a href="x"`, ``},
+	}
+	for _, tt := range tests {
+		got := parseHTML(tt.in).String()
+		if got != tt.want {
+			t.Errorf("parseHTML(%q) =>\n%s\nwant:\n%s", tt.in, got, tt.want)
+		}
+		if again := parseHTML(got).String(); again != got {
+			t.Errorf("parseHTML(%q) not idempotent: %s", got, again)
+		}
+	}
+}
+
+func TestHTMLLiteral(t *testing.T) {
+	v, err := NewTypedLiteral(`<p class="a">x<em>y</em></p>`, rdfHTML).Typed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := v.(*HTMLNode)
+	if !ok || n.Type != HTMLFragment || len(n.Children) != 1 {
+		t.Fatalf("Typed() => %#v; want HTML fragment", v)
+	}
+	p := n.Children[0]
+	if p.Type != HTMLElement || p.Data != "p" || len(p.Attr) != 1 || p.Attr[0] != (HTMLAttr{"class", "a"}) ||
+		len(p.Children) != 2 || p.Children[0].Type != HTMLText || p.Children[1].Data != "em" {
+		t.Errorf("Typed() => unexpected tree for %s", n)
+	}
+
+	// RDF/XML accepts markup content for rdf:HTML property elements.
+	doc := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example/">
+  <rdf:Description rdf:about="http://example/s">
+    <ex:p rdf:datatype="http://www.w3.org/1999/02/22-rdf-syntax-ns#HTML"><p id="b" class="a">x &lt; y<br/></p></ex:p>
+    <ex:q>z</ex:q>
+  </rdf:Description>
+</rdf:RDF>`
+	ts, err := NewTripleDecoder(strings.NewReader(doc), RDFXML).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`<http://example/s> <http://example/p> "<p class=\"a\" id=\"b\">x &lt; y<br></p>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#HTML> .` + "\n",
+		`<http://example/s> <http://example/q> "z" .` + "\n",
+	}
+	if got := sortedNT(ts); !equalStrings(got, want) {
+		t.Errorf("decoding rdf:HTML property =>\n%q\nwant:\n%q", got, want)
+	}
+}
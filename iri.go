@@ -0,0 +1,153 @@
+package rdf
+
+import "strings"
+
+// iriRef is an IRI reference split into its components. The authority,
+// query and fragment may be defined but empty, as in "http:///x?#".
+type iriRef struct {
+	scheme, authority, path, query, fragment string
+
+	hasAuthority, hasQuery, hasFragment bool
+}
+
+// parseIRIRef splits an IRI reference into its components.
+// See https://www.rfc-editor.org/rfc/rfc3986#appendix-B
+func parseIRIRef(s string) iriRef {
+	var r iriRef
+	if i := strings.IndexAny(s, ":/?#"); i > 0 && s[i] == ':' && isScheme(s[:i]) {
+		r.scheme, s = s[:i], s[i+1:]
+	}
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		r.fragment, r.hasFragment, s = s[i+1:], true, s[:i]
+	}
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		r.query, r.hasQuery, s = s[i+1:], true, s[:i]
+	}
+	if strings.HasPrefix(s, "//") {
+		s = s[2:]
+		i := strings.IndexByte(s, '/')
+		if i < 0 {
+			i = len(s)
+		}
+		r.authority, r.hasAuthority, s = s[:i], true, s[i:]
+	}
+	r.path = s
+	return r
+}
+
+func isScheme(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (r iriRef) String() string {
+	var b strings.Builder
+	if r.scheme != "" {
+		b.WriteString(r.scheme + ":")
+	}
+	if r.hasAuthority {
+		b.WriteString("//" + r.authority)
+	}
+	b.WriteString(r.path)
+	if r.hasQuery {
+		b.WriteString("?" + r.query)
+	}
+	if r.hasFragment {
+		b.WriteString("#" + r.fragment)
+	}
+	return b.String()
+}
+
+// resolveIRI resolves an IRI reference against a base IRI, as specified
+// by RFC 3986. If the base IRI is empty, the reference is returned as is.
+// See https://www.rfc-editor.org/rfc/rfc3986#section-5.2
+func resolveIRI(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	b, r := parseIRIRef(base), parseIRIRef(ref)
+	var t iriRef
+	switch {
+	case r.scheme != "":
+		t = r
+		t.path = removeDotSegments(r.path)
+	case r.hasAuthority:
+		t = r
+		t.scheme = b.scheme
+		t.path = removeDotSegments(r.path)
+	default:
+		t.scheme = b.scheme
+		t.authority, t.hasAuthority = b.authority, b.hasAuthority
+		switch {
+		case r.path == "":
+			t.path = b.path
+			t.query, t.hasQuery = b.query, b.hasQuery
+			if r.hasQuery {
+				t.query, t.hasQuery = r.query, true
+			}
+		case strings.HasPrefix(r.path, "/"):
+			t.path = removeDotSegments(r.path)
+			t.query, t.hasQuery = r.query, r.hasQuery
+		default:
+			// Merge the paths.
+			if b.hasAuthority && b.path == "" {
+				t.path = "/" + r.path
+			} else {
+				t.path = b.path[:strings.LastIndexByte(b.path, '/')+1] + r.path
+			}
+			t.path = removeDotSegments(t.path)
+			t.query, t.hasQuery = r.query, r.hasQuery
+		}
+		t.fragment, t.hasFragment = r.fragment, r.hasFragment
+	}
+	return t.String()
+}
+
+// removeDotSegments removes the "." and ".." segments of a path.
+// See https://www.rfc-editor.org/rfc/rfc3986#section-5.2.4
+func removeDotSegments(in string) string {
+	var out []string
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			// Move the first segment, with its leading slash if any,
+			// to the output.
+			i := strings.IndexByte(in[1:], '/')
+			if i < 0 {
+				i = len(in)
+			} else {
+				i++
+			}
+			out = append(out, in[:i])
+			in = in[i:]
+		}
+	}
+	return strings.Join(out, "")
+}
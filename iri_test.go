@@ -0,0 +1,70 @@
+package rdf
+
+import "testing"
+
+func TestResolveIRI(t *testing.T) {
+	// The examples of https://www.rfc-editor.org/rfc/rfc3986#section-5.4
+	const base = "http://a/b/c/d;p?q"
+	tests := []struct {
+		ref, want string
+	}{
+		{"g:h", "g:h"},
+		{"g", "http://a/b/c/g"},
+		{"./g", "http://a/b/c/g"},
+		{"g/", "http://a/b/c/g/"},
+		{"/g", "http://a/g"},
+		{"//g", "http://g"},
+		{"?y", "http://a/b/c/d;p?y"},
+		{"g?y", "http://a/b/c/g?y"},
+		{"#s", "http://a/b/c/d;p?q#s"},
+		{"g#s", "http://a/b/c/g#s"},
+		{"g?y#s", "http://a/b/c/g?y#s"},
+		{";x", "http://a/b/c/;x"},
+		{"g;x", "http://a/b/c/g;x"},
+		{"g;x?y#s", "http://a/b/c/g;x?y#s"},
+		{"", "http://a/b/c/d;p?q"},
+		{".", "http://a/b/c/"},
+		{"./", "http://a/b/c/"},
+		{"..", "http://a/b/"},
+		{"../", "http://a/b/"},
+		{"../g", "http://a/b/g"},
+		{"../..", "http://a/"},
+		{"../../", "http://a/"},
+		{"../../g", "http://a/g"},
+		{"../../../g", "http://a/g"},
+		{"../../../../g", "http://a/g"},
+		{"/./g", "http://a/g"},
+		{"/../g", "http://a/g"},
+		{"g.", "http://a/b/c/g."},
+		{".g", "http://a/b/c/.g"},
+		{"g..", "http://a/b/c/g.."},
+		{"..g", "http://a/b/c/..g"},
+		{"./../g", "http://a/b/g"},
+		{"./g/.", "http://a/b/c/g/"},
+		{"g/./h", "http://a/b/c/g/h"},
+		{"g/../h", "http://a/b/c/h"},
+		{"g;x=1/./y", "http://a/b/c/g;x=1/y"},
+		{"g;x=1/../y", "http://a/b/c/y"},
+		{"g?y/./x", "http://a/b/c/g?y/./x"},
+		{"g?y/../x", "http://a/b/c/g?y/../x"},
+		{"g#s/./x", "http://a/b/c/g#s/./x"},
+		{"g#s/../x", "http://a/b/c/g#s/../x"},
+		{"http:g", "http:g"},
+	}
+	for _, test := range tests {
+		if got := resolveIRI(base, test.ref); got != test.want {
+			t.Errorf("resolveIRI(%q, %q) => %q; want %q", base, test.ref, got, test.want)
+		}
+	}
+
+	for _, test := range []struct{ base, ref, want string }{
+		{"", "a/b", "a/b"},
+		{"http://example", "a", "http://example/a"},
+		{"http://example/ä/", "ö#x", "http://example/ä/ö#x"},
+		{"urn:x:y", "#f", "urn:x:y#f"},
+	} {
+		if got := resolveIRI(test.base, test.ref); got != test.want {
+			t.Errorf("resolveIRI(%q, %q) => %q; want %q", test.base, test.ref, got, test.want)
+		}
+	}
+}
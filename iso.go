@@ -0,0 +1,255 @@
+package rdf
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Isomorphic reports whether the two sets of triples are isomorphic; that is,
+// equal up to a bijective renaming of their blank nodes, including blank
+// nodes in triple terms. Duplicate triples are ignored.
+//
+// Blank nodes are first partitioned by iteratively hashing the triples they
+// appear in, and the remaining candidate mappings between blank nodes with
+// equal hashes are then searched, backtracking as soon as a triple can't be
+// mapped. See https://www.w3.org/TR/rdf11-concepts/#graph-isomorphism
+func Isomorphic(a, b []Triple) bool {
+	groundA, restA := splitGround(a)
+	groundB, restB := splitGround(b)
+	if len(groundA) != len(groundB) || len(restA) != len(restB) {
+		return false
+	}
+	for k := range groundA {
+		if !groundB[k] {
+			return false
+		}
+	}
+	if len(restA) == 0 {
+		return true
+	}
+
+	hashA, hashB := blankHashes(restA), blankHashes(restB)
+	if len(hashA) != len(hashB) {
+		return false
+	}
+	cands := make(map[string][]string)
+	for id, h := range hashB {
+		cands[h] = append(cands[h], id)
+	}
+	// The classes of blank nodes with equal hashes must have the same sizes.
+	sizes := make(map[string]int)
+	for _, h := range hashA {
+		sizes[h]++
+	}
+	for h, n := range sizes {
+		if n != len(cands[h]) {
+			return false
+		}
+	}
+
+	m := isoMatcher{
+		hash:    hashA,
+		cands:   cands,
+		mapping: make(map[string]string),
+		used:    make(map[string]bool),
+		triples: make(map[string][]Triple),
+		want:    make(map[string]bool),
+	}
+	for _, t := range restB {
+		m.want[isoKey(t, func(id string) string { return id })] = true
+	}
+	for _, t := range restA {
+		for _, id := range tripleBlanks(t) {
+			m.triples[id] = append(m.triples[id], t)
+		}
+	}
+	for id := range hashA {
+		m.order = append(m.order, id)
+	}
+	// Map the blank nodes in the smallest classes first.
+	sort.Slice(m.order, func(i, j int) bool {
+		x, y := m.order[i], m.order[j]
+		if nx, ny := len(cands[hashA[x]]), len(cands[hashA[y]]); nx != ny {
+			return nx < ny
+		}
+		if hashA[x] != hashA[y] {
+			return hashA[x] < hashA[y]
+		}
+		return x < y
+	})
+	return m.search(0)
+}
+
+// splitGround returns the distinct triples without blank nodes as a set of
+// keys, and the distinct triples with blank nodes.
+func splitGround(ts []Triple) (map[string]bool, []Triple) {
+	ground := make(map[string]bool)
+	seen := make(map[string]bool)
+	var rest []Triple
+	for _, t := range ts {
+		k := isoKey(t, func(id string) string { return id })
+		if len(tripleBlanks(t)) == 0 {
+			ground[k] = true
+			continue
+		}
+		if !seen[k] {
+			seen[k] = true
+			rest = append(rest, t)
+		}
+	}
+	return ground, rest
+}
+
+// tripleBlanks returns the distinct blank node ids of the triple.
+func tripleBlanks(t Triple) []string {
+	var ids []string
+	var walk func(Term)
+	walk = func(term Term) {
+		switch term := term.(type) {
+		case Blank:
+			for _, id := range ids {
+				if id == term.id {
+					return
+				}
+			}
+			ids = append(ids, term.id)
+		case TripleTerm:
+			walk(term.Subj)
+			walk(term.Pred)
+			walk(term.Obj)
+		}
+	}
+	walk(t.Subj)
+	walk(t.Pred)
+	walk(t.Obj)
+	return ids
+}
+
+// isoKey returns a key of the triple, where blank nodes are replaced by the
+// given labels.
+func isoKey(t Triple, label func(id string) string) string {
+	var b strings.Builder
+	var write func(Term)
+	write = func(term Term) {
+		switch term := term.(type) {
+		case Blank:
+			b.WriteString(label(term.id))
+		case TripleTerm:
+			b.WriteString("<<( ")
+			write(term.Subj)
+			b.WriteByte(' ')
+			write(term.Pred)
+			b.WriteByte(' ')
+			write(term.Obj)
+			b.WriteString(" )>>")
+		default:
+			b.WriteString(termKey(term))
+		}
+	}
+	write(t.Subj)
+	b.WriteByte(' ')
+	write(t.Pred)
+	b.WriteByte(' ')
+	write(t.Obj)
+	return b.String()
+}
+
+// blankHashes returns a hash of each blank node in the triples, which only
+// depends on the structure of the graph around it, and not on its id. The
+// hashes are refined until they no longer split the blank nodes into more
+// classes.
+func blankHashes(ts []Triple) map[string]string {
+	hash := make(map[string]string)
+	for _, t := range ts {
+		for _, id := range tripleBlanks(t) {
+			hash[id] = ""
+		}
+	}
+	for classes, round := 1, 0; round < len(hash); round++ {
+		sigs := make(map[string][]string, len(hash))
+		for _, t := range ts {
+			for _, id := range tripleBlanks(t) {
+				self := id
+				sigs[id] = append(sigs[id], isoKey(t, func(id string) string {
+					if id == self {
+						return "@"
+					}
+					return "#" + hash[id]
+				}))
+			}
+		}
+		next := make(map[string]string, len(hash))
+		distinct := make(map[string]bool)
+		for id, s := range sigs {
+			sort.Strings(s)
+			h := fnv.New64a()
+			h.Write([]byte(hash[id]))
+			for _, sig := range s {
+				h.Write([]byte{0})
+				h.Write([]byte(sig))
+			}
+			next[id] = fmt.Sprintf("%016x", h.Sum64())
+			distinct[next[id]] = true
+		}
+		hash = next
+		if len(distinct) == classes {
+			break
+		}
+		classes = len(distinct)
+	}
+	return hash
+}
+
+// isoMatcher searches for a bijection between the blank nodes of two graphs,
+// which maps every triple of the first to a triple of the second.
+type isoMatcher struct {
+	order   []string            // blank nodes of the first graph, in search order
+	hash    map[string]string   // blank node -> hash, of the first graph
+	cands   map[string][]string // hash -> blank nodes of the second graph
+	mapping map[string]string   // blank node of the first graph -> of the second
+	used    map[string]bool     // blank nodes of the second graph in mapping
+	triples map[string][]Triple // blank node -> triples of the first graph
+	want    map[string]bool     // triples of the second graph, by isoKey
+}
+
+func (m *isoMatcher) search(i int) bool {
+	if i == len(m.order) {
+		// Every triple is mapped to a distinct triple of the second
+		// graph, which has as many triples.
+		return true
+	}
+	x := m.order[i]
+	for _, y := range m.cands[m.hash[x]] {
+		if m.used[y] {
+			continue
+		}
+		m.mapping[x], m.used[y] = y, true
+		if m.consistent(x) && m.search(i+1) {
+			return true
+		}
+		delete(m.mapping, x)
+		m.used[y] = false
+	}
+	return false
+}
+
+// consistent reports whether the triples of the blank node, which have all
+// their blank nodes mapped, are mapped to triples of the second graph.
+func (m *isoMatcher) consistent(x string) bool {
+	for _, t := range m.triples[x] {
+		complete := true
+		k := isoKey(t, func(id string) string {
+			y, ok := m.mapping[id]
+			if !ok {
+				complete = false
+			}
+			return y
+		})
+		if complete && !m.want[k] {
+			return false
+		}
+	}
+	return true
+}
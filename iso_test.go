@@ -0,0 +1,83 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsomorphic(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{
+			"<http://example/s> <http://example/p> <http://example/o> .",
+			"<http://example/s> <http://example/p> <http://example/o> .\n<http://example/s> <http://example/p> <http://example/o> .",
+			true,
+		},
+		{
+			"<http://example/s> <http://example/p> <http://example/o> .",
+			"<http://example/s> <http://example/p> <http://example/o2> .",
+			false,
+		},
+		{
+			"_:a <http://example/p> _:b .\n_:b <http://example/q> \"x\" .",
+			"_:x <http://example/p> _:y .\n_:y <http://example/q> \"x\" .",
+			true,
+		},
+		{
+			"_:a <http://example/p> _:b .\n_:b <http://example/q> \"x\" .",
+			"_:x <http://example/p> _:y .\n_:x <http://example/q> \"x\" .",
+			false,
+		},
+		{
+			"_:a <http://example/p> _:a .",
+			"_:a <http://example/p> _:b .",
+			false,
+		},
+		{
+			// A cycle of six blank nodes, and two cycles of three, which
+			// can't be told apart by the hashes alone.
+			"_:a <http://example/p> _:b .\n_:b <http://example/p> _:c .\n_:c <http://example/p> _:d .\n_:d <http://example/p> _:e .\n_:e <http://example/p> _:f .\n_:f <http://example/p> _:a .",
+			"_:a <http://example/p> _:b .\n_:b <http://example/p> _:c .\n_:c <http://example/p> _:a .\n_:d <http://example/p> _:e .\n_:e <http://example/p> _:f .\n_:f <http://example/p> _:d .",
+			false,
+		},
+		{
+			"_:a <http://example/p> _:b .\n_:b <http://example/p> _:c .\n_:c <http://example/p> _:d .\n_:d <http://example/p> _:e .\n_:e <http://example/p> _:f .\n_:f <http://example/p> _:a .",
+			"_:f <http://example/p> _:e .\n_:e <http://example/p> _:d .\n_:d <http://example/p> _:c .\n_:c <http://example/p> _:b .\n_:b <http://example/p> _:a .\n_:a <http://example/p> _:f .",
+			true,
+		},
+		{
+			"_:a <http://example/p> _:b .\n_:a <http://example/p> _:c .\n_:b <http://example/q> \"1\" .\n_:c <http://example/q> \"2\" .",
+			"_:x <http://example/p> _:y .\n_:x <http://example/p> _:z .\n_:z <http://example/q> \"1\" .\n_:y <http://example/q> \"2\" .",
+			true,
+		},
+		{
+			"<http://example/s> <http://example/p> <<( _:a <http://example/q> _:b )>> .\n_:a <http://example/r> \"x\" .",
+			"<http://example/s> <http://example/p> <<( _:y <http://example/q> _:z )>> .\n_:y <http://example/r> \"x\" .",
+			true,
+		},
+		{
+			"<http://example/s> <http://example/p> <<( _:a <http://example/q> _:b )>> .\n_:a <http://example/r> \"x\" .",
+			"<http://example/s> <http://example/p> <<( _:y <http://example/q> _:z )>> .\n_:z <http://example/r> \"x\" .",
+			false,
+		},
+	}
+	decode := func(s string) []Triple {
+		ts, err := NewTripleDecoder(strings.NewReader(s), NTriples).DecodeAll()
+		if err != nil {
+			t.Fatalf("decoding %q: %v", s, err)
+		}
+		return ts
+	}
+	for _, test := range tests {
+		a, b := decode(test.a), decode(test.b)
+		if got := Isomorphic(a, b); got != test.want {
+			t.Errorf("Isomorphic(\n%s\n,\n%s\n) => %v; want %v", test.a, test.b, got, test.want)
+		}
+		if got := Isomorphic(b, a); got != test.want {
+			t.Errorf("Isomorphic(\n%s\n,\n%s\n) => %v; want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
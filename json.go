@@ -0,0 +1,142 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// canonicalJSON returns the JSON Canonicalization Scheme (JCS) form of the
+// JSON text, which is the canonical form of rdf:JSON literals; without
+// whitespace, with object members sorted by name, and with numbers and
+// strings serialized as by ECMAScript.
+//
+// See https://www.rfc-editor.org/rfc/rfc8785
+func canonicalJSON(s string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return "", errors.New("invalid JSON: data after top-level value")
+	}
+	var b bytes.Buffer
+	if err := writeCanonicalJSON(&b, v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeCanonicalJSON(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("invalid JSON number: %s", v)
+		}
+		b.WriteString(jsonNumber(f))
+	case string:
+		jsonQuote(b, v)
+	case []interface{}:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Members are sorted by the UTF-16 code units of their names.
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := utf16.Encode([]rune(keys[i])), utf16.Encode([]rune(keys[j]))
+			for k := 0; k < len(a) && k < len(b); k++ {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return len(a) < len(b)
+		})
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			jsonQuote(b, k)
+			b.WriteByte(':')
+			if err := writeCanonicalJSON(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	}
+	return nil
+}
+
+// jsonNumber formats the number as ECMAScript's Number.prototype.toString;
+// in decimal notation from 1e-6 up to 1e21, and otherwise in exponential
+// notation, with the fewest digits which represent the number exactly.
+func jsonNumber(f float64) string {
+	if f == 0 {
+		return "0" // also for -0
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	mant, exp := s[:i], s[i+1:]
+	sign := exp[0]
+	exp = strings.TrimLeft(exp[1:], "0")
+	return mant + "e" + string(sign) + exp
+}
+
+// jsonQuote writes the string as a JSON string, escaping only the
+// characters which must be escaped.
+func jsonQuote(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
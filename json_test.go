@@ -0,0 +1,58 @@
+package rdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{` null `, `null`},
+		{`[ true,false ]`, `[true,false]`},
+		{`{"b": 1, "a": {"d": [], "c": {}}}`, `{"a":{"c":{},"d":[]},"b":1}`},
+		{"{\"\u20ac\": 1, \"\U0001f600\": 2, \"\ufb33\": 3, \"z\": 4}", "{\"z\":4,\"\u20ac\":1,\"\U0001f600\":2,\"\ufb33\":3}"},
+		{`[1.0, -0, 1e2, 0.000001, 1e-7, 123e17, 1e21, -1.5E+30, 0.1]`, `[1,0,100,0.000001,1e-7,12300000000000000000,1e+21,-1.5e+30,0.1]`},
+		{`"a\"\\\/\b\f\n\r\t\u0001é<>&"`, `"a\"\\/\b\f\n\r\t\u0001é<>&"`},
+	}
+	for _, tt := range tests {
+		got, err := canonicalJSON(tt.in)
+		if err != nil {
+			t.Errorf("canonicalJSON(%s) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("canonicalJSON(%s) => %s; want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{``, `{`, `[1] [2]`, `{"a" 1}`, `1e400`} {
+		if got, err := canonicalJSON(in); err == nil {
+			t.Errorf("canonicalJSON(%s) => %s; want error", in, got)
+		}
+	}
+}
+
+func TestJSONLiteral(t *testing.T) {
+	v, err := NewTypedLiteral(`{"a": [1, "x", null]}`, rdfJSON).Typed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a": []interface{}{1.0, "x", nil}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Typed() => %#v; want %#v", v, want)
+	}
+	if _, err := NewTypedLiteral(`{"a":`, rdfJSON).Typed(); err == nil {
+		t.Error("Typed() of invalid rdf:JSON => <no error>; want error")
+	}
+
+	l := NewTypedLiteral(`{ "b": 2, "a": 1 }`, rdfJSON)
+	if got, want := l.Serialize(NTriples), `"{\"a\":1,\"b\":2}"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON>`; got != want {
+		t.Errorf("Serialize(NTriples) => %s; want %s", got, want)
+	}
+	if err := validateLexical(`[1,`, rdfJSON); err == nil {
+		t.Error("validateLexical of invalid rdf:JSON => <no error>; want error")
+	}
+}
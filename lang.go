@@ -0,0 +1,195 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Language tags are checked for well-formedness according to BCP 47:
+// http://tools.ietf.org/html/bcp47 [section 2.1]
+//
+//  langtag       = language ["-" script] ["-" region] *("-" variant)
+//                  *("-" extension) ["-" privateuse]
+//  language      = 2*3ALPHA ["-" extlang] / 4ALPHA / 5*8ALPHA
+//  extlang       = 3ALPHA *2("-" 3ALPHA)
+//  script        = 4ALPHA
+//  region        = 2ALPHA / 3DIGIT
+//  variant       = 5*8alphanum / (DIGIT 3alphanum)
+//  extension     = singleton 1*("-" (2*8alphanum))
+//  privateuse    = "x" 1*("-" (1*8alphanum))
+
+// grandfathered contains the irregular and regular grandfathered tags of
+// BCP 47, which are well-formed even if they don't match the langtag production.
+var grandfathered = map[string]bool{
+	"en-gb-oed": true, "i-ami": true, "i-bnn": true, "i-default": true,
+	"i-enochian": true, "i-hak": true, "i-klingon": true, "i-lux": true,
+	"i-mingo": true, "i-navajo": true, "i-pwn": true, "i-tao": true,
+	"i-tay": true, "i-tsu": true, "sgn-be-fr": true, "sgn-be-nl": true,
+	"sgn-ch-de": true, "art-lojban": true, "cel-gaulish": true,
+	"no-bok": true, "no-nyn": true, "zh-guoyu": true, "zh-hakka": true,
+	"zh-min": true, "zh-min-nan": true, "zh-xiang": true,
+}
+
+// errLangTag is returned for language tags which are not well-formed.
+var errLangTag = errors.New("invalid language tag")
+
+// langTagError returns an error describing why a language tag is not well-formed.
+func langTagError(format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %s", errLangTag, fmt.Sprintf(format, args...))
+}
+
+func isAlphaString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigitString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isVariant checks if s (lowercased) matches the variant production.
+func isVariant(s string) bool {
+	switch {
+	case len(s) >= 5 && len(s) <= 8:
+		return true
+	case len(s) == 4:
+		return isDigit(rune(s[0]))
+	}
+	return false
+}
+
+// checkLangTag returns an error if the language tag is not well-formed
+// according to BCP 47.
+func checkLangTag(tag string) error {
+	if tag == "" {
+		return errLangTag
+	}
+	if tag[0] == '-' {
+		return langTagError("must start with a letter")
+	}
+	if tag[len(tag)-1] == '-' {
+		return langTagError("trailing '-' disallowed")
+	}
+	for _, r := range tag {
+		if !isAlphaOrDigit(r) && r != '-' {
+			return langTagError("unexpected character: %q", r)
+		}
+	}
+	lower := strings.ToLower(tag)
+	if grandfathered[lower] {
+		return nil
+	}
+	subtags := strings.Split(lower, "-")
+	for _, s := range subtags {
+		if len(s) == 0 {
+			return langTagError("empty subtag")
+		}
+		if len(s) > 8 {
+			return langTagError("subtag too long: %q", s)
+		}
+	}
+
+	if subtags[0] == "x" {
+		return checkPrivateUse(subtags[1:])
+	}
+
+	// language
+	i := 0
+	s := subtags[0]
+	if !isAlphaString(s) || len(s) < 2 {
+		return langTagError("invalid primary language subtag: %q", s)
+	}
+	i++
+	if len(s) <= 3 {
+		// extlang
+		for n := 0; n < 3 && i < len(subtags) && len(subtags[i]) == 3 && isAlphaString(subtags[i]); n++ {
+			i++
+		}
+	}
+
+	// script
+	if i < len(subtags) && len(subtags[i]) == 4 && isAlphaString(subtags[i]) {
+		i++
+	}
+
+	// region
+	if i < len(subtags) && ((len(subtags[i]) == 2 && isAlphaString(subtags[i])) ||
+		(len(subtags[i]) == 3 && isDigitString(subtags[i]))) {
+		i++
+	}
+
+	// variants
+	for i < len(subtags) && isVariant(subtags[i]) {
+		i++
+	}
+
+	// extensions
+	for i < len(subtags) && len(subtags[i]) == 1 && subtags[i] != "x" {
+		singleton := subtags[i]
+		i++
+		n := 0
+		for i < len(subtags) && len(subtags[i]) >= 2 {
+			i++
+			n++
+		}
+		if n == 0 {
+			return langTagError("extension %q must have at least one subtag", singleton)
+		}
+	}
+
+	// private use
+	if i < len(subtags) && subtags[i] == "x" {
+		return checkPrivateUse(subtags[i+1:])
+	}
+
+	if i < len(subtags) {
+		return langTagError("unexpected subtag: %q", subtags[i])
+	}
+	return nil
+}
+
+// checkPrivateUse checks the subtags following the private use singleton "x".
+func checkPrivateUse(subtags []string) error {
+	if len(subtags) == 0 {
+		return langTagError("private use must have at least one subtag")
+	}
+	// All subtags are 1-8 alphanumerics, which is already checked.
+	return nil
+}
+
+// NormalizeLangTag returns the language tag in the case recommended by BCP 47:
+// lowercase, except for script subtags which are titlecased (e.g. "Latn"), and
+// two-letter region subtags which are uppercased (e.g. "GB"). Subtags after a
+// singleton (extensions and private use) are lowercased.
+//
+// Language tags which are not well-formed are returned unchanged.
+func NormalizeLangTag(tag string) string {
+	if checkLangTag(tag) != nil {
+		return tag
+	}
+	subtags := strings.Split(strings.ToLower(tag), "-")
+	for i := 1; i < len(subtags); i++ {
+		s := subtags[i]
+		if len(s) == 1 {
+			// Anything after a singleton stays lowercase.
+			break
+		}
+		switch {
+		case len(s) == 2 && isAlphaString(s):
+			subtags[i] = strings.ToUpper(s)
+		case len(s) == 4 && isAlphaString(s):
+			subtags[i] = strings.ToUpper(s[:1]) + s[1:]
+		}
+	}
+	return strings.Join(subtags, "-")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 type tokenType int
@@ -28,6 +29,7 @@ const (
 	tokenLiteralBoolean    // RDF literal (boolean)
 	tokenLangMarker        // '@''
 	tokenLang              // literal language tag
+	tokenDir               // literal base direction, following '--' after language tag
 	tokenDataTypeMarker    // '^^'
 	tokenDot               // '.'
 	tokenSemicolon         // ';'
@@ -44,6 +46,25 @@ const (
 	tokenPropertyListEnd   // ']'
 	tokenCollectionStart   // '('
 	tokenCollectionEnd     // ')'
+
+	// RDF 1.2 / RDF-star tokens
+	tokenReifiedTripleStart // '<<'
+	tokenReifiedTripleEnd   // '>>'
+	tokenTripleTermStart    // '<<('
+	tokenTripleTermEnd      // ')>>'
+	tokenAnnotationStart    // '{|'
+	tokenAnnotationEnd      // '|}'
+	tokenTilde              // '~'
+
+	// SPARQL tokens
+	tokenVariable   // '?name' or '$name'
+	tokenKeyword    // keyword or function name, e.g. 'SELECT' or 'STRLEN'
+	tokenOperator   // operator or property path modifier, e.g. '&&', '<=' or '+'
+	tokenGroupStart // '{'
+	tokenGroupEnd   // '}'
+
+	// ShExC tokens
+	tokenRegexp // '/pattern/flags'
 )
 
 const eof = -1
@@ -78,6 +99,8 @@ type lexer struct {
 
 	input    []byte     // the input being scanned (should not inlcude newlines)
 	lineMode bool       // true when lexing line-based formats (N-Triples & N-Quads)
+	sparql   bool       // true when lexing SPARQL queries and updates
+	shex     bool       // true when lexing ShExC schemas and shape maps (implies sparql)
 	state    stateFn    // the next lexing function to enter
 	line     int        // the current line number
 	pos      int        // the current position in input
@@ -106,6 +129,27 @@ func newLineLexer(r io.Reader) *lexer {
 	return &l
 }
 
+func newSparqlLexer(r io.Reader) *lexer {
+	l := lexer{
+		rdr:    bufio.NewReader(r),
+		tokens: make(chan token),
+		sparql: true,
+	}
+	go l.run()
+	return &l
+}
+
+func newShExLexer(r io.Reader) *lexer {
+	l := lexer{
+		rdr:    bufio.NewReader(r),
+		tokens: make(chan token),
+		sparql: true,
+		shex:   true,
+	}
+	go l.run()
+	return &l
+}
+
 // next returns the next rune in the input.
 func (l *lexer) next() rune {
 	if l.pos >= len(l.input) {
@@ -353,6 +397,9 @@ func (l *lexer) errorf(format string, args ...interface{}) stateFn {
 }
 
 func lexAny(l *lexer) stateFn {
+	if l.sparql {
+		return lexSparql(l)
+	}
 	r := l.next()
 	switch r {
 	case '@':
@@ -377,8 +424,42 @@ func lexAny(l *lexer) stateFn {
 		//l.ignore()
 		return lexBNode
 	case '<':
+		if l.peek() == '<' {
+			l.next()
+			if l.peek() == '(' {
+				l.next()
+				l.emit(tokenTripleTermStart)
+				return lexAny
+			}
+			l.emit(tokenReifiedTripleStart)
+			return lexAny
+		}
 		l.ignore()
 		return lexIRI
+	case '>':
+		if l.peek() != '>' {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.next()
+		l.emit(tokenReifiedTripleEnd)
+		return lexAny
+	case '{':
+		if l.peek() != '|' {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.next()
+		l.emit(tokenAnnotationStart)
+		return lexAny
+	case '|':
+		if l.peek() != '}' {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.next()
+		l.emit(tokenAnnotationEnd)
+		return lexAny
+	case '~':
+		l.emit(tokenTilde)
+		return lexAny
 	case 'a':
 		p := l.peek()
 		for _, a := range okAfterRDFType {
@@ -438,6 +519,11 @@ func lexAny(l *lexer) stateFn {
 		l.emit(tokenCollectionStart)
 		return lexAny
 	case ')':
+		if bytes.HasPrefix(l.input[l.pos:], []byte(">>")) {
+			l.pos += 2
+			l.emit(tokenTripleTermEnd)
+			return lexAny
+		}
 		l.ignore()
 		l.emit(tokenCollectionEnd)
 		return lexAny
@@ -756,6 +842,8 @@ func lexNumber(l *lexer) stateFn {
 		gotDot = true
 	default:
 		// a digit
+	}
+	{
 	outer:
 		for {
 			r = l.next()
@@ -789,7 +877,7 @@ func lexNumber(l *lexer) stateFn {
 					}
 				}
 			default:
-				if r == ' ' || r == ',' || r == ';' || r == eof || r == ')' || r == ']' {
+				if l.sparql || r == ' ' || r == ',' || r == ';' || r == eof || r == ')' || r == ']' || r == '>' || r == '|' || r == '~' || r == '{' {
 					l.backup()
 					break outer
 				}
@@ -843,32 +931,32 @@ func lexBNode(l *lexer) stateFn {
 }
 
 func lexLang(l *lexer) stateFn {
-	// NOTE this is only a rough check of the language tag's well-formedness.
-	// TODO: Full conformance with the spec is quite complex:
-	// http://tools.ietf.org/html/bcp47 [section 2.1]
-	c := 0
-	var r rune
-	for r = l.next(); isAlpha(r); r = l.next() {
-		c++
+	for r := l.next(); isAlphaOrDigit(r) || r == '-'; r = l.next() {
 	}
+	l.backup()
 
-	if c == 0 {
-		return l.errorf("bad literal: invalid language tag")
-	}
-	if r == '-' {
-		c = 0
-		for r := l.next(); isAlphaOrDigit(r) || r == '-'; r = l.next() {
-			c++
-		}
-		l.backup()
-		if c == 0 {
-			return l.errorf("bad literal: invalid language tag")
-		}
-	} else {
-		l.backup()
+	// A base direction can follow the language tag: "text"@ar--rtl
+	end := l.pos
+	dir := bytes.Index(l.input[l.start:end], []byte("--"))
+	if dir >= 0 {
+		l.pos = l.start + dir
 	}
 
+	if err := checkLangTag(string(l.input[l.start:l.pos])); err != nil {
+		return l.errorf("bad literal: %v", err)
+	}
 	l.emit(tokenLang)
+
+	if dir >= 0 {
+		// ignore '--'
+		l.pos += 2
+		l.ignore()
+		l.pos = end
+		if err := checkDir(string(l.input[l.start:l.pos])); err != nil {
+			return l.errorf("bad literal: %v", err)
+		}
+		l.emit(tokenDir)
+	}
 	return lexAny
 }
 
@@ -962,6 +1050,12 @@ func lexIRISuffix(l *lexer) stateFn {
 		return lexAny
 	}
 	if !isPnLocalFirst(r) {
+		if l.sparql {
+			// prefix only IRI, followed by punctuation
+			l.backup()
+			l.emit(tokenIRISuffix)
+			return lexAny
+		}
 		return l.errorf("unexpected character: %q", r)
 	}
 	if r == '\\' || r == '%' {
@@ -1006,3 +1100,248 @@ func lexBase(l *lexer) stateFn {
 	}
 	return l.errorf("invalid character 'b'")
 }
+
+// lexSparql lexes SPARQL queries and updates. IRIs, literals, numbers,
+// blank nodes and prefixed names are lexed by the same state functions as
+// Turtle; they return to lexAny, which dispatches back to lexSparql.
+func lexSparql(l *lexer) stateFn {
+	r := l.next()
+	switch r {
+	case ' ', '\t':
+		l.ignore()
+		return lexSparql
+	case '\r':
+		l.ignore()
+		return lexSparql
+	case '\n', '#', eof:
+		// comment tokens are not emitted, so treated as eof
+		l.ignore()
+		l.emit(tokenEOL)
+		return nil // This parks the lexer until it gets more input
+	case '@':
+		if l.shex {
+			// shape reference or language tag
+			l.emit(tokenOperator)
+			return lexSparql
+		}
+		// Turtle directives, as used in rules.
+		switch l.next() {
+		case 'p':
+			l.start++ // consume '@'
+			return lexPrefix
+		case 'b':
+			l.start++ // consume '@'
+			return lexBase
+		}
+		l.backup()
+		return l.errorf("unrecognized directive")
+	case '?', '$':
+		if l.shex {
+			// cardinality or triple expression label
+			l.emit(tokenOperator)
+			return lexSparql
+		}
+		if p := l.peek(); (isPnCharsU(p) && p != ':') || isDigit(p) {
+			l.ignore() // ignore '?' or '$'
+			for r = l.next(); isPnChars(r) && r != '-' && r != ':'; r = l.next() {
+			}
+			l.backup()
+			l.emit(tokenVariable)
+			return lexSparql
+		}
+		if r == '$' {
+			return l.errorf("bad variable: invalid character %q", l.peek())
+		}
+		// property path modifier
+		l.emit(tokenOperator)
+		return lexSparql
+	case '<':
+		if isIRIRef(l.input[l.pos:]) {
+			l.ignore()
+			return lexIRI
+		}
+		if l.peek() == '=' {
+			l.next()
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '>', '!':
+		if l.peek() == '=' {
+			l.next()
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '&':
+		if l.shex {
+			// inclusion of a triple expression
+			l.emit(tokenOperator)
+			return lexSparql
+		}
+		if l.next() != '&' {
+			l.backup()
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '|':
+		if l.peek() == '|' {
+			l.next()
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '+', '-':
+		if p := l.peek(); isDigit(p) || (p == '.' && l.pos+1 < len(l.input) && isDigit(rune(l.input[l.pos+1]))) {
+			l.backup()
+			return lexNumber
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '/':
+		if l.shex {
+			if l.peek() != '/' {
+				return lexRegexp
+			}
+			// annotation
+			l.next()
+		}
+		l.emit(tokenOperator)
+		return lexSparql
+	case '~':
+		if !l.shex {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.emit(tokenTilde)
+		return lexSparql
+	case '=', '*', '^':
+		l.emit(tokenOperator)
+		return lexSparql
+	case '{':
+		l.emit(tokenGroupStart)
+		return lexSparql
+	case '}':
+		l.emit(tokenGroupEnd)
+		return lexSparql
+	case '(':
+		l.emit(tokenCollectionStart)
+		return lexSparql
+	case ')':
+		l.emit(tokenCollectionEnd)
+		return lexSparql
+	case '[':
+		for r = l.next(); r == ' ' || r == '\t'; r = l.next() {
+		}
+		if r == ']' {
+			l.emit(tokenAnonBNode)
+			return lexSparql
+		}
+		l.backup()
+		l.pos = l.start + 1
+		l.emit(tokenPropertyListStart)
+		return lexSparql
+	case ']':
+		l.emit(tokenPropertyListEnd)
+		return lexSparql
+	case ',':
+		l.emit(tokenComma)
+		return lexSparql
+	case ';':
+		l.emit(tokenSemicolon)
+		return lexSparql
+	case '.':
+		if isDigit(l.peek()) {
+			l.backup()
+			return lexNumber
+		}
+		l.emit(tokenDot)
+		return lexSparql
+	case '"', '\'':
+		l.backup()
+		return lexLiteral
+	case '_':
+		if l.peek() != ':' {
+			return l.errorf("illegal character %q in blank node identifier", l.peek())
+		}
+		l.next()
+		return lexBNode
+	case ':':
+		l.backup()
+		return lexPrefixLabel
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		l.backup()
+		return lexNumber
+	}
+
+	if !isPnCharsBase(r) {
+		return l.errorf("unexpected character: %q", r)
+	}
+
+	// A keyword, function name or prefixed name
+	for r = l.next(); (isPnChars(r) && r != ':') || r == '.'; r = l.next() {
+	}
+	if r == ':' {
+		l.pos = l.start
+		return lexPrefixLabel
+	}
+	l.backup()
+	for l.input[l.pos-1] == '.' {
+		l.pos--
+	}
+	word := string(l.input[l.start:l.pos])
+	switch {
+	case word == "a":
+		l.emit(tokenRDFType)
+	case strings.EqualFold(word, "true"), strings.EqualFold(word, "false"):
+		l.emit(tokenLiteralBoolean)
+	case strings.EqualFold(word, "PREFIX"):
+		l.emit(tokenSparqlPrefix)
+		// consume and ignore any whitespace before prefix label
+		for r := l.next(); r == ' ' || r == '\t'; r = l.next() {
+		}
+		l.backup()
+		l.ignore()
+		return lexPrefixLabelInDirective
+	case strings.EqualFold(word, "BASE"):
+		l.emit(tokenSparqlBase)
+	default:
+		l.emit(tokenKeyword)
+	}
+	return lexSparql
+}
+
+// lexRegexp lexes a ShExC regular expression, after the opening '/'. The
+// token is the regular expression as given, /pattern/flags.
+func lexRegexp(l *lexer) stateFn {
+	for {
+		switch l.next() {
+		case '\\':
+			if l.next() == eof {
+				return l.errorf("bad regular expression: unexpected end of line")
+			}
+		case '/':
+			for r := l.next(); r == 's' || r == 'm' || r == 'i' || r == 'x' || r == 'q'; r = l.next() {
+			}
+			l.backup()
+			l.emit(tokenRegexp)
+			return lexSparql
+		case eof:
+			return l.errorf("bad regular expression: unexpected end of line")
+		}
+	}
+}
+
+// isIRIRef checks if b starts with the remainder of an IRI reference, after
+// the opening '<'. In SPARQL, '<' can also be the less-than operator.
+func isIRIRef(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case '>':
+			return true
+		case '<', '"', '{', '}', '|', '^', '`', '\\':
+			return false
+		}
+		if c <= 0x20 {
+			return false
+		}
+	}
+	return false
+}
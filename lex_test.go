@@ -22,6 +22,7 @@ var tokenName = map[tokenType]string{
 	tokenBNode:             "Blank node",
 	tokenLangMarker:        "Language tag marker",
 	tokenLang:              "Language tag",
+	tokenDir:               "Base direction",
 	tokenDataTypeMarker:    "Literal datatype marker",
 	tokenDot:               "Dot",
 	tokenSemicolon:         "Semicolon",
@@ -38,6 +39,22 @@ var tokenName = map[tokenType]string{
 	tokenPropertyListEnd:   "Property list end",
 	tokenCollectionStart:   "Collection start",
 	tokenCollectionEnd:     "Collection end",
+
+	tokenReifiedTripleStart: "Reified triple start",
+	tokenReifiedTripleEnd:   "Reified triple end",
+	tokenTripleTermStart:    "Triple term start",
+	tokenTripleTermEnd:      "Triple term end",
+	tokenAnnotationStart:    "Annotation start",
+	tokenAnnotationEnd:      "Annotation end",
+	tokenTilde:              "Reifier marker",
+
+	tokenVariable:   "Variable",
+	tokenKeyword:    "Keyword",
+	tokenOperator:   "Operator",
+	tokenGroupStart: "Group start",
+	tokenGroupEnd:   "Group end",
+
+	tokenRegexp: "Regular expression",
 }
 
 func (t tokenType) String() string {
@@ -212,6 +229,38 @@ two
 			{tokenLang, "zh-latn-pinyin-x-notone"},
 			{tokenEOF, ""}},
 		},
+		{`"a"@zh-Hant-TW "b"@es-419.`, []testToken{
+			{tokenLiteral, "a"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "zh-Hant-TW"},
+			{tokenLiteral, "b"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "es-419"},
+			{tokenDot, ""},
+			{tokenEOF, ""}},
+		},
+		{`"a"@ar--rtl "b"@en-GB--ltr`, []testToken{
+			{tokenLiteral, "a"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "ar"},
+			{tokenDir, "rtl"},
+			{tokenLiteral, "b"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "en-GB"},
+			{tokenDir, "ltr"},
+			{tokenEOF, ""}},
+		},
+		{`"a"@ar--up`, []testToken{
+			{tokenLiteral, "a"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "ar"},
+			{tokenError, `bad literal: invalid base direction: "up"`}},
+		},
+		{`"a"@en-a`, []testToken{
+			{tokenLiteral, "a"},
+			{tokenLangMarker, "@"},
+			{tokenError, `bad literal: invalid language tag: extension "a" must have at least one subtag`}},
+		},
 		{`"a"^^<s://mydatatype>`, []testToken{
 			{tokenLiteral, "a"},
 			{tokenDataTypeMarker, "^^"},
@@ -383,3 +432,179 @@ two
 		}
 	}
 }
+
+func TestSparqlTokens(t *testing.T) {
+	lexTests := []struct {
+		in   string
+		want []testToken
+	}{
+		{"SELECT ?x $y WHERE {?x a ex:C}", []testToken{
+			{tokenKeyword, "SELECT"},
+			{tokenVariable, "x"},
+			{tokenVariable, "y"},
+			{tokenKeyword, "WHERE"},
+			{tokenGroupStart, "{"},
+			{tokenVariable, "x"},
+			{tokenRDFType, "a"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "C"},
+			{tokenGroupEnd, "}"},
+			{tokenEOF, ""}},
+		},
+		{"prefix ex: <http://example/> base <http://b/>", []testToken{
+			{tokenSparqlPrefix, "prefix"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRIAbs, "http://example/"},
+			{tokenSparqlBase, "base"},
+			{tokenIRIAbs, "http://b/"},
+			{tokenEOF, ""}},
+		},
+		{"FILTER(?x < 3&&?y>=-1.5||!BOUND(?z)) # comment", []testToken{
+			{tokenKeyword, "FILTER"},
+			{tokenCollectionStart, "("},
+			{tokenVariable, "x"},
+			{tokenOperator, "<"},
+			{tokenLiteralInteger, "3"},
+			{tokenOperator, "&&"},
+			{tokenVariable, "y"},
+			{tokenOperator, ">="},
+			{tokenLiteralDecimal, "-1.5"},
+			{tokenOperator, "||"},
+			{tokenOperator, "!"},
+			{tokenKeyword, "BOUND"},
+			{tokenCollectionStart, "("},
+			{tokenVariable, "z"},
+			{tokenCollectionEnd, ")"},
+			{tokenCollectionEnd, ")"},
+			{tokenEOF, ""}},
+		},
+		{"?s ex:p/^ex:q* ?o ; ex:|ex:r+ ?z", []testToken{
+			{tokenVariable, "s"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "p"},
+			{tokenOperator, "/"},
+			{tokenOperator, "^"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "q"},
+			{tokenOperator, "*"},
+			{tokenVariable, "o"},
+			{tokenSemicolon, ";"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, ""},
+			{tokenOperator, "|"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "r"},
+			{tokenOperator, "+"},
+			{tokenVariable, "z"},
+			{tokenEOF, ""}},
+		},
+		{`"a"@en, 'b'^^<t>, .5, TRUE, _:b, [ ]`, []testToken{
+			{tokenLiteral, "a"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "en"},
+			{tokenComma, ","},
+			{tokenLiteral, "b"},
+			{tokenDataTypeMarker, "^^"},
+			{tokenIRIRel, "t"},
+			{tokenComma, ","},
+			{tokenLiteralDecimal, ".5"},
+			{tokenComma, ","},
+			{tokenLiteralBoolean, "TRUE"},
+			{tokenComma, ","},
+			{tokenBNode, "_:b"},
+			{tokenComma, ","},
+			{tokenAnonBNode, "[ ]"},
+			{tokenEOF, ""}},
+		},
+		{"?x & ?y", []testToken{
+			{tokenVariable, "x"},
+			{tokenError, "unexpected character: '&'"}},
+		},
+		{"@prefix ex: <http://example/> . @base <b> . { ?x ex:p ?y } => {}", []testToken{
+			{tokenPrefix, "prefix"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRIAbs, "http://example/"},
+			{tokenDot, "."},
+			{tokenBase, "base"},
+			{tokenIRIRel, "b"},
+			{tokenDot, "."},
+			{tokenGroupStart, "{"},
+			{tokenVariable, "x"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "p"},
+			{tokenVariable, "y"},
+			{tokenGroupEnd, "}"},
+			{tokenOperator, "="},
+			{tokenOperator, ">"},
+			{tokenGroupStart, "{"},
+			{tokenGroupEnd, "}"},
+			{tokenEOF, ""}},
+		},
+	}
+
+	for _, tt := range lexTests {
+		res := collect(newSparqlLexer(strings.NewReader(tt.in)))
+		if !equalTokens(tt.want, res) {
+			t.Errorf("lexing %q, got:\n\t%v\nexpected:\n\t%v", tt.in, res, tt.want)
+		}
+	}
+}
+
+func TestShExTokens(t *testing.T) {
+	lexTests := []struct {
+		in   string
+		want []testToken
+	}{
+		{`<S> { $<t> ex:p @<T> ? ; ^ex:q /a\/b/i // ex:c "x" ; &<t> }`, []testToken{
+			{tokenIRIRel, "S"},
+			{tokenGroupStart, "{"},
+			{tokenOperator, "$"},
+			{tokenIRIRel, "t"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "p"},
+			{tokenOperator, "@"},
+			{tokenIRIRel, "T"},
+			{tokenOperator, "?"},
+			{tokenSemicolon, ";"},
+			{tokenOperator, "^"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "q"},
+			{tokenRegexp, `/a\/b/i`},
+			{tokenOperator, "//"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "c"},
+			{tokenLiteral, "x"},
+			{tokenSemicolon, ";"},
+			{tokenOperator, "&"},
+			{tokenIRIRel, "t"},
+			{tokenGroupEnd, "}"},
+			{tokenEOF, ""}},
+		},
+		{`[ex:~ - ex:a @en~ "b"~]`, []testToken{
+			{tokenPropertyListStart, "["},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, ""},
+			{tokenTilde, "~"},
+			{tokenOperator, "-"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "a"},
+			{tokenOperator, "@"},
+			{tokenKeyword, "en"},
+			{tokenTilde, "~"},
+			{tokenLiteral, "b"},
+			{tokenTilde, "~"},
+			{tokenPropertyListEnd, "]"},
+			{tokenEOF, ""}},
+		},
+		{`/a`, []testToken{
+			{tokenError, "bad regular expression: unexpected end of line"}},
+		},
+	}
+
+	for _, tt := range lexTests {
+		res := collect(newShExLexer(strings.NewReader(tt.in)))
+		if !equalTokens(tt.want, res) {
+			t.Errorf("lexing %q, got:\n\t%v\nexpected:\n\t%v", tt.in, res, tt.want)
+		}
+	}
+}
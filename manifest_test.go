@@ -0,0 +1,346 @@
+package rdf
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var earlReport = flag.String("earl", "", "write an EARL report of the W3C manifest tests, in Turtle, to this file")
+
+// Name spaces of the W3C test manifests.
+const (
+	mfNS   = "http://www.w3.org/2001/sw/DataAccess/tests/test-manifest#"
+	rdftNS = "http://www.w3.org/ns/rdftest#"
+	earlNS = "http://www.w3.org/ns/earl#"
+	doapNS = "http://usefulinc.com/ns/doap#"
+	dcNS   = "http://purl.org/dc/terms/"
+)
+
+// manifestEntry is a test of a W3C test manifest.
+type manifestEntry struct {
+	id      string // IRI of the test
+	typ     string // IRI of the test type, like rdft:TestXMLEval
+	name    string
+	comment string
+	action  string // IRI of the input document
+	result  string // IRI of the expected result, if any
+}
+
+// testManifest is a W3C test manifest in a local directory, which stands
+// for the base IRI of the test suite.
+type testManifest struct {
+	dir     string
+	base    string
+	entries []manifestEntry
+}
+
+// loadManifest reads the manifest.ttl of the test suite in the directory,
+// with relative IRIs resolved against the base IRI of the suite. The entries
+// are in the order of the manifest's mf:entries list.
+func loadManifest(t *testing.T, dir, base string) *testManifest {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, "manifest.ttl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := NewTripleDecoder(f, Turtle)
+	dec.SetOption(Base, IRI{str: base + "manifest.ttl"})
+	ts, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatalf("decoding manifest in %s: %v", dir, err)
+	}
+	g := NewGraph(ts...)
+
+	value := func(s Subject, p string) string {
+		for _, tr := range g.Match(s, IRI{str: p}, nil) {
+			switch o := tr.Obj.(type) {
+			case IRI:
+				return o.str
+			case Literal:
+				return o.str
+			}
+		}
+		return ""
+	}
+
+	m := &testManifest{dir: dir, base: base}
+	for _, tr := range g.Match(nil, IRI{str: mfNS + "entries"}, nil) {
+		for l := tr.Obj; l != rdfNil; {
+			cell, ok := l.(Subject)
+			if !ok {
+				t.Fatalf("malformed mf:entries list in %s", dir)
+			}
+			firsts := g.Match(cell, rdfFirst, nil)
+			rests := g.Match(cell, rdfRest, nil)
+			if len(firsts) != 1 || len(rests) != 1 {
+				t.Fatalf("malformed mf:entries list in %s", dir)
+			}
+			test, ok := firsts[0].Obj.(IRI)
+			if !ok {
+				t.Fatalf("mf:entries member is not an IRI: %v", firsts[0].Obj)
+			}
+			m.entries = append(m.entries, manifestEntry{
+				id:      test.str,
+				typ:     value(test, rdfType.str),
+				name:    value(test, mfNS+"name"),
+				comment: value(test, rdfsNS+"comment"),
+				action:  value(test, mfNS+"action"),
+				result:  value(test, mfNS+"result"),
+			})
+			l = rests[0].Obj
+		}
+	}
+	if len(m.entries) == 0 {
+		t.Fatalf("no entries in manifest in %s", dir)
+	}
+	return m
+}
+
+// file returns the local path of a document of the suite, given its IRI.
+func (m *testManifest) file(iri string) string {
+	if !strings.HasPrefix(iri, m.base) {
+		return ""
+	}
+	return filepath.Join(m.dir, filepath.FromSlash(strings.TrimPrefix(iri, m.base)))
+}
+
+// read returns the content of a document of the suite, given its IRI.
+func (m *testManifest) read(t *testing.T, iri string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(m.file(iri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// w3cSuites are the vendored W3C test suites, by directory in testdata, with
+// the base IRIs of the suites. A suite is refreshed by replacing its directory
+// with the files of the W3C suite; the tests are read from its manifest.ttl.
+// A suite whose directory is missing is skipped, with the IRI to fetch it.
+//
+// The local suites are written for this package in the manifest format, for
+// the RDF 1.2 syntax until the W3C suites are vendored. They have a base IRI
+// of their own, and are left out of the EARL report, which only makes claims
+// about the W3C tests. An EARL report of the outcomes is written with:
+//
+//	go test -run TestW3CManifests -earl earl.ttl
+var w3cSuites = []struct {
+	dir, base string
+	local     bool
+}{
+	{"rdf-xml", "http://www.w3.org/2013/RDFXMLTests/", false},
+	{"turtle", "http://www.w3.org/2013/TurtleTests/", false},
+	{"n-triples", "http://www.w3.org/2013/N-TriplesTests/", false},
+	{"n-quads", "http://www.w3.org/2013/N-QuadsTests/", false},
+	{"trig", "http://www.w3.org/2013/TriGTests/", false},
+	{"rdf12-turtle-syntax", localSuites + "rdf12-turtle-syntax/", true},
+	{"rdf12-turtle-eval", localSuites + "rdf12-turtle-eval/", true},
+	{"rdf12-n-triples", localSuites + "rdf12-n-triples/", true},
+	{"rdf12-n-quads", localSuites + "rdf12-n-quads/", true},
+}
+
+// localSuites is the base IRI of the local test suites, by directory.
+const localSuites = "https://github.com/knakk/rdf/testdata/"
+
+// manifestRunners run the tests of a manifest, by test type. A test passes
+// if the runner returns nil. Tests of other types are inapplicable.
+var manifestRunners = map[string]func(m *testManifest, e manifestEntry) error{
+	rdftNS + "TestXMLEval":                evalTest(RDFXML),
+	rdftNS + "TestXMLNegativeSyntax":      negativeTest(RDFXML),
+	rdftNS + "TestTurtleEval":             evalTest(Turtle),
+	rdftNS + "TestTurtlePositiveSyntax":   positiveTest(Turtle),
+	rdftNS + "TestTurtleNegativeSyntax":   negativeTest(Turtle),
+	rdftNS + "TestTurtleNegativeEval":     negativeTest(Turtle),
+	rdftNS + "TestNTriplesPositiveSyntax": positiveTest(NTriples),
+	rdftNS + "TestNTriplesNegativeSyntax": negativeTest(NTriples),
+	rdftNS + "TestNQuadsPositiveSyntax":   positiveTest(NQuads),
+	rdftNS + "TestNQuadsNegativeSyntax":   negativeTest(NQuads),
+
+	// There is no TriG decoder, so the TriG tests are reported as
+	// inapplicable.
+	rdftNS + "TestTrigEval":           inapplicableTest,
+	rdftNS + "TestTrigPositiveSyntax": inapplicableTest,
+	rdftNS + "TestTrigNegativeSyntax": inapplicableTest,
+	rdftNS + "TestTrigNegativeEval":   inapplicableTest,
+}
+
+// decodeTest decodes the action of a test, in the given format; as triples,
+// or as the triples of quads for N-Quads.
+func decodeTest(m *testManifest, e manifestEntry, f Format) ([]Triple, error) {
+	b, err := os.ReadFile(m.file(e.action))
+	if err != nil {
+		return nil, err
+	}
+	if f == NQuads {
+		qs, err := NewQuadDecoder(bytes.NewReader(b), NQuads).DecodeAll()
+		ts := make([]Triple, len(qs))
+		for i, q := range qs {
+			ts[i] = q.Triple
+		}
+		return ts, err
+	}
+	dec := NewTripleDecoder(bytes.NewReader(b), f)
+	dec.SetOption(Base, IRI{str: e.action})
+	if f == RDFXML {
+		dec.SetOption(StrictIDs, true)
+	}
+	return dec.DecodeAll()
+}
+
+func positiveTest(f Format) func(*testManifest, manifestEntry) error {
+	return func(m *testManifest, e manifestEntry) error {
+		_, err := decodeTest(m, e, f)
+		return err
+	}
+}
+
+func negativeTest(f Format) func(*testManifest, manifestEntry) error {
+	return func(m *testManifest, e manifestEntry) error {
+		ts, err := decodeTest(m, e, f)
+		if err == nil {
+			return fmt.Errorf("decoding %s => <no error>; want error\n%s", e.action, serializeNT(ts))
+		}
+		return nil
+	}
+}
+
+func evalTest(f Format) func(*testManifest, manifestEntry) error {
+	return func(m *testManifest, e manifestEntry) error {
+		ts, err := decodeTest(m, e, f)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(m.file(e.result))
+		if err != nil {
+			return err
+		}
+		want, err := NewTripleDecoder(bytes.NewReader(b), NTriples).DecodeAll()
+		if err != nil {
+			return fmt.Errorf("decoding %s => %v", e.result, err)
+		}
+		if !Isomorphic(ts, want) {
+			return fmt.Errorf("decoding %s =>\n%s\nwant graph isomorphic to:\n%s", e.action, serializeNT(ts), serializeNT(want))
+		}
+		return nil
+	}
+}
+
+// errInapplicable is the outcome of tests of types without a runner.
+var errInapplicable = errors.New("inapplicable")
+
+// inapplicableTest is the runner of test types which are known, but which
+// this package doesn't support.
+func inapplicableTest(*testManifest, manifestEntry) error {
+	return errInapplicable
+}
+
+// runEntry runs a test with the runner of its type. A panic of the decoder
+// is recovered, and makes the test fail rather than abort the suite.
+func runEntry(m *testManifest, e manifestEntry) (err error) {
+	run, ok := manifestRunners[e.typ]
+	if !ok {
+		return errInapplicable
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decoding %s => panic: %v", e.action, r)
+		}
+	}()
+	return run(m, e)
+}
+
+func TestW3CManifests(t *testing.T) {
+	var earl []Triple
+	project := IRI{str: "https://github.com/knakk/rdf"}
+	if *earlReport != "" {
+		earl = earlProject(project)
+	}
+	for _, suite := range w3cSuites {
+		t.Run(suite.dir, func(t *testing.T) {
+			dir := filepath.Join("testdata", suite.dir)
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				t.Skipf("suite not vendored: copy the files of %s to %s", suite.base, dir)
+			}
+			m := loadManifest(t, dir, suite.base)
+			for _, e := range m.entries {
+				t.Run(e.name, func(t *testing.T) {
+					err := runEntry(m, e)
+					if earl != nil && !suite.local {
+						earl = append(earl, earlAssertion(project, len(earl), e, err)...)
+					}
+					switch err {
+					case nil:
+					case errInapplicable:
+						t.Skipf("unsupported test type: %s", e.typ)
+					default:
+						t.Error(err)
+					}
+				})
+			}
+		})
+	}
+	if earl != nil {
+		f, err := os.Create(*earlReport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		enc := NewTripleEncoder(f, Turtle)
+		if err := enc.EncodeAll(earl); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// earlProject returns the EARL description of the project under test.
+func earlProject(project IRI) []Triple {
+	lit := func(s string) Literal { return Literal{str: s, DataType: xsdString} }
+	return []Triple{
+		{Subj: project, Pred: rdfType, Obj: IRI{str: doapNS + "Project"}},
+		{Subj: project, Pred: rdfType, Obj: IRI{str: earlNS + "TestSubject"}},
+		{Subj: project, Pred: rdfType, Obj: IRI{str: earlNS + "Software"}},
+		{Subj: project, Pred: IRI{str: doapNS + "name"}, Obj: lit("rdf")},
+		{Subj: project, Pred: IRI{str: doapNS + "description"}, Obj: lit("RDF library for Go")},
+		{Subj: project, Pred: IRI{str: doapNS + "programming-language"}, Obj: lit("Go")},
+		{Subj: project, Pred: IRI{str: doapNS + "homepage"}, Obj: project},
+	}
+}
+
+// earlAssertion returns the EARL assertion of the outcome of a test, as the
+// n-th assertion of the report.
+func earlAssertion(project IRI, n int, e manifestEntry, err error) []Triple {
+	outcome := "passed"
+	switch err {
+	case nil:
+	case errInapplicable:
+		outcome = "inapplicable"
+	default:
+		outcome = "failed"
+	}
+	assertion := Blank{id: fmt.Sprintf("_:a%d", n)}
+	result := Blank{id: fmt.Sprintf("_:r%d", n)}
+	date, _ := NewLiteral(time.Now().UTC().Truncate(time.Second))
+	return []Triple{
+		{Subj: assertion, Pred: rdfType, Obj: IRI{str: earlNS + "Assertion"}},
+		{Subj: assertion, Pred: IRI{str: earlNS + "assertedBy"}, Obj: project},
+		{Subj: assertion, Pred: IRI{str: earlNS + "subject"}, Obj: project},
+		{Subj: assertion, Pred: IRI{str: earlNS + "test"}, Obj: IRI{str: e.id}},
+		{Subj: assertion, Pred: IRI{str: earlNS + "mode"}, Obj: IRI{str: earlNS + "automatic"}},
+		{Subj: assertion, Pred: IRI{str: earlNS + "result"}, Obj: result},
+		{Subj: result, Pred: rdfType, Obj: IRI{str: earlNS + "TestResult"}},
+		{Subj: result, Pred: IRI{str: earlNS + "outcome"}, Obj: IRI{str: earlNS + outcome}},
+		{Subj: result, Pred: IRI{str: dcNS + "date"}, Obj: date},
+	}
+}
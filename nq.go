@@ -17,45 +17,14 @@ func (d *QuadDecoder) parseNQ() (q Quad, err error) {
 	q.Ctx = d.DefaultGraph
 
 	// parse quad subject
-	tok := d.expectAs("subject", tokenIRIAbs, tokenBNode)
-	if tok.typ == tokenIRIAbs {
-		q.Subj = IRI{str: tok.text}
-	} else {
-		q.Subj = Blank{id: tok.text}
-	}
+	q.Subj = parseNTSubject(d, d.star, d.validate)
 
 	// parse quad predicate
-	tok = d.expect1As("predicate", tokenIRIAbs)
+	tok := d.expect1As("predicate", tokenIRIAbs)
 	q.Pred = IRI{str: tok.text}
 
 	// parse quad object
-	tok = d.expectAs("object", tokenIRIAbs, tokenBNode, tokenLiteral)
-
-	switch tok.typ {
-	case tokenBNode:
-		q.Obj = Blank{id: tok.text}
-	case tokenLiteral:
-		val := tok.text
-		l := Literal{
-			str:      val,
-			DataType: xsdString,
-		}
-		p := d.peek()
-		switch p.typ {
-		case tokenLangMarker:
-			d.next() // consume peeked token
-			tok = d.expect1As("literal language", tokenLang)
-			l.lang = tok.text
-			l.DataType = rdfLangString
-		case tokenDataTypeMarker:
-			d.next() // consume peeked token
-			tok = d.expect1As("literal datatype", tokenIRIAbs)
-			l.DataType = IRI{str: tok.text}
-		}
-		q.Obj = l
-	case tokenIRIAbs:
-		q.Obj = IRI{str: tok.text}
-	}
+	q.Obj = parseNTObject(d, d.star, d.validate)
 
 	// parse optional graph
 	p := d.peek()
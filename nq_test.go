@@ -777,7 +777,7 @@ _:1a  <http://example/p> <http://example/o> .`, "", []Quad{
 	//   .
 
 	{`# Bad lang tag
-<http://example/s> <http://example/p> "string"@1 .`, "syntax error: bad literal: invalid language tag", []Quad{}},
+<http://example/s> <http://example/p> "string"@1 .`, "syntax error: bad literal: invalid language tag: invalid primary language subtag: \"1\"", []Quad{}},
 
 	//<#nt-syntax-bad-esc-01> a rdft:TestNQuadsNegativeSyntax ;
 	//   mf:name    "nt-syntax-bad-esc-01" ;
@@ -11,6 +11,8 @@ type ntDecoder struct {
 	l         *lexer   // Turtle lexer (N-Triples is a subset of Turtle)
 	tokens    [2]token // 2 token lookahead
 	peekCount int      // Number of tokens peeked at (position in tokens lookahead array)
+	validate  bool     // Validate literals
+	star      bool     // RDF-star mode
 }
 
 // newNTDecoder returns a new N-Triples parser on the given io.Reader.
@@ -32,45 +34,14 @@ again:
 	}
 
 	// parse triple subject
-	tok := d.expectAs("subject", tokenIRIAbs, tokenBNode)
-	if tok.typ == tokenIRIAbs {
-		t.Subj = IRI{str: tok.text}
-	} else {
-		t.Subj = Blank{id: tok.text}
-	}
+	t.Subj = parseNTSubject(d, d.star, d.validate)
 
 	// parse triple predicate
-	tok = d.expect1As("predicate", tokenIRIAbs)
+	tok := d.expect1As("predicate", tokenIRIAbs)
 	t.Pred = IRI{str: tok.text}
 
 	// parse triple object
-	tok = d.expectAs("object", tokenIRIAbs, tokenBNode, tokenLiteral)
-
-	switch tok.typ {
-	case tokenBNode:
-		t.Obj = Blank{id: tok.text}
-	case tokenLiteral:
-		val := tok.text
-		l := Literal{
-			str:      val,
-			DataType: xsdString,
-		}
-		p := d.peek()
-		switch p.typ {
-		case tokenLangMarker:
-			d.next() // consume peeked token
-			tok = d.expect1As("literal language", tokenLang)
-			l.lang = tok.text
-			l.DataType = rdfLangString
-		case tokenDataTypeMarker:
-			d.next() // consume peeked token
-			tok = d.expect1As("literal datatype", tokenIRIAbs)
-			l.DataType = IRI{str: tok.text}
-		}
-		t.Obj = l
-	case tokenIRIAbs:
-		t.Obj = IRI{str: tok.text}
-	}
+	t.Obj = parseNTObject(d, d.star, d.validate)
 
 	// parse final dot
 	d.expect1As("dot (.)", tokenDot)
@@ -102,6 +73,20 @@ func (d *ntDecoder) DecodeAll() ([]Triple, error) {
 // SetOption sets a ParseOption to the give value
 func (d *ntDecoder) SetOption(o ParseOption, v interface{}) error {
 	switch o {
+	case ValidateLiterals:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"ValidateLiterals\" must be a bool.")
+		}
+		d.validate = b
+		return nil
+	case RDFStar:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"RDFStar\" must be a bool.")
+		}
+		d.star = b
+		return nil
 	default:
 		return fmt.Errorf("N-Triples decoder doesn't support option: %v", o)
 	}
@@ -109,6 +94,97 @@ func (d *ntDecoder) SetOption(o ParseOption, v interface{}) error {
 
 // Parsing functions:
 
+// tokenReader is implemented by the decoders of the line-based formats,
+// N-Triples and N-Quads, so that they can share the parsing of terms.
+type tokenReader interface {
+	next() token
+	peek() token
+	expect1As(context string, expected tokenType) token
+	expectAs(context string, expected ...tokenType) token
+	errorf(format string, args ...interface{})
+}
+
+// parseNTSubject parses the subject of a triple. In RDF-star mode, the
+// subject can be a triple term.
+func parseNTSubject(d tokenReader, star, validate bool) Subject {
+	tok := d.expectAs("subject", tokenIRIAbs, tokenBNode, tokenTripleTermStart, tokenReifiedTripleStart)
+	switch tok.typ {
+	case tokenIRIAbs:
+		return IRI{str: tok.text}
+	case tokenBNode:
+		return Blank{id: tok.text}
+	}
+	if !star {
+		d.errorf("%d:%d: triple term not allowed as subject", tok.line, tok.col)
+	}
+	return parseNTTripleTerm(d, tok, star, validate)
+}
+
+// parseNTObject parses the object of a triple.
+func parseNTObject(d tokenReader, star, validate bool) Object {
+	tok := d.expectAs("object", tokenIRIAbs, tokenBNode, tokenLiteral, tokenTripleTermStart, tokenReifiedTripleStart)
+
+	switch tok.typ {
+	case tokenBNode:
+		return Blank{id: tok.text}
+	case tokenLiteral:
+		val := tok.text
+		line, col := tok.line, tok.col
+		l := Literal{
+			str:      val,
+			DataType: xsdString,
+		}
+		p := d.peek()
+		switch p.typ {
+		case tokenLangMarker:
+			d.next() // consume peeked token
+			tok = d.expect1As("literal language", tokenLang)
+			l.lang = tok.text
+			l.DataType = rdfLangString
+			if d.peek().typ == tokenDir {
+				l.dir = d.next().text
+				l.DataType = rdfDirLangString
+			}
+		case tokenDataTypeMarker:
+			d.next() // consume peeked token
+			tok = d.expect1As("literal datatype", tokenIRIAbs)
+			l.DataType = IRI{str: tok.text}
+			if validate {
+				if err := checkLiteral(l, line, col); err != nil {
+					panic(err)
+				}
+			}
+			l = l.canonicalMarkup()
+		}
+		return l
+	case tokenReifiedTripleStart:
+		if !star {
+			d.errorf("%d:%d: quoted triples require RDF-star mode; use <<( s p o )>> for triple terms", tok.line, tok.col)
+		}
+		return parseNTTripleTerm(d, tok, star, validate)
+	case tokenTripleTermStart:
+		return parseNTTripleTerm(d, tok, star, validate)
+	default:
+		return IRI{str: tok.text}
+	}
+}
+
+// parseNTTripleTerm parses a triple term after the opening token, which is
+// either '<<(', or '<<' in RDF-star mode.
+func parseNTTripleTerm(d tokenReader, open token, star, validate bool) TripleTerm {
+	var t Triple
+	t.Subj = parseNTSubject(d, star, validate)
+	tok := d.expect1As("predicate", tokenIRIAbs)
+	t.Pred = IRI{str: tok.text}
+	t.Obj = parseNTObject(d, star, validate)
+	if open.typ == tokenTripleTermStart {
+		d.expect1As("triple term end", tokenTripleTermEnd)
+	} else {
+		d.expect1As("quoted triple end", tokenReifiedTripleEnd)
+	}
+	return TripleTerm{Triple: t}
+}
+
 // next returns the next token.
 func (d *ntDecoder) next() token {
 	if d.peekCount > 0 {
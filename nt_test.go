@@ -322,6 +322,49 @@ func TestNT(t *testing.T) {
 	}
 }
 
+func TestNTTripleTerms(t *testing.T) {
+	s := IRI{str: "http://example/s"}
+	p := IRI{str: "http://example/p"}
+	spo := TripleTerm{Triple: Triple{Subj: s, Pred: p, Obj: Literal{str: "o", DataType: xsdString}}}
+
+	tests := []struct {
+		input   string
+		star    bool
+		errWant string
+		want    []Triple
+	}{
+		{`<http://example/s> <http://example/p> <<( <http://example/s> <http://example/p> "o" )>> .`, false, "",
+			[]Triple{{Subj: s, Pred: p, Obj: spo}}},
+		{`<< <http://example/s> <http://example/p> "o" >> <http://example/p> << <http://example/s> <http://example/p> "o" >> .`, true, "",
+			[]Triple{{Subj: spo, Pred: p, Obj: spo}}},
+		{`<<( <http://example/s> <http://example/p> "o" )>> <http://example/p> <http://example/s> .`, false, "triple term not allowed as subject", nil},
+		{`<http://example/s> <http://example/p> << <http://example/s> <http://example/p> "o" >> .`, false, "quoted triples require RDF-star mode; use <<( s p o )>> for triple terms", nil},
+		{`<http://example/s> <http://example/p> <<( <http://example/s> <http://example/p> "o" >> .`, false, "unexpected Reified triple end as triple term end", nil},
+	}
+
+	for _, tt := range tests {
+		dec := NewTripleDecoder(bytes.NewBufferString(tt.input), NTriples)
+		dec.SetOption(RDFStar, tt.star)
+		ts, err := dec.DecodeAll()
+		if tt.errWant != "" {
+			if err == nil || !strings.HasSuffix(err.Error(), tt.errWant) {
+				t.Errorf("parseNT(%s) => %v, want %q", tt.input, err, tt.errWant)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseNT(%s) => %v, want %v", tt.input, err, tt.want)
+			continue
+		}
+		if !reflect.DeepEqual(ts, tt.want) {
+			t.Errorf("parseNT(%s) => %v, want %v", tt.input, ts, tt.want)
+		}
+		if out := ts[0].Serialize(NTriples); !strings.Contains(out, spo.Serialize(NTriples)) {
+			t.Errorf("%v.Serialize(NTriples) => %s; want it to contain %s", ts[0], out, spo.Serialize(NTriples))
+		}
+	}
+}
+
 var empty = []Triple{Triple{}}
 
 // ntTestSuite is a representation of the official W3C test suite for N-Triples
@@ -703,7 +746,7 @@ var ntTestSuite = []struct {
 	//   .
 
 	{`# Bad lang tag
-	<http://example/s> <http://example/p> "string"@1 .`, "syntax error: bad literal: invalid language tag", nil},
+	<http://example/s> <http://example/p> "string"@1 .`, "syntax error: bad literal: invalid language tag: invalid primary language subtag: \"1\"", nil},
 
 	//<#nt-syntax-bad-esc-01> rdf:type rdft:TestNTriplesNegativeSyntax ;
 	//   mf:name    "nt-syntax-bad-esc-01" ;
@@ -0,0 +1,718 @@
+package rdf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const owlNS = "http://www.w3.org/2002/07/owl#"
+
+var (
+	owlSameAs                    = IRI{str: owlNS + "sameAs"}
+	owlDifferentFrom             = IRI{str: owlNS + "differentFrom"}
+	owlAllDifferent              = IRI{str: owlNS + "AllDifferent"}
+	owlMembers                   = IRI{str: owlNS + "members"}
+	owlDistinctMembers           = IRI{str: owlNS + "distinctMembers"}
+	owlFunctionalProperty        = IRI{str: owlNS + "FunctionalProperty"}
+	owlInverseFunctionalProperty = IRI{str: owlNS + "InverseFunctionalProperty"}
+	owlIrreflexiveProperty       = IRI{str: owlNS + "IrreflexiveProperty"}
+	owlSymmetricProperty         = IRI{str: owlNS + "SymmetricProperty"}
+	owlAsymmetricProperty        = IRI{str: owlNS + "AsymmetricProperty"}
+	owlTransitiveProperty        = IRI{str: owlNS + "TransitiveProperty"}
+	owlObjectProperty            = IRI{str: owlNS + "ObjectProperty"}
+	owlDatatypeProperty          = IRI{str: owlNS + "DatatypeProperty"}
+	owlEquivalentProperty        = IRI{str: owlNS + "equivalentProperty"}
+	owlPropertyDisjointWith      = IRI{str: owlNS + "propertyDisjointWith"}
+	owlAllDisjointProperties     = IRI{str: owlNS + "AllDisjointProperties"}
+	owlInverseOf                 = IRI{str: owlNS + "inverseOf"}
+	owlPropertyChainAxiom        = IRI{str: owlNS + "propertyChainAxiom"}
+	owlHasKey                    = IRI{str: owlNS + "hasKey"}
+	owlSourceIndividual          = IRI{str: owlNS + "sourceIndividual"}
+	owlAssertionProperty         = IRI{str: owlNS + "assertionProperty"}
+	owlTargetIndividual          = IRI{str: owlNS + "targetIndividual"}
+	owlTargetValue               = IRI{str: owlNS + "targetValue"}
+	owlThing                     = IRI{str: owlNS + "Thing"}
+	owlNothing                   = IRI{str: owlNS + "Nothing"}
+	owlClass                     = IRI{str: owlNS + "Class"}
+	owlEquivalentClass           = IRI{str: owlNS + "equivalentClass"}
+	owlDisjointWith              = IRI{str: owlNS + "disjointWith"}
+	owlAllDisjointClasses        = IRI{str: owlNS + "AllDisjointClasses"}
+	owlComplementOf              = IRI{str: owlNS + "complementOf"}
+	owlIntersectionOf            = IRI{str: owlNS + "intersectionOf"}
+	owlUnionOf                   = IRI{str: owlNS + "unionOf"}
+	owlOneOf                     = IRI{str: owlNS + "oneOf"}
+	owlSomeValuesFrom            = IRI{str: owlNS + "someValuesFrom"}
+	owlAllValuesFrom             = IRI{str: owlNS + "allValuesFrom"}
+	owlHasValue                  = IRI{str: owlNS + "hasValue"}
+	owlOnProperty                = IRI{str: owlNS + "onProperty"}
+	owlOnClass                   = IRI{str: owlNS + "onClass"}
+	owlMaxCardinality            = IRI{str: owlNS + "maxCardinality"}
+	owlMaxQualifiedCardinality   = IRI{str: owlNS + "maxQualifiedCardinality"}
+
+	xsdNonNegativeInteger = IRI{str: "http://www.w3.org/2001/XMLSchema#nonNegativeInteger"}
+)
+
+// owlRule is a rule of the OWL 2 RL/RDF rule set: the head is inferred for
+// each solution of the body. A rule without a head detects an inconsistency.
+type owlRule struct {
+	name string
+	body []TriplePattern
+	head []TriplePattern
+	cond func(Binding) bool // additional condition on the solutions, if any
+}
+
+func pat(s, p, o Term) TriplePattern {
+	return TriplePattern{Subj: s, Pred: p, Obj: o}
+}
+
+// cardinality returns a condition that the variable is bound to the given
+// integer.
+func cardinality(v Var, n uint64) func(Binding) bool {
+	return func(b Binding) bool {
+		l, ok := b[v].(Literal)
+		if !ok {
+			return false
+		}
+		switch l.DataType {
+		case xsdNonNegativeInteger, xsdInteger, xsdInt:
+			m, err := strconv.ParseUint(l.str, 10, 64)
+			return err == nil && m == n
+		}
+		return false
+	}
+}
+
+// owlRules are the rules of the tables of
+// https://www.w3.org/TR/owl2-profiles/#OWL_2_RL, which don't involve lists.
+// Rules on lists are compiled into rules like these, from the axioms which
+// use them; see OWLReasoner.compile.
+var owlRules = func() []owlRule {
+	var (
+		x, y, z, u, v    = Var("x"), Var("y"), Var("z"), Var("u"), Var("v")
+		y1, y2, x1, x2   = Var("y1"), Var("y2"), Var("x1"), Var("x2")
+		p, p1, p2        = Var("p"), Var("p1"), Var("p2")
+		c, c1, c2, c3, n = Var("c"), Var("c1"), Var("c2"), Var("c3"), Var("n")
+		i1, i2, lt       = Var("i1"), Var("i2"), Var("lt")
+	)
+	return []owlRule{
+		// The semantics of equality; the rules eq-ref, eq-sym, eq-trans
+		// and eq-rep-* are implemented by canonicalisation.
+		{name: "eq-diff1", body: []TriplePattern{pat(x, owlDifferentFrom, x)}},
+
+		// The semantics of axioms about properties.
+		{name: "prp-dom", body: []TriplePattern{pat(p, rdfsDomain, c), pat(x, p, y)}, head: []TriplePattern{pat(x, rdfType, c)}},
+		{name: "prp-rng", body: []TriplePattern{pat(p, rdfsRange, c), pat(x, p, y)}, head: []TriplePattern{pat(y, rdfType, c)}},
+		{name: "prp-fp", body: []TriplePattern{pat(p, rdfType, owlFunctionalProperty), pat(x, p, y1), pat(x, p, y2)}, head: []TriplePattern{pat(y1, owlSameAs, y2)}},
+		{name: "prp-ifp", body: []TriplePattern{pat(p, rdfType, owlInverseFunctionalProperty), pat(x1, p, y), pat(x2, p, y)}, head: []TriplePattern{pat(x1, owlSameAs, x2)}},
+		{name: "prp-irp", body: []TriplePattern{pat(p, rdfType, owlIrreflexiveProperty), pat(x, p, x)}},
+		{name: "prp-symp", body: []TriplePattern{pat(p, rdfType, owlSymmetricProperty), pat(x, p, y)}, head: []TriplePattern{pat(y, p, x)}},
+		{name: "prp-asyp", body: []TriplePattern{pat(p, rdfType, owlAsymmetricProperty), pat(x, p, y), pat(y, p, x)}},
+		{name: "prp-trp", body: []TriplePattern{pat(p, rdfType, owlTransitiveProperty), pat(x, p, y), pat(y, p, z)}, head: []TriplePattern{pat(x, p, z)}},
+		{name: "prp-spo1", body: []TriplePattern{pat(p1, rdfsSubPropertyOf, p2), pat(x, p1, y)}, head: []TriplePattern{pat(x, p2, y)}},
+		{name: "prp-eqp1", body: []TriplePattern{pat(p1, owlEquivalentProperty, p2), pat(x, p1, y)}, head: []TriplePattern{pat(x, p2, y)}},
+		{name: "prp-eqp2", body: []TriplePattern{pat(p1, owlEquivalentProperty, p2), pat(x, p2, y)}, head: []TriplePattern{pat(x, p1, y)}},
+		{name: "prp-pdw", body: []TriplePattern{pat(p1, owlPropertyDisjointWith, p2), pat(x, p1, y), pat(x, p2, y)}},
+		{name: "prp-inv1", body: []TriplePattern{pat(p1, owlInverseOf, p2), pat(x, p1, y)}, head: []TriplePattern{pat(y, p2, x)}},
+		{name: "prp-inv2", body: []TriplePattern{pat(p1, owlInverseOf, p2), pat(x, p2, y)}, head: []TriplePattern{pat(y, p1, x)}},
+		{name: "prp-npa1", body: []TriplePattern{pat(x, owlSourceIndividual, i1), pat(x, owlAssertionProperty, p), pat(x, owlTargetIndividual, i2), pat(i1, p, i2)}},
+		{name: "prp-npa2", body: []TriplePattern{pat(x, owlSourceIndividual, i1), pat(x, owlAssertionProperty, p), pat(x, owlTargetValue, lt), pat(i1, p, lt)}},
+
+		// The semantics of classes.
+		{name: "cls-nothing2", body: []TriplePattern{pat(x, rdfType, owlNothing)}},
+		{name: "cls-com", body: []TriplePattern{pat(c1, owlComplementOf, c2), pat(x, rdfType, c1), pat(x, rdfType, c2)}},
+		{name: "cls-svf1", body: []TriplePattern{pat(x, owlSomeValuesFrom, y), pat(x, owlOnProperty, p), pat(u, p, v), pat(v, rdfType, y)}, head: []TriplePattern{pat(u, rdfType, x)}},
+		{name: "cls-svf2", body: []TriplePattern{pat(x, owlSomeValuesFrom, owlThing), pat(x, owlOnProperty, p), pat(u, p, v)}, head: []TriplePattern{pat(u, rdfType, x)}},
+		{name: "cls-avf", body: []TriplePattern{pat(x, owlAllValuesFrom, y), pat(x, owlOnProperty, p), pat(u, rdfType, x), pat(u, p, v)}, head: []TriplePattern{pat(v, rdfType, y)}},
+		{name: "cls-hv1", body: []TriplePattern{pat(x, owlHasValue, y), pat(x, owlOnProperty, p), pat(u, rdfType, x)}, head: []TriplePattern{pat(u, p, y)}},
+		{name: "cls-hv2", body: []TriplePattern{pat(x, owlHasValue, y), pat(x, owlOnProperty, p), pat(u, p, y)}, head: []TriplePattern{pat(u, rdfType, x)}},
+		{name: "cls-maxc1", body: []TriplePattern{pat(x, owlMaxCardinality, n), pat(x, owlOnProperty, p), pat(u, rdfType, x), pat(u, p, y)}, cond: cardinality(n, 0)},
+		{name: "cls-maxc2", body: []TriplePattern{pat(x, owlMaxCardinality, n), pat(x, owlOnProperty, p), pat(u, rdfType, x), pat(u, p, y1), pat(u, p, y2)}, head: []TriplePattern{pat(y1, owlSameAs, y2)}, cond: cardinality(n, 1)},
+		{name: "cls-maxqc1", body: []TriplePattern{pat(x, owlMaxQualifiedCardinality, n), pat(x, owlOnProperty, p), pat(x, owlOnClass, c), pat(u, rdfType, x), pat(u, p, y), pat(y, rdfType, c)}, cond: cardinality(n, 0)},
+		{name: "cls-maxqc2", body: []TriplePattern{pat(x, owlMaxQualifiedCardinality, n), pat(x, owlOnProperty, p), pat(x, owlOnClass, owlThing), pat(u, rdfType, x), pat(u, p, y)}, cond: cardinality(n, 0)},
+		{name: "cls-maxqc3", body: []TriplePattern{pat(x, owlMaxQualifiedCardinality, n), pat(x, owlOnProperty, p), pat(x, owlOnClass, c), pat(u, rdfType, x), pat(u, p, y1), pat(y1, rdfType, c), pat(u, p, y2), pat(y2, rdfType, c)}, head: []TriplePattern{pat(y1, owlSameAs, y2)}, cond: cardinality(n, 1)},
+		{name: "cls-maxqc4", body: []TriplePattern{pat(x, owlMaxQualifiedCardinality, n), pat(x, owlOnProperty, p), pat(x, owlOnClass, owlThing), pat(u, rdfType, x), pat(u, p, y1), pat(u, p, y2)}, head: []TriplePattern{pat(y1, owlSameAs, y2)}, cond: cardinality(n, 1)},
+
+		// The semantics of class axioms.
+		{name: "cax-sco", body: []TriplePattern{pat(c1, rdfsSubClassOf, c2), pat(x, rdfType, c1)}, head: []TriplePattern{pat(x, rdfType, c2)}},
+		{name: "cax-eqc1", body: []TriplePattern{pat(c1, owlEquivalentClass, c2), pat(x, rdfType, c1)}, head: []TriplePattern{pat(x, rdfType, c2)}},
+		{name: "cax-eqc2", body: []TriplePattern{pat(c1, owlEquivalentClass, c2), pat(x, rdfType, c2)}, head: []TriplePattern{pat(x, rdfType, c1)}},
+		{name: "cax-dw", body: []TriplePattern{pat(c1, owlDisjointWith, c2), pat(x, rdfType, c1), pat(x, rdfType, c2)}},
+
+		// The semantics of schema vocabulary.
+		{name: "scm-cls", body: []TriplePattern{pat(c, rdfType, owlClass)}, head: []TriplePattern{pat(c, rdfsSubClassOf, c), pat(c, owlEquivalentClass, c), pat(c, rdfsSubClassOf, owlThing), pat(owlNothing, rdfsSubClassOf, c)}},
+		{name: "scm-sco", body: []TriplePattern{pat(c1, rdfsSubClassOf, c2), pat(c2, rdfsSubClassOf, c3)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c3)}},
+		{name: "scm-eqc1", body: []TriplePattern{pat(c1, owlEquivalentClass, c2)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c2), pat(c2, rdfsSubClassOf, c1)}},
+		{name: "scm-eqc2", body: []TriplePattern{pat(c1, rdfsSubClassOf, c2), pat(c2, rdfsSubClassOf, c1)}, head: []TriplePattern{pat(c1, owlEquivalentClass, c2)}},
+		{name: "scm-op", body: []TriplePattern{pat(p, rdfType, owlObjectProperty)}, head: []TriplePattern{pat(p, rdfsSubPropertyOf, p), pat(p, owlEquivalentProperty, p)}},
+		{name: "scm-dp", body: []TriplePattern{pat(p, rdfType, owlDatatypeProperty)}, head: []TriplePattern{pat(p, rdfsSubPropertyOf, p), pat(p, owlEquivalentProperty, p)}},
+		{name: "scm-spo", body: []TriplePattern{pat(p1, rdfsSubPropertyOf, p2), pat(p2, rdfsSubPropertyOf, Var("p3"))}, head: []TriplePattern{pat(p1, rdfsSubPropertyOf, Var("p3"))}},
+		{name: "scm-eqp1", body: []TriplePattern{pat(p1, owlEquivalentProperty, p2)}, head: []TriplePattern{pat(p1, rdfsSubPropertyOf, p2), pat(p2, rdfsSubPropertyOf, p1)}},
+		{name: "scm-eqp2", body: []TriplePattern{pat(p1, rdfsSubPropertyOf, p2), pat(p2, rdfsSubPropertyOf, p1)}, head: []TriplePattern{pat(p1, owlEquivalentProperty, p2)}},
+		{name: "scm-dom1", body: []TriplePattern{pat(p, rdfsDomain, c1), pat(c1, rdfsSubClassOf, c2)}, head: []TriplePattern{pat(p, rdfsDomain, c2)}},
+		{name: "scm-dom2", body: []TriplePattern{pat(p2, rdfsDomain, c), pat(p1, rdfsSubPropertyOf, p2)}, head: []TriplePattern{pat(p1, rdfsDomain, c)}},
+		{name: "scm-rng1", body: []TriplePattern{pat(p, rdfsRange, c1), pat(c1, rdfsSubClassOf, c2)}, head: []TriplePattern{pat(p, rdfsRange, c2)}},
+		{name: "scm-rng2", body: []TriplePattern{pat(p2, rdfsRange, c), pat(p1, rdfsSubPropertyOf, p2)}, head: []TriplePattern{pat(p1, rdfsRange, c)}},
+		{name: "scm-hv", body: []TriplePattern{pat(c1, owlHasValue, y), pat(c1, owlOnProperty, p1), pat(c2, owlHasValue, y), pat(c2, owlOnProperty, p2), pat(p1, rdfsSubPropertyOf, p2)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c2)}},
+		{name: "scm-svf1", body: []TriplePattern{pat(c1, owlSomeValuesFrom, y1), pat(c1, owlOnProperty, p), pat(c2, owlSomeValuesFrom, y2), pat(c2, owlOnProperty, p), pat(y1, rdfsSubClassOf, y2)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c2)}},
+		{name: "scm-svf2", body: []TriplePattern{pat(c1, owlSomeValuesFrom, y), pat(c1, owlOnProperty, p1), pat(c2, owlSomeValuesFrom, y), pat(c2, owlOnProperty, p2), pat(p1, rdfsSubPropertyOf, p2)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c2)}},
+		{name: "scm-avf1", body: []TriplePattern{pat(c1, owlAllValuesFrom, y1), pat(c1, owlOnProperty, p), pat(c2, owlAllValuesFrom, y2), pat(c2, owlOnProperty, p), pat(y1, rdfsSubClassOf, y2)}, head: []TriplePattern{pat(c1, rdfsSubClassOf, c2)}},
+		{name: "scm-avf2", body: []TriplePattern{pat(c1, owlAllValuesFrom, y), pat(c1, owlOnProperty, p1), pat(c2, owlAllValuesFrom, y), pat(c2, owlOnProperty, p2), pat(p1, rdfsSubPropertyOf, p2)}, head: []TriplePattern{pat(c2, rdfsSubClassOf, c1)}},
+	}
+}()
+
+// InconsistencyError describes an inconsistency detected by an
+// OWLReasoner: the rule which detected it, and the triples it was
+// detected from.
+type InconsistencyError struct {
+	Rule     string
+	Premises []Triple
+}
+
+// Error returns the rule and the premises of the inconsistency.
+func (e *InconsistencyError) Error() string {
+	ts := make([]string, len(e.Premises))
+	for i, t := range e.Premises {
+		ts[i] = strings.TrimSuffix(t.Serialize(NTriples), "\n")
+	}
+	return fmt.Sprintf("inconsistency %s: %s", e.Rule, strings.Join(ts, " "))
+}
+
+// owlDistinct is an owl:AllDifferent axiom.
+type owlDistinct struct {
+	rule    string
+	axiom   []Triple
+	members []Term
+}
+
+// OWLReasoner materializes the entailments of a set of triples under the
+// OWL 2 RL/RDF rules, by semi-naive forward chaining; see
+// https://www.w3.org/TR/owl2-profiles/#Reasoning_in_OWL_2_RL_and_RDF_Graphs_using_Rules
+//
+// Equal resources, by owl:sameAs, are canonicalised instead of copying all
+// their triples to each other: they are merged with a union-find structure,
+// and their triples are rewritten to a single canonical term. In the output,
+// the canonical term is the least IRI of the equal resources, or the least
+// blank node if there are none; the other resources are related to it by
+// owl:sameAs.
+//
+// Axioms on lists, as owl:propertyChainAxiom, owl:hasKey, owl:intersectionOf
+// and owl:members, are compiled into rules when their lists are complete.
+// The datatype rules, and the axiomatic triples of rules like cls-thing and
+// prp-ap, are not applied.
+//
+// Inconsistencies don't stop the reasoning; they are collected, and can be
+// retrieved with Inconsistencies.
+//
+// An OWLReasoner is not safe for concurrent use.
+type OWLReasoner struct {
+	g        *Graph                // asserted and inferred triples, of canonical terms
+	rules    []owlRule             // rules compiled from axioms on lists
+	compiled map[string]bool       // keys of the compiled axioms
+	pending  []Triple              // axioms on lists, to be compiled
+	distinct []owlDistinct         // owl:AllDifferent axioms
+	delta    []Triple              // triples to join with the graph
+	parent   map[string]Term       // term key -> parent, of merged terms
+	members  map[string][]Term     // canonical term key -> equal terms
+	names    map[string]Term       // canonical term key -> name in output
+	incons   []*InconsistencyError // inconsistencies, in order of detection
+	seen     map[string]bool       // keys of the inconsistencies
+}
+
+// NewOWLReasoner returns a reasoner of the triples of the source. The
+// entailments of the source are materialized before it returns.
+func NewOWLReasoner(src TripleSource) *OWLReasoner {
+	r := &OWLReasoner{
+		g:        NewGraph(),
+		compiled: make(map[string]bool),
+		parent:   make(map[string]Term),
+		members:  make(map[string][]Term),
+		names:    make(map[string]Term),
+		seen:     make(map[string]bool),
+	}
+	if src != nil {
+		r.Add(src.Match(nil, nil, nil)...)
+	}
+	return r
+}
+
+// Add asserts the triples, and materializes their entailments.
+func (r *OWLReasoner) Add(ts ...Triple) {
+	for _, t := range ts {
+		r.add(t)
+	}
+	for {
+		for len(r.delta) > 0 {
+			t := r.delta[0]
+			r.delta = r.delta[1:]
+			if !r.g.Has(t) {
+				// Rewritten since, by canonicalisation.
+				continue
+			}
+			r.apply(t)
+		}
+		if !r.compilePending() {
+			break
+		}
+	}
+	r.checkDistinct()
+}
+
+// Triples returns the asserted and inferred triples, with equal resources
+// replaced by their canonical terms, and the owl:sameAs triples relating
+// each resource to its canonical term.
+func (r *OWLReasoner) Triples() []Triple {
+	ts := make([]Triple, 0, r.g.Len())
+	for _, t := range r.g.Triples() {
+		ts = append(ts, r.named(t))
+	}
+	var same []Triple
+	for k, ms := range r.members {
+		name := r.names[k]
+		for _, m := range ms {
+			if !sameTerm(m, name) {
+				same = append(same, Triple{Subj: m.(Subject), Pred: owlSameAs, Obj: name.(Object)})
+			}
+		}
+	}
+	sort.Slice(same, func(i, j int) bool {
+		return same[i].Serialize(NTriples) < same[j].Serialize(NTriples)
+	})
+	return append(ts, same...)
+}
+
+// Canonical returns the canonical term of the resources equal to t.
+func (r *OWLReasoner) Canonical(t Term) Term {
+	root := r.find(t)
+	if name, ok := r.names[termKey(root)]; ok {
+		return name
+	}
+	return root
+}
+
+// Inconsistencies returns the inconsistencies detected, in order of
+// detection.
+func (r *OWLReasoner) Inconsistencies() []*InconsistencyError {
+	var errs []*InconsistencyError
+	seen := make(map[string]bool)
+	for _, e := range r.incons {
+		named := &InconsistencyError{Rule: e.Rule}
+		for _, t := range e.Premises {
+			named.Premises = append(named.Premises, r.named(t))
+		}
+		if k := named.key(); !seen[k] {
+			seen[k] = true
+			errs = append(errs, named)
+		}
+	}
+	return errs
+}
+
+// Match returns the asserted and inferred triples matching the given
+// subject, predicate and object, where a nil value matches any term. The
+// triples have canonical terms, and owl:sameAs triples are not matched.
+func (r *OWLReasoner) Match(s Subject, p Predicate, o Object) []Triple {
+	ts := r.g.Match(r.canonical(s, p, o))
+	for i, t := range ts {
+		ts[i] = r.named(t)
+	}
+	return ts
+}
+
+// Count returns the number of triples Match would return.
+func (r *OWLReasoner) Count(s Subject, p Predicate, o Object) int {
+	return r.g.Count(r.canonical(s, p, o))
+}
+
+// find returns the root of the set of equal resources of t.
+func (r *OWLReasoner) find(t Term) Term {
+	if len(r.parent) == 0 || t == nil {
+		return t
+	}
+	k := termKey(t)
+	p, ok := r.parent[k]
+	if !ok {
+		return t
+	}
+	root := r.find(p)
+	r.parent[k] = root
+	return root
+}
+
+// canonical returns the roots of the given terms, which may be nil.
+func (r *OWLReasoner) canonical(s Subject, p Predicate, o Object) (Subject, Predicate, Object) {
+	cs, _ := r.find(s).(Subject)
+	cp, _ := r.find(p).(Predicate)
+	co, _ := r.find(o).(Object)
+	return cs, cp, co
+}
+
+// named returns the triple with its terms replaced by their names.
+func (r *OWLReasoner) named(t Triple) Triple {
+	s, _ := r.Canonical(t.Subj).(Subject)
+	p, _ := r.Canonical(t.Pred).(Predicate)
+	o, _ := r.Canonical(t.Obj).(Object)
+	return Triple{Subj: s, Pred: p, Obj: o}
+}
+
+// add adds the triple, with its terms canonicalised. If it is new, it is
+// queued to be joined with the graph.
+func (r *OWLReasoner) add(t Triple) {
+	if t.Pred == owlSameAs {
+		r.union(t.Subj.(Object), t.Obj, []Triple{t})
+		return
+	}
+	s, p, o := r.canonical(t.Subj, t.Pred, t.Obj)
+	t = Triple{Subj: s, Pred: p, Obj: o}
+	if r.g.Add(t) {
+		r.delta = append(r.delta, t)
+	}
+}
+
+// union merges the sets of equal resources of a and b, which are equal by
+// the premises. The triples of the smaller set are rewritten to the root of
+// the larger, so each triple is rewritten at most a logarithmic number of
+// times. IRIs are preferred as roots, since they can be predicates.
+func (r *OWLReasoner) union(a, b Object, premises []Triple) {
+	if la, ok := a.(Literal); ok {
+		if lb, ok := b.(Literal); ok && !LiteralsValueEqual(la, lb) {
+			r.inconsistent("dt-diff", premises)
+		}
+		return
+	}
+	if _, ok := nodeSubject(a); !ok {
+		return
+	}
+	if _, ok := nodeSubject(b); !ok {
+		return
+	}
+	ra, rb := r.find(a), r.find(b)
+	ka, kb := termKey(ra), termKey(rb)
+	if ka == kb {
+		return
+	}
+	_, aIRI := ra.(IRI)
+	_, bIRI := rb.(IRI)
+	if bIRI && !aIRI || aIRI == bIRI && len(r.equal(kb, rb)) > len(r.equal(ka, ra)) {
+		ra, rb, ka, kb = rb, ra, kb, ka
+	}
+
+	na, nb := r.Canonical(ra), r.Canonical(rb)
+	r.parent[kb] = ra
+	r.members[ka] = append(r.equal(ka, ra), r.equal(kb, rb)...)
+	delete(r.members, kb)
+	if nameBefore(nb, na) {
+		na = nb
+	}
+	r.names[ka] = na
+	delete(r.names, kb)
+
+	// Rewrite the triples of the merged root.
+	ts := r.g.Match(rb.(Subject), nil, nil)
+	if p, ok := rb.(IRI); ok {
+		ts = append(ts, r.g.Match(nil, p, nil)...)
+	}
+	ts = append(ts, r.g.Match(nil, nil, rb.(Object))...)
+	for _, t := range ts {
+		if r.g.Remove(t) {
+			r.add(t)
+		}
+	}
+}
+
+// equal returns the resources equal to the given root.
+func (r *OWLReasoner) equal(k string, root Term) []Term {
+	if ms, ok := r.members[k]; ok {
+		return ms
+	}
+	return []Term{root}
+}
+
+// nameBefore reports whether a is preferred to b as the canonical term of
+// equal resources.
+func nameBefore(a, b Term) bool {
+	_, aIRI := a.(IRI)
+	_, bIRI := b.(IRI)
+	if aIRI != bIRI {
+		return aIRI
+	}
+	return termKey(a) < termKey(b)
+}
+
+// inconsistent records an inconsistency, unless it has been recorded before.
+func (r *OWLReasoner) inconsistent(rule string, premises []Triple) {
+	e := &InconsistencyError{Rule: rule, Premises: premises}
+	if k := e.key(); !r.seen[k] {
+		r.seen[k] = true
+		r.incons = append(r.incons, e)
+	}
+}
+
+// key identifies an inconsistency by its rule and set of premises, as
+// symmetric rules detect the same inconsistency twice.
+func (e *InconsistencyError) key() string {
+	ts := make([]string, len(e.Premises))
+	for i, t := range e.Premises {
+		ts[i] = t.Serialize(NTriples)
+	}
+	sort.Strings(ts)
+	return e.Rule + " " + strings.Join(ts, "")
+}
+
+// apply joins the new triple t with the graph, in each position of the
+// body of each rule.
+func (r *OWLReasoner) apply(t Triple) {
+	for _, rules := range [][]owlRule{owlRules, r.rules} {
+		for _, rule := range rules {
+			for i, atom := range rule.body {
+				if b, ok := unify(r.canonicalPattern(atom), t); ok {
+					r.solve(rule, b, i)
+				}
+			}
+		}
+	}
+	switch t.Pred {
+	case owlPropertyChainAxiom, owlHasKey, owlIntersectionOf, owlUnionOf, owlOneOf, owlMembers, owlDistinctMembers:
+		r.pending = append(r.pending, t)
+	}
+}
+
+// canonicalPattern returns the pattern with its constants canonicalised.
+func (r *OWLReasoner) canonicalPattern(tp TriplePattern) TriplePattern {
+	for _, x := range []*Term{&tp.Subj, &tp.Pred, &tp.Obj} {
+		if _, ok := (*x).(Var); !ok {
+			*x = r.find(*x)
+		}
+	}
+	return tp
+}
+
+// solve finds the solutions of the body of the rule extending the binding,
+// where the atom at index skip is already matched, and infers the head for
+// each of them.
+func (r *OWLReasoner) solve(rule owlRule, b Binding, skip int) {
+	var bgp []TriplePattern
+	for i, atom := range rule.body {
+		if i == skip {
+			continue
+		}
+		tp := r.canonicalPattern(bindPattern(atom, b))
+		if s, p, o, ok := substitute(tp, nil); !ok || s != nil && p != nil && o != nil && !r.g.Has(Triple{Subj: s, Pred: p, Obj: o}) {
+			// A ground pattern which doesn't match; typically the schema
+			// triple of the rule.
+			return
+		}
+		bgp = append(bgp, tp)
+	}
+	// The solutions are collected first, as inferences modify the graph.
+	var solutions []Binding
+	it := QueryBGP(r.g, bgp)
+	for s, ok := it.Next(); ok; s, ok = it.Next() {
+		for v, t := range b {
+			s[v] = t
+		}
+		if rule.cond == nil || rule.cond(s) {
+			solutions = append(solutions, s)
+		}
+	}
+	for _, s := range solutions {
+		r.fire(rule, s)
+	}
+}
+
+// fire infers the head of the rule for the solution of its body, or
+// records an inconsistency if the rule has no head.
+func (r *OWLReasoner) fire(rule owlRule, b Binding) {
+	premises := func() []Triple {
+		var ts []Triple
+		for _, atom := range rule.body {
+			if s, p, o, ok := substitute(atom, b); ok {
+				ts = append(ts, Triple{Subj: s, Pred: p, Obj: o})
+			}
+		}
+		return ts
+	}
+	if len(rule.head) == 0 {
+		r.inconsistent(rule.name, premises())
+		return
+	}
+	for _, h := range rule.head {
+		if h.Pred == owlSameAs {
+			// Literals may be equal too, so the subject isn't substituted.
+			a, aOK := b[h.Subj.(Var)].(Object)
+			o, oOK := b[h.Obj.(Var)].(Object)
+			if aOK && oOK {
+				r.union(a, o, premises())
+			}
+			continue
+		}
+		if s, p, o, ok := substitute(h, b); ok && s != nil && p != nil && o != nil {
+			r.add(Triple{Subj: s, Pred: p, Obj: o})
+		}
+	}
+}
+
+// compilePending compiles the pending axioms on lists whose lists are
+// complete. It returns true if any was compiled.
+func (r *OWLReasoner) compilePending() bool {
+	var pending []Triple
+	done := false
+	for _, t := range r.pending {
+		s, p, o := r.canonical(t.Subj, t.Pred, t.Obj)
+		t = Triple{Subj: s, Pred: p, Obj: o}
+		k := t.Serialize(NTriples)
+		switch {
+		case r.compiled[k]:
+		case r.compile(t):
+			r.compiled[k] = true
+			done = true
+		default:
+			pending = append(pending, t)
+		}
+	}
+	r.pending = pending
+	return done
+}
+
+// compile compiles an axiom on a list into rules, which are evaluated
+// against the graph, and facts, which are added. It returns false if the
+// list is not complete, or the kind of owl:members axiom is not yet known.
+func (r *OWLReasoner) compile(t Triple) bool {
+	list, ok := r.list(t.Obj)
+	if !ok {
+		return false
+	}
+	c := t.Subj
+	fact := func(rule string, s, p, o Term) {
+		r.fire(owlRule{name: rule, head: []TriplePattern{pat(s, p, o)}}, nil)
+	}
+	x, y := Var("x"), Var("y")
+	switch t.Pred {
+	case owlPropertyChainAxiom:
+		if len(list) == 0 {
+			break
+		}
+		u := func(i int) Var { return Var(fmt.Sprintf("u%d", i)) }
+		rule := owlRule{name: "prp-spo2", head: []TriplePattern{pat(u(0), c, u(len(list)))}}
+		for i, p := range list {
+			rule.body = append(rule.body, pat(u(i), p, u(i+1)))
+		}
+		r.addRule(rule)
+	case owlHasKey:
+		if len(list) == 0 {
+			break
+		}
+		rule := owlRule{
+			name: "prp-key",
+			body: []TriplePattern{pat(x, rdfType, c), pat(y, rdfType, c)},
+			head: []TriplePattern{pat(x, owlSameAs, y)},
+		}
+		for i, p := range list {
+			z := Var(fmt.Sprintf("z%d", i))
+			rule.body = append(rule.body, pat(x, p, z), pat(y, p, z))
+		}
+		r.addRule(rule)
+	case owlIntersectionOf:
+		if len(list) == 0 {
+			break
+		}
+		int1 := owlRule{name: "cls-int1", head: []TriplePattern{pat(y, rdfType, c)}}
+		for _, ci := range list {
+			int1.body = append(int1.body, pat(y, rdfType, ci))
+			r.addRule(owlRule{name: "cls-int2", body: []TriplePattern{pat(y, rdfType, c)}, head: []TriplePattern{pat(y, rdfType, ci)}})
+			fact("scm-int", c, rdfsSubClassOf, ci)
+		}
+		r.addRule(int1)
+	case owlUnionOf:
+		for _, ci := range list {
+			r.addRule(owlRule{name: "cls-uni", body: []TriplePattern{pat(y, rdfType, ci)}, head: []TriplePattern{pat(y, rdfType, c)}})
+			fact("scm-uni", ci, rdfsSubClassOf, c)
+		}
+	case owlOneOf:
+		for _, yi := range list {
+			fact("cls-oo", yi, rdfType, c)
+		}
+	case owlMembers, owlDistinctMembers:
+		typed := func(class IRI) bool {
+			return r.g.Has(Triple{Subj: c, Pred: rdfType, Obj: r.find(class).(Object)})
+		}
+		switch {
+		case t.Pred == owlDistinctMembers:
+			r.addDistinct("eq-diff3", t, list)
+		case typed(owlAllDifferent):
+			r.addDistinct("eq-diff2", t, list)
+		case typed(owlAllDisjointClasses):
+			for i := range list {
+				for _, cj := range list[i+1:] {
+					r.addRule(owlRule{name: "cax-adc", body: []TriplePattern{pat(x, rdfType, list[i]), pat(x, rdfType, cj)}})
+				}
+			}
+		case typed(owlAllDisjointProperties):
+			for i := range list {
+				for _, pj := range list[i+1:] {
+					r.addRule(owlRule{name: "prp-adp", body: []TriplePattern{pat(x, list[i], y), pat(x, pj, y)}})
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// addRule adds a compiled rule, and evaluates it against the graph.
+func (r *OWLReasoner) addRule(rule owlRule) {
+	r.rules = append(r.rules, rule)
+	r.solve(rule, Binding{}, -1)
+}
+
+// addDistinct adds an owl:AllDifferent axiom, with the given members.
+func (r *OWLReasoner) addDistinct(rule string, t Triple, members []Term) {
+	d := owlDistinct{rule: rule, axiom: []Triple{t}}
+	for _, m := range members {
+		if _, ok := m.(Literal); !ok {
+			d.members = append(d.members, m)
+		}
+	}
+	r.distinct = append(r.distinct, d)
+}
+
+// checkDistinct records an inconsistency for each pair of members of an
+// owl:AllDifferent axiom which are equal.
+func (r *OWLReasoner) checkDistinct() {
+	for _, d := range r.distinct {
+		roots := make(map[string]Term)
+		for _, m := range d.members {
+			k := termKey(r.find(m))
+			if first, ok := roots[k]; ok {
+				premises := append(append([]Triple(nil), d.axiom...), Triple{Subj: first.(Subject), Pred: owlSameAs, Obj: m.(Object)})
+				r.inconsistent(d.rule, premises)
+				continue
+			}
+			roots[k] = m
+		}
+	}
+}
+
+// list returns the members of the RDF collection with the given head, or
+// false if it is not complete.
+func (r *OWLReasoner) list(head Object) ([]Term, bool) {
+	var items []Term
+	seen := make(map[string]bool)
+	end := r.find(rdfNil)
+	for n := r.find(head); !sameTerm(n, end); {
+		s, ok := nodeSubject(n.(Object))
+		if !ok || seen[termKey(s)] {
+			return nil, false
+		}
+		seen[termKey(s)] = true
+		first, rest := r.g.Match(s, rdfFirst, nil), r.g.Match(s, rdfRest, nil)
+		if len(first) == 0 || len(rest) == 0 {
+			return nil, false
+		}
+		items = append(items, first[0].Obj)
+		n = rest[0].Obj
+	}
+	return items, true
+}
@@ -0,0 +1,228 @@
+package rdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const owlTestPrefixes = `
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix owl: <http://www.w3.org/2002/07/owl#> .
+@prefix : <http://example/> .
+`
+
+const owlTestOntology = owlTestPrefixes + `
+:hasParent owl:inverseOf :hasChild ; rdfs:subPropertyOf :ancestor .
+:ancestor a owl:TransitiveProperty .
+:hasUncle owl:propertyChainAxiom ( :hasParent :hasBrother ) .
+:knows a owl:SymmetricProperty .
+:email a owl:InverseFunctionalProperty .
+:Person owl:equivalentClass :Human .
+:Parent owl:intersectionOf ( :Person [ owl:onProperty :hasChild ; owl:someValuesFrom owl:Thing ] ) .
+
+:ann a :Human ; :hasParent :bob ; :knows :dan .
+:robert :email "bob@example.org" ; :hasParent :carl .
+:bob :email "bob@example.org" ; :hasBrother :ed ; a :Person .
+`
+
+func TestOWLReasoner(t *testing.T) {
+	ts := mustDecodeTTL(owlTestOntology)
+	r := NewOWLReasoner(NewGraph(ts...))
+	got := sortedNT(r.Triples())
+	has := make(map[string]bool)
+	for _, s := range got {
+		has[s] = true
+	}
+	for _, want := range []string{
+		"<http://example/bob> <http://example/hasChild> <http://example/ann> .\n",
+		"<http://example/ann> <http://example/ancestor> <http://example/bob> .\n",
+		"<http://example/ann> <http://example/ancestor> <http://example/carl> .\n",
+		"<http://example/ann> <http://example/hasUncle> <http://example/ed> .\n",
+		"<http://example/ann> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Person> .\n",
+		"<http://example/bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Parent> .\n",
+		"<http://example/bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Human> .\n",
+		"<http://example/dan> <http://example/knows> <http://example/ann> .\n",
+		"<http://example/robert> <http://www.w3.org/2002/07/owl#sameAs> <http://example/bob> .\n",
+	} {
+		if !has[want] {
+			t.Errorf("Triples() lacks %s", want)
+		}
+	}
+	for _, s := range got {
+		if strings.HasPrefix(s, "<http://example/robert> ") && !strings.Contains(s, "#sameAs>") {
+			t.Errorf("Triples() includes %s; want only canonical terms", s)
+		}
+	}
+	if errs := r.Inconsistencies(); len(errs) != 0 {
+		t.Errorf("Inconsistencies() => %v; want none", errs)
+	}
+
+	// Equal resources are queried by their canonical term.
+	robert := IRI{str: "http://example/robert"}
+	if c := r.Canonical(robert); c != (IRI{str: "http://example/bob"}) {
+		t.Errorf("Canonical(%v) => %v; want <http://example/bob>", robert, c)
+	}
+	if n := len(r.Match(robert, IRI{str: "http://example/hasParent"}, nil)); n != 1 {
+		t.Errorf("Match(robert hasParent ?) => %d triples; want 1", n)
+	}
+
+	// The materialization doesn't depend on the order of the triples.
+	oneByOne := NewOWLReasoner(nil)
+	for i := len(ts) - 1; i >= 0; i-- {
+		oneByOne.Add(ts[i])
+	}
+	if inc := sortedNT(oneByOne.Triples()); !equalStrings(inc, got) {
+		t.Errorf("incremental Triples() =>\n%v\nwant:\n%v", inc, got)
+	}
+
+	// The output can be encoded as is.
+	var buf bytes.Buffer
+	enc := NewTripleEncoder(&buf, Turtle)
+	if err := enc.EncodeAll(r.Triples()); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if decoded := mustDecodeTTL(buf.String()); !Isomorphic(decoded, r.Triples()) {
+		t.Errorf("encoded Triples() don't decode to the same graph:\n%s", buf.String())
+	}
+}
+
+func TestOWLRules(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`:Key owl:hasKey ( :ssn ) . :a a :Key ; :ssn 1 . :b a :Key ; :ssn 1 .`,
+			`<http://example/b> <http://www.w3.org/2002/07/owl#sameAs> <http://example/a> .`,
+		},
+		{
+			`:p a owl:FunctionalProperty . :x :p _:b1 , :y .`,
+			`_:b1 <http://www.w3.org/2002/07/owl#sameAs> <http://example/y> .`,
+		},
+		{
+			`:R owl:maxCardinality 1 ; owl:onProperty :p . :x a :R ; :p :y , :z .`,
+			`<http://example/z> <http://www.w3.org/2002/07/owl#sameAs> <http://example/y> .`,
+		},
+		{
+			`:p owl:equivalentProperty :q . :x :q :y .`,
+			`<http://example/x> <http://example/p> <http://example/y> .`,
+		},
+		{
+			`:R owl:hasValue :red ; owl:onProperty :color . :x a :R .`,
+			`<http://example/x> <http://example/color> <http://example/red> .`,
+		},
+		{
+			`:R owl:hasValue :red ; owl:onProperty :color . :x :color :red .`,
+			`<http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/R> .`,
+		},
+		{
+			`:R owl:allValuesFrom :Dog ; owl:onProperty :pet . :x a :R ; :pet :rex .`,
+			`<http://example/rex> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Dog> .`,
+		},
+		{
+			`:Pet owl:unionOf ( :Cat :Dog ) . :rex a :Dog .`,
+			`<http://example/rex> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Pet> .`,
+		},
+		{
+			`:Color owl:oneOf ( :red :green ) .`,
+			`<http://example/green> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Color> .`,
+		},
+		{
+			`:A owl:intersectionOf ( :B :C ) . :x a :A .`,
+			`<http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/C> .`,
+		},
+		{
+			`:A a owl:Class .`,
+			`<http://www.w3.org/2002/07/owl#Nothing> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example/A> .`,
+		},
+		{
+			`:A rdfs:subClassOf :B . :B rdfs:subClassOf :A .`,
+			`<http://example/A> <http://www.w3.org/2002/07/owl#equivalentClass> <http://example/B> .`,
+		},
+		{
+			`:p rdfs:domain :A . :A rdfs:subClassOf :B . :q rdfs:subPropertyOf :p .`,
+			`<http://example/q> <http://www.w3.org/2000/01/rdf-schema#domain> <http://example/B> .`,
+		},
+		{
+			// Equal properties are rewritten in predicate position.
+			`:p owl:sameAs :q . :x :q :y .`,
+			`<http://example/x> <http://example/p> <http://example/y> .`,
+		},
+		{
+			// Equal classes are rewritten in compiled rules.
+			`:A owl:unionOf ( :B ) . :B owl:sameAs :C . :x a :C .`,
+			`<http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/A> .`,
+		},
+		{
+			// The list of an axiom may come after it.
+			`:A owl:intersectionOf _:l1 . :x a :B , :C . _:l1 rdf:first :B ; rdf:rest _:l2 . _:l2 rdf:first :C ; rdf:rest rdf:nil .`,
+			`<http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/A> .`,
+		},
+	}
+	for _, test := range tests {
+		ts := mustDecodeTTL(owlTestPrefixes + test.input)
+		// Add the triples one by one, so the list triples come last.
+		r := NewOWLReasoner(nil)
+		for _, tr := range ts {
+			r.Add(tr)
+		}
+		found := false
+		for _, tr := range r.Triples() {
+			if strings.TrimSuffix(tr.Serialize(NTriples), "\n") == test.want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s\n=> %v\nwant %s", test.input, sortedNT(r.Triples()), test.want)
+		}
+	}
+}
+
+func TestOWLInconsistencies(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`:x a :A .`, nil},
+		{`:x a owl:Nothing .`, []string{"cls-nothing2"}},
+		{`:A rdfs:subClassOf owl:Nothing . :x a :A .`, []string{"cls-nothing2"}},
+		{`:a owl:sameAs :b . :a owl:differentFrom :b .`, []string{"eq-diff1"}},
+		{`:p a owl:FunctionalProperty . :x :p :a , :b . :b owl:differentFrom :a .`, []string{"eq-diff1"}},
+		{`:p a owl:FunctionalProperty . :x :p 1 , 2 .`, []string{"dt-diff"}},
+		{`:p a owl:FunctionalProperty . :x :p 1 , 1.0 .`, nil},
+		{`[] a owl:AllDifferent ; owl:members ( :a :b :c ) . :c owl:sameAs :b .`, []string{"eq-diff2"}},
+		{`[] owl:distinctMembers ( :a :b ) . :a owl:sameAs :b .`, []string{"eq-diff3"}},
+		{`:A owl:disjointWith :B . :x a :A , :B .`, []string{"cax-dw"}},
+		{`[] a owl:AllDisjointClasses ; owl:members ( :A :B :C ) . :x a :A , :C .`, []string{"cax-adc"}},
+		{`:A owl:complementOf :B . :x a :A , :B .`, []string{"cls-com"}},
+		{`:p a owl:IrreflexiveProperty . :x :p :y . :x owl:sameAs :y .`, []string{"prp-irp"}},
+		{`:p a owl:AsymmetricProperty . :x :p :y . :y :p :x .`, []string{"prp-asyp"}},
+		{`:p owl:propertyDisjointWith :q . :x :p :y ; :q :y .`, []string{"prp-pdw"}},
+		{`[] a owl:AllDisjointProperties ; owl:members ( :p :q ) . :x :p :y ; :q :y .`, []string{"prp-adp"}},
+		{`[] owl:sourceIndividual :x ; owl:assertionProperty :p ; owl:targetIndividual :y . :x :p :y .`, []string{"prp-npa1"}},
+		{`[] owl:sourceIndividual :x ; owl:assertionProperty :p ; owl:targetValue 3 . :x :p 3 .`, []string{"prp-npa2"}},
+		{`:R owl:maxCardinality 0 ; owl:onProperty :p . :x a :R ; :p :y .`, []string{"cls-maxc1"}},
+		{`:R owl:maxQualifiedCardinality 0 ; owl:onProperty :p ; owl:onClass :C . :x a :R ; :p :y . :y a :C .`, []string{"cls-maxqc1"}},
+	}
+	for _, test := range tests {
+		r := NewOWLReasoner(NewGraph(mustDecodeTTL(owlTestPrefixes + test.input)...))
+		var got []string
+		for _, err := range r.Inconsistencies() {
+			got = append(got, err.Rule)
+		}
+		if !equalStrings(got, test.want) {
+			t.Errorf("%s\n=> %v; want %v", test.input, r.Inconsistencies(), test.want)
+		}
+	}
+
+	r := NewOWLReasoner(NewGraph(mustDecodeTTL(owlTestPrefixes + `:x a owl:Nothing .`)...))
+	want := "inconsistency cls-nothing2: <http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2002/07/owl#Nothing> ."
+	if errs := r.Inconsistencies(); len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("Inconsistencies() => %v; want [%s]", errs, want)
+	}
+}
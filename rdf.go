@@ -39,8 +39,10 @@
 package rdf
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 	"time"
@@ -58,8 +60,8 @@ var (
 
 	xsdString  = IRI{str: "http://www.w3.org/2001/XMLSchema#string"}  // string
 	xsdBoolean = IRI{str: "http://www.w3.org/2001/XMLSchema#boolean"} // bool
-	xsdDecimal = IRI{str: "http://www.w3.org/2001/XMLSchema#decimal"} // float64
-	xsdInteger = IRI{str: "http://www.w3.org/2001/XMLSchema#integer"} // int
+	xsdDecimal = IRI{str: "http://www.w3.org/2001/XMLSchema#decimal"} // *big.Rat
+	xsdInteger = IRI{str: "http://www.w3.org/2001/XMLSchema#integer"} // int, or *big.Int if out of range
 
 	// IEEE floating-point numbers:
 
@@ -92,8 +94,11 @@ var (
 
 	// Various
 
-	rdfLangString = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"} // string
+	rdfLangString    = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"}    // string
+	rdfDirLangString = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#dirLangString"} // string
 	xmlLiteral    = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral"} // string
+	rdfHTML       = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#HTML"}       // *HTMLNode
+	rdfJSON       = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON"}       // interface{}, as decoded by encoding/json
 )
 
 // Format represents a RDF serialization format.
@@ -106,18 +111,20 @@ const (
 	NTriples Format = iota
 	Turtle
 	RDFXML
-	// TODO: JSON-LD
 
 	// Quad serialization:
 
 	NQuads // N-Quads
 	// TODO: Format TriG
 
+	JSONLD // Detected, but not yet supported by decoders and encoders.
+
 	// Internal formats
 	formatInternal
 )
 
-// Term represents an RDF term. There are 3 term types: Blank node, Literal and IRI.
+// Term represents an RDF term. There are 4 term types: Blank node, Literal, IRI
+// and, as introduced in RDF 1.2, Triple term.
 type Term interface {
 	// Serialize returns a string representation of the Term in the specified serialization format.
 	Serialize(Format) string
@@ -129,7 +136,7 @@ type Term interface {
 	Type() TermType
 }
 
-// TermType describes the type of RDF term: Blank node, IRI or Literal
+// TermType describes the type of RDF term: Blank node, IRI, Literal or Triple term
 type TermType int
 
 // Exported RDF term types.
@@ -137,6 +144,11 @@ const (
 	TermBlank TermType = iota
 	TermIRI
 	TermLiteral
+	TermTriple
+
+	// TermVariable is the type of query variables (Var); it is not an RDF
+	// term type, and never occurs in triples.
+	TermVariable
 )
 
 // Blank represents a RDF blank node; an unqualified IRI with identified by a label.
@@ -252,6 +264,11 @@ type Literal struct {
 	// A language tagged string has the datatype: rdf:langString.
 	lang string
 
+	// dir, if not empty, represents the base direction of a language tagged
+	// string; either "ltr" or "rtl". A directional language tagged string
+	// has the datatype: rdf:dirLangString.
+	dir string
+
 	// The datatype of the Literal.
 	DataType IRI
 }
@@ -261,6 +278,9 @@ func (l Literal) Serialize(f Format) string {
 	if TermsEqual(l.DataType, rdfLangString) {
 		return fmt.Sprintf("\"%s\"@%s", escapeLiteral(l.str), l.Lang())
 	}
+	if TermsEqual(l.DataType, rdfDirLangString) {
+		return fmt.Sprintf("\"%s\"@%s--%s", escapeLiteral(l.str), l.Lang(), l.Dir())
+	}
 	if l.DataType != xsdString {
 		switch f {
 		case formatInternal:
@@ -283,6 +303,20 @@ func (l Literal) Serialize(f Format) string {
 	return fmt.Sprintf("\"%s\"", escapeLiteral(l.str))
 }
 
+// canonicalMarkup returns the literal with the lexical form of a
+// rdf:XMLLiteral, rdf:HTML or rdf:JSON literal in canonical form, unless
+// it is not valid for its datatype. Literals are built this way by the
+// decoders, so that equal markup has an equal lexical form.
+func (l Literal) canonicalMarkup() Literal {
+	switch l.DataType {
+	case xmlLiteral, rdfHTML, rdfJSON:
+		if s, ok := canonicalLexical(l.str, l.DataType); ok {
+			l.str = s
+		}
+	}
+	return l
+}
+
 // Type returns the TermType of a Literal.
 func (l Literal) Type() TermType {
 	return TermLiteral
@@ -293,6 +327,12 @@ func (l Literal) Lang() string {
 	return l.lang
 }
 
+// Dir returns the base direction of a directional language-tagged string;
+// either "ltr" or "rtl". It returns an empty string for other literals.
+func (l Literal) Dir() string {
+	return l.dir
+}
+
 // String returns the literal string.
 func (l Literal) String() string {
 	return l.str
@@ -303,20 +343,41 @@ func (l Literal) String() string {
 func (l Literal) Typed() (interface{}, error) {
 	if l.val == nil {
 		switch l.DataType.str {
-		case xsdInteger.str, xsdInt.str:
+		case xsdInteger.str:
+			i, err := parseInteger(l.str)
+			if err != nil {
+				return nil, err
+			}
+			l.val = i
+			return i, nil
+		case xsdInt.str:
 			i, err := strconv.Atoi(l.str)
 			if err != nil {
 				return nil, err
 			}
 			l.val = i
 			return i, nil
-		case xsdDouble.str, xsdDecimal.str:
+		case xsdDecimal.str:
+			r, err := parseDecimal(l.str)
+			if err != nil {
+				return nil, err
+			}
+			l.val = r
+			return r, nil
+		case xsdDouble.str:
 			f, err := strconv.ParseFloat(l.str, 64)
 			if err != nil {
 				return nil, err
 			}
 			l.val = f
 			return f, nil
+		case xsdFloat.str:
+			f, err := parseDouble(l.str, 32)
+			if err != nil {
+				return nil, err
+			}
+			l.val = f
+			return f, nil
 		case xsdBoolean.str:
 			b, err := strconv.ParseBool(l.str)
 			if err != nil {
@@ -326,7 +387,25 @@ func (l Literal) Typed() (interface{}, error) {
 			return b, nil
 		case xsdByte.str:
 			return []byte(l.str), nil
-			// TODO xsdDateTime etc
+		case xsdDateTime.str:
+			t, _, err := parseDateTime(l.str)
+			if err != nil {
+				return nil, err
+			}
+			l.val = t
+			return t, nil
+		case rdfJSON.str:
+			var v interface{}
+			if err := json.Unmarshal([]byte(l.str), &v); err != nil {
+				return nil, err
+			}
+			l.val = v
+			return v, nil
+		case rdfHTML.str:
+			n := parseHTML(l.str)
+			l.val = n
+			return n, nil
+			// TODO xsdDate etc
 		default:
 			return l.str, nil
 		}
@@ -334,13 +413,61 @@ func (l Literal) Typed() (interface{}, error) {
 	return l.val, nil
 }
 
+// Canonical returns the literal with its lexical form in the XSD canonical
+// representation, so that e.g. "01"^^xsd:integer becomes "1"^^xsd:integer.
+// The supported datatypes are xsd:integer, xsd:int, xsd:decimal, xsd:double,
+// xsd:float, xsd:boolean and xsd:dateTime, as well as rdf:XMLLiteral, rdf:HTML
+// and rdf:JSON. Language tags are lowercased.
+//
+// Literals with other datatypes, or with lexical forms which are not valid
+// for their datatype, are returned unchanged.
+func (l Literal) Canonical() Literal {
+	if l.lang != "" {
+		l.lang = strings.ToLower(l.lang)
+		return l
+	}
+	if s, ok := canonicalLexical(l.str, l.DataType); ok {
+		l.str = s
+	}
+	return l
+}
+
 // validAsObject denotes that a Literal is valid as a Triple's Object.
 func (l Literal) validAsObject() {}
 
 // NewLiteral returns a new Literal, or an error on invalid input. It tries
 // to map the given Go values to a corresponding xsd datatype.
+//
+// Arbitrary-precision numbers are supported: *big.Int maps to xsd:integer,
+// while *big.Rat and *big.Float map to xsd:decimal. A *big.Rat without a
+// finite decimal representation, such as 1/3, is an error.
+//
+// A json.RawMessage maps to rdf:JSON, and must be valid JSON.
 func NewLiteral(v interface{}) (Literal, error) {
 	switch t := v.(type) {
+	case json.RawMessage:
+		if !json.Valid(t) {
+			return Literal{}, fmt.Errorf("invalid JSON: %q", t)
+		}
+		return Literal{str: string(t), DataType: rdfJSON}.canonicalMarkup(), nil
+	case *big.Int:
+		return Literal{val: t, str: t.String(), DataType: xsdInteger}, nil
+	case *big.Rat:
+		s, err := decimalString(t)
+		if err != nil {
+			return Literal{}, err
+		}
+		return Literal{val: t, str: s, DataType: xsdDecimal}, nil
+	case *big.Float:
+		r, err := floatDecimal(t)
+		if err != nil {
+			return Literal{}, err
+		}
+		s, err := decimalString(r)
+		if err != nil {
+			return Literal{}, err
+		}
+		return Literal{val: r, str: s, DataType: xsdDecimal}, nil
 	case bool:
 		return Literal{val: t, str: fmt.Sprintf("%v", t), DataType: xsdBoolean}, nil
 	case int, int32, int64:
@@ -359,41 +486,85 @@ func NewLiteral(v interface{}) (Literal, error) {
 }
 
 // NewLangLiteral creates a RDF literal with a given language tag, or fails
-// if the language tag is not well-formed.
+// if the language tag is not well-formed according to BCP 47. The case of
+// the language tag is preserved; see NormalizeLangTag.
 //
-// The literal will have the datatype IRI xsd:String.
+// The literal will have the datatype IRI rdf:langString.
 func NewLangLiteral(v, lang string) (Literal, error) {
-	afterDash := false
-	if len(lang) >= 1 && lang[0] == '-' {
-		return Literal{}, errors.New("invalid language tag: must start with a letter")
+	if err := checkLangTag(lang); err != nil {
+		return Literal{}, err
 	}
-	for _, r := range lang {
-		switch {
-		case (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z'):
-			continue
-		case r == '-':
-			if afterDash {
-				return Literal{}, errors.New("invalid language tag: only one '-' allowed")
-			}
-			afterDash = true
-		case r >= '0' && r <= '9':
-			if afterDash {
-				continue
-			}
-			fallthrough
-		default:
-			return Literal{}, fmt.Errorf("invalid language tag: unexpected character: %q", r)
-		}
+	return Literal{str: v, lang: lang, DataType: rdfLangString}, nil
+}
+
+// NewDirLangLiteral creates a RDF literal with a given language tag and base
+// direction, or fails if the language tag is not well-formed, or if the direction
+// is not "ltr" (left-to-right) or "rtl" (right-to-left).
+//
+// The literal will have the datatype IRI rdf:dirLangString.
+func NewDirLangLiteral(v, lang, dir string) (Literal, error) {
+	if err := checkLangTag(lang); err != nil {
+		return Literal{}, err
 	}
-	if lang[len(lang)-1] == '-' {
-		return Literal{}, errors.New("invalid language tag: trailing '-' disallowed")
+	if err := checkDir(dir); err != nil {
+		return Literal{}, err
 	}
-	return Literal{str: v, lang: lang, DataType: rdfLangString}, nil
+	return Literal{str: v, lang: lang, dir: dir, DataType: rdfDirLangString}, nil
+}
+
+// checkDir returns an error if the base direction is not "ltr" or "rtl".
+func checkDir(dir string) error {
+	if dir != "ltr" && dir != "rtl" {
+		return fmt.Errorf("invalid base direction: %q", dir)
+	}
+	return nil
 }
 
-// NewTypedLiteral returns a literal with the given datatype.
+// NewTypedLiteral returns a literal with the given datatype. The lexical
+// forms of rdf:XMLLiteral, rdf:HTML and rdf:JSON literals are canonicalized.
 func NewTypedLiteral(v string, dt IRI) Literal {
-	return Literal{str: v, DataType: dt}
+	return Literal{str: v, DataType: dt}.canonicalMarkup()
+}
+
+// TripleTerm represents a RDF 1.2 triple term; a Triple which is itself used as
+// a term of another Triple. Triple terms are valid as objects. In RDF-star,
+// they are also valid as subjects.
+type TripleTerm struct {
+	Triple
+}
+
+// NewTripleTerm returns a new TripleTerm of the given Triple. It returns an
+// error if any of the Triple's terms are missing.
+func NewTripleTerm(t Triple) (TripleTerm, error) {
+	if t.Subj == nil || t.Pred == nil || t.Obj == nil {
+		return TripleTerm{}, errors.New("incomplete triple")
+	}
+	return TripleTerm{Triple: t}, nil
+}
+
+// validAsSubject denotes that a Triple term is valid as a Triple's Subject (RDF-star).
+func (t TripleTerm) validAsSubject() {}
+
+// validAsObject denotes that a Triple term is valid as a Triple's Object.
+func (t TripleTerm) validAsObject() {}
+
+// Type returns the TermType of a Triple term.
+func (t TripleTerm) Type() TermType {
+	return TermTriple
+}
+
+// String returns the Triple term in N-Triples syntax.
+func (t TripleTerm) String() string {
+	return t.Serialize(NTriples)
+}
+
+// Serialize returns a string representation of a Triple term: <<( s p o )>>.
+func (t TripleTerm) Serialize(f Format) string {
+	if f == formatInternal {
+		// Make sure the datatypes of nested literals are part of the serialization.
+		f = NTriples
+	}
+	return fmt.Sprintf("<<( %s %s %s )>>", t.Subj.Serialize(f), t.Pred.Serialize(f), t.Obj.Serialize(f))
 }
 
 // Subject interface distiguishes which Terms are valid as a Subject of a Triple.
@@ -439,6 +610,8 @@ func (t Triple) Serialize(f Format) string {
 		s = term.Serialize(f)
 	case Blank:
 		s = term.Serialize(f)
+	case TripleTerm:
+		s = term.Serialize(f)
 	}
 	switch term := t.Obj.(type) {
 	case IRI:
@@ -447,6 +620,8 @@ func (t Triple) Serialize(f Format) string {
 		o = term.Serialize(f)
 	case Blank:
 		o = term.Serialize(f)
+	case TripleTerm:
+		o = term.Serialize(f)
 	}
 	return fmt.Sprintf(
 		"%s %s %s .\n",
@@ -470,6 +645,56 @@ func TermsEqual(a, b Term) bool {
 	return a.Serialize(formatInternal) == b.Serialize(formatInternal)
 }
 
+// LiteralsValueEqual returns true if two Literals have the same value, following
+// the semantics of the SPARQL '=' operator:
+//  - numeric literals are compared by value, after numeric type promotion,
+//    so that "1"^^xsd:integer equals "1.0"^^xsd:decimal and "1.0E0"^^xsd:double.
+//  - booleans and dateTimes are compared by value.
+//  - language-tagged strings are equal if their strings and base directions are
+//    equal and their language tags match case-insensitively.
+//  - other literals are equal only if they are the same RDF term.
+//
+// Ill-typed literals are compared as RDF terms.
+func LiteralsValueEqual(a, b Literal) bool {
+	if na, ok := literalNumber(a); ok {
+		if nb, ok := literalNumber(b); ok {
+			c, ok := na.cmp(nb)
+			return ok && c == 0
+		}
+		return false
+	}
+	switch {
+	case a.DataType == rdfLangString && b.DataType == rdfLangString,
+		a.DataType == rdfDirLangString && b.DataType == rdfDirLangString:
+		return a.str == b.str && strings.EqualFold(a.lang, b.lang) && a.dir == b.dir
+	case a.DataType == xsdBoolean && b.DataType == xsdBoolean:
+		ba, errA := parseBoolean(a.str)
+		bb, errB := parseBoolean(b.str)
+		if errA == nil && errB == nil {
+			return ba == bb
+		}
+	case a.DataType == xsdDateTime && b.DataType == xsdDateTime:
+		ta, tzA, errA := parseDateTime(a.str)
+		tb, tzB, errB := parseDateTime(b.str)
+		if errA == nil && errB == nil {
+			// Values with and without timezone are incomparable.
+			return tzA == tzB && ta.Equal(tb)
+		}
+	}
+	return a.DataType == b.DataType && a.str == b.str && a.lang == b.lang && a.dir == b.dir
+}
+
+// TermsValueEqual returns true if two Terms are equal. Literals are compared by
+// value (see LiteralsValueEqual), other Terms are compared as with TermsEqual.
+func TermsValueEqual(a, b Term) bool {
+	la, okA := a.(Literal)
+	lb, okB := b.(Literal)
+	if okA && okB {
+		return LiteralsValueEqual(la, lb)
+	}
+	return TermsEqual(a, b)
+}
+
 // TriplesEqual tests if two Triples are identical.
 func TriplesEqual(a, b Triple) bool {
 	return TermsEqual(a.Subj, b.Subj) && TermsEqual(a.Pred, b.Pred) && TermsEqual(a.Obj, b.Obj)
@@ -1,8 +1,13 @@
 package rdf
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIRI(t *testing.T) {
@@ -42,6 +47,12 @@ func TestLiteral(t *testing.T) {
 		{false, xsdBoolean, ""},
 		{"a", xsdString, ""},
 		{[]byte("123"), xsdByte, ""},
+		{big.NewInt(1), xsdInteger, ""},
+		{big.NewRat(1, 4), xsdDecimal, ""},
+		{big.NewFloat(2.5), xsdDecimal, ""},
+		{big.NewRat(1, 3), IRI{}, "1/3 has no finite decimal representation"},
+		{json.RawMessage(`{"a": [1, 2]}`), rdfJSON, ""},
+		{json.RawMessage(`{"a": `), IRI{}, "invalid JSON: \"{\\\"a\\\": \""},
 		{struct{ a, b string }{"1", "2"}, IRI{}, `cannot infer XSD datatype from struct { a string; b string }{a:"1", b:"2"}`},
 	}
 
@@ -72,9 +83,23 @@ func TestLiteral(t *testing.T) {
 	}{
 		{"en", ""},
 		{"en-GB", ""},
-		{"nb-no2", ""},
-		{"no-no-a", "invalid language tag: only one '-' allowed"},
-		{"1", "invalid language tag: unexpected character: '1'"},
+		{"zh-Hant-TW", ""},
+		{"sr-Latn-RS", ""},
+		{"es-419", ""},
+		{"zh-yue-HK", ""},
+		{"de-CH-1996", ""},
+		{"sl-rozaj-biske", ""},
+		{"en-US-u-islamcal", ""},
+		{"en-a-bbb-x-a-ccc", ""},
+		{"x-whatever", ""},
+		{"i-klingon", ""},
+		{"", "invalid language tag"},
+		{"nb-no2", "invalid language tag: unexpected subtag: \"no2\""},
+		{"no-no-a", "invalid language tag: extension \"a\" must have at least one subtag"},
+		{"en-x", "invalid language tag: private use must have at least one subtag"},
+		{"en--GB", "invalid language tag: empty subtag"},
+		{"1", "invalid language tag: invalid primary language subtag: \"1\""},
+		{"abcdefghi", "invalid language tag: subtag too long: \"abcdefghi\""},
 		{"fr-ø", "invalid language tag: unexpected character: 'ø'"},
 		{"en-", "invalid language tag: trailing '-' disallowed"},
 		{"-en", "invalid language tag: must start with a letter"},
@@ -98,3 +123,226 @@ func TestLiteral(t *testing.T) {
 
 	}
 }
+
+func TestNormalizeLangTag(t *testing.T) {
+	tests := []struct {
+		tag, want string
+	}{
+		{"EN", "en"},
+		{"en-gb", "en-GB"},
+		{"ZH-hant-tw", "zh-Hant-TW"},
+		{"es-419", "es-419"},
+		{"en-CA-x-CA", "en-CA-x-ca"},
+		{"sgn-BE-FR", "sgn-BE-FR"},
+		{"az-latn-x-latn", "az-Latn-x-latn"},
+		{"not--valid", "not--valid"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLangTag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeLangTag(%q) => %q; want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestLiteralBigNumbers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	lexTests := []struct {
+		input interface{}
+		want  string
+	}{
+		{huge, "123456789012345678901234567890"},
+		{big.NewInt(-42), "-42"},
+		{big.NewRat(1, 4), "0.25"},
+		{big.NewRat(-10, 1), "-10.0"},
+		{big.NewRat(0, 1), "0.0"},
+		{big.NewRat(1, 1000), "0.001"},
+		{big.NewFloat(1.5), "1.5"},
+	}
+	for _, tt := range lexTests {
+		l, err := NewLiteral(tt.input)
+		if err != nil {
+			t.Errorf("NewLiteral(%v) failed with %v", tt.input, err)
+			continue
+		}
+		if l.String() != tt.want {
+			t.Errorf("NewLiteral(%v).String() => %q; want %q", tt.input, l.String(), tt.want)
+		}
+	}
+
+	typedTests := []struct {
+		l    Literal
+		want interface{}
+	}{
+		{NewTypedLiteral("42", xsdInteger), 42},
+		{NewTypedLiteral("+42", xsdInteger), 42},
+		{NewTypedLiteral("123456789012345678901234567890", xsdInteger), huge},
+		{NewTypedLiteral("0.10", xsdDecimal), big.NewRat(1, 10)},
+		{NewTypedLiteral("-1.", xsdDecimal), big.NewRat(-1, 1)},
+		{NewTypedLiteral("123456789012345678901234567890.000001", xsdDecimal), new(big.Rat).Add(new(big.Rat).SetInt(huge), big.NewRat(1, 1000000))},
+		{NewTypedLiteral("1.5E0", xsdFloat), 1.5},
+		{NewTypedLiteral("2002-10-10T12:00:00-05:00", xsdDateTime), time.Date(2002, 10, 10, 12, 0, 0, 0, time.FixedZone("", -5*3600))},
+	}
+	for _, tt := range typedTests {
+		v, err := tt.l.Typed()
+		if err != nil {
+			t.Errorf("%v.Typed() failed with %v", tt.l, err)
+			continue
+		}
+		switch want := tt.want.(type) {
+		case *big.Int:
+			if got, ok := v.(*big.Int); !ok || got.Cmp(want) != 0 {
+				t.Errorf("%v.Typed() => %v; want %v", tt.l, v, want)
+			}
+		case *big.Rat:
+			if got, ok := v.(*big.Rat); !ok || got.Cmp(want) != 0 {
+				t.Errorf("%v.Typed() => %v; want %v", tt.l, v, want)
+			}
+		case time.Time:
+			if got, ok := v.(time.Time); !ok || !got.Equal(want) {
+				t.Errorf("%v.Typed() => %v; want %v", tt.l, v, want)
+			}
+		default:
+			if v != want {
+				t.Errorf("%v.Typed() => %v; want %v", tt.l, v, want)
+			}
+		}
+	}
+
+	for _, s := range []string{"1e3", "1/3", "", "+", "."} {
+		if _, err := NewTypedLiteral(s, xsdDecimal).Typed(); err == nil {
+			t.Errorf("%q^^xsd:decimal.Typed() => <no error>; want error", s)
+		}
+	}
+	for _, s := range []string{"1.0", "abc", "", "-"} {
+		if _, err := NewTypedLiteral(s, xsdInteger).Typed(); err == nil {
+			t.Errorf("%q^^xsd:integer.Typed() => <no error>; want error", s)
+		}
+	}
+}
+
+func TestLiteralCanonical(t *testing.T) {
+	tests := []struct {
+		l    Literal
+		want string
+	}{
+		{NewTypedLiteral("01", xsdInteger), "1"},
+		{NewTypedLiteral("+0", xsdInteger), "0"},
+		{NewTypedLiteral("-007", xsdInt), "-7"},
+		{NewTypedLiteral("01.50", xsdDecimal), "1.5"},
+		{NewTypedLiteral("2", xsdDecimal), "2.0"},
+		{NewTypedLiteral(".5", xsdDecimal), "0.5"},
+		{NewTypedLiteral("100", xsdDouble), "1.0E2"},
+		{NewTypedLiteral("-0.0015e1", xsdDouble), "-1.5E-2"},
+		{NewTypedLiteral("+INF", xsdDouble), "INF"},
+		{NewTypedLiteral("0.1", xsdFloat), "1.0E-1"},
+		{NewTypedLiteral("1", xsdBoolean), "true"},
+		{NewTypedLiteral("0", xsdBoolean), "false"},
+		{NewTypedLiteral("2002-10-10T12:00:00-05:00", xsdDateTime), "2002-10-10T17:00:00Z"},
+		{NewTypedLiteral("2002-10-10T12:00:00.500", xsdDateTime), "2002-10-10T12:00:00.5"},
+		{NewTypedLiteral("abc", xsdInteger), "abc"},
+		{NewTypedLiteral(" 1", xsdInteger), " 1"},
+		{NewTypedLiteral("x", IRI{str: "http://example.org/dt"}), "x"},
+		{NewTypedLiteral(`<a  b='1'/>`, xmlLiteral), `<a b="1"></a>`},
+		{NewTypedLiteral(`<P Class=x>a<BR>b`, rdfHTML), `<p class="x">a<br>b</p>`},
+		{NewTypedLiteral(`{ "b": 1.0, "a": [true, null, "\u0041"] }`, rdfJSON), `{"a":[true,null,"A"],"b":1}`},
+		{NewTypedLiteral(`{"a":`, rdfJSON), `{"a":`},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Canonical().String(); got != tt.want {
+			t.Errorf("%q^^%v.Canonical() => %q; want %q", tt.l.str, tt.l.DataType, got, tt.want)
+		}
+	}
+
+	l, _ := NewLangLiteral("hello", "EN-gb")
+	if got := l.Canonical().Lang(); got != "en-gb" {
+		t.Errorf("NewLangLiteral(\"hello\", \"EN-gb\").Canonical().Lang() => %q; want \"en-gb\"", got)
+	}
+}
+
+func TestTermsValueEqual(t *testing.T) {
+	en, _ := NewLangLiteral("a", "en")
+	EN, _ := NewLangLiteral("a", "EN")
+	fr, _ := NewLangLiteral("a", "fr")
+	tests := []struct {
+		a, b Term
+		want bool
+	}{
+		{NewTypedLiteral("01", xsdInteger), NewTypedLiteral("1", xsdInteger), true},
+		{NewTypedLiteral("1", xsdInteger), NewTypedLiteral("1.0", xsdDecimal), true},
+		{NewTypedLiteral("1", xsdInteger), NewTypedLiteral("1.0E0", xsdDouble), true},
+		{NewTypedLiteral("0.1", xsdDecimal), NewTypedLiteral("0.10", xsdDecimal), true},
+		{NewTypedLiteral("1", xsdInteger), NewTypedLiteral("2", xsdInteger), false},
+		{NewTypedLiteral("NaN", xsdDouble), NewTypedLiteral("NaN", xsdDouble), false},
+		{NewTypedLiteral("1", xsdInteger), NewTypedLiteral("1", xsdString), false},
+		{NewTypedLiteral("true", xsdBoolean), NewTypedLiteral("1", xsdBoolean), true},
+		{NewTypedLiteral("2002-10-10T12:00:00-05:00", xsdDateTime), NewTypedLiteral("2002-10-10T17:00:00Z", xsdDateTime), true},
+		{NewTypedLiteral("2002-10-10T17:00:00", xsdDateTime), NewTypedLiteral("2002-10-10T17:00:00Z", xsdDateTime), false},
+		{NewTypedLiteral("abc", xsdInteger), NewTypedLiteral("abc", xsdInteger), true},
+		{en, EN, true},
+		{en, fr, false},
+		{en, NewTypedLiteral("a", xsdString), false},
+		{IRI{str: "http://example.org/a"}, IRI{str: "http://example.org/a"}, true},
+		{IRI{str: "http://example.org/a"}, NewTypedLiteral("http://example.org/a", xsdString), false},
+	}
+	for _, tt := range tests {
+		if got := TermsValueEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("TermsValueEqual(%v, %v) => %v; want %v", tt.a.Serialize(NTriples), tt.b.Serialize(NTriples), got, tt.want)
+		}
+	}
+}
+
+func TestDirLangString(t *testing.T) {
+	if _, err := NewDirLangLiteral("שלום", "he", "up"); err == nil || err.Error() != `invalid base direction: "up"` {
+		t.Errorf("NewDirLangLiteral(\"שלום\", \"he\", \"up\") => %v; want invalid base direction", err)
+	}
+	want, err := NewDirLangLiteral("مرحبا", "ar", "rtl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.DataType != rdfDirLangString || want.Lang() != "ar" || want.Dir() != "rtl" {
+		t.Fatalf("NewDirLangLiteral(\"مرحبا\", \"ar\", \"rtl\") => %#v", want)
+	}
+	if got := want.Serialize(NTriples); got != `"مرحبا"@ar--rtl` {
+		t.Errorf("Serialize(NTriples) => %s; want \"مرحبا\"@ar--rtl", got)
+	}
+	ltr, _ := NewDirLangLiteral("مرحبا", "ar", "ltr")
+	if TermsEqual(want, ltr) || LiteralsValueEqual(want, ltr) {
+		t.Errorf("literals with different base direction compare as equal")
+	}
+
+	tests := []struct {
+		format Format
+		input  string
+	}{
+		{NTriples, `<http://ex/s> <http://ex/p> "مرحبا"@ar--rtl .`},
+		{Turtle, `<http://ex/s> <http://ex/p> "مرحبا"@ar--rtl .`},
+		{RDFXML, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:its="http://www.w3.org/2005/11/its" xmlns:ex="http://ex/">
+  <rdf:Description rdf:about="http://ex/s">
+    <ex:p xml:lang="ar" its:dir="rtl">مرحبا</ex:p>
+  </rdf:Description>
+</rdf:RDF>`},
+	}
+	for _, tt := range tests {
+		ts, err := NewTripleDecoder(bytes.NewBufferString(tt.input), tt.format).DecodeAll()
+		if err != nil {
+			t.Errorf("decoding %q failed with %v", tt.input, err)
+			continue
+		}
+		if len(ts) != 1 || !TermsEqual(ts[0].Obj, want) || ts[0].Obj.(Literal).Dir() != "rtl" {
+			t.Errorf("decoding %q => %v; want object %v", tt.input, ts, want)
+		}
+	}
+
+	qs, err := NewQuadDecoder(bytes.NewBufferString(`<http://ex/s> <http://ex/p> "مرحبا"@ar--rtl <http://ex/g> .`), NQuads).DecodeAll()
+	if err != nil || len(qs) != 1 || qs[0].Obj.(Literal).Dir() != "rtl" {
+		t.Errorf("decoding N-Quads => %v, %v; want directional literal", qs, err)
+	}
+
+	var b bytes.Buffer
+	enc := NewTripleEncoder(&b, Turtle)
+	enc.Encode(Triple{Subj: IRI{str: "http://ex/s"}, Pred: IRI{str: "http://ex/p"}, Obj: want})
+	enc.Close()
+	if !strings.Contains(b.String(), `"مرحبا"@ar--rtl`) {
+		t.Errorf("encoding Turtle => %s; want \"مرحبا\"@ar--rtl", b.String())
+	}
+}
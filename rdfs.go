@@ -0,0 +1,275 @@
+package rdf
+
+import "strings"
+
+const rdfsNS = "http://www.w3.org/2000/01/rdf-schema#"
+
+var (
+	rdfProperty = IRI{str: rdfNS + "Property"}
+
+	rdfsSubClassOf    = IRI{str: rdfsNS + "subClassOf"}
+	rdfsSubPropertyOf = IRI{str: rdfsNS + "subPropertyOf"}
+	rdfsDomain        = IRI{str: rdfsNS + "domain"}
+	rdfsRange         = IRI{str: rdfsNS + "range"}
+	rdfsResource      = IRI{str: rdfsNS + "Resource"}
+	rdfsClass         = IRI{str: rdfsNS + "Class"}
+	rdfsLiteral       = IRI{str: rdfsNS + "Literal"}
+	rdfsDatatype      = IRI{str: rdfsNS + "Datatype"}
+	rdfsMember        = IRI{str: rdfsNS + "member"}
+	rdfsContainerMP   = IRI{str: rdfsNS + "ContainerMembershipProperty"}
+)
+
+// RDFSRules are the names of the entailment rules applied by RDFSReasoner,
+// as named in https://www.w3.org/TR/rdf11-mt/#patterns-of-rdfs-entailment-informative,
+// and rdf1 of RDF entailment. The rule "rdfs-cmp" gives the axiomatic
+// triples rdf:_n rdf:type rdfs:ContainerMembershipProperty for the
+// container membership properties used as predicates.
+//
+// The rules rdf1, rdfs4a and rdfs4b, which type every predicate as
+// rdf:Property and every resource as rdfs:Resource, are often left out.
+var RDFSRules = []string{
+	"rdf1", "rdfs1", "rdfs2", "rdfs3", "rdfs4a", "rdfs4b", "rdfs5", "rdfs6",
+	"rdfs7", "rdfs8", "rdfs9", "rdfs10", "rdfs11", "rdfs12", "rdfs13", "rdfs-cmp",
+}
+
+// Derivation explains how a triple was inferred; by which rule, from
+// which premises.
+type Derivation struct {
+	Rule     string
+	Premises []Triple
+}
+
+// RDFSReasoner materializes the RDFS entailments of a set of triples, by
+// semi-naive forward chaining: each new triple, asserted or inferred, is
+// joined once with the triples known so far, so no inference is repeated.
+//
+// The triples are kept in an in-memory Graph, which is updated
+// incrementally as more triples are added. The reasoner is a TripleSource
+// of the asserted and inferred triples, which can be queried with QueryBGP.
+//
+// An RDFSReasoner is not safe for concurrent use.
+type RDFSReasoner struct {
+	g        *Graph                // asserted and inferred triples
+	rules    map[string]bool       // enabled rules
+	derived  map[string]Derivation // triple key -> derivation, of inferred triples
+	inferred []Triple              // inferred triples, in order of inference
+}
+
+// NewRDFSReasoner returns a reasoner of the triples of the source, which
+// applies the given rules, or all RDFSRules if none are given. The
+// entailments of the source are materialized before it returns.
+func NewRDFSReasoner(src TripleSource, rules ...string) *RDFSReasoner {
+	if len(rules) == 0 {
+		rules = RDFSRules
+	}
+	r := &RDFSReasoner{
+		g:       NewGraph(),
+		rules:   make(map[string]bool, len(rules)),
+		derived: make(map[string]Derivation),
+	}
+	for _, rule := range rules {
+		r.rules[rule] = true
+	}
+	if src != nil {
+		r.Add(src.Match(nil, nil, nil)...)
+	}
+	return r
+}
+
+// Add asserts the triples, and returns the triples which are inferred as
+// a consequence, in order of inference.
+func (r *RDFSReasoner) Add(ts ...Triple) []Triple {
+	var delta []Triple
+	for _, t := range ts {
+		if r.g.Add(t) {
+			delta = append(delta, t)
+		} else if k := t.Serialize(NTriples); r.isInferred(k) {
+			// An inferred triple which is now also asserted.
+			delete(r.derived, k)
+		}
+	}
+	n := len(r.inferred)
+	for len(delta) > 0 {
+		t := delta[0]
+		delta = delta[1:]
+		r.apply(t, func(inf Triple, rule string, premises ...Triple) {
+			if !r.g.Add(inf) {
+				return
+			}
+			r.derived[inf.Serialize(NTriples)] = Derivation{Rule: rule, Premises: premises}
+			r.inferred = append(r.inferred, inf)
+			delta = append(delta, inf)
+		})
+	}
+	return append([]Triple(nil), r.inferred[n:]...)
+}
+
+func (r *RDFSReasoner) isInferred(key string) bool {
+	_, ok := r.derived[key]
+	return ok
+}
+
+// Inferred returns the inferred triples which are not also asserted, in
+// order of inference.
+func (r *RDFSReasoner) Inferred() []Triple {
+	var ts []Triple
+	for _, t := range r.inferred {
+		if r.isInferred(t.Serialize(NTriples)) {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// Explain returns the derivation of an inferred triple. It returns false
+// if the triple is asserted, or not entailed.
+func (r *RDFSReasoner) Explain(t Triple) (Derivation, bool) {
+	d, ok := r.derived[t.Serialize(NTriples)]
+	return d, ok
+}
+
+// Graph returns the graph of asserted and inferred triples. It must not be
+// modified, other than through the reasoner.
+func (r *RDFSReasoner) Graph() *Graph {
+	return r.g
+}
+
+// Match returns the asserted and inferred triples matching the given
+// subject, predicate and object, where a nil value matches any term.
+func (r *RDFSReasoner) Match(s Subject, p Predicate, o Object) []Triple {
+	return r.g.Match(s, p, o)
+}
+
+// Count returns the number of asserted and inferred triples matching the
+// given subject, predicate and object.
+func (r *RDFSReasoner) Count(s Subject, p Predicate, o Object) int {
+	return r.g.Count(s, p, o)
+}
+
+// apply calls infer with each triple inferred by a single rule from the
+// new triple t, and the triples in the graph.
+func (r *RDFSReasoner) apply(t Triple, infer func(inf Triple, rule string, premises ...Triple)) {
+	emit := func(rule string, s Subject, p Predicate, o Object, premises ...Triple) {
+		if r.rules[rule] {
+			infer(Triple{Subj: s, Pred: p, Obj: o}, rule, premises...)
+		}
+	}
+	pIRI, _ := t.Pred.(IRI)
+	oNode, oIsNode := nodeSubject(t.Obj)
+
+	// Rules for any triple.
+	emit("rdf1", pIRI, rdfType, rdfProperty, t)
+	if l, ok := t.Obj.(Literal); ok && l.DataType.str != "" {
+		emit("rdfs1", l.DataType, rdfType, rdfsDatatype, t)
+	}
+	emit("rdfs4a", t.Subj, rdfType, rdfsResource, t)
+	if oIsNode {
+		emit("rdfs4b", oNode, rdfType, rdfsResource, t)
+	}
+	if isContainerMembership(pIRI) {
+		emit("rdfs-cmp", pIRI, rdfType, rdfsContainerMP, t)
+	}
+	if r.rules["rdfs2"] {
+		for _, d := range r.g.Match(pIRI, rdfsDomain, nil) {
+			emit("rdfs2", t.Subj, rdfType, d.Obj, d, t)
+		}
+	}
+	if r.rules["rdfs3"] && oIsNode {
+		for _, rg := range r.g.Match(pIRI, rdfsRange, nil) {
+			emit("rdfs3", oNode, rdfType, rg.Obj, rg, t)
+		}
+	}
+	if r.rules["rdfs7"] {
+		for _, sp := range r.g.Match(pIRI, rdfsSubPropertyOf, nil) {
+			if q, ok := sp.Obj.(IRI); ok {
+				emit("rdfs7", t.Subj, q, t.Obj, sp, t)
+			}
+		}
+	}
+
+	// Rules where t is a schema triple.
+	subjIRI, subjIsIRI := t.Subj.(IRI)
+	switch pIRI {
+	case rdfsDomain:
+		if subjIsIRI {
+			for _, u := range r.g.Match(nil, subjIRI, nil) {
+				emit("rdfs2", u.Subj, rdfType, t.Obj, t, u)
+			}
+		}
+	case rdfsRange:
+		if subjIsIRI {
+			for _, u := range r.g.Match(nil, subjIRI, nil) {
+				if s, ok := nodeSubject(u.Obj); ok {
+					emit("rdfs3", s, rdfType, t.Obj, t, u)
+				}
+			}
+		}
+	case rdfsSubPropertyOf:
+		if !oIsNode {
+			break
+		}
+		for _, sp := range r.g.Match(nil, rdfsSubPropertyOf, t.Subj.(Object)) {
+			emit("rdfs5", sp.Subj, rdfsSubPropertyOf, t.Obj, sp, t)
+		}
+		for _, sp := range r.g.Match(oNode, rdfsSubPropertyOf, nil) {
+			emit("rdfs5", t.Subj, rdfsSubPropertyOf, sp.Obj, t, sp)
+		}
+		q, ok := t.Obj.(IRI)
+		if subjIsIRI && ok {
+			for _, u := range r.g.Match(nil, subjIRI, nil) {
+				emit("rdfs7", u.Subj, q, u.Obj, t, u)
+			}
+		}
+	case rdfsSubClassOf:
+		if !oIsNode {
+			break
+		}
+		for _, sc := range r.g.Match(nil, rdfsSubClassOf, t.Subj.(Object)) {
+			emit("rdfs11", sc.Subj, rdfsSubClassOf, t.Obj, sc, t)
+		}
+		for _, sc := range r.g.Match(oNode, rdfsSubClassOf, nil) {
+			emit("rdfs11", t.Subj, rdfsSubClassOf, sc.Obj, t, sc)
+		}
+		for _, u := range r.g.Match(nil, rdfType, t.Subj.(Object)) {
+			emit("rdfs9", u.Subj, rdfType, t.Obj, t, u)
+		}
+	case rdfType:
+		if oIsNode {
+			for _, sc := range r.g.Match(oNode, rdfsSubClassOf, nil) {
+				emit("rdfs9", t.Subj, rdfType, sc.Obj, sc, t)
+			}
+		}
+		switch t.Obj {
+		case rdfProperty:
+			emit("rdfs6", t.Subj, rdfsSubPropertyOf, t.Subj.(Object), t)
+		case rdfsClass:
+			emit("rdfs8", t.Subj, rdfsSubClassOf, rdfsResource, t)
+			emit("rdfs10", t.Subj, rdfsSubClassOf, t.Subj.(Object), t)
+		case rdfsContainerMP:
+			emit("rdfs12", t.Subj, rdfsSubPropertyOf, rdfsMember, t)
+		case rdfsDatatype:
+			emit("rdfs13", t.Subj, rdfsSubClassOf, rdfsLiteral, t)
+		}
+	}
+}
+
+// nodeSubject returns the object as a subject, if it is an IRI or a blank
+// node; literals and triple terms can't be instances or classes.
+func nodeSubject(o Object) (Subject, bool) {
+	switch o := o.(type) {
+	case IRI:
+		return o, true
+	case Blank:
+		return o, true
+	}
+	return nil, false
+}
+
+// isContainerMembership reports whether the IRI is a container membership
+// property, rdf:_1, rdf:_2 and so on.
+func isContainerMembership(p IRI) bool {
+	if !strings.HasPrefix(p.str, rdfNS+"_") {
+		return false
+	}
+	return isLn(p.str[len(rdfNS):])
+}
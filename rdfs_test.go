@@ -0,0 +1,207 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+const rdfsTestSchema = `
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix : <http://example/> .
+:Dog rdfs:subClassOf :Mammal .
+:Mammal rdfs:subClassOf :Animal .
+:hasPuppy rdfs:subPropertyOf :hasChild .
+:hasChild rdfs:subPropertyOf :relative .
+:hasChild rdfs:domain :Animal .
+:hasChild rdfs:range :Animal .
+`
+
+const rdfsTestData = `
+@prefix : <http://example/> .
+:rex a :Dog ; :hasPuppy :fido .
+:fido :name "Fido" .
+`
+
+func TestRDFSReasoner(t *testing.T) {
+	want := []string{
+		"<http://example/Dog> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example/Animal> .\n",
+		"<http://example/fido> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Animal> .\n",
+		"<http://example/hasPuppy> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/relative> .\n",
+		"<http://example/rex> <http://example/hasChild> <http://example/fido> .\n",
+		"<http://example/rex> <http://example/relative> <http://example/fido> .\n",
+		"<http://example/rex> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Animal> .\n",
+		"<http://example/rex> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Mammal> .\n",
+	}
+	schema, data := mustDecodeTTL(rdfsTestSchema), mustDecodeTTL(rdfsTestData)
+	rules := []string{"rdfs2", "rdfs3", "rdfs5", "rdfs7", "rdfs9", "rdfs11"}
+
+	// All at once, and incrementally in both orders.
+	batch := NewRDFSReasoner(NewGraph(append(schema, data...)...), rules...)
+	schemaFirst := NewRDFSReasoner(NewGraph(schema...), rules...)
+	schemaFirst.Add(data...)
+	dataFirst := NewRDFSReasoner(NewGraph(data...), rules...)
+	dataFirst.Add(schema...)
+	oneByOne := NewRDFSReasoner(nil, rules...)
+	for _, tr := range append(data, schema...) {
+		oneByOne.Add(tr)
+	}
+
+	for name, r := range map[string]*RDFSReasoner{
+		"batch":        batch,
+		"schema first": schemaFirst,
+		"data first":   dataFirst,
+		"one by one":   oneByOne,
+	} {
+		if got := sortedNT(r.Inferred()); !equalStrings(got, want) {
+			t.Errorf("%s: Inferred() =>\n%v\nwant:\n%v", name, got, want)
+		}
+		if got, want := r.Graph().Len(), len(schema)+len(data)+len(want); got != want {
+			t.Errorf("%s: Graph().Len() => %d; want %d", name, got, want)
+		}
+	}
+
+	// Add returns the new inferences only.
+	r := NewRDFSReasoner(NewGraph(append(schema, data...)...), rules...)
+	added := r.Add(mustDecodeTTL(`<http://example/bella> a <http://example/Mammal> .`)...)
+	if got, want := sortedNT(added), []string{
+		"<http://example/bella> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Animal> .\n",
+	}; !equalStrings(got, want) {
+		t.Errorf("Add() =>\n%v\nwant:\n%v", got, want)
+	}
+
+	// Asserting an inferred triple makes it no longer inferred.
+	rexAnimal := mustDecodeTTL(`<http://example/rex> a <http://example/Animal> .`)[0]
+	if _, ok := r.Explain(rexAnimal); !ok {
+		t.Fatalf("Explain(%v) => false; want derivation", rexAnimal)
+	}
+	if added := r.Add(rexAnimal); len(added) != 0 {
+		t.Errorf("Add(inferred triple) => %v; want no inferences", added)
+	}
+	if _, ok := r.Explain(rexAnimal); ok {
+		t.Errorf("Explain(asserted triple) => true; want false")
+	}
+	for _, tr := range r.Inferred() {
+		if TermsEqual(tr.Subj, rexAnimal.Subj) && TermsEqual(tr.Obj, rexAnimal.Obj) && TermsEqual(tr.Pred, rdfType) {
+			t.Errorf("Inferred() includes asserted triple %v", tr)
+		}
+	}
+}
+
+func TestRDFSExplain(t *testing.T) {
+	// Triples are added in order, for deterministic derivations.
+	r := NewRDFSReasoner(nil)
+	r.Add(mustDecodeTTL(rdfsTestSchema)...)
+	r.Add(mustDecodeTTL(rdfsTestData)...)
+
+	// Every inference is explained by premises which are asserted, or
+	// inferred before it.
+	known := make(map[string]bool)
+	for _, tr := range r.Graph().Triples() {
+		if _, ok := r.Explain(tr); !ok {
+			known[tr.Serialize(NTriples)] = true
+		}
+	}
+	for _, tr := range r.Inferred() {
+		d, ok := r.Explain(tr)
+		if !ok {
+			t.Fatalf("Explain(%v) => false; want derivation", tr)
+		}
+		for _, p := range d.Premises {
+			if !known[p.Serialize(NTriples)] {
+				t.Errorf("Explain(%v) => %s with premise %v, which isn't known before", tr, d.Rule, p)
+			}
+		}
+		known[tr.Serialize(NTriples)] = true
+	}
+
+	tests := []struct {
+		triple   string
+		rule     string
+		premises []string
+	}{
+		{
+			`<http://example/rex> <http://example/relative> <http://example/fido> .`,
+			"rdfs7",
+			[]string{
+				`<http://example/hasPuppy> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/relative> .`,
+				`<http://example/rex> <http://example/hasPuppy> <http://example/fido> .`,
+			},
+		},
+		{
+			`<http://example/hasPuppy> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/relative> .`,
+			"rdfs5",
+			[]string{
+				`<http://example/hasPuppy> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/hasChild> .`,
+				`<http://example/hasChild> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/relative> .`,
+			},
+		},
+		{
+			`<http://example/fido> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Animal> .`,
+			"rdfs3",
+			[]string{
+				`<http://example/hasChild> <http://www.w3.org/2000/01/rdf-schema#range> <http://example/Animal> .`,
+				`<http://example/rex> <http://example/hasChild> <http://example/fido> .`,
+			},
+		},
+	}
+	for _, test := range tests {
+		d, ok := r.Explain(mustDecodeNT(test.triple)[0])
+		if !ok {
+			t.Errorf("Explain(%s) => false; want %s", test.triple, test.rule)
+			continue
+		}
+		var premises []string
+		for _, p := range d.Premises {
+			premises = append(premises, strings.TrimSuffix(p.Serialize(NTriples), "\n"))
+		}
+		if d.Rule != test.rule || !equalStrings(premises, test.premises) {
+			t.Errorf("Explain(%s) => %s %v; want %s %v", test.triple, d.Rule, premises, test.rule, test.premises)
+		}
+	}
+}
+
+func TestRDFSRules(t *testing.T) {
+	r := NewRDFSReasoner(NewGraph(mustDecodeTTL(`
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+@prefix : <http://example/> .
+:C a rdfs:Class .
+:p a rdf:Property .
+:m a rdfs:ContainerMembershipProperty .
+:s :q 1 .
+:bag rdf:_2 :x .
+`)...))
+	tests := []struct {
+		triple string
+		rule   string
+	}{
+		{`<http://example/q> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Property> .`, "rdf1"},
+		{`<http://www.w3.org/2001/XMLSchema#integer> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2000/01/rdf-schema#Datatype> .`, "rdfs1"},
+		{`<http://example/s> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2000/01/rdf-schema#Resource> .`, "rdfs4a"},
+		{`<http://example/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2000/01/rdf-schema#Resource> .`, "rdfs4b"},
+		{`<http://example/p> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://example/p> .`, "rdfs6"},
+		{`<http://example/C> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://www.w3.org/2000/01/rdf-schema#Resource> .`, "rdfs8"},
+		{`<http://example/C> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://example/C> .`, "rdfs10"},
+		{`<http://example/m> <http://www.w3.org/2000/01/rdf-schema#subPropertyOf> <http://www.w3.org/2000/01/rdf-schema#member> .`, "rdfs12"},
+		{`<http://www.w3.org/2001/XMLSchema#integer> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <http://www.w3.org/2000/01/rdf-schema#Literal> .`, "rdfs13"},
+		{`<http://www.w3.org/1999/02/22-rdf-syntax-ns#_2> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/2000/01/rdf-schema#ContainerMembershipProperty> .`, "rdfs-cmp"},
+		{`<http://example/bag> <http://www.w3.org/2000/01/rdf-schema#member> <http://example/x> .`, "rdfs7"},
+	}
+	for _, test := range tests {
+		if d, ok := r.Explain(mustDecodeNT(test.triple)[0]); !ok || d.Rule != test.rule {
+			t.Errorf("Explain(%s) => %v, %v; want rule %s", test.triple, d, ok, test.rule)
+		}
+	}
+
+	// The reasoner can be queried as a TripleSource.
+	x, c := Var("x"), Var("c")
+	it := QueryBGP(r, []TriplePattern{{Subj: x, Pred: rdfType, Obj: c}})
+	n := 0
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		n++
+	}
+	if want := r.Count(nil, rdfType, nil); n != want {
+		t.Errorf("QueryBGP over reasoner => %d solutions; want %d", n, want)
+	}
+}
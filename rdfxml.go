@@ -1,20 +1,18 @@
 package rdf
 
 import (
-	"bytes"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"regexp"
 	"runtime"
-	"strings"
-	"unicode/utf8"
 )
 
 const (
 	rdfNS = `http://www.w3.org/1999/02/22-rdf-syntax-ns#`
 	xmlNS = `http://www.w3.org/XML/1998/namespace`
+	itsNS = `http://www.w3.org/2005/11/its`
 )
 
 var (
@@ -26,6 +24,7 @@ var (
 	rdfPred      = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate"}
 	rdfObj       = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#object"}
 	rdfStatement = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement"}
+	rdfReifies   = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#reifies"}
 )
 
 var rgxpNCName = regexp.MustCompile(`^[\pL_][\d\pL\pM_.-]*$`)
@@ -35,8 +34,13 @@ type evalCtx struct {
 	Base string
 	Subj Subject
 	Lang string
+	Dir  string
 	LiN  int
-	NS   []string
+
+	// The subject and predicate of the next link triple of a
+	// parseType="Collection" list, when parsing its items.
+	ListSubj Subject
+	ListPred Predicate
 }
 
 // rdfXMLDecoder decodes Triples from an XML stream.
@@ -44,30 +48,38 @@ type evalCtx struct {
 // Deviations from the RDF/XML specification at http://www.w3.org/TR/rdf-syntax-grammar/ :
 // - A valid RDF/XML document cannot have to elements with the same ID, but this
 //   decoder only emits valid triples as soon as they are available in a stream, and then
-//   it's up to the consumer to decide what to do with duplicates.
+//   it's up to the consumer to decide what to do with duplicates, unless the StrictIDs
+//   option is set.
 type rdfXMLDecoder struct {
 	dec *xml.Decoder
 
 	// xml parser state
 	state     parseXMLFn // current state function
 	nextState parseXMLFn // which state function enter on the next call to Decode()
-	ns        []string   // prefix and namespaces (only from the top-level element, usually rdf:RDF)
+	ns        nsScope    // name space declarations of the open elements
+	popNS     bool       // pop the name space scope before the next token
 	base      string     // top level xml:base
 	bnodeN    int        // anonymous blank node counter
 	tok       xml.Token  // current XML token
+	tokLine   int        // line of the start of the current XML token
+	tokCol    int        // column of the start of the current XML token
 	topElem   string     // top level element (namespace+localname)
 	reifyID   string     // if not "", id to be resolved against the current in-scope Base IRI
 	dt        *IRI       // datatype of the Literal to be parsed
 	lang      string     // xml element in-scope xml:lang
+	dir       string     // xml element in-scope its:dir or rdf:dir
 	current   Triple     // the current triple beeing parsed
 	ctx       evalCtx    // current node evaluation context
 	ctxStack  []evalCtx  // stack of parent evaluation contexts
 
+	validate bool                // validate literals
+	ids      map[string]struct{} // rdf:IDs seen, if StrictIDs is set
+
 	triples []Triple // complete, valid triples to be emitted
 }
 
 func newRDFXMLDecoder(r io.Reader) *rdfXMLDecoder {
-	return &rdfXMLDecoder{dec: xml.NewDecoder(r), nextState: parseXMLTopElem}
+	return &rdfXMLDecoder{dec: xml.NewDecoder(r), ns: newNSScope(), nextState: parseXMLTopElem}
 }
 
 // SetOption sets a ParseOption to the give value
@@ -79,6 +91,22 @@ func (d *rdfXMLDecoder) SetOption(o ParseOption, v interface{}) error {
 			return fmt.Errorf("ParseOption \"Base\" must be an IRI.")
 		}
 		d.ctx.Base = iri.str
+		d.base = iri.str
+	case ValidateLiterals:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"ValidateLiterals\" must be a bool.")
+		}
+		d.validate = b
+	case StrictIDs:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"StrictIDs\" must be a bool.")
+		}
+		d.ids = nil
+		if b {
+			d.ids = make(map[string]struct{})
+		}
 	default:
 		return fmt.Errorf("RDF/XML decoder doesn't support option: %v", o)
 	}
@@ -91,6 +119,9 @@ func (d *rdfXMLDecoder) Decode() (t Triple, err error) {
 	defer d.recover(&err)
 
 	if len(d.triples) == 0 {
+		// Reuse the buffer of emitted triples.
+		d.triples = d.triples[:0]
+
 		// Run the parser state machine.
 		d.nextXMLToken()
 		for d.state = d.nextState; d.state != nil; {
@@ -136,23 +167,13 @@ func parseXMLTopElem(d *rdfXMLDecoder) parseXMLFn {
 		// parsing when we reach the corresponding closing tag.
 		d.topElem = elem.Name.Space + elem.Name.Local
 
-		// Store prefix and namespaces. Go's XML encoder provides the
-		// name space in eachr xml.StartElement, but we need the space
-		// to prefix mapping in case of any parseType="Literal".
-		d.storePrefixNS(elem)
+		d.storeBase(elem)
 
 		// Store top-level base
 		if as := attrXML(elem, "base"); as != nil {
 			d.base = as[0].Value
 		}
 
-		// Store top-level prefix and namespaces
-		if as := attrXMLNS(elem); as != nil {
-			for _, a := range as {
-				d.ns = append(d.ns, a.Value, a.Name.Local)
-			}
-		}
-
 		if elem.Name.Space != rdfNS || elem.Name.Local != "RDF" {
 			// When there is only one top-level node element,
 			// rdf:RDF can be omitted.
@@ -176,63 +197,19 @@ func parseXMLNodeElem(d *rdfXMLDecoder) parseXMLFn {
 		if elem.Name.Space == rdfNS {
 			switch elem.Name.Local {
 			case "Description":
-				d.storePrefixNS(elem)
-
-				if as := attrRDF(elem, "about"); as != nil {
-					d.current.Subj = IRI{str: d.resolve(d.ctx.Base, as[0].Value)}
-				}
-
-				if as := attrRDF(elem, "ID"); as != nil {
-					if a := attrRDF(elem, "nodeID"); a != nil {
-						panic(errors.New("A node element cannot have both rdf:ID and rdf:nodeID"))
-					}
-
-					// http://www.w3.org/TR/rdf-syntax-grammar/#section-Syntax-ID-xml-base
-					d.current.Subj = IRI{str: d.resolve(d.ctx.Base, "#"+as[0].Value)}
-				}
-
-				if as := attrRDF(elem, "nodeID"); as != nil {
-					if a := attrRDF(elem, "about"); a != nil {
-						panic(errors.New("A node element cannot have both rdf:about and rdf:nodeID"))
-					}
-					d.current.Subj = Blank{id: fmt.Sprintf("_:%s", as[0].Value)}
-				}
-
-				if as := attrRDF(elem, "type"); as != nil {
-					d.current.Pred = rdfType
-					d.current.Obj = IRI{str: d.resolve(d.ctx.Base, as[0].Value)}
-					d.triples = append(d.triples, d.current)
-
-					d.nextState = parseXMLPropElemOrNodeEnd
-					return nil
-
-					// TODO what if as := attrRest(elem); as != nil ?
-				}
+				d.storeBase(elem)
+				d.current.Subj = d.nodeSubject(elem)
 
 				if l := attrXML(elem, "lang"); l != nil {
+					checkXMLLang(l[0].Value)
 					d.ctx.Lang = l[0].Value
 				}
 
-				if len(elem.Attr) == 0 || d.current.Subj == nil {
-					// A rdf:Description with no ID or about attribute describes an
-					// un-named resource, aka a bNode.
-					d.current.Subj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-					d.bnodeN++
+				if dir := attrDir(elem); dir != nil {
+					d.ctx.Dir = dir[0].Value
 				}
 
-				if as := attrRest(elem); as != nil {
-					// When a property element's content is string literal, it may be possible
-					// to use it as an XML attribute on the containing node element. This can be
-					// done for multiple properties on the same node element only if the property
-					// element name is not repeated (required by XML — attribute names are unique
-					// on an XML element) and any in-scope xml:lang on the property element's
-					// string literal (if any) are the same.
-					for _, a := range as {
-						d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
-						d.parseObjLiteral(a.Value)
-						d.triples = append(d.triples, d.current)
-					}
-
+				if d.parsePropAttrs(elem, attrRest(elem)) {
 					// We now have one or more complete triples and can return.
 					// On the next call to Decode(), continue looking for property elements,
 					// or the end of the containing node element.
@@ -245,8 +222,8 @@ func parseXMLNodeElem(d *rdfXMLDecoder) parseXMLFn {
 				d.nextXMLToken()
 				return parseXMLPropElem
 			case "Bag", "Seq", "Alt":
-				d.storePrefixNS(elem)
-				d.pushContext() // TODO explain why?
+				d.storeBase(elem)
+				d.ctx.LiN = 0 // rdf:li elements are numbered per container
 
 				// Handled as typed node element below
 			case "li", "RDF", "ID", "bagID", "about", "parseType", "resource", "nodeID", "aboutEach", "aboutEachPrefix":
@@ -260,32 +237,23 @@ func parseXMLNodeElem(d *rdfXMLDecoder) parseXMLFn {
 		// when the element is a typed node element.
 		// http://www.w3.org/TR/rdf-syntax-grammar/#section-Syntax-typed-nodes
 
-		if as := attrRDF(elem, "about"); as != nil {
-			d.current.Subj = IRI{str: d.resolve(d.ctx.Base, as[0].Value)}
-		}
+		d.storeBase(elem)
+		d.current.Subj = d.nodeSubject(elem)
 
-		if as := attrRDF(elem, "ID"); as != nil {
-			// http://www.w3.org/TR/rdf-syntax-grammar/#section-Syntax-ID-xml-base
-			d.current.Subj = IRI{str: d.resolve(d.ctx.Base, "#"+as[0].Value)}
+		if l := attrXML(elem, "lang"); l != nil {
+			checkXMLLang(l[0].Value)
+			d.ctx.Lang = l[0].Value
 		}
 
-		if d.current.Subj == nil {
-			// A typed element without with no attributes
-			d.current.Subj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-			d.bnodeN++
+		if dir := attrDir(elem); dir != nil {
+			d.ctx.Dir = dir[0].Value
 		}
 
 		d.current.Pred = rdfType
 		d.current.Obj = IRI{elem.Name.Space + elem.Name.Local}
 		d.triples = append(d.triples, d.current)
 
-		if as := attrRestWithLn(elem); as != nil {
-			for _, a := range as {
-				d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
-				d.parseObjLiteral(a.Value)
-				d.triples = append(d.triples, d.current)
-			}
-		}
+		d.parsePropAttrs(elem, attrRestWithLn(elem))
 
 		d.nextState = parseXMLPropElemOrNodeEnd
 		return nil
@@ -307,6 +275,98 @@ func parseXMLNodeElem(d *rdfXMLDecoder) parseXMLFn {
 	}
 }
 
+// nodeSubject returns the subject of a node element; the IRI given by its
+// rdf:about or rdf:ID attribute, the blank node given by its rdf:nodeID
+// attribute, or else a new blank node.
+func (d *rdfXMLDecoder) nodeSubject(elem xml.StartElement) Subject {
+	about, id, nodeID := attrRDF(elem, "about"), attrRDF(elem, "ID"), attrRDF(elem, "nodeID")
+	switch {
+	case id != nil && nodeID != nil:
+		panic(errors.New("A node element cannot have both rdf:ID and rdf:nodeID"))
+	case about != nil && nodeID != nil:
+		panic(errors.New("A node element cannot have both rdf:about and rdf:nodeID"))
+	case about != nil && id != nil:
+		panic(errors.New("A node element cannot have both rdf:about and rdf:ID"))
+	case id != nil:
+		// http://www.w3.org/TR/rdf-syntax-grammar/#section-Syntax-ID-xml-base
+		iri := d.resolve(d.ctx.Base, "#"+id[0].Value)
+		d.checkID(iri)
+		return IRI{str: iri}
+	case about != nil:
+		return IRI{str: d.resolve(d.ctx.Base, about[0].Value)}
+	case nodeID != nil:
+		return Blank{id: fmt.Sprintf("_:%s", nodeID[0].Value)}
+	}
+	// A node element with no rdf:ID, rdf:about or rdf:nodeID attribute
+	// describes an un-named resource, aka a bNode.
+	b := Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+	d.bnodeN++
+	return b
+}
+
+// parsePropAttrs emits the triples of the rdf:type attribute and the property
+// attributes as of a node element, given the subject. It reports whether
+// any triples were emitted.
+func (d *rdfXMLDecoder) parsePropAttrs(elem xml.StartElement, as []xml.Attr) bool {
+	emitted := false
+	if a := attrRDF(elem, "type"); a != nil {
+		d.current.Pred = rdfType
+		d.current.Obj = IRI{str: d.resolve(d.ctx.Base, a[0].Value)}
+		d.triples = append(d.triples, d.current)
+		emitted = true
+	}
+	// When a property element's content is string literal, it may be possible
+	// to use it as an XML attribute on the containing node element. This can be
+	// done for multiple properties on the same node element only if the property
+	// element name is not repeated (required by XML — attribute names are unique
+	// on an XML element) and any in-scope xml:lang on the property element's
+	// string literal (if any) are the same.
+	for _, a := range as {
+		d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
+		d.parseObjLiteral(a.Value, d.tokLine, d.tokCol)
+		d.triples = append(d.triples, d.current)
+		emitted = true
+	}
+	return emitted
+}
+
+// checkID fails if the IRI of an rdf:ID has been seen before, when the
+// StrictIDs option is set.
+func (d *rdfXMLDecoder) checkID(iri string) {
+	if d.ids == nil {
+		return
+	}
+	if _, ok := d.ids[iri]; ok {
+		panic(fmt.Errorf("duplicate rdf:ID: %s", iri))
+	}
+	d.ids[iri] = struct{}{}
+}
+
+// parseXMLObjNodeElem parses a node element which is the object of the
+// current property element, and emits the triple linking them before the
+// triples of the node element.
+func parseXMLObjNodeElem(d *rdfXMLDecoder) parseXMLFn {
+	// We need to push stack twice, since popContext is called on the
+	// closing of both property element tag, and node element tag.
+	d.pushContext()
+	d.pushContext()
+
+	link := d.current
+	n := len(d.triples)
+	d.current.Subj = nil
+	next := parseXMLNodeElem(d)
+	link.Obj = d.current.Subj.(Object)
+
+	node := d.current
+	rest := append([]Triple(nil), d.triples[n:]...)
+	d.triples = append(d.triples[:n], link)
+	d.current = link
+	d.reifyCheck()
+	d.current = node
+	d.triples = append(d.triples, rest...)
+	return next
+}
+
 // parseXMLPropElemOrNodeEnd parses property elements of a containing
 // element node, or the end of that element node.
 func parseXMLPropElemOrNodeEnd(d *rdfXMLDecoder) parseXMLFn {
@@ -338,6 +398,13 @@ func parseXMLPropElemOrNodeEnd(d *rdfXMLDecoder) parseXMLFn {
 		// Restore parent context, if any:
 		d.popContext()
 
+		if d.ctx.ListSubj != nil {
+			// The node element was an item of a collection; continue
+			// with the next item, or the end of the collection.
+			d.nextXMLToken()
+			return parseXMLCollItem
+		}
+
 		if d.current.Subj != nil {
 			// Parent context restored, with subject set.
 			// Continue looking for property elements, or the closing
@@ -360,6 +427,7 @@ func parseXMLPropElemOrNodeEnd(d *rdfXMLDecoder) parseXMLFn {
 // This function will establish the object of the triple.
 func parseXMLCharDataOrElemNode(d *rdfXMLDecoder) parseXMLFn {
 	var charData string
+	var line, col int
 
 first:
 	switch elem := d.tok.(type) {
@@ -367,35 +435,13 @@ first:
 		// Could be string literal or the white space between two tokens,
 		// store it until we know.
 		charData = string(elem)
+		line, col = d.tokLine, d.tokCol
 	case xml.StartElement:
-		// Entering a new element. We need to push current context to stack:
-		d.pushContext()
-		d.pushContext() // TODO doc why twice
-
-		if elem.Name.Space == rdfNS {
-			switch elem.Name.Local {
-			case "Description":
-				// A new element
-				if len(elem.Attr) == 0 {
-					// Element is a blank node
-					d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-					d.bnodeN++
-					d.triples = append(d.triples, d.current)
-
-					d.current.Subj = d.current.Obj.(Subject)
-					d.nextState = parseXMLPropElemOrNodeEnd
-					return nil
-				}
-			default:
-				panic(fmt.Errorf("parseXMLCharDataOrElemNode first: TODO rdf:!Decsription"))
-			}
-		} else {
-			panic(fmt.Errorf("parseXMLCharDataOrElemNode first: TODO not rdf name space"))
-		}
-
+		// A new node element, directly after the property element start tag.
+		return parseXMLObjNodeElem
 	case xml.EndElement:
 		// It's an empty string literal
-		d.parseObjLiteral("")
+		d.parseObjLiteral("", d.tokLine, d.tokCol)
 
 		// Emit the complete triple and return
 		d.triples = append(d.triples, d.current)
@@ -412,81 +458,15 @@ first:
 	d.nextXMLToken()
 
 second:
-	switch elem := d.tok.(type) {
+	switch d.tok.(type) {
 	case xml.StartElement:
 		// A new node element.
 		// (it means that charData was only whitespace between tokens)
-
-		// We need to push stack twice, since popContext is called on the
-		// closing of both property element tag, and node element tag.
-		d.pushContext()
-		d.pushContext()
-
-		if elem.Name.Space == rdfNS {
-			switch elem.Name.Local {
-			case "Description":
-				// A new element
-
-				d.storePrefixNS(elem)
-
-				if as := attrRest(elem); as != nil {
-					// Element is an anonymous blank node
-					d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-					d.bnodeN++
-					d.triples = append(d.triples, d.current)
-					d.reifyCheck()
-
-					d.current.Subj = d.current.Obj.(Subject)
-
-					// Construct triples from attribute elements
-					for _, a := range as {
-						d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
-						d.parseObjLiteral(a.Value)
-						d.triples = append(d.triples, d.current)
-					}
-
-					d.nextState = parseXMLPropElemOrNodeEnd
-					return nil
-				}
-
-				if as := attrRDF(elem, "nodeID"); as != nil {
-					d.current.Obj = Blank{id: fmt.Sprintf("_:%s", as[0].Value)}
-					d.triples = append(d.triples, d.current)
-					d.reifyCheck()
-
-					d.current.Subj = d.current.Obj.(Subject)
-					d.nextState = parseXMLPropElemOrNodeEnd
-					return nil
-				}
-
-				// Default case, Element is a blank node
-				d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-				d.bnodeN++
-				d.triples = append(d.triples, d.current)
-				d.reifyCheck()
-
-				d.current.Subj = d.current.Obj.(Subject)
-				d.nextState = parseXMLPropElemOrNodeEnd
-				return nil
-
-			default:
-				panic(fmt.Errorf("parseXMLCharDataOrElemNode second: TODO rdf:!Description"))
-			}
-		} else {
-			if as := attrRDF(elem, "about"); as != nil {
-				d.current.Obj = IRI{str: as[0].Value}
-				d.triples = append(d.triples, d.current)
-
-				d.current.Subj = d.current.Obj.(Subject)
-				d.nextState = parseXMLPropElemOrNodeEnd
-				return nil
-			}
-			panic(fmt.Errorf("parseXMLCharDataOrElemNode second: TODO not rdf name space, not rdf:about"))
-		}
+		return parseXMLObjNodeElem
 	case xml.EndElement:
 		// The closing of the property element; it meanst hat charData
 		// represents the string literal as the object.
-		d.parseObjLiteral(charData)
+		d.parseObjLiteral(charData, line, col)
 
 		// Emit the complete triple and return
 		d.triples = append(d.triples, d.current)
@@ -505,6 +485,7 @@ func parseXMLPropElemEnd(d *rdfXMLDecoder) parseXMLFn {
 	case xml.EndElement:
 		d.reifyCheck()
 		d.lang = "" // clear the in-scope xml:lang
+		d.dir = ""  // clear the in-scope base direction
 
 		return nil
 	case xml.CharData, xml.Comment, xml.ProcInst:
@@ -519,7 +500,7 @@ func parseXMLPropElemEnd(d *rdfXMLDecoder) parseXMLFn {
 func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 	switch elem := d.tok.(type) {
 	case xml.StartElement:
-		d.storePrefixNS(elem)
+		d.storeBase(elem)
 
 		if elem.Name.Space == rdfNS {
 			switch elem.Name.Local {
@@ -563,9 +544,6 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 				//return nil
 			case "Collection":
 				// http://www.w3.org/TR/rdf-syntax-grammar/#section-Syntax-parsetype-Collection
-				// If we emit triple by triple, we have to keep track of more state to know
-				// we're in a collection, to know previous and next nodes and so on, so we
-				// parse all items in one go in the followeing state function:
 				return parseXMLColl
 			default: // case "Literal"
 				// All rdf:parseType attribute values other than the strings "Resource",
@@ -578,6 +556,7 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 				// The inner tokens and character data are stored as an XML literal
 				d.parseXMLLiteral(elem)
 				d.triples = append(d.triples, d.current)
+				d.reifyCheck()
 
 				d.nextState = parseXMLPropElemOrNodeEnd
 				return nil
@@ -599,7 +578,7 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 				d.current.Subj = d.current.Obj.(Subject)
 				for _, a := range ar {
 					d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
-					d.parseObjLiteral(a.Value)
+					d.parseObjLiteral(a.Value, d.tokLine, d.tokCol)
 					d.triples = append(d.triples, d.current)
 				}
 				d.popContext()
@@ -626,13 +605,27 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 
 		if a := attrRDF(elem, "datatype"); a != nil {
 			d.dt = &IRI{str: d.resolve(d.ctx.Base, a[0].Value)}
+			if *d.dt == rdfHTML {
+				d.dt = nil
+				d.parseHTMLLiteral(elem)
+				d.triples = append(d.triples, d.current)
+				d.reifyCheck()
+
+				d.nextState = parseXMLPropElemOrNodeEnd
+				return nil
+			}
 		} else {
 			// Only check for xml:lang if datatype not found
 			// TODO or error if both?
 			if l := attrXML(elem, "lang"); l != nil {
 				// store as in-scope lang
+				checkXMLLang(l[0].Value)
 				d.lang = l[0].Value
 			}
+			if dir := attrDir(elem); dir != nil {
+				// store as in-scope base direction
+				d.dir = dir[0].Value
+			}
 		}
 
 		if as := attrRest(elem); as != nil {
@@ -654,7 +647,7 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 			d.current.Subj = d.current.Obj.(Subject)
 			for _, a := range as {
 				d.current.Pred = IRI{str: a.Name.Space + a.Name.Local}
-				d.parseObjLiteral(a.Value)
+				d.parseObjLiteral(a.Value, d.tokLine, d.tokCol)
 				d.triples = append(d.triples, d.current)
 			}
 
@@ -675,158 +668,151 @@ func parseXMLPropElem(d *rdfXMLDecoder) parseXMLFn {
 	}
 }
 
-// parseXMLColl parses collections; all the node elements in a property
-// element with attribute parseType="Collection".
-// Subject an Predicate is set.
+// parseXMLColl parses the start of a property element with attribute
+// parseType="Collection". Its node elements are the items of a list,
+// which is the object of the property. Subject and Predicate is set.
 func parseXMLColl(d *rdfXMLDecoder) parseXMLFn {
-	d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-	d.bnodeN++
+	d.ctx.ListSubj = d.current.Subj
+	d.ctx.ListPred = d.current.Pred
+	d.nextXMLToken()
+	return parseXMLCollItem
+}
 
-	d.triples = append(d.triples, d.current)
+// parseXMLCollItem parses the node elements of a collection, one at a time,
+// or the end of the collection. Each item gets a new list cell, linked from
+// the previous cell, or from the subject of the property for the first.
+func parseXMLCollItem(d *rdfXMLDecoder) parseXMLFn {
+	switch d.tok.(type) {
+	case xml.StartElement:
+		cell := Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+		d.bnodeN++
+		d.linkList(cell)
+		d.ctx.ListSubj, d.ctx.ListPred = cell, rdfRest
 
-	d.current.Subj = d.current.Obj.(Subject)
-	tag := d.tok.(xml.StartElement).Name.Space + d.tok.(xml.StartElement).Name.Local
-	first := true
-outer:
-	for {
+		// The item is a node element; its end tag restores the context
+		// of the collection.
+		d.pushContext()
+		n := len(d.triples)
+		d.current.Subj = nil
+		next := parseXMLNodeElem(d)
+		first := Triple{Subj: cell, Pred: rdfFirst, Obj: d.current.Subj.(Object)}
+		d.triples = append(d.triples, Triple{})
+		copy(d.triples[n+1:], d.triples[n:])
+		d.triples[n] = first
+		return next
+	case xml.EndElement:
+		// The end of the property element; add final statement
+		// marking the end of the collection.
+		d.linkList(rdfNil)
+		d.ctx.ListSubj, d.ctx.ListPred = nil, nil
+		d.nextState = parseXMLPropElemOrNodeEnd
+		return nil
+	default: // xml.CharData, xml.Comment etc
 		d.nextXMLToken()
-
-		switch elem := d.tok.(type) {
-		case xml.StartElement:
-			if elem.Name.Space == rdfNS && elem.Name.Local == "Description" {
-				if a := attrRDF(elem, "about"); a != nil {
-					if first {
-						d.current.Pred = rdfFirst
-						d.current.Obj = IRI{str: a[0].Value}
-						d.triples = append(d.triples, d.current)
-						first = false
-					} else {
-						d.current.Pred = rdfRest
-						d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-						d.bnodeN++
-						d.triples = append(d.triples, d.current)
-
-						d.current.Subj = d.current.Obj.(Subject)
-						d.current.Pred = rdfFirst
-						d.current.Obj = IRI{str: a[0].Value}
-						d.triples = append(d.triples, d.current)
-					}
-				} else {
-					panic(fmt.Errorf("parseXMLColl: TODO <rdf:Description> element without rdf:about %v", elem))
-				}
-			} else {
-				panic(fmt.Errorf("parseXMLColl: TODO element node not <rdf:Description> %v", elem))
-			}
-		case xml.EndElement:
-			if elem.Name.Space+elem.Name.Local == tag {
-				// we're done
-				break outer
-			}
-		default: // xml.CharData, xml.Comment etc
-			continue outer
-		}
+		return parseXMLCollItem
 	}
+}
 
-	// add final statement marking the end of the collection
-	d.current.Pred = rdfRest
-	d.current.Obj = rdfNil
-	d.triples = append(d.triples, d.current)
-
-	return nil
+// linkList emits the triple linking the previous cell of a collection, or
+// the subject of its property, to the object. The triple of the property
+// is reified if it has an rdf:ID.
+func (d *rdfXMLDecoder) linkList(obj Object) {
+	link := Triple{Subj: d.ctx.ListSubj, Pred: d.ctx.ListPred, Obj: obj}
+	d.triples = append(d.triples, link)
+	if link.Pred != rdfRest {
+		cur := d.current
+		d.current = link
+		d.reifyCheck()
+		d.current = cur
+	}
 }
 
 // parseObjLiteral parses the object from the given character data,
-// making sure it get's the in-scope xml:lang and correct datatype.
-func (d *rdfXMLDecoder) parseObjLiteral(data string) {
+// making sure it get's the in-scope xml:lang and correct datatype. The
+// line and column of the start of the data are used to report ill-typed
+// literals.
+func (d *rdfXMLDecoder) parseObjLiteral(data string, line, col int) {
 	if d.dt != nil {
-		d.current.Obj = Literal{str: data, DataType: *d.dt, lang: d.lang}
+		l := Literal{str: data, DataType: *d.dt, lang: d.lang}
 		d.dt = nil
+		if d.validate {
+			if err := checkLiteral(l, line, col); err != nil {
+				panic(err)
+			}
+		}
+		d.current.Obj = l.canonicalMarkup()
 	} else if d.lang != "" {
-		d.current.Obj = Literal{str: data, DataType: rdfLangString, lang: d.lang}
+		d.current.Obj = d.langLiteral(data, d.lang)
 	} else if d.ctx.Lang != "" {
-		d.current.Obj = Literal{str: data, DataType: rdfLangString, lang: d.ctx.Lang}
+		d.current.Obj = d.langLiteral(data, d.ctx.Lang)
 	} else {
 		d.current.Obj = Literal{str: data, DataType: xsdString}
 	}
 }
 
-// parseXMLLiteral parses XML literals, making sure to declare any
-// name spaces used (so that the result is a self-contained XML document).
+// langLiteral returns a language tagged string literal, with the in-scope
+// base direction, if any.
+func (d *rdfXMLDecoder) langLiteral(data, lang string) Literal {
+	dir := d.dir
+	if dir == "" {
+		dir = d.ctx.Dir
+	}
+	if dir != "" {
+		return Literal{str: data, DataType: rdfDirLangString, lang: lang, dir: dir}
+	}
+	return Literal{str: data, DataType: rdfLangString, lang: lang}
+}
+
+// parseXMLLiteral parses XML literals into their canonical form, making sure
+// to declare any name spaces used (so that the result is a self-contained XML
+// document).
 func (d *rdfXMLDecoder) parseXMLLiteral(elem xml.StartElement) {
-	var b bytes.Buffer
-	curTok := elem.Name.Space + elem.Name.Local
-	prefixes := make(map[string]struct{})
-parseLiteral:
-	for {
+	d.current.Obj = Literal{
+		str:      d.parseXMLContent(elem),
+		DataType: xmlLiteral,
+	}
+}
+
+// parseHTMLLiteral parses the content of a property element with datatype
+// rdf:HTML into a rdf:HTML literal in canonical form. As an extension to
+// RDF/XML, which only allows text for typed literals, the content can be
+// markup, like with parseType="Literal".
+func (d *rdfXMLDecoder) parseHTMLLiteral(elem xml.StartElement) {
+	d.current.Obj = Literal{
+		str:      parseHTML(d.parseXMLContent(elem)).String(),
+		DataType: rdfHTML,
+	}
+}
+
+// parseXMLContent parses the content of the element, up to and including
+// its end tag, and returns it as canonical XML.
+func (d *rdfXMLDecoder) parseXMLContent(elem xml.StartElement) string {
+	c := xmlCanonicalizer{outer: d.ns.lookupPrefix}
+	c.declare(elem)
+	for depth := 0; ; {
 		d.nextXMLToken()
-		switch elem := d.tok.(type) {
+		switch d.tok.(type) {
 		case xml.StartElement:
-			b.Write([]byte("<"))
-			if elem.Name.Space != "" {
-				b.WriteString(d.getPrefix(elem.Name.Space))
-				b.Write([]byte(":"))
-				b.WriteString(elem.Name.Local)
-				if _, ok := prefixes[elem.Name.Space]; !ok {
-					b.Write([]byte(" xmlns:"))
-					b.WriteString(d.getPrefix(elem.Name.Space))
-					b.Write([]byte("=\""))
-					b.WriteString(elem.Name.Space)
-					b.Write([]byte("\""))
-					prefixes[elem.Name.Space] = struct{}{}
-				}
-			} else {
-				b.WriteString(elem.Name.Local)
-			}
-			for _, a := range elem.Attr {
-				b.Write([]byte(" "))
-				if a.Name.Space != "" {
-					b.WriteString(d.getPrefix(a.Name.Space))
-					b.Write([]byte(":"))
-					b.WriteString(a.Name.Local)
-					if _, ok := prefixes[a.Name.Space]; !ok {
-						b.Write([]byte(" xmlns:"))
-						b.WriteString(d.getPrefix(a.Name.Space))
-						b.Write([]byte("=\""))
-						b.WriteString(a.Name.Space)
-						b.Write([]byte("\""))
-						prefixes[a.Name.Space] = struct{}{}
-					}
-				} else {
-					b.WriteString(a.Name.Local)
-				}
-				b.Write([]byte("=\""))
-				b.WriteString(a.Value)
-				b.Write([]byte("\""))
-			}
-			b.Write([]byte(">"))
+			depth++
 		case xml.EndElement:
-			if elem.Name.Space+elem.Name.Local == curTok {
+			if depth == 0 {
 				// We're done
-				break parseLiteral
-			}
-			b.Write([]byte("</"))
-			if elem.Name.Space != "" {
-				b.WriteString(d.getPrefix(elem.Name.Space))
-				b.Write([]byte(":"))
+				return c.buf.String()
 			}
-			b.WriteString(elem.Name.Local)
-			b.Write([]byte(">"))
-		case xml.CharData:
-			b.Write(elem)
-		default:
-			panic(fmt.Errorf("parseXMLPropElem: TODO parseType=Literal & token: %v", elem))
+			depth--
+		}
+		if err := c.token(d.tok); err != nil {
+			panic(err)
 		}
-	}
-
-	d.current.Obj = Literal{
-		str:      b.String(),
-		DataType: xmlLiteral,
 	}
 }
 
+// reifyCheck emits the reification of the current triple, if its property
+// element has an rdf:ID.
 func (d *rdfXMLDecoder) reifyCheck() {
 	if d.reifyID != "" {
 		iri := IRI{str: d.resolve(d.ctx.Base, d.reifyID)}
+		d.checkID(iri.str)
 		d.triples = append(d.triples,
 			Triple{
 				Subj: iri,
@@ -854,53 +840,25 @@ func (d *rdfXMLDecoder) reifyCheck() {
 
 // getPrefix returns the in-scope prefix for the given name space.
 func (d *rdfXMLDecoder) getPrefix(ns string) string {
-	// First check for context local declarations
-	for i := 0; i < len(d.ctx.NS); i += 2 {
-		if d.ctx.NS[i] == ns {
-			return d.ctx.NS[i+1]
-		}
-	}
-
-	// Check in top-level declarations
-	for i := 0; i < len(d.ns); i += 2 {
-		if d.ns[i] == ns {
-			return d.ns[i+1]
-		}
+	if p, ok := d.ns.lookupPrefix(ns); ok {
+		return p
 	}
-
 	panic(fmt.Errorf("no prefix found for name space: %q", ns))
 }
 
 // getNS returns the in-scope name space for the prefix.
 func (d *rdfXMLDecoder) getNS(prefix string) string {
-	// First check for context local declarations
-	for i := 0; i < len(d.ctx.NS); i += 2 {
-		if d.ctx.NS[i+1] == prefix {
-			return d.ctx.NS[i]
-		}
+	if ns, ok := d.ns.lookupNS(prefix); ok {
+		return ns
 	}
-
-	// Check in top-level declarations
-	for i := 0; i < len(d.ns); i += 2 {
-		if d.ns[i+1] == prefix {
-			return d.ns[i]
-		}
-	}
-
 	panic(fmt.Errorf("no name space found for prefix: %q", prefix))
 }
 
-// storePrefixNS stores any name space prefixes declared to the element context.
-// It also stores the base URI, if xml:base is present.
+// storeBase stores the base URI to the element context, if xml:base is present.
 // TODO also store xml:lang?
-func (d *rdfXMLDecoder) storePrefixNS(elem xml.StartElement) {
-	if as := attrXMLNS(elem); as != nil {
-		for _, a := range as {
-			d.ctx.NS = append(d.ctx.NS, a.Value, a.Name.Local)
-		}
-	}
+func (d *rdfXMLDecoder) storeBase(elem xml.StartElement) {
 	if as := attrXML(elem, "base"); as != nil {
-		d.ctx.Base = as[0].Value
+		d.ctx.Base = d.resolve(d.ctx.Base, as[0].Value)
 	}
 }
 
@@ -913,6 +871,7 @@ func (d *rdfXMLDecoder) pushContext() {
 	// Reset li-counter and subject of current context
 	// Base and Lang are inherited and doesn't have to be cleared TODO hm?
 	d.ctx.LiN = 0
+	d.ctx.ListSubj, d.ctx.ListPred = nil, nil
 }
 
 // popContext restores the next context on the stack as the current context.
@@ -951,152 +910,110 @@ func (d *rdfXMLDecoder) recover(errp *error) {
 }
 
 func (d *rdfXMLDecoder) nextXMLToken() {
+	if d.popNS {
+		// The declarations of an element stay in scope while its
+		// end tag is parsed.
+		d.ns.pop()
+		d.popNS = false
+	}
 	var err error
+	d.tokLine, d.tokCol = d.dec.InputPos()
 	d.tok, err = d.dec.Token()
 	if err != nil {
 		panic(err)
 	}
+	switch elem := d.tok.(type) {
+	case xml.StartElement:
+		d.ns.push(elem)
+	case xml.EndElement:
+		d.popNS = true
+	}
 }
 
-func (d *rdfXMLDecoder) resolve(base string, path string) string {
-	for i := 0; i < len(path); {
-		r, w := utf8.DecodeRuneInString(path[i:])
-		i += w
-		if r == ':' {
-			if strings.HasPrefix(path[i:], "//") {
-				// scheme found; it means the path is not relative
-				return path
-			}
-			if i < len(path) {
-				// URI is composed of prefix:suffix
-				return d.getNS(path[:i-1]) + path[i:]
-			}
-			break
-		}
-	}
-	if len(base) == 0 {
-		return path
-	}
-	if len(path) == 0 {
-		return base[:iriFragmentIdx(base)]
-	}
-	switch path[0] {
-	case '#':
-		return base[:iriFragmentIdx(base)] + path
-	case '/':
-		if len(path) > 1 && path[1] == '/' {
-			return base[:iriSchemeEnd(base)] + path
-		}
-		return base[:iriHostEnd(base)] + path
-	case '.':
-		numLevels := len(strings.Split(path, "../"))
-		return base[:iriSlashIdx(base, numLevels)] + strings.TrimLeft(path, "../")
-	default:
-		i := iriLastSlashIdx(base)
-		if base[i-1] != '/' {
-			return base + "/" + path
-		}
-		return base[:i] + path
-	}
+// nsScope maps the name space prefixes declared on the open XML elements to
+// their name spaces, and back. The declarations of an element are undone when
+// it ends, so that memory is bounded by the declarations on the open elements,
+// rather than by the document size. Looking up a prefix takes constant time;
+// looking up a name space takes time proportional to the number of prefixes
+// declared for it on the open elements, which is rarely more than one.
+type nsScope struct {
+	ns       map[string]string   // prefix -> name space
+	prefixes map[string][]string // name space -> prefixes declared for it, innermost last
+	undo     []nsUndo            // declarations of the open elements
+	marks    []int               // index in undo of the declarations of each open element
 }
 
-func iriFragmentIdx(s string) int {
-	i := len(s)
-	for i > 0 {
-		r, w := utf8.DecodeLastRuneInString(s[0:i])
-		i -= w
-		if r == '#' {
-			return i
-		}
-		if r == '/' {
-			break
-		}
-	}
-	return len(s)
+// nsUndo records a name space declaration, and the mapping it replaced.
+type nsUndo struct {
+	prefix, ns string
+	oldNS      string
+	hadNS      bool
 }
 
-func iriHostEnd(s string) int {
-	i := 0
-	l := len(s)
-	for i < l {
-		r, w := utf8.DecodeRuneInString(s[i:])
-		if w == 0 {
-			return i
-		}
-		i += w
-		if r == '.' {
-			for r, w = utf8.DecodeRuneInString(s[i:]); isAlpha(r); r, w = utf8.DecodeRuneInString(s[i:]) {
-				i += w
-			}
-			if w == 0 {
-				return i
-			}
-			if r == '/' {
-				return i
-			}
+func newNSScope() nsScope {
+	return nsScope{ns: make(map[string]string), prefixes: make(map[string][]string)}
+}
+
+// push adds the name space declarations of a starting element.
+func (s *nsScope) push(elem xml.StartElement) {
+	s.marks = append(s.marks, len(s.undo))
+	for _, a := range elem.Attr {
+		if a.Name.Space != "xmlns" {
+			continue
 		}
+		u := nsUndo{prefix: a.Name.Local, ns: a.Value}
+		u.oldNS, u.hadNS = s.ns[u.prefix]
+		s.undo = append(s.undo, u)
+		s.ns[u.prefix] = u.ns
+		s.prefixes[u.ns] = append(s.prefixes[u.ns], u.prefix)
 	}
-	return i
 }
 
-func iriSchemeEnd(s string) int {
-	if strings.HasPrefix(s, "http://") {
-		return 5
+// pop removes the name space declarations of the innermost open element.
+func (s *nsScope) pop() {
+	if len(s.marks) == 0 {
+		return
 	}
-	i := 0
-	l := len(s)
-	for i < l {
-		r, w := utf8.DecodeRuneInString(s[i:])
-		if w == 0 {
-			return i
+	mark := s.marks[len(s.marks)-1]
+	s.marks = s.marks[:len(s.marks)-1]
+	for i := len(s.undo) - 1; i >= mark; i-- {
+		u := s.undo[i]
+		if ps := s.prefixes[u.ns]; len(ps) > 1 {
+			s.prefixes[u.ns] = ps[:len(ps)-1]
+		} else {
+			delete(s.prefixes, u.ns)
 		}
-		i += w
-		if r == ':' {
-			if i+2 < l && s[i] == '/' && s[i+1] == '/' {
-				return i
-			}
+		if u.hadNS {
+			s.ns[u.prefix] = u.oldNS
+		} else {
+			delete(s.ns, u.prefix)
 		}
 	}
-	return i
+	s.undo = s.undo[:mark]
 }
 
-func iriLastSlashIdx(s string) int {
-	i := len(s)
-	for i > 0 {
-		r, w := utf8.DecodeLastRuneInString(s[0:i])
-		if r == '/' {
-			if i > 1 && s[i-w-1] == '/' {
-				return len(s)
-			}
-			return i
-		}
-		i -= w
-	}
-	return 0
+// lookupNS returns the name space of the prefix, and whether it is declared.
+func (s *nsScope) lookupNS(prefix string) (string, bool) {
+	ns, ok := s.ns[prefix]
+	return ns, ok
 }
 
-func iriSlashIdx(s string, n int) int {
-	c := 0
-	i := len(s)
-	for {
-		r, w := utf8.DecodeLastRuneInString(s[:i])
-		if w == 0 {
-			break
-		}
-		i -= w
-
-		if r == '/' {
-			if i > 0 && s[i-1] == '/' {
-				// Return if we have reached the URI scheme.
-				return i + 1
-			}
-			c++
-			if c == n {
-				return i + 1
-			}
+// lookupPrefix returns a prefix of the name space, and whether one is declared.
+// The innermost prefix which has not been redeclared for another name space
+// is returned.
+func (s *nsScope) lookupPrefix(ns string) (string, bool) {
+	ps := s.prefixes[ns]
+	for i := len(ps) - 1; i >= 0; i-- {
+		if s.ns[ps[i]] == ns {
+			return ps[i], true
 		}
 	}
-	return i
+	return "", false
+}
+
+// resolve resolves the IRI reference path against the base IRI.
+func (d *rdfXMLDecoder) resolve(base string, path string) string {
+	return resolveIRI(base, path)
 }
 
 // isLn checks if string matches ^_[1-9]\d*$
@@ -1146,11 +1063,28 @@ func attrRDF(e xml.StartElement, lname string) []xml.Attr {
 	return as
 }
 
-func attrXMLNS(e xml.StartElement) []xml.Attr {
+// checkXMLLang panics if the xml:lang value is not a well-formed language tag.
+// An empty value is allowed; it means that there is no language.
+func checkXMLLang(lang string) {
+	if lang == "" {
+		return
+	}
+	if err := checkLangTag(lang); err != nil {
+		panic(fmt.Errorf("xml:lang: %v", err))
+	}
+}
+
+// attrDir looks for a base direction attribute; its:dir or rdf:dir. It panics
+// if the direction is not "ltr" or "rtl".
+func attrDir(e xml.StartElement) []xml.Attr {
 	var as []xml.Attr
 	for _, a := range e.Attr {
-		if a.Name.Space == "xmlns" {
+		if (a.Name.Space == itsNS || a.Name.Space == rdfNS) && a.Name.Local == "dir" {
+			if err := checkDir(a.Value); err != nil {
+				panic(err)
+			}
 			as = append(as, a)
+			break
 		}
 	}
 	return as
@@ -1174,7 +1108,7 @@ func attrRest(e xml.StartElement) []xml.Attr {
 	for _, a := range e.Attr {
 		if a.Name.Space == rdfNS {
 			switch a.Name.Local {
-			case "about", "parseType", "resource", "datatype", "li", "type":
+			case "about", "parseType", "resource", "datatype", "li", "type", "dir":
 				continue
 			case "ID", "nodeID":
 				// validate as NCName:
@@ -1192,7 +1126,7 @@ func attrRest(e xml.StartElement) []xml.Attr {
 				continue
 			}
 		}
-		if a.Name.Space == xmlNS || a.Name.Local == "xmlns" || a.Name.Space == "" {
+		if a.Name.Space == xmlNS || a.Name.Space == itsNS || a.Name.Local == "xmlns" || a.Name.Space == "" {
 			continue
 		}
 		as = append(as, a)
@@ -1206,7 +1140,7 @@ func attrRestWithLn(e xml.StartElement) []xml.Attr {
 	for _, a := range e.Attr {
 		if a.Name.Space == rdfNS {
 			switch a.Name.Local {
-			case "about", "parseType", "resource", "datatype", "li", "type":
+			case "about", "parseType", "resource", "datatype", "li", "type", "dir":
 				continue
 			case "ID", "nodeID":
 				// validate as NCName:
@@ -1221,7 +1155,7 @@ func attrRestWithLn(e xml.StartElement) []xml.Attr {
 				continue
 			}
 		}
-		if a.Name.Space == xmlNS || a.Name.Local == "xmlns" {
+		if a.Name.Space == xmlNS || a.Name.Space == itsNS || a.Name.Local == "xmlns" {
 			continue
 		}
 		as = append(as, a)
@@ -1,8 +1,12 @@
 package rdf
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/xml"
+	"fmt"
 	"io"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -215,6 +219,149 @@ func BenchmarkDecodeRDFXML(b *testing.B) {
 	b.SetBytes(int64(len(input)))
 }
 
+// rdfXMLStream is a synthetic RDF/XML document of the given size, generated
+// while it is read. Its records declare name spaces on node and property
+// elements, and nest node elements.
+type rdfXMLStream struct {
+	size int64 // minimum size of the document
+	n    int64 // bytes generated
+	i    int   // records generated
+	buf  []byte
+	done bool
+}
+
+func (s *rdfXMLStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		switch {
+		case s.done:
+			return 0, io.EOF
+		case s.i == 0:
+			s.buf = []byte(`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example.org/">
+`)
+		case s.n >= s.size:
+			s.buf = []byte("</rdf:RDF>\n")
+			s.done = true
+		default:
+			s.buf = []byte(fmt.Sprintf(`  <ex:Book rdf:about="http://example.org/book/%d" xmlns:dc="http://purl.org/dc/terms/">
+    <dc:title xml:lang="en">Title %d</dc:title>
+    <dc:creator>
+      <rdf:Description rdf:about="http://example.org/person/%d" xmlns:foaf="http://xmlns.com/foaf/0.1/">
+        <foaf:name>Person %d</foaf:name>
+      </rdf:Description>
+    </dc:creator>
+    <ex:notes rdf:parseType="Literal" xmlns:h="http://www.w3.org/1999/xhtml"><h:p>Note <h:em>%d</h:em></h:p></ex:notes>
+    <ex:copy rdf:parseType="Resource"><ex:shelf>%d</ex:shelf></ex:copy>
+  </ex:Book>
+`, s.i, s.i, s.i%1000, s.i%1000, s.i, s.i%100))
+		}
+		s.i++
+		s.n += int64(len(s.buf))
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// BenchmarkDecodeRDFXMLStream decodes a synthetic 2GB document, reporting
+// the peak heap size, which is bounded by the nesting depth of the document
+// rather than its size.
+func BenchmarkDecodeRDFXMLStream(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping multi-GB benchmark in short mode")
+	}
+	const size = 2 << 30
+	b.SetBytes(size)
+	var peak uint64
+	for n := 0; n < b.N; n++ {
+		dec := NewTripleDecoder(bufio.NewReaderSize(&rdfXMLStream{size: size}, 1<<16), RDFXML)
+		var m runtime.MemStats
+		for i := 0; ; i++ {
+			_, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			if i%(1<<20) == 0 {
+				runtime.ReadMemStats(&m)
+				if m.HeapInuse > peak {
+					peak = m.HeapInuse
+				}
+			}
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+}
+
+func TestRDFXMLStreamBounded(t *testing.T) {
+	d := newRDFXMLDecoder(&rdfXMLStream{size: 4 << 20})
+	n := 0
+	for {
+		_, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		// The deepest nesting is 5 elements, with a name space
+		// declaration on each of the first 4.
+		if len(d.ns.marks) > 5 || len(d.ns.undo) > 4 || len(d.ctxStack) > 5 || cap(d.triples) > 16 {
+			t.Fatalf("after %d triples: %d open elements, %d name space declarations, %d contexts, %d buffered triples",
+				n, len(d.ns.marks), len(d.ns.undo), len(d.ctxStack), cap(d.triples))
+		}
+	}
+}
+
+func TestNSScope(t *testing.T) {
+	s := newNSScope()
+	elem := func(decls ...string) xml.StartElement {
+		var e xml.StartElement
+		for i := 0; i < len(decls); i += 2 {
+			e.Attr = append(e.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: decls[i]}, Value: decls[i+1]})
+		}
+		return e
+	}
+	check := func(prefix, ns string) {
+		t.Helper()
+		if got, _ := s.lookupNS(prefix); got != ns {
+			t.Errorf("lookupNS(%q) => %q; want %q", prefix, got, ns)
+		}
+		if got, _ := s.lookupPrefix(ns); got != prefix {
+			t.Errorf("lookupPrefix(%q) => %q; want %q", ns, got, prefix)
+		}
+	}
+
+	s.push(elem("a", "http://a/"))
+	s.push(elem("b", "http://a/", "a", "http://x/"))
+	check("b", "http://a/")
+	check("a", "http://x/")
+	s.push(elem("b", "http://b/"))
+	check("b", "http://b/")
+	check("a", "http://x/")
+	if p, ok := s.lookupPrefix("http://a/"); ok {
+		t.Errorf("lookupPrefix of shadowed name space => %q; want none", p)
+	}
+	// A name space declared with the same prefix twice.
+	s.push(elem("c", "http://c/"))
+	s.push(elem("c", "http://c/"))
+	check("c", "http://c/")
+	s.pop()
+	check("c", "http://c/")
+	s.pop()
+	s.pop()
+	check("b", "http://a/")
+	s.pop()
+	check("a", "http://a/")
+	s.pop()
+	if _, ok := s.lookupNS("a"); ok || len(s.prefixes) != 0 {
+		t.Errorf("declarations left after popping all elements: %v %v", s.ns, s.prefixes)
+	}
+}
+
 func TestRDFXMLExamples(t *testing.T) {
 	for i, test := range rdfxmlExamples {
 		dec := NewTripleDecoder(bytes.NewBufferString(test.rdfxml), RDFXML)
@@ -238,14 +385,174 @@ func TestRDFXMLExamples(t *testing.T) {
 	}
 }
 
+func TestRDFXMLNodeElements(t *testing.T) {
+	const head = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example/">`
+	tests := []struct {
+		rdfxml string
+		nt     string
+	}{
+		{
+			// A typed node element as object, directly after the property element.
+			head + `<rdf:Description rdf:about="http://example/s"><ex:p><ex:Thing rdf:about="o" ex:name="n"><ex:q>x</ex:q></ex:Thing></ex:p><ex:r>y</ex:r></rdf:Description></rdf:RDF>`,
+			`<http://example/s> <http://example/p> <http://example/o> .
+<http://example/o> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Thing> .
+<http://example/o> <http://example/name> "n" .
+<http://example/o> <http://example/q> "x" .
+<http://example/s> <http://example/r> "y" .
+`,
+		},
+		{
+			// rdf:Description with rdf:about, rdf:type and property attributes
+			// as object of a reified property.
+			head + `
+  <rdf:Description rdf:about="http://example/s">
+    <ex:p rdf:ID="r1">
+      <rdf:Description rdf:about="http://example/o" rdf:type="http://example/T" ex:a="1"/>
+    </ex:p>
+  </rdf:Description>
+</rdf:RDF>`,
+			`<http://example/s> <http://example/p> <http://example/o> .
+<http://example/doc#r1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+<http://example/doc#r1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+<http://example/doc#r1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+<http://example/doc#r1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> <http://example/o> .
+<http://example/o> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/T> .
+<http://example/o> <http://example/a> "1" .
+`,
+		},
+		{
+			// Collection items of any kind of node element, a nested empty
+			// collection, and a reified collection property.
+			head + `
+  <rdf:Description rdf:about="http://example/s">
+    <ex:list rdf:parseType="Collection" rdf:ID="l">
+      <ex:Thing/>
+      <rdf:Description rdf:nodeID="x"><ex:q>1</ex:q></rdf:Description>
+      <rdf:Description rdf:about="http://example/c"><ex:list rdf:parseType="Collection"/></rdf:Description>
+    </ex:list>
+    <ex:after>z</ex:after>
+  </rdf:Description>
+</rdf:RDF>`,
+			`<http://example/s> <http://example/list> _:b0 .
+<http://example/doc#l> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+<http://example/doc#l> <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+<http://example/doc#l> <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/list> .
+<http://example/doc#l> <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> _:b0 .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> _:b1 .
+_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Thing> .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:b2 .
+_:b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> _:x .
+_:x <http://example/q> "1" .
+_:b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:b3 .
+_:b3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> <http://example/c> .
+<http://example/c> <http://example/list> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+_:b3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+<http://example/s> <http://example/after> "z" .
+`,
+		},
+		{
+			// A typed node element with rdf:nodeID, xml:lang and rdf:type,
+			// a nested container, and a reified parseType="Literal" property.
+			head + `
+  <ex:T rdf:nodeID="n" xml:lang="en" ex:label="hi" rdf:type="http://example/U">
+    <ex:p><rdf:Bag><rdf:li>a</rdf:li><rdf:li>b</rdf:li></rdf:Bag></ex:p>
+    <ex:q rdf:parseType="Literal" rdf:ID="lit"><b>x</b></ex:q>
+    <rdf:li>c</rdf:li>
+  </ex:T>
+</rdf:RDF>`,
+			`_:n <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/T> .
+_:n <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/U> .
+_:n <http://example/label> "hi"@en .
+_:n <http://example/p> _:b0 .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Bag> .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#_1> "a"@en .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#_2> "b"@en .
+_:n <http://example/q> "<b>x</b>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral> .
+<http://example/doc#lit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+<http://example/doc#lit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> _:n .
+<http://example/doc#lit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/q> .
+<http://example/doc#lit> <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "<b>x</b>"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#XMLLiteral> .
+_:n <http://www.w3.org/1999/02/22-rdf-syntax-ns#_1> "c"@en .
+`,
+		},
+		{
+			// Relative IRIs, and a relative xml:base, are resolved as
+			// specified by RFC 3986.
+			head + `
+  <rdf:Description rdf:about="a/../b?q" xml:base="dir/">
+    <ex:p rdf:resource="urn:x:y"/>
+    <ex:p rdf:resource="./c/./d"/>
+    <ex:p rdf:resource="//other/e"/>
+    <ex:p rdf:resource=""/>
+  </rdf:Description>
+</rdf:RDF>`,
+			`<http://example/dir/b?q> <http://example/p> <urn:x:y> .
+<http://example/dir/b?q> <http://example/p> <http://example/dir/c/d> .
+<http://example/dir/b?q> <http://example/p> <http://other/e> .
+<http://example/dir/b?q> <http://example/p> <http://example/dir/> .
+`,
+		},
+	}
+	for _, test := range tests {
+		dec := NewTripleDecoder(strings.NewReader(test.rdfxml), RDFXML)
+		dec.SetOption(Base, IRI{str: "http://example/doc"})
+		ts, err := dec.DecodeAll()
+		if err != nil {
+			t.Errorf("decoding %s => %v", test.rdfxml, err)
+			continue
+		}
+		if got := serializeNT(ts); got != test.nt {
+			t.Errorf("decoding %s =>\n%s\nwant:\n%s", test.rdfxml, got, test.nt)
+		}
+	}
+}
+
+func TestRDFXMLStrictIDs(t *testing.T) {
+	const head = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example/">`
+	tests := []struct {
+		rdfxml string
+		err    string
+	}{
+		{
+			head + `<rdf:Description rdf:ID="a"/><ex:T rdf:ID="b"/><rdf:Description rdf:ID="a"/></rdf:RDF>`,
+			"duplicate rdf:ID: http://example/doc#a",
+		},
+		{
+			head + `<rdf:Description rdf:ID="a"><ex:p rdf:ID="a">x</ex:p></rdf:Description></rdf:RDF>`,
+			"duplicate rdf:ID: http://example/doc#a",
+		},
+		{
+			// The same ID with different bases.
+			head + `<rdf:Description rdf:ID="a"/><rdf:Description xml:base="http://example/other" rdf:ID="a"/></rdf:RDF>`,
+			"",
+		},
+	}
+	for _, test := range tests {
+		for _, strict := range []bool{false, true} {
+			dec := NewTripleDecoder(strings.NewReader(test.rdfxml), RDFXML)
+			dec.SetOption(Base, IRI{str: "http://example/doc"})
+			dec.SetOption(StrictIDs, strict)
+			_, err := dec.DecodeAll()
+			want := test.err
+			if !strict {
+				want = ""
+			}
+			switch {
+			case want == "" && err != nil:
+				t.Errorf("decoding %s with StrictIDs=%v => %v; want no error", test.rdfxml, strict, err)
+			case want != "" && (err == nil || err.Error() != want):
+				t.Errorf("decoding %s with StrictIDs=%v => %v; want %q", test.rdfxml, strict, err, want)
+			}
+		}
+	}
+}
+
 func TestRDFXML(t *testing.T) {
 	for i, test := range rdfxmlTestSuite {
 		dec := NewTripleDecoder(bytes.NewBufferString(test.rdfxml), RDFXML)
 		dec.SetOption(Base, IRI{str: "http://www.w3.org/2013/RDFXMLTests/" + test.file})
+		dec.SetOption(StrictIDs, true)
 		ts, err := dec.DecodeAll()
-		if test.err == "TODO" {
-			continue
-		}
 		if test.err != "" && err == nil {
 			t.Fatalf("[%d] parseRDFXML(%s).Serialize(NTriples) => <no error>, want %q", i, test.rdfxml, test.err)
 			continue
@@ -1393,7 +1700,7 @@ _:b0 <http://example.org/property2> "bar" .
 	</rdf:RDF>`,
 		"",
 
-		"TODO",
+		"duplicate rdf:ID: http://www.w3.org/2013/RDFXMLTests/rdfms-difference-between-ID-and-about/error1.rdf#foo",
 	},
 	{
 		// [30] #rdfms-difference-between-ID-and-about-test1
@@ -0,0 +1,280 @@
+package rdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReificationError describes a reification which could not be collapsed
+// into a triple term, because it is partial or conflicting.
+type ReificationError struct {
+	Node   Subject // the reified statement node
+	Reason string  // description of the problem
+}
+
+// Error returns the description of the reification problem.
+func (e *ReificationError) Error() string {
+	return fmt.Sprintf("reification %s: %s", e.Node.Serialize(NTriples), e.Reason)
+}
+
+// quadlet collects the reification triples of a statement node.
+type quadlet struct {
+	first     int       // index of first reification triple
+	statement bool      // has rdf:type rdf:Statement
+	subj      []Subject // rdf:subject values
+	pred      []Term    // rdf:predicate values
+	obj       []Object  // rdf:object values
+}
+
+// isReification reports whether the triple is one of the four triples
+// of a standard reification.
+func isReification(t Triple) bool {
+	switch t.Pred {
+	case rdfSubj, rdfPred, rdfObj:
+		return true
+	case rdfType:
+		return sameTerm(t.Obj, rdfStatement)
+	}
+	return false
+}
+
+// sameTerm reports whether a and b are the same RDF term. Unlike TermsEqual,
+// it takes the datatype of literals into account.
+func sameTerm(a, b Term) bool {
+	return a.Serialize(NTriples) == b.Serialize(NTriples)
+}
+
+// appendTerm appends t to ts, unless it is already present.
+func appendTerm(ts []Term, t Term) []Term {
+	for _, x := range ts {
+		if sameTerm(x, t) {
+			return ts
+		}
+	}
+	return append(ts, t)
+}
+
+// CollapseReification detects complete standard reifications in the given
+// triples and collapses them into triple terms; that is, the four triples
+//
+//	X rdf:type rdf:Statement .
+//	X rdf:subject S .
+//	X rdf:predicate P .
+//	X rdf:object O .
+//
+// are replaced with the RDF 1.2 reifying triple
+//
+//	X rdf:reifies <<( S P O )>> .
+//
+// and any other triples about X are kept as annotations on the reifier X.
+// The rdf:type rdf:Statement triple is optional.
+//
+// If star is true, RDF-star triple terms are used instead: the reification
+// triples are removed, and X is replaced with the triple term <<( S P O )>>
+// wherever it occurs as subject or object.
+//
+// Partial reifications (e.g. missing rdf:object) and conflicting reifications
+// (e.g. two different rdf:subject values) are left untouched and reported
+// as *ReificationError.
+func CollapseReification(ts []Triple, star bool) ([]Triple, []error) {
+	quadlets := make(map[string]*quadlet)
+	var order []string // statement nodes in order of appearance
+	for i, t := range ts {
+		if !isReification(t) {
+			continue
+		}
+		key := t.Subj.Serialize(NTriples)
+		q, ok := quadlets[key]
+		if !ok {
+			q = &quadlet{first: i}
+			quadlets[key] = q
+			order = append(order, key)
+		}
+		switch t.Pred {
+		case rdfType:
+			q.statement = true
+		case rdfSubj:
+			if s, ok := t.Obj.(Subject); ok {
+				q.subj = appendSubject(q.subj, s)
+			} else {
+				q.subj = append(q.subj, nil) // literal as subject; reported below
+			}
+		case rdfPred:
+			q.pred = appendTerm(q.pred, t.Obj)
+		case rdfObj:
+			q.obj = appendObject(q.obj, t.Obj)
+		}
+	}
+
+	var errs []error
+	terms := make(map[string]TripleTerm) // statement node -> triple term
+	for _, key := range order {
+		q := quadlets[key]
+		node := ts[q.first].Subj
+		var missing []string
+		if len(q.subj) == 0 {
+			missing = append(missing, "rdf:subject")
+		}
+		if len(q.pred) == 0 {
+			missing = append(missing, "rdf:predicate")
+		}
+		if len(q.obj) == 0 {
+			missing = append(missing, "rdf:object")
+		}
+		switch {
+		case len(missing) > 0:
+			errs = append(errs, &ReificationError{Node: node, Reason: "partial: missing " + strings.Join(missing, ", ")})
+			continue
+		case len(q.subj) > 1 || len(q.pred) > 1 || len(q.obj) > 1:
+			errs = append(errs, &ReificationError{Node: node, Reason: "conflicting: multiple values for rdf:subject, rdf:predicate or rdf:object"})
+			continue
+		case q.subj[0] == nil:
+			errs = append(errs, &ReificationError{Node: node, Reason: "invalid: rdf:subject is a literal"})
+			continue
+		}
+		p, ok := q.pred[0].(IRI)
+		if !ok {
+			errs = append(errs, &ReificationError{Node: node, Reason: "invalid: rdf:predicate is not an IRI"})
+			continue
+		}
+		terms[key] = TripleTerm{Triple: Triple{Subj: q.subj[0], Pred: p, Obj: q.obj[0]}}
+	}
+
+	res := make([]Triple, 0, len(ts))
+	for i, t := range ts {
+		key := t.Subj.Serialize(NTriples)
+		tt, reified := terms[key]
+		if reified && isReification(t) {
+			if !star && quadlets[key].first == i {
+				res = append(res, Triple{Subj: t.Subj, Pred: rdfReifies, Obj: tt})
+			}
+			continue
+		}
+		if star {
+			if reified {
+				t.Subj = tt
+			}
+			if o, ok := terms[t.Obj.Serialize(NTriples)]; ok {
+				t.Obj = o
+			}
+		}
+		res = append(res, t)
+	}
+	return res, errs
+}
+
+// appendSubject appends s to ss, unless it is already present.
+func appendSubject(ss []Subject, s Subject) []Subject {
+	for _, x := range ss {
+		if x != nil && sameTerm(x, s) {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+// appendObject appends o to os, unless it is already present.
+func appendObject(os []Object, o Object) []Object {
+	for _, x := range os {
+		if sameTerm(x, o) {
+			return os
+		}
+	}
+	return append(os, o)
+}
+
+// ExpandTripleTerms replaces triple terms with standard reification, for
+// consumers which don't support RDF 1.2 or RDF-star. This is the inverse of
+// CollapseReification.
+//
+// A reifying triple X rdf:reifies <<( S P O )>> is replaced by the four triples
+// of a standard reification of X. Any other triple term, as used in RDF-star,
+// is replaced with a new blank node, and the reification triples for that
+// blank node are added. Nested triple terms are expanded recursively.
+func ExpandTripleTerms(ts []Triple) []Triple {
+	// Find the blank node labels in use, to avoid clashes with new ones.
+	used := make(map[string]bool)
+	for _, t := range ts {
+		collectBlanks(t, used)
+	}
+	x := expander{used: used, nodes: make(map[string]Blank)}
+
+	res := make([]Triple, 0, len(ts))
+	for _, t := range ts {
+		if tt, ok := t.Obj.(TripleTerm); ok && t.Pred == rdfReifies {
+			if _, ok := t.Subj.(TripleTerm); !ok {
+				res = x.reify(res, t.Subj, tt)
+				continue
+			}
+		}
+		if tt, ok := t.Subj.(TripleTerm); ok {
+			var node Blank
+			node, res = x.node(res, tt)
+			t.Subj = node
+		}
+		if tt, ok := t.Obj.(TripleTerm); ok {
+			var node Blank
+			node, res = x.node(res, tt)
+			t.Obj = node
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// expander keeps track of the blank nodes created by ExpandTripleTerms.
+type expander struct {
+	used  map[string]bool  // blank node labels in use
+	n     int              // blank node counter
+	nodes map[string]Blank // triple term -> blank node
+}
+
+// node returns the blank node of the given triple term, adding its
+// reification triples to ts if it's the first time the term is seen.
+func (x *expander) node(ts []Triple, tt TripleTerm) (Blank, []Triple) {
+	key := tt.Serialize(NTriples)
+	if b, ok := x.nodes[key]; ok {
+		return b, ts
+	}
+	for {
+		x.n++
+		id := fmt.Sprintf("_:reif%d", x.n)
+		if !x.used[id] {
+			b := Blank{id: id}
+			x.used[id] = true
+			x.nodes[key] = b
+			return b, x.reify(ts, b, tt)
+		}
+	}
+}
+
+// reify adds the four reification triples of the triple term to ts, with
+// the given statement node.
+func (x *expander) reify(ts []Triple, node Subject, tt TripleTerm) []Triple {
+	s, o := tt.Subj, tt.Obj
+	if inner, ok := s.(TripleTerm); ok {
+		s, ts = x.node(ts, inner)
+	}
+	if inner, ok := o.(TripleTerm); ok {
+		o, ts = x.node(ts, inner)
+	}
+	return append(ts,
+		Triple{Subj: node, Pred: rdfType, Obj: rdfStatement},
+		Triple{Subj: node, Pred: rdfSubj, Obj: s.(Object)},
+		Triple{Subj: node, Pred: rdfPred, Obj: tt.Pred.(Object)},
+		Triple{Subj: node, Pred: rdfObj, Obj: o},
+	)
+}
+
+// collectBlanks adds the labels of the blank nodes in t, including those
+// nested in triple terms, to the given set.
+func collectBlanks(t Triple, used map[string]bool) {
+	for _, term := range []Term{t.Subj, t.Obj} {
+		switch term := term.(type) {
+		case Blank:
+			used[term.id] = true
+		case TripleTerm:
+			collectBlanks(term.Triple, used)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package rdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// mustDecodeNT decodes N-Triples in RDF-star mode, panicking on errors.
+func mustDecodeNT(s string) []Triple {
+	dec := NewTripleDecoder(bytes.NewBufferString(s), NTriples)
+	dec.SetOption(RDFStar, true)
+	ts, err := dec.DecodeAll()
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+func serializeNT(ts []Triple) string {
+	var b bytes.Buffer
+	for _, t := range ts {
+		b.WriteString(t.Serialize(NTriples))
+	}
+	return b.String()
+}
+
+func TestCollapseReification(t *testing.T) {
+	tests := []struct {
+		input string
+		star  bool
+		want  string
+		errs  []string
+	}{
+		{
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:x <http://example/source> <http://example/doc> .
+`,
+			false,
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#reifies> <<( <http://example/s> <http://example/p> "o" )>> .
+_:x <http://example/source> <http://example/doc> .
+`,
+			nil,
+		},
+		{
+			`<http://example/a> <http://example/says> _:x .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:x <http://example/source> <http://example/doc> .
+`,
+			true,
+			`<http://example/a> <http://example/says> <<( <http://example/s> <http://example/p> "o" )>> .
+<<( <http://example/s> <http://example/p> "o" )>> <http://example/source> <http://example/doc> .
+`,
+			nil,
+		},
+		{
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/t> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> "s" .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+`,
+			false,
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/t> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:y <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> "s" .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:z <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+`,
+			[]string{
+				"reification _:x: partial: missing rdf:predicate, rdf:object",
+				"reification _:y: conflicting: multiple values for rdf:subject, rdf:predicate or rdf:object",
+				"reification _:z: invalid: rdf:subject is a literal",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ts, errs := CollapseReification(mustDecodeNT(tt.input), tt.star)
+		if got := serializeNT(ts); got != tt.want {
+			t.Errorf("CollapseReification(%q, %v) =>\n%s\nwant:\n%s", tt.input, tt.star, got, tt.want)
+		}
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.errs, "\n") {
+			t.Errorf("CollapseReification(%q, %v) errors => %q, want %q", tt.input, tt.star, got, tt.errs)
+		}
+	}
+}
+
+func TestExpandTripleTerms(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#reifies> <<( <http://example/s> <http://example/p> "o" )>> .
+_:x <http://example/source> <http://example/doc> .
+`,
+			`_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:x <http://example/source> <http://example/doc> .
+`,
+		},
+		{
+			`<< <http://example/s> <http://example/p> << _:reif1 <http://example/p> "o" >> >> <http://example/source> <http://example/doc> .
+`,
+			`_:reif3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:reif3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> _:reif1 .
+_:reif3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:reif3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> "o" .
+_:reif2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/1999/02/22-rdf-syntax-ns#Statement> .
+_:reif2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#subject> <http://example/s> .
+_:reif2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <http://example/p> .
+_:reif2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#object> _:reif3 .
+_:reif2 <http://example/source> <http://example/doc> .
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := serializeNT(ExpandTripleTerms(mustDecodeNT(tt.input))); got != tt.want {
+			t.Errorf("ExpandTripleTerms(%q) =>\n%s\nwant:\n%s", tt.input, got, tt.want)
+		}
+	}
+
+	// Collapsing the expanded triples gives back the original.
+	input := `_:x <http://www.w3.org/1999/02/22-rdf-syntax-ns#reifies> <<( <http://example/s> <http://example/p> "o" )>> .
+_:x <http://example/source> <http://example/doc> .
+`
+	ts, errs := CollapseReification(ExpandTripleTerms(mustDecodeNT(input)), false)
+	if got := serializeNT(ts); got != input || len(errs) != 0 {
+		t.Errorf("CollapseReification(ExpandTripleTerms(%q)) =>\n%s%v", input, got, errs)
+	}
+}
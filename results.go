@@ -0,0 +1,1210 @@
+package rdf
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ResultsFormat represents a serialization format for SPARQL query results.
+type ResultsFormat int
+
+// Supported SPARQL 1.1 query results formats.
+const (
+	ResultsJSON ResultsFormat = iota // SPARQL 1.1 Query Results JSON Format
+	ResultsXML                       // SPARQL Query Results XML Format
+	ResultsCSV                       // SPARQL 1.1 Query Results CSV Format
+	ResultsTSV                       // SPARQL 1.1 Query Results TSV Format
+)
+
+// sparqlResultsNS is the namespace of the SPARQL Query Results XML Format.
+const sparqlResultsNS = "http://www.w3.org/2005/sparql-results#"
+
+// ErrNotBoolean is the error returned from ResultsDecoder.Boolean when the
+// results are variable bindings, rather than the result of an ASK query.
+var ErrNotBoolean = errors.New("results are not a boolean result")
+
+// ResultsDecoder parses SPARQL query results, either variable bindings
+// or a boolean ASK result, in one of the following formats:
+// JSON, XML, CSV, TSV.
+//
+// For streaming parsing, use the Decode() method to decode a single solution
+// at a time. Or, if you want to read the whole source in one go, DecodeAll().
+//
+// The CSV format doesn't preserve the types of terms: values starting with
+// "_:" are decoded as blank nodes, values which are absolute IRIs as IRIs,
+// and all other values as simple literals. The CSV and TSV formats have no
+// representation of boolean results.
+type ResultsDecoder struct {
+	r       resultsReader
+	started bool
+	vars    []Var
+	boolean *bool // the boolean result, or nil if the results are bindings
+	err     error // error reading the head of the results
+}
+
+// resultsReader reads SPARQL results in a specific format.
+type resultsReader interface {
+	// head reads up to the first solution, and returns the variables,
+	// and the boolean result, if any.
+	head() ([]Var, *bool, error)
+
+	// next returns the next solution, or io.EOF when there are no more
+	// solutions.
+	next() (Binding, error)
+}
+
+// NewResultsDecoder returns a new ResultsDecoder capable of parsing SPARQL
+// query results from the given io.Reader in the given format.
+func NewResultsDecoder(r io.Reader, f ResultsFormat) *ResultsDecoder {
+	switch f {
+	case ResultsJSON:
+		return &ResultsDecoder{r: &jsonResultsReader{dec: json.NewDecoder(r)}}
+	case ResultsXML:
+		return &ResultsDecoder{r: &xmlResultsReader{dec: xml.NewDecoder(r)}}
+	case ResultsCSV:
+		return &ResultsDecoder{r: &csvResultsReader{r: csv.NewReader(r)}}
+	case ResultsTSV:
+		return &ResultsDecoder{r: &tsvResultsReader{r: bufio.NewReader(r)}}
+	default:
+		panic(fmt.Errorf("Decoder for results format %v not implemented", f))
+	}
+}
+
+// start reads the head of the results, if not already done.
+func (d *ResultsDecoder) start() error {
+	if !d.started {
+		d.started = true
+		d.vars, d.boolean, d.err = d.r.head()
+	}
+	return d.err
+}
+
+// Vars returns the variables of the results. In the JSON format, they are
+// only known if the head precedes the bindings in the document.
+func (d *ResultsDecoder) Vars() ([]Var, error) {
+	if err := d.start(); err != nil {
+		return nil, err
+	}
+	return d.vars, nil
+}
+
+// Boolean returns the result of an ASK query, or ErrNotBoolean if the
+// results are variable bindings.
+func (d *ResultsDecoder) Boolean() (bool, error) {
+	if err := d.start(); err != nil {
+		return false, err
+	}
+	if d.boolean == nil {
+		return false, ErrNotBoolean
+	}
+	return *d.boolean, nil
+}
+
+// Decode returns the next solution, or an error. It returns io.EOF when
+// there are no more solutions, and immediately for a boolean result.
+// Unbound variables are not part of the Binding.
+func (d *ResultsDecoder) Decode() (Binding, error) {
+	if err := d.start(); err != nil {
+		return nil, err
+	}
+	if d.boolean != nil {
+		return nil, io.EOF
+	}
+	return d.r.next()
+}
+
+// DecodeAll decodes and returns all solutions from source, or an error.
+func (d *ResultsDecoder) DecodeAll() ([]Binding, error) {
+	var bs []Binding
+	for b, err := d.Decode(); err != io.EOF; b, err = d.Decode() {
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
+
+// resultTerm returns the term of the given type, as named in the JSON
+// and XML formats. A literal with a language can have a base direction,
+// as in SPARQL 1.2.
+func resultTerm(typ, value, lang, dir, dt string) (Term, error) {
+	switch typ {
+	case "uri":
+		return IRI{str: value}, nil
+	case "bnode":
+		return Blank{id: "_:" + value}, nil
+	case "literal", "typed-literal":
+		if lang != "" {
+			if err := checkLangTag(lang); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case dir != "":
+			if lang == "" {
+				return nil, fmt.Errorf("base direction without language: %q", value)
+			}
+			if err := checkDir(dir); err != nil {
+				return nil, err
+			}
+			return Literal{str: value, lang: lang, dir: dir, DataType: rdfDirLangString}, nil
+		case lang != "":
+			return Literal{str: value, lang: lang, DataType: rdfLangString}, nil
+		case dt != "":
+			return NewTypedLiteral(value, IRI{str: dt}), nil
+		}
+		return Literal{str: value, DataType: xsdString}, nil
+	}
+	return nil, fmt.Errorf("unknown term type: %q", typ)
+}
+
+// resultTriple returns the triple term of the given terms, as given in
+// the JSON and XML formats.
+func resultTriple(s, p, o Term) (Term, error) {
+	subj, ok1 := s.(Subject)
+	pred, ok2 := p.(Predicate)
+	obj, ok3 := o.(Object)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.New("invalid triple term")
+	}
+	return TripleTerm{Triple{Subj: subj, Pred: pred, Obj: obj}}, nil
+}
+
+// jsonResultsReader reads the SPARQL 1.1 Query Results JSON Format. It
+// streams the members of the bindings array, while the rest of the
+// document is read as it is encountered.
+type jsonResultsReader struct {
+	dec *json.Decoder
+
+	// level is the position in the document: 0 before it, 1 in the
+	// document object, 2 in the results object, 3 in the bindings
+	// array, and -1 after it.
+	level   int
+	vars    []Var
+	boolean *bool
+}
+
+// jsonTerm is a RDF term in the JSON format.
+type jsonTerm struct {
+	Type     string          `json:"type"`
+	Value    json.RawMessage `json:"value"`
+	Lang     string          `json:"xml:lang"`
+	Dir      string          `json:"its:dir"`
+	DataType string          `json:"datatype"`
+}
+
+func (jt jsonTerm) term() (Term, error) {
+	if jt.Type == "triple" {
+		var v struct{ Subject, Predicate, Object jsonTerm }
+		if err := json.Unmarshal(jt.Value, &v); err != nil {
+			return nil, err
+		}
+		var ts [3]Term
+		for i, t := range []jsonTerm{v.Subject, v.Predicate, v.Object} {
+			var err error
+			if ts[i], err = t.term(); err != nil {
+				return nil, err
+			}
+		}
+		return resultTriple(ts[0], ts[1], ts[2])
+	}
+	var s string
+	if err := json.Unmarshal(jt.Value, &s); err != nil {
+		return nil, err
+	}
+	return resultTerm(jt.Type, s, jt.Lang, jt.Dir, jt.DataType)
+}
+
+func (r *jsonResultsReader) head() ([]Var, *bool, error) {
+	if err := r.advance(); err != nil {
+		return nil, nil, err
+	}
+	return r.vars, r.boolean, nil
+}
+
+func (r *jsonResultsReader) next() (Binding, error) {
+	for {
+		if err := r.advance(); err != nil {
+			return nil, err
+		}
+		if r.level != 3 {
+			return nil, io.EOF
+		}
+		if !r.dec.More() {
+			if _, err := r.token(); err != nil {
+				return nil, err
+			}
+			r.level = 2
+			continue
+		}
+		var row map[string]jsonTerm
+		if err := r.dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		b := make(Binding, len(row))
+		for v, jt := range row {
+			t, err := jt.term()
+			if err != nil {
+				return nil, err
+			}
+			b[Var(v)] = t
+		}
+		return b, nil
+	}
+}
+
+// advance reads the document until the next solution in the bindings
+// array, or to the end of the document.
+func (r *jsonResultsReader) advance() error {
+	for r.level >= 0 && r.level < 3 {
+		if r.level == 0 {
+			if err := r.expectDelim('{'); err != nil {
+				return err
+			}
+			r.level = 1
+			continue
+		}
+		if !r.dec.More() {
+			if _, err := r.token(); err != nil {
+				return err
+			}
+			if r.level--; r.level == 0 {
+				r.level = -1
+			}
+			continue
+		}
+		tok, err := r.token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		switch {
+		case r.level == 1 && key == "head":
+			var h struct {
+				Vars []string `json:"vars"`
+			}
+			if err := r.dec.Decode(&h); err != nil {
+				return err
+			}
+			r.vars = make([]Var, len(h.Vars))
+			for i, v := range h.Vars {
+				r.vars[i] = Var(v)
+			}
+		case r.level == 1 && key == "boolean":
+			var b bool
+			if err := r.dec.Decode(&b); err != nil {
+				return err
+			}
+			r.boolean = &b
+		case r.level == 1 && key == "results":
+			if err := r.expectDelim('{'); err != nil {
+				return err
+			}
+			r.level = 2
+		case r.level == 2 && key == "bindings":
+			if err := r.expectDelim('['); err != nil {
+				return err
+			}
+			r.level = 3
+		default:
+			var skip json.RawMessage
+			if err := r.dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// token returns the next JSON token; the input may not end before the
+// end of the document.
+func (r *jsonResultsReader) token() (json.Token, error) {
+	tok, err := r.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
+
+func (r *jsonResultsReader) expectDelim(d json.Delim) error {
+	tok, err := r.token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("unexpected JSON token %v, expected '%v'", tok, d)
+	}
+	return nil
+}
+
+// xmlResultsReader reads the SPARQL Query Results XML Format.
+type xmlResultsReader struct {
+	dec *xml.Decoder
+	end bool // true after </results>, or after a boolean result
+}
+
+// xmlAttr returns the value of the attribute with the given local name.
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (r *xmlResultsReader) head() ([]Var, *bool, error) {
+	var vars []Var
+	for {
+		tok, err := r.token()
+		if err != nil {
+			return nil, nil, err
+		}
+		e, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch e.Name.Local {
+		case "variable":
+			vars = append(vars, Var(xmlAttr(e, "name")))
+		case "results":
+			return vars, nil, nil
+		case "boolean":
+			var s string
+			if err := r.dec.DecodeElement(&s, &e); err != nil {
+				return nil, nil, err
+			}
+			b, err := parseBoolean(strings.TrimSpace(s))
+			if err != nil {
+				return nil, nil, err
+			}
+			r.end = true
+			return vars, &b, nil
+		}
+	}
+}
+
+func (r *xmlResultsReader) next() (Binding, error) {
+	for !r.end {
+		tok, err := r.token()
+		if err != nil {
+			return nil, err
+		}
+		switch e := tok.(type) {
+		case xml.StartElement:
+			if e.Name.Local != "result" {
+				return nil, fmt.Errorf("unexpected element <%s>, expected <result>", e.Name.Local)
+			}
+			return r.result()
+		case xml.EndElement:
+			r.end = true
+		}
+	}
+	return nil, io.EOF
+}
+
+// result reads the bindings of a <result> element.
+func (r *xmlResultsReader) result() (Binding, error) {
+	b := make(Binding)
+	for {
+		e, ok, err := r.start()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return b, nil
+		}
+		if e.Name.Local != "binding" {
+			return nil, fmt.Errorf("unexpected element <%s>, expected <binding>", e.Name.Local)
+		}
+		t, err := r.term()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok, err := r.start(); err != nil || ok {
+			return nil, errors.New("expected one term in <binding>")
+		}
+		b[Var(xmlAttr(e, "name"))] = t
+	}
+}
+
+// term reads a term element.
+func (r *xmlResultsReader) term() (Term, error) {
+	e, ok, err := r.start()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("missing term")
+	}
+	if e.Name.Local != "triple" {
+		var s string
+		if err := r.dec.DecodeElement(&s, &e); err != nil {
+			return nil, err
+		}
+		var dir string
+		for _, a := range e.Attr {
+			if a.Name.Space == itsNS && a.Name.Local == "dir" {
+				dir = a.Value
+			}
+		}
+		return resultTerm(e.Name.Local, s, xmlAttr(e, "lang"), dir, xmlAttr(e, "datatype"))
+	}
+	parts := make(map[string]Term)
+	for {
+		e, ok, err := r.start()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if parts[e.Name.Local], err = r.term(); err != nil {
+			return nil, err
+		}
+		if _, ok, err := r.start(); err != nil || ok {
+			return nil, fmt.Errorf("expected one term in <%s>", e.Name.Local)
+		}
+	}
+	return resultTriple(parts["subject"], parts["predicate"], parts["object"])
+}
+
+// start returns the next start element, or false if the current
+// element ends first. Character data between elements is ignored.
+func (r *xmlResultsReader) start() (xml.StartElement, bool, error) {
+	for {
+		tok, err := r.token()
+		if err != nil {
+			return xml.StartElement{}, false, err
+		}
+		switch e := tok.(type) {
+		case xml.StartElement:
+			return e, true, nil
+		case xml.EndElement:
+			return xml.StartElement{}, false, nil
+		}
+	}
+}
+
+// token returns the next XML token; the input may not end before the
+// end of the document.
+func (r *xmlResultsReader) token() (xml.Token, error) {
+	tok, err := r.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
+
+// csvResultsReader reads the SPARQL 1.1 Query Results CSV Format.
+type csvResultsReader struct {
+	r    *csv.Reader
+	vars []Var
+}
+
+func (r *csvResultsReader) head() ([]Var, *bool, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	for _, v := range rec {
+		r.vars = append(r.vars, Var(v))
+	}
+	return r.vars, nil, nil
+}
+
+func (r *csvResultsReader) next() (Binding, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	b := make(Binding)
+	for i, s := range rec {
+		switch {
+		case s == "":
+		case strings.HasPrefix(s, "_:"):
+			b[r.vars[i]] = Blank{id: s}
+		case isAbsoluteIRI(s):
+			b[r.vars[i]] = IRI{str: s}
+		default:
+			b[r.vars[i]] = Literal{str: s, DataType: xsdString}
+		}
+	}
+	return b, nil
+}
+
+// isAbsoluteIRI returns true if the string is a valid IRI with a scheme.
+func isAbsoluteIRI(s string) bool {
+	i := strings.IndexByte(s, ':')
+	if i < 1 || !isAlpha(rune(s[0])) {
+		return false
+	}
+	for _, r := range s[1:i] {
+		if !isAlpha(r) && !isDigit(r) && r != '+' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	_, err := NewIRI(s)
+	return err == nil
+}
+
+// tsvResultsReader reads the SPARQL 1.1 Query Results TSV Format.
+type tsvResultsReader struct {
+	r    *bufio.Reader
+	line int
+	vars []Var
+}
+
+// readLine returns the fields of the next line.
+func (r *tsvResultsReader) readLine() ([]string, error) {
+	s, err := r.r.ReadString('\n')
+	if err == io.EOF && s == "" {
+		return nil, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	r.line++
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+	return strings.Split(s, "\t"), nil
+}
+
+func (r *tsvResultsReader) head() ([]Var, *bool, error) {
+	fields, err := r.readLine()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+	for _, s := range fields {
+		if len(s) < 2 || (s[0] != '?' && s[0] != '$') {
+			return nil, nil, fmt.Errorf("line 1: invalid variable: %q", s)
+		}
+		r.vars = append(r.vars, Var(s[1:]))
+	}
+	return r.vars, nil, nil
+}
+
+func (r *tsvResultsReader) next() (Binding, error) {
+	fields, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != len(r.vars) {
+		return nil, fmt.Errorf("line %d: wrong number of fields", r.line)
+	}
+	b := make(Binding)
+	for i, s := range fields {
+		if s == "" {
+			continue
+		}
+		t, err := parseTSVTerm(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		b[r.vars[i]] = t
+	}
+	return b, nil
+}
+
+// parseTSVTerm parses a RDF term in Turtle syntax, as used by the TSV
+// format: an IRI, a blank node, a literal, or a triple term.
+func parseTSVTerm(s string) (Term, error) {
+	p := tsvTermParser{s: s}
+	t, err := p.term()
+	if err == nil && p.pos < len(s) {
+		err = p.errorf("unexpected %q, expected end of term", s[p.pos:])
+	}
+	return t, err
+}
+
+// tsvTermParser parses the Turtle syntax of a single RDF term. Prefixed
+// names are not allowed, as TSV results have no prologue.
+type tsvTermParser struct {
+	s   string
+	pos int
+}
+
+func (p *tsvTermParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("column %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips the white space between the terms of a triple term.
+func (p *tsvTermParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tsvTermParser) term() (Term, error) {
+	rest := p.s[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "<<("):
+		return p.tripleTerm()
+	case strings.HasPrefix(rest, "<"):
+		return p.iri()
+	case strings.HasPrefix(rest, "_:"):
+		return p.blank()
+	case strings.HasPrefix(rest, `"`), strings.HasPrefix(rest, "'"):
+		return p.literal()
+	case p.keyword("true"):
+		return Literal{str: "true", DataType: xsdBoolean}, nil
+	case p.keyword("false"):
+		return Literal{str: "false", DataType: xsdBoolean}, nil
+	case rest != "" && strings.IndexByte("+-.0123456789", rest[0]) >= 0:
+		return p.number()
+	case rest == "":
+		return nil, p.errorf("unexpected end of term")
+	}
+	return nil, p.errorf("unexpected %q, expected term", rest)
+}
+
+// keyword consumes the word, if it is next and not followed by a letter
+// or digit.
+func (p *tsvTermParser) keyword(w string) bool {
+	rest := p.s[p.pos:]
+	if !strings.HasPrefix(rest, w) || len(rest) > len(w) && isAlphaOrDigit(rune(rest[len(w)])) {
+		return false
+	}
+	p.pos += len(w)
+	return true
+}
+
+// tripleTerm parses a triple term, as '<<(' subject predicate object ')>>'.
+func (p *tsvTermParser) tripleTerm() (Term, error) {
+	p.pos += len("<<(")
+	var ts [3]Term
+	for i := range ts {
+		p.skipSpace()
+		start := p.pos
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		switch i {
+		case 0:
+			_, ok = t.(Subject)
+			_, isTT := t.(TripleTerm)
+			ok = ok && !isTT
+		case 1:
+			_, ok = t.(IRI)
+		case 2:
+			_, ok = t.(Object)
+		}
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("unexpected %s in triple term", t.Serialize(NTriples))
+		}
+		ts[i] = t
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], ")>>") {
+		return nil, p.errorf("unexpected %q, expected ')>>'", p.s[p.pos:])
+	}
+	p.pos += len(")>>")
+	return TripleTerm{Triple: Triple{Subj: ts[0].(Subject), Pred: ts[1].(Predicate), Obj: ts[2].(Object)}}, nil
+}
+
+func (p *tsvTermParser) iri() (IRI, error) {
+	i := strings.IndexByte(p.s[p.pos:], '>')
+	if i < 0 {
+		return IRI{}, p.errorf("invalid IRI: %q", p.s[p.pos:])
+	}
+	str := p.s[p.pos+1 : p.pos+i]
+	for _, c := range []byte(str) {
+		if c <= 0x20 || strings.IndexByte("<\"{}|^`", c) >= 0 {
+			return IRI{}, p.errorf("invalid IRI: %q", p.s[p.pos:])
+		}
+	}
+	if strings.IndexByte(str, '\\') >= 0 {
+		var err error
+		if str, err = p.unescape(str, "uU"); err != nil {
+			return IRI{}, err
+		}
+	}
+	p.pos += i + 1
+	return IRI{str: str}, nil
+}
+
+func (p *tsvTermParser) blank() (Blank, error) {
+	start := p.pos
+	p.pos += len("_:")
+	for i, r := range p.s[p.pos:] {
+		if i == 0 && !isPnCharsU(r) && !isDigit(r) || i > 0 && !isPnChars(r) && r != '.' {
+			break
+		}
+		p.pos += utf8.RuneLen(r)
+	}
+	for p.pos > start+2 && p.s[p.pos-1] == '.' {
+		p.pos--
+	}
+	if p.pos == start+2 {
+		return Blank{}, p.errorf("invalid blank node: %q", p.s[start:])
+	}
+	return Blank{id: p.s[start:p.pos]}, nil
+}
+
+// literal parses a quoted literal, with its language tag or datatype.
+func (p *tsvTermParser) literal() (Literal, error) {
+	q := p.s[p.pos : p.pos+1]
+	long := strings.HasPrefix(p.s[p.pos:], q+q+q)
+	if long {
+		q = q + q + q
+	}
+	start := p.pos
+	p.pos += len(q)
+	end := -1
+	for i := p.pos; i < len(p.s); i++ {
+		if p.s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(p.s[i:], q) {
+			end = i
+			break
+		}
+		if !long && (p.s[i] == '\n' || p.s[i] == '\r') {
+			break
+		}
+	}
+	if end < 0 {
+		p.pos = start
+		return Literal{}, p.errorf("unterminated literal: %s", p.s[start:])
+	}
+	str, err := p.unescape(p.s[p.pos:end], "tbnrf\"'\\uU")
+	if err != nil {
+		return Literal{}, err
+	}
+	p.pos = end + len(q)
+	l := Literal{str: str, DataType: xsdString}
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "@"):
+		p.pos++
+		n := 0
+		for n < len(p.s[p.pos:]) && (isAlphaOrDigit(rune(p.s[p.pos+n])) || p.s[p.pos+n] == '-') {
+			n++
+		}
+		if i := strings.Index(p.s[p.pos:p.pos+n], "--"); i >= 0 {
+			n = i
+		}
+		if err := checkLangTag(p.s[p.pos : p.pos+n]); err != nil {
+			return Literal{}, p.errorf("%v", err)
+		}
+		l.lang, l.DataType = p.s[p.pos:p.pos+n], rdfLangString
+		p.pos += n
+		if strings.HasPrefix(p.s[p.pos:], "--") {
+			switch dir := p.s[p.pos+2:]; {
+			case strings.HasPrefix(dir, "ltr"), strings.HasPrefix(dir, "rtl"):
+				l.dir, l.DataType = dir[:3], rdfDirLangString
+				p.pos += 5
+			default:
+				return Literal{}, p.errorf("invalid base direction: %q", p.s[p.pos:])
+			}
+		}
+	case strings.HasPrefix(p.s[p.pos:], "^^"):
+		p.pos += 2
+		if !strings.HasPrefix(p.s[p.pos:], "<") || strings.HasPrefix(p.s[p.pos:], "<<") {
+			return Literal{}, p.errorf("unexpected %q, expected datatype IRI", p.s[p.pos:])
+		}
+		dt, err := p.iri()
+		if err != nil {
+			return Literal{}, err
+		}
+		l.DataType = dt
+		l = l.canonicalMarkup()
+	}
+	return l, nil
+}
+
+// unescape replaces the escape sequences in s, which may be those of the
+// given characters.
+func (p *tsvTermParser) unescape(s string, escapes string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) || strings.IndexByte(escapes, s[i+1]) < 0 {
+			return "", p.errorf("invalid escape sequence in %q", s)
+		}
+		i++
+		switch c := s[i]; c {
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", p.errorf("invalid escape sequence in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid escape sequence in %q", s)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// number parses an integer, decimal or double in Turtle syntax.
+func (p *tsvTermParser) number() (Literal, error) {
+	start := p.pos
+	digits := func() int {
+		n := 0
+		for p.pos < len(p.s) && isDigit(rune(p.s[p.pos])) {
+			p.pos++
+			n++
+		}
+		return n
+	}
+	if p.s[p.pos] == '+' || p.s[p.pos] == '-' {
+		p.pos++
+	}
+	dt := xsdInteger
+	n := digits()
+	if p.pos < len(p.s) && p.s[p.pos] == '.' {
+		p.pos++
+		frac := digits()
+		if frac == 0 && (p.pos == len(p.s) || (p.s[p.pos] != 'e' && p.s[p.pos] != 'E')) {
+			p.pos--
+		} else {
+			n += frac
+			dt = xsdDecimal
+		}
+	}
+	if n > 0 && p.pos < len(p.s) && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			p.pos = start
+			return Literal{}, p.errorf("invalid number: %q", p.s[start:])
+		}
+		dt = xsdDouble
+	}
+	if n == 0 {
+		p.pos = start
+		return Literal{}, p.errorf("invalid number: %q", p.s[start:])
+	}
+	return Literal{str: p.s[start:p.pos], DataType: dt}, nil
+}
+
+// ResultsEncoder serializes SPARQL query results, either variable bindings
+// or a boolean ASK result, into one of the following formats:
+// JSON, XML, CSV, TSV.
+//
+// Variable bindings are serialized by calling EncodeVars once, followed
+// by Encode for each solution. A boolean result is serialized with
+// EncodeBoolean. In either case; when done serializing, Close() must be
+// called, to complete the document and flush the buffered writer.
+type ResultsEncoder struct {
+	format  ResultsFormat
+	w       *errWriter // Buffered writer. Set to nil when Encoder is closed.
+	vars    []Var      // nil until the head is written
+	started bool       // true when the head is written
+	rows    int        // number of encoded solutions
+	boolean bool       // true when a boolean result is written
+}
+
+// NewResultsEncoder returns a new ResultsEncoder capable of serializing
+// into the given io.Writer in the given results format.
+func NewResultsEncoder(w io.Writer, f ResultsFormat) *ResultsEncoder {
+	return &ResultsEncoder{
+		format: f,
+		w:      &errWriter{w: bufio.NewWriter(w)},
+	}
+}
+
+// EncodeVars serializes the head of the results, with the given variables.
+// It must be called once, before encoding any solutions.
+func (e *ResultsEncoder) EncodeVars(vars []Var) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if e.started {
+		return errors.New("results head already encoded")
+	}
+	e.started = true
+	e.vars = vars
+	switch e.format {
+	case ResultsJSON:
+		e.w.write([]byte(`{"head":{"vars":[`))
+		for i, v := range vars {
+			if i > 0 {
+				e.w.write([]byte(","))
+			}
+			e.w.write(jsonString(string(v)))
+		}
+		e.w.write([]byte("]},\n\"results\":{\"bindings\":["))
+	case ResultsXML:
+		e.w.write([]byte("<?xml version=\"1.0\"?>\n<sparql xmlns=\"" + sparqlResultsNS + "\" xmlns:its=\"" + itsNS + "\">\n  <head>\n"))
+		for _, v := range vars {
+			e.w.write([]byte(`    <variable name="` + xmlEscape(string(v)) + "\"/>\n"))
+		}
+		e.w.write([]byte("  </head>\n  <results>\n"))
+	case ResultsCSV:
+		fields := make([]string, len(vars))
+		for i, v := range vars {
+			fields[i] = string(v)
+		}
+		e.writeCSV(fields)
+	case ResultsTSV:
+		for i, v := range vars {
+			if i > 0 {
+				e.w.write([]byte("\t"))
+			}
+			e.w.write([]byte("?" + string(v)))
+		}
+		e.w.write([]byte("\n"))
+	}
+	return e.w.err
+}
+
+// Encode serializes a single solution. Variables of the solution which are
+// not in the head are ignored.
+func (e *ResultsEncoder) Encode(b Binding) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if !e.started || e.boolean {
+		return errors.New("results head not encoded")
+	}
+	switch e.format {
+	case ResultsJSON:
+		if e.rows > 0 {
+			e.w.write([]byte(","))
+		}
+		e.w.write([]byte("\n{"))
+		n := 0
+		for _, v := range e.vars {
+			t, ok := b[v]
+			if !ok {
+				continue
+			}
+			if n > 0 {
+				e.w.write([]byte(","))
+			}
+			n++
+			e.w.write(jsonString(string(v)))
+			e.w.write([]byte(":"))
+			e.writeJSONTerm(t)
+		}
+		e.w.write([]byte("}"))
+	case ResultsXML:
+		e.w.write([]byte("    <result>\n"))
+		for _, v := range e.vars {
+			if t, ok := b[v]; ok {
+				e.w.write([]byte(`      <binding name="` + xmlEscape(string(v)) + `">`))
+				e.writeXMLTerm(t)
+				e.w.write([]byte("</binding>\n"))
+			}
+		}
+		e.w.write([]byte("    </result>\n"))
+	case ResultsCSV:
+		fields := make([]string, len(e.vars))
+		for i, v := range e.vars {
+			switch t := b[v].(type) {
+			case nil:
+			case Blank, TripleTerm:
+				fields[i] = t.Serialize(NTriples)
+			default:
+				fields[i] = t.String()
+			}
+		}
+		e.writeCSV(fields)
+	case ResultsTSV:
+		for i, v := range e.vars {
+			if i > 0 {
+				e.w.write([]byte("\t"))
+			}
+			if t, ok := b[v]; ok {
+				e.w.write([]byte(strings.Replace(t.Serialize(NTriples), "\t", `\t`, -1)))
+			}
+		}
+		e.w.write([]byte("\n"))
+	}
+	e.rows++
+	return e.w.err
+}
+
+// EncodeAll serializes the head with the given variables, and all the
+// solutions of the iterator.
+func (e *ResultsEncoder) EncodeAll(vars []Var, it BindingIterator) error {
+	if err := e.EncodeVars(vars); err != nil {
+		return err
+	}
+	for b, ok := it.Next(); ok; b, ok = it.Next() {
+		if err := e.Encode(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeBoolean serializes the result of an ASK query. It fails for the CSV
+// and TSV formats, which have no representation of boolean results.
+func (e *ResultsEncoder) EncodeBoolean(v bool) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if e.started {
+		return errors.New("results head already encoded")
+	}
+	s := strconv.FormatBool(v)
+	switch e.format {
+	case ResultsJSON:
+		e.w.write([]byte("{\"head\":{},\n\"boolean\":" + s + "}\n"))
+	case ResultsXML:
+		e.w.write([]byte("<?xml version=\"1.0\"?>\n<sparql xmlns=\"" + sparqlResultsNS + "\">\n  <head/>\n  <boolean>" + s + "</boolean>\n</sparql>\n"))
+	default:
+		return errors.New("boolean results not supported in CSV and TSV formats")
+	}
+	e.started = true
+	e.boolean = true
+	return e.w.err
+}
+
+// Close completes the document, and flushes the buffered writer. If no
+// head was encoded, an empty head is written.
+func (e *ResultsEncoder) Close() error {
+	if e.w == nil {
+		return nil
+	}
+	if !e.started {
+		if err := e.EncodeVars(nil); err != nil {
+			return err
+		}
+	}
+	if !e.boolean {
+		switch e.format {
+		case ResultsJSON:
+			e.w.write([]byte("\n]}}\n"))
+		case ResultsXML:
+			e.w.write([]byte("  </results>\n</sparql>\n"))
+		}
+	}
+	if e.w.err != nil {
+		return e.w.err
+	}
+	err := e.w.w.Flush()
+	e.w = nil
+	return err
+}
+
+func (e *ResultsEncoder) writeJSONTerm(t Term) {
+	switch t := t.(type) {
+	case IRI:
+		e.w.write([]byte(`{"type":"uri","value":`))
+		e.w.write(jsonString(t.str))
+	case Blank:
+		e.w.write([]byte(`{"type":"bnode","value":`))
+		e.w.write(jsonString(t.String()))
+	case Literal:
+		e.w.write([]byte(`{"type":"literal","value":`))
+		e.w.write(jsonString(t.str))
+		switch {
+		case t.lang != "":
+			e.w.write([]byte(`,"xml:lang":`))
+			e.w.write(jsonString(t.lang))
+			if t.dir != "" {
+				e.w.write([]byte(`,"its:dir":`))
+				e.w.write(jsonString(t.dir))
+			}
+		case t.DataType != xsdString:
+			e.w.write([]byte(`,"datatype":`))
+			e.w.write(jsonString(t.DataType.str))
+		}
+	case TripleTerm:
+		e.w.write([]byte(`{"type":"triple","value":{"subject":`))
+		e.writeJSONTerm(t.Subj)
+		e.w.write([]byte(`,"predicate":`))
+		e.writeJSONTerm(t.Pred)
+		e.w.write([]byte(`,"object":`))
+		e.writeJSONTerm(t.Obj)
+		e.w.write([]byte("}"))
+	}
+	e.w.write([]byte("}"))
+}
+
+func (e *ResultsEncoder) writeXMLTerm(t Term) {
+	switch t := t.(type) {
+	case IRI:
+		e.w.write([]byte("<uri>" + xmlEscape(t.str) + "</uri>"))
+	case Blank:
+		e.w.write([]byte("<bnode>" + xmlEscape(t.String()) + "</bnode>"))
+	case Literal:
+		switch {
+		case t.dir != "":
+			e.w.write([]byte(`<literal xml:lang="` + xmlEscape(t.lang) + `" its:dir="` + xmlEscape(t.dir) + `">`))
+		case t.lang != "":
+			e.w.write([]byte(`<literal xml:lang="` + xmlEscape(t.lang) + `">`))
+		case t.DataType != xsdString:
+			e.w.write([]byte(`<literal datatype="` + xmlEscape(t.DataType.str) + `">`))
+		default:
+			e.w.write([]byte("<literal>"))
+		}
+		e.w.write([]byte(xmlEscape(t.str) + "</literal>"))
+	case TripleTerm:
+		e.w.write([]byte("<triple><subject>"))
+		e.writeXMLTerm(t.Subj)
+		e.w.write([]byte("</subject><predicate>"))
+		e.writeXMLTerm(t.Pred)
+		e.w.write([]byte("</predicate><object>"))
+		e.writeXMLTerm(t.Obj)
+		e.w.write([]byte("</object></triple>"))
+	}
+}
+
+// writeCSV writes a record in the CSV format, with CRLF line endings.
+func (e *ResultsEncoder) writeCSV(fields []string) {
+	w := csv.NewWriter(e.w.w)
+	w.UseCRLF = true
+	if err := w.Write(fields); err != nil && e.w.err == nil {
+		e.w.err = err
+	}
+	w.Flush()
+}
+
+// jsonString returns the string as a JSON string.
+func jsonString(s string) []byte {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+// xmlEscape escapes the string for use in XML character data and
+// attribute values.
+func xmlEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
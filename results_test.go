@@ -0,0 +1,288 @@
+package rdf
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// resultsTestBindings returns solutions covering all kinds of terms.
+func resultsTestBindings() []Binding {
+	a := IRI{str: "http://example/a"}
+	return []Binding{
+		{
+			"x": a,
+			"y": Literal{str: "chat", lang: "fr", DataType: rdfLangString},
+			"z": Blank{id: "_:b0"},
+		},
+		{
+			"x": Literal{str: "tab\tquote\" new\nline <&>", DataType: xsdString},
+			"y": Literal{str: "-1.5", DataType: xsdDecimal},
+		},
+		{
+			"x": Literal{str: "salaam", lang: "ar", dir: "rtl", DataType: rdfDirLangString},
+			"z": TripleTerm{Triple{Subj: a, Pred: IRI{str: "http://example/p"}, Obj: Literal{str: "42", DataType: xsdInteger}}},
+		},
+	}
+}
+
+func TestResultsRoundtrip(t *testing.T) {
+	vars := []Var{"x", "y", "z"}
+	want := formatBindings(&sliceIterator{bs: resultsTestBindings()})
+	for _, f := range []ResultsFormat{ResultsJSON, ResultsXML, ResultsTSV} {
+		var buf bytes.Buffer
+		enc := NewResultsEncoder(&buf, f)
+		if err := enc.EncodeAll(vars, &sliceIterator{bs: resultsTestBindings()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := NewResultsDecoder(bytes.NewReader(buf.Bytes()), f)
+		gotVars, err := dec.Vars()
+		if err != nil || len(gotVars) != 3 || gotVars[2] != "z" {
+			t.Errorf("format %d: Vars() => %v, %v; want %v", f, gotVars, err, vars)
+		}
+		if _, err := dec.Boolean(); err != ErrNotBoolean {
+			t.Errorf("format %d: Boolean() => %v; want ErrNotBoolean", f, err)
+		}
+		bs, err := dec.DecodeAll()
+		if err != nil {
+			t.Errorf("format %d: DecodeAll() failed: %v\n%s", f, err, buf.String())
+			continue
+		}
+		if got := formatBindings(&sliceIterator{bs: bs}); !equalStrings(got, want) {
+			t.Errorf("format %d: decoded =>\n%q\nwant:\n%q\nfrom:\n%s", f, got, want, buf.String())
+		}
+	}
+}
+
+func TestResultsEncode(t *testing.T) {
+	tests := []struct {
+		format ResultsFormat
+		want   string
+	}{
+		{
+			ResultsCSV,
+			"x,y,z\r\n" +
+				"http://example/a,chat,_:b0\r\n" +
+				"\"tab\tquote\"\" new\r\nline <&>\",-1.5,\r\n" +
+				"salaam,,\"<<( <http://example/a> <http://example/p> \"\"42\"\"^^<http://www.w3.org/2001/XMLSchema#integer> )>>\"\r\n",
+		},
+		{
+			ResultsTSV,
+			"?x\t?y\t?z\n" +
+				"<http://example/a>\t\"chat\"@fr\t_:b0\n" +
+				"\"tab\\tquote\\\" new\\nline <&>\"\t\"-1.5\"^^<http://www.w3.org/2001/XMLSchema#decimal>\t\n" +
+				"\"salaam\"@ar--rtl\t\t<<( <http://example/a> <http://example/p> \"42\"^^<http://www.w3.org/2001/XMLSchema#integer> )>>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc := NewResultsEncoder(&buf, tt.format)
+		if err := enc.EncodeAll([]Var{"x", "y", "z"}, &sliceIterator{bs: resultsTestBindings()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("format %d: encoded =>\n%q\nwant:\n%q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestResultsDecode(t *testing.T) {
+	tests := []struct {
+		format ResultsFormat
+		input  string
+		vars   []Var
+		want   []string
+	}{
+		{
+			ResultsJSON,
+			`{
+  "head": { "vars": [ "book", "title" ], "link": [ "http://example/info" ] },
+  "results": {
+    "distinct": false,
+    "bindings": [
+      { "book": { "type": "uri", "value": "http://example/book1" },
+        "title": { "type": "literal", "xml:lang": "en", "value": "SPARQL Tutorial" } },
+      { "book": { "type": "bnode", "value": "r2" },
+        "title": { "type": "typed-literal", "datatype": "http://example/dt", "value": "x" } },
+      { "title": { "type": "literal", "xml:lang": "ar", "its:dir": "rtl", "value": "salaam" } },
+      { }
+    ],
+    "ordered": true
+  }
+}`,
+			[]Var{"book", "title"},
+			[]string{
+				``,
+				`?book=<http://example/book1> ?title="SPARQL Tutorial"@en`,
+				`?book=_:r2 ?title="x"^^<http://example/dt>`,
+				`?title="salaam"@ar--rtl`,
+			},
+		},
+		{
+			ResultsJSON,
+			`{"results": {"bindings": [{"x": {"type": "literal", "value": "1"}}]}, "head": {"vars": ["x"]}}`,
+			nil,
+			[]string{`?x="1"`},
+		},
+		{
+			ResultsXML,
+			`<?xml version="1.0"?>
+<sparql xmlns="http://www.w3.org/2005/sparql-results#" xmlns:its="http://www.w3.org/2005/11/its">
+  <head>
+    <variable name="x"/>
+    <variable name="hpage"/>
+    <link href="example.rq" />
+  </head>
+  <results>
+    <result>
+      <binding name="x"><bnode>r1</bnode></binding>
+      <binding name="hpage">
+        <uri>http://work.example.org/alice/</uri>
+      </binding>
+    </result>
+    <result>
+      <binding name="x"><literal datatype="http://www.w3.org/2001/XMLSchema#integer">30</literal></binding>
+      <binding name="hpage"><literal xml:lang="en">a &lt;b&gt;</literal></binding>
+    </result>
+    <result>
+      <binding name="hpage"><literal xml:lang="ar" its:dir="rtl">salaam</literal></binding>
+    </result>
+  </results>
+</sparql>`,
+			[]Var{"x", "hpage"},
+			[]string{
+				`?hpage="a <b>"@en ?x="30"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`?hpage="salaam"@ar--rtl`,
+				`?hpage=<http://work.example.org/alice/> ?x=_:r1`,
+			},
+		},
+		{
+			ResultsCSV,
+			"x,name\r\n_:b0,\"Alice, A.\"\r\nmailto:a@example.org,\r\n",
+			[]Var{"x", "name"},
+			[]string{`?name="Alice, A." ?x=_:b0`, `?x=<mailto:a@example.org>`},
+		},
+		{
+			ResultsTSV,
+			"?x\t?n\n<http://example/a>\t-2\n\t1.5e0\n\t\n_:b\t'''x'''@en-GB\n" +
+				"<http://example/\\u00E9>\t\"a\\tb\\u00e9\"@ar--rtl\n_:b.1\t.5\n\ttrue\n" +
+				"<<(_:x <http://example/p> <<( <http://example/s> <http://example/p> \"1\"^^<http://example/dt> )>>)>>\tfalse\n",
+			[]Var{"x", "n"},
+			[]string{
+				``,
+				`?n="-2"^^<http://www.w3.org/2001/XMLSchema#integer> ?x=<http://example/a>`,
+				`?n=".5"^^<http://www.w3.org/2001/XMLSchema#decimal> ?x=_:b.1`,
+				`?n="1.5e0"^^<http://www.w3.org/2001/XMLSchema#double>`,
+				"?n=\"a\tbé\"@ar--rtl ?x=<http://example/é>",
+				`?n="false"^^<http://www.w3.org/2001/XMLSchema#boolean> ?x=<<( _:x <http://example/p> <<( <http://example/s> <http://example/p> "1"^^<http://example/dt> )>> )>>`,
+				`?n="true"^^<http://www.w3.org/2001/XMLSchema#boolean>`,
+				`?n="x"@en-GB ?x=_:b`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		dec := NewResultsDecoder(strings.NewReader(tt.input), tt.format)
+		vars, err := dec.Vars()
+		if err != nil {
+			t.Errorf("format %d: Vars() failed: %v", tt.format, err)
+			continue
+		}
+		if len(vars) != len(tt.vars) {
+			t.Errorf("format %d: Vars() => %v; want %v", tt.format, vars, tt.vars)
+		}
+		var bs []Binding
+		for {
+			b, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("format %d: Decode() failed: %v", tt.format, err)
+			}
+			bs = append(bs, b)
+		}
+		if got := formatBindings(&sliceIterator{bs: bs}); !equalStrings(got, tt.want) {
+			t.Errorf("format %d: decoded =>\n%q\nwant:\n%q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestResultsBoolean(t *testing.T) {
+	for _, f := range []ResultsFormat{ResultsJSON, ResultsXML} {
+		for _, v := range []bool{true, false} {
+			var buf bytes.Buffer
+			enc := NewResultsEncoder(&buf, f)
+			if err := enc.EncodeBoolean(v); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.Encode(Binding{}); err == nil {
+				t.Errorf("format %d: Encode() after EncodeBoolean() succeeded", f)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			dec := NewResultsDecoder(&buf, f)
+			if got, err := dec.Boolean(); err != nil || got != v {
+				t.Errorf("format %d: Boolean() => %v, %v; want %v", f, got, err, v)
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("format %d: Decode() of boolean result => %v; want io.EOF", f, err)
+			}
+		}
+	}
+
+	dec := NewResultsDecoder(strings.NewReader(`{"boolean": true, "head": {}}`), ResultsJSON)
+	if got, err := dec.Boolean(); err != nil || !got {
+		t.Errorf("Boolean() => %v, %v; want true", got, err)
+	}
+
+	for _, f := range []ResultsFormat{ResultsCSV, ResultsTSV} {
+		if err := NewResultsEncoder(&bytes.Buffer{}, f).EncodeBoolean(true); err == nil {
+			t.Errorf("format %d: EncodeBoolean() succeeded", f)
+		}
+	}
+}
+
+func TestResultsDecodeErrors(t *testing.T) {
+	tests := []struct {
+		format ResultsFormat
+		input  string
+		want   string
+	}{
+		{ResultsJSON, `{"head": {"vars": ["x"]}, "results": {"bindings": [`, "unexpected end of JSON input"},
+		{ResultsJSON, `{"head": {"vars": ["x"]}, "results": {"bindings": [{"x": {"type": "iri", "value": "a"}}]}}`, `unknown term type: "iri"`},
+		{ResultsJSON, `[]`, "unexpected JSON token [, expected '{'"},
+		{ResultsXML, `<sparql><head/><results><result><binding name="x"></binding></result></results></sparql>`, "missing term"},
+		{ResultsXML, `<sparql xmlns:its="http://www.w3.org/2005/11/its"><head/><results><result><binding name="x"><literal its:dir="rtl">a</literal></binding></result></results></sparql>`, `base direction without language: "a"`},
+		{ResultsJSON, `{"head": {"vars": ["x"]}, "results": {"bindings": [{"x": {"type": "literal", "value": "a", "xml:lang": "en", "its:dir": "up"}}]}}`, `invalid base direction: "up"`},
+		{ResultsTSV, "?x\n<http://example/a> .\n", `line 2: column 18: unexpected " .", expected end of term`},
+		{ResultsTSV, "?x\n\"a\\q\"\n", `line 2: column 1: invalid escape sequence in "a\\q"`},
+		{ResultsJSON, `{"head": {"vars": ["x"]}, "results": {"bindings": [{"x": {"type": "literal", "value": "a", "xml:lang": "en_GB"}}]}}`, `invalid language tag: unexpected character: '_'`},
+		{ResultsXML, `<sparql><head/><results><result><binding name="x"><literal xml:lang="toolongsubtag">a</literal></binding></result></results></sparql>`, `invalid language tag: subtag too long: "toolongsubtag"`},
+		{ResultsTSV, "?x\n\"a\"@en-abcdefghi\n", `line 2: column 4: invalid language tag: subtag too long: "abcdefghi"`},
+		{ResultsTSV, "?x\n\"a\"@1en\n", `line 2: column 4: invalid language tag: invalid primary language subtag: "1en"`},
+		{ResultsTSV, "?x\n\"a\"@en--up\n", `line 2: column 6: invalid base direction: "--up"`},
+		{ResultsTSV, "?x\n<<( \"a\" <http://example/p> 1 )>>\n", `line 2: column 4: unexpected "a" in triple term`},
+		{ResultsTSV, "?x\n<<( <http://example/s> <http://example/p> 1\n", `line 2: column 43: unexpected "", expected ')>>'`},
+		{ResultsTSV, "?x\nex:a\n", `line 2: column 0: unexpected "ex:a", expected term`},
+		{ResultsTSV, "?x\t?y\n<http://example/a>\n", "line 2: wrong number of fields"},
+		{ResultsTSV, "x\n", `line 1: invalid variable: "x"`},
+	}
+
+	for _, tt := range tests {
+		_, err := NewResultsDecoder(strings.NewReader(tt.input), tt.format).DecodeAll()
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("format %d: DecodeAll(%q) => %v; want %q", tt.format, tt.input, err, tt.want)
+		}
+	}
+}
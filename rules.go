@@ -0,0 +1,404 @@
+package rdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule is a Datalog rule over triples: for each solution of the triple
+// patterns of the body, for which none of the negated groups of patterns
+// has a solution, the triples of the head are inferred.
+type Rule struct {
+	Body []TriplePattern
+	Not  [][]TriplePattern // negated groups, NOT { ... }
+	Head []TriplePattern
+}
+
+// String returns the rule in the syntax of ParseRules, with terms in
+// N-Triples syntax.
+func (r Rule) String() string {
+	group := func(tps []TriplePattern) string {
+		s := make([]string, len(tps))
+		for i, tp := range tps {
+			s[i] = tp.Subj.Serialize(NTriples) + " " + tp.Pred.Serialize(NTriples) + " " + tp.Obj.Serialize(NTriples)
+		}
+		return strings.Join(s, " . ")
+	}
+	body := []string{group(r.Body)}
+	for _, not := range r.Not {
+		body = append(body, "NOT { "+group(not)+" }")
+	}
+	return "{ " + strings.Join(body, " . ") + " } => { " + group(r.Head) + " } ."
+}
+
+// ParseRules parses rules in a syntax similar to Notation3, where the
+// body and head of a rule are groups of triple patterns, as in SPARQL:
+//
+//	@prefix ex: <http://example.org/> .
+//	{ ?x ex:partOf ?y . ?y ex:locatedIn ?z } => { ?x ex:locatedIn ?z } .
+//	{ ?x a ex:Person . NOT { ?x ex:parent ?p } } => { ?x a ex:Orphan } .
+//
+// Prefixes and base IRIs are declared in Turtle or SPARQL syntax. The
+// triples of the body and head are written in Turtle syntax, with variables;
+// blank nodes in the body are variables too, but they are not allowed in
+// the head. Sequence and inverse property paths are allowed in the body.
+//
+// The variables of the head must occur in the body, outside the negated
+// groups. Variables which occur only in a negated group are existentially
+// quantified; the group is satisfied if there is any binding for them.
+//
+// Syntax errors are reported with line and column.
+func ParseRules(s string) (rules []Rule, err error) {
+	p := newSparqlParser(s)
+	defer p.recover(&err)
+	for {
+		p.parseRulePrologue()
+		if p.peek().typ == tokenEOF {
+			return rules, nil
+		}
+		rules = append(rules, p.parseRule())
+	}
+}
+
+// parseRulePrologue parses prefix and base declarations, in Turtle or
+// SPARQL syntax.
+func (p *sparqlParser) parseRulePrologue() {
+	for {
+		switch p.peek().typ {
+		case tokenPrefix:
+			p.next()
+			label := p.expect(tokenPrefixLabel, "prefix label")
+			p.ns[label.text] = p.parseIRIRef(p.next()).str
+			p.expect(tokenDot, "'.'")
+		case tokenBase:
+			p.next()
+			p.base = p.parseIRIRef(p.next())
+			p.expect(tokenDot, "'.'")
+		case tokenSparqlPrefix, tokenSparqlBase:
+			p.parsePrologue()
+		default:
+			return
+		}
+	}
+}
+
+// parseRule parses a rule: '{' body '}' '=>' '{' head '}' '.'.
+func (p *sparqlParser) parseRule() Rule {
+	var r Rule
+	p.bnodes = make(map[string]Var)
+	p.expect(tokenGroupStart, "'{'")
+	var elems []interface{}
+	for !p.accept(tokenGroupEnd) {
+		if p.acceptKeyword("NOT") {
+			p.expect(tokenGroupStart, "'{'")
+			r.Not = append(r.Not, p.parseRulePatterns())
+		} else {
+			elems = p.parseTriplesSameSubject(elems)
+		}
+		if !p.accept(tokenDot) {
+			p.expect(tokenGroupEnd, "'}'")
+			break
+		}
+	}
+	r.Body = p.rulePatterns(elems)
+
+	p.expectOp("=")
+	p.expectOp(">")
+	t := p.expect(tokenGroupStart, "'{'")
+	r.Head = p.parseTemplate()
+	bound := make(map[Var]bool)
+	for _, tp := range r.Body {
+		for _, v := range patternVars(tp) {
+			bound[v] = true
+		}
+	}
+	for _, tp := range r.Head {
+		for _, x := range []Term{tp.Subj, tp.Pred, tp.Obj} {
+			switch x := x.(type) {
+			case Blank:
+				p.errorf(t, "blank nodes not allowed in rule heads")
+			case Var:
+				if !bound[x] {
+					p.errorf(t, "variable ?%s of head not bound in body", x)
+				}
+			}
+		}
+	}
+	p.accept(tokenDot)
+	return r
+}
+
+// parseRulePatterns parses a group of triple patterns, after the opening
+// '{'.
+func (p *sparqlParser) parseRulePatterns() []TriplePattern {
+	var elems []interface{}
+	for !p.accept(tokenGroupEnd) {
+		elems = p.parseTriplesSameSubject(elems)
+		if !p.accept(tokenDot) {
+			p.expect(tokenGroupEnd, "'}'")
+			break
+		}
+	}
+	return p.rulePatterns(elems)
+}
+
+// rulePatterns returns the triple patterns of the elements; property paths
+// which can't be translated to triple patterns aren't allowed.
+func (p *sparqlParser) rulePatterns(elems []interface{}) []TriplePattern {
+	tps := make([]TriplePattern, 0, len(elems))
+	for _, e := range elems {
+		tp, ok := e.(TriplePattern)
+		if !ok {
+			p.errorf(p.last, "property paths not allowed in rules")
+		}
+		tps = append(tps, tp)
+	}
+	return tps
+}
+
+// RuleSet is a set of rules, ordered in strata so that negation is
+// evaluated on triples which have all been inferred; that is, a rule is
+// in a higher stratum than the rules inferring triples which match its
+// negated groups.
+type RuleSet struct {
+	rules  []Rule
+	strata [][]int // indexes of the rules in each stratum
+}
+
+// NewRuleSet returns a set of the rules. It returns an error if the rules
+// are not stratifiable, because a triple can depend on its own negation.
+func NewRuleSet(rules ...Rule) (*RuleSet, error) {
+	n := len(rules)
+	// dep[i][j] is 1 if rule j depends on rule i, and 2 if it depends on
+	// the negation of a triple inferred by rule i.
+	dep := make([][]int, n)
+	for i, ri := range rules {
+		dep[i] = make([]int, n)
+		for j, rj := range rules {
+			for _, not := range rj.Not {
+				if patternsOverlap(ri.Head, not) {
+					dep[i][j] = 2
+				}
+			}
+			if dep[i][j] == 0 && patternsOverlap(ri.Head, rj.Body) {
+				dep[i][j] = 1
+			}
+		}
+	}
+	stratum := make([]int, n)
+	for changed := true; changed; {
+		changed = false
+		for i := range rules {
+			for j := range rules {
+				if dep[i][j] == 0 {
+					continue
+				}
+				need := stratum[i] + dep[i][j] - 1
+				if stratum[j] < need {
+					if need >= n {
+						return nil, fmt.Errorf("rules not stratifiable: negation in %s depends on itself", rules[j])
+					}
+					stratum[j] = need
+					changed = true
+				}
+			}
+		}
+	}
+	rs := &RuleSet{rules: rules}
+	for i, s := range stratum {
+		for len(rs.strata) <= s {
+			rs.strata = append(rs.strata, nil)
+		}
+		rs.strata[s] = append(rs.strata[s], i)
+	}
+	return rs, nil
+}
+
+// patternsOverlap reports whether a triple matching a pattern of a may
+// match a pattern of b.
+func patternsOverlap(a, b []TriplePattern) bool {
+	match := func(x, y Term) bool {
+		_, xVar := x.(Var)
+		_, yVar := y.(Var)
+		return xVar || yVar || sameTerm(x, y)
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if match(x.Subj, y.Subj) && match(x.Pred, y.Pred) && match(x.Obj, y.Obj) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rules returns the rules of the set.
+func (rs *RuleSet) Rules() []Rule {
+	return rs.rules
+}
+
+// Infer applies the rules to the triples of the source until no more
+// triples can be inferred, and returns the inferred triples which are not
+// in the source, in order of inference. The source is not modified.
+//
+// The strata are evaluated in order; the rules of each stratum by
+// semi-naive forward chaining: each new triple is joined once with the
+// triples known so far, in each position of the body of each rule.
+func (rs *RuleSet) Infer(src TripleSource) []Triple {
+	e := &ruleEval{src: src, inf: NewGraph()}
+	for _, stratum := range rs.strata {
+		rules := make([]Rule, len(stratum))
+		for i, r := range stratum {
+			rules[i] = rs.rules[r]
+		}
+		for _, r := range rules {
+			e.solve(r, Binding{}, -1)
+		}
+		for len(e.delta) > 0 {
+			t := e.delta[0]
+			e.delta = e.delta[1:]
+			for _, r := range rules {
+				for i, tp := range r.Body {
+					if b, ok := unify(tp, t); ok {
+						e.solve(r, b, i)
+					}
+				}
+			}
+		}
+	}
+	return e.order
+}
+
+// ruleEval is the state of an evaluation of rules. It is a TripleSource of
+// the union of the source triples and the inferred triples.
+type ruleEval struct {
+	src   TripleSource
+	inf   *Graph   // inferred triples
+	order []Triple // inferred triples, in order of inference
+	delta []Triple // inferred triples to join with the others
+}
+
+// Match returns the source and inferred triples matching the pattern.
+func (e *ruleEval) Match(s Subject, p Predicate, o Object) []Triple {
+	var ts []Triple
+	if e.src != nil {
+		ts = e.src.Match(s, p, o)
+	}
+	// The slice is copied before appending, as it may belong to the source.
+	return append(ts[:len(ts):len(ts)], e.inf.Match(s, p, o)...)
+}
+
+// Count returns the number of source and inferred triples matching the
+// pattern.
+func (e *ruleEval) Count(s Subject, p Predicate, o Object) int {
+	n := e.inf.Count(s, p, o)
+	switch src := e.src.(type) {
+	case nil:
+	case Counter:
+		n += src.Count(s, p, o)
+	default:
+		n += len(src.Match(s, p, o))
+	}
+	return n
+}
+
+// solve finds the solutions of the body of the rule extending the binding,
+// where the pattern at index skip is already matched, and infers the head
+// for each solution which satisfies none of the negated groups.
+func (e *ruleEval) solve(r Rule, b Binding, skip int) {
+	var bgp []TriplePattern
+	for i, tp := range r.Body {
+		if i != skip {
+			bgp = append(bgp, bindPattern(tp, b))
+		}
+	}
+	var solutions []Binding
+	it := QueryBGP(e, bgp)
+	for s, ok := it.Next(); ok; s, ok = it.Next() {
+		for v, t := range b {
+			s[v] = t
+		}
+		if !e.negated(r, s) {
+			solutions = append(solutions, s)
+		}
+	}
+	// The heads are inferred after, as inferences modify the graph.
+	for _, s := range solutions {
+		for _, tp := range r.Head {
+			subj, pred, obj, ok := substitute(tp, s)
+			if !ok || subj == nil || pred == nil || obj == nil {
+				continue
+			}
+			t := Triple{Subj: subj, Pred: pred, Obj: obj}
+			if e.Count(subj, pred, obj) == 0 {
+				e.inf.Add(t)
+				e.order = append(e.order, t)
+				e.delta = append(e.delta, t)
+			}
+		}
+	}
+}
+
+// negated reports whether any of the negated groups of the rule has a
+// solution extending the binding.
+func (e *ruleEval) negated(r Rule, b Binding) bool {
+	for _, not := range r.Not {
+		bgp := make([]TriplePattern, len(not))
+		for i, tp := range not {
+			bgp[i] = bindPattern(tp, b)
+		}
+		if _, ok := QueryBGP(e, bgp).Next(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unify returns the binding of the variables of the pattern which makes it
+// equal to the triple, or false if there is none.
+func unify(tp TriplePattern, t Triple) (Binding, bool) {
+	pairs := [...]struct{ pat, term Term }{{tp.Subj, t.Subj}, {tp.Pred, t.Pred}, {tp.Obj, t.Obj}}
+	for _, x := range pairs {
+		if _, ok := x.pat.(Var); !ok && !sameNode(x.pat, x.term) {
+			return nil, false
+		}
+	}
+	b := make(Binding, 3)
+	for _, x := range pairs {
+		v, ok := x.pat.(Var)
+		if !ok {
+			continue
+		}
+		if bound, ok := b[v]; ok && !sameNode(bound, x.term) {
+			return nil, false
+		}
+		b[v] = x.term
+	}
+	return b, true
+}
+
+// sameNode is sameTerm, without serializing IRIs and blank nodes.
+func sameNode(a, b Term) bool {
+	switch a := a.(type) {
+	case IRI:
+		b, ok := b.(IRI)
+		return ok && a.str == b.str
+	case Blank:
+		b, ok := b.(Blank)
+		return ok && a.id == b.id
+	}
+	return sameTerm(a, b)
+}
+
+// bindPattern returns the pattern with its bound variables replaced by
+// their values.
+func bindPattern(tp TriplePattern, b Binding) TriplePattern {
+	for _, x := range []*Term{&tp.Subj, &tp.Pred, &tp.Obj} {
+		if v, ok := (*x).(Var); ok {
+			if t, ok := b[v]; ok {
+				*x = t
+			}
+		}
+	}
+	return tp
+}
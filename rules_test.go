@@ -0,0 +1,178 @@
+package rdf
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+		err   string
+	}{
+		{
+			`@prefix ex: <http://example/> .
+			{ ?x ex:partOf ?y . ?y ex:locatedIn ?z } => { ?x ex:locatedIn ?z } .`,
+			[]string{`{ ?x <http://example/partOf> ?y . ?y <http://example/locatedIn> ?z } => { ?x <http://example/locatedIn> ?z } .`},
+			"",
+		},
+		{
+			`PREFIX ex: <http://example/>
+			{ ?x a ex:Person ; ex:name "x"@en . NOT { ?x ex:parent ?p } } => { ?x a ex:Orphan }
+			{ ?x ex:knows ?y } => { ?y ex:knows ?x ; a ex:Social } .`,
+			[]string{
+				`{ ?x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Person> . ?x <http://example/name> "x"@en . NOT { ?x <http://example/parent> ?p } } => { ?x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Orphan> } .`,
+				`{ ?x <http://example/knows> ?y } => { ?y <http://example/knows> ?x . ?y <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Social> } .`,
+			},
+			"",
+		},
+		{
+			`@base <http://example/> .
+			{ ?x <parent>/<brother> ?y . ?x ^<child> ?z } => { ?x <uncle> ?y ; <hasParent> ?z } .`,
+			[]string{`{ ?x <http://example/parent> ?.1 . ?.1 <http://example/brother> ?y . ?z <http://example/child> ?x } => { ?x <http://example/uncle> ?y . ?x <http://example/hasParent> ?z } .`},
+			"",
+		},
+		{
+			`{ ?x <http://example/p> _:b . _:b <http://example/q> ?y } => { ?x <http://example/r> ?y } .`,
+			[]string{`{ ?x <http://example/p> ?.1 . ?.1 <http://example/q> ?y } => { ?x <http://example/r> ?y } .`},
+			"",
+		},
+		{`{ ?x <http://example/p> ?y } => { ?x <http://example/p> ?z } .`, nil, "1:32: variable ?z of head not bound in body"},
+		{`{ ?x <http://example/p> ?y . NOT { ?x <http://example/q> ?z } } => { ?x <http://example/p> ?z } .`, nil, "1:67: variable ?z of head not bound in body"},
+		{`{ ?x <http://example/p> ?y } => { ?x <http://example/p> [] } .`, nil, "1:32: blank nodes not allowed in rule heads"},
+		{`{ ?x <http://example/p>* ?y } => { ?x <http://example/p> ?y } .`, nil, "1:28: property paths not allowed in rules"},
+		{`{ ?x <http://example/p> ?y } { ?x <http://example/p> ?y } .`, nil, "1:29: unexpected \"{\", expected '='"},
+		{`{ ?x ex:p ?y } => { ?x ex:p ?y } .`, nil, "1:5: missing namespace for prefix: 'ex'"},
+	}
+	for _, test := range tests {
+		rules, err := ParseRules(test.input)
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("ParseRules(%q) => error %v; want %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("ParseRules(%q) => no error; want %q", test.input, test.err)
+			continue
+		}
+		var got []string
+		for _, r := range rules {
+			got = append(got, r.String())
+		}
+		if !equalStrings(got, test.want) {
+			t.Errorf("ParseRules(%q) =>\n%v\nwant:\n%v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestRuleSetInfer(t *testing.T) {
+	data := mustDecodeTTL(`
+@prefix ex: <http://example/> .
+ex:desk ex:partOf ex:office .
+ex:office ex:partOf ex:floor2 .
+ex:floor2 ex:locatedIn ex:building .
+ex:building ex:locatedIn ex:oslo .
+ex:ann a ex:Person ; ex:parent ex:bob .
+ex:bob a ex:Person .
+`)
+	tests := []struct {
+		rules string
+		want  []string
+	}{
+		{
+			// Recursion.
+			`@prefix ex: <http://example/> .
+			{ ?x ex:partOf ?y . ?y ex:locatedIn ?z } => { ?x ex:locatedIn ?z } .
+			{ ?x ex:locatedIn ?y . ?y ex:locatedIn ?z } => { ?x ex:locatedIn ?z } .`,
+			[]string{
+				"<http://example/desk> <http://example/locatedIn> <http://example/building> .\n",
+				"<http://example/desk> <http://example/locatedIn> <http://example/oslo> .\n",
+				"<http://example/floor2> <http://example/locatedIn> <http://example/oslo> .\n",
+				"<http://example/office> <http://example/locatedIn> <http://example/building> .\n",
+				"<http://example/office> <http://example/locatedIn> <http://example/oslo> .\n",
+			},
+		},
+		{
+			// Negation of a triple inferred by another rule.
+			`@prefix ex: <http://example/> .
+			{ ?x a ex:Person . NOT { ?x ex:ancestor ?y } } => { ?x a ex:Orphan } .
+			{ ?x ex:parent ?y } => { ?x ex:ancestor ?y } .`,
+			[]string{
+				"<http://example/ann> <http://example/ancestor> <http://example/bob> .\n",
+				"<http://example/bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/Orphan> .\n",
+			},
+		},
+		{
+			// Variable predicates, and triples already in the source.
+			`{ ?x ?p ?y . ?p a <http://example/Symmetric> } => { ?y ?p ?x } .
+			{ ?x <http://example/partOf> ?y } => { ?x <http://example/partOf> ?y } .`,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		rules, err := ParseRules(test.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := NewRuleSet(rules...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The source can be any TripleSource.
+		for _, src := range []TripleSource{NewGraph(data...), sliceSource(data)} {
+			if got := sortedNT(rs.Infer(src)); !equalStrings(got, test.want) {
+				t.Errorf("%s\n=>\n%v\nwant:\n%v", test.rules, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRuleSetStratification(t *testing.T) {
+	tests := []struct {
+		rules  string
+		strata [][]int
+		err    string
+	}{
+		{
+			`{ ?x <http://example/p> ?y } => { ?x <http://example/q> ?y } .
+			{ ?x <http://example/q> ?y } => { ?x <http://example/p> ?y } .`,
+			[][]int{{0, 1}},
+			"",
+		},
+		{
+			`{ ?x a <http://example/A> . NOT { ?x a <http://example/B> } } => { ?x a <http://example/C> } .
+			{ ?x a <http://example/D> . NOT { ?x a <http://example/C> } } => { ?x a <http://example/E> } .
+			{ ?x <http://example/p> ?y } => { ?y a <http://example/B> } .`,
+			[][]int{{2}, {0}, {1}},
+			"",
+		},
+		{
+			`{ ?x a <http://example/A> . NOT { ?x a <http://example/B> } } => { ?x a <http://example/C> } .
+			{ ?x a <http://example/C> } => { ?x a <http://example/B> } .`,
+			nil,
+			"rules not stratifiable: negation in { ?x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/A> . NOT { ?x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/B> } } => { ?x <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example/C> } . depends on itself",
+		},
+	}
+	for _, test := range tests {
+		rules, err := ParseRules(test.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := NewRuleSet(rules...)
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("NewRuleSet(%s) => error %v; want %q", test.rules, err, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("NewRuleSet(%s) => no error; want %q", test.rules, test.err)
+			continue
+		}
+		if fmt.Sprint(rs.strata) != fmt.Sprint(test.strata) {
+			t.Errorf("NewRuleSet(%s) => strata %v; want %v", test.rules, rs.strata, test.strata)
+		}
+	}
+}
@@ -0,0 +1,1090 @@
+package rdf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const shNS = "http://www.w3.org/ns/shacl#"
+
+var (
+	shNodeShape                         = IRI{str: shNS + "NodeShape"}
+	shPropertyShape                     = IRI{str: shNS + "PropertyShape"}
+	shTargetNode                        = IRI{str: shNS + "targetNode"}
+	shTargetClass                       = IRI{str: shNS + "targetClass"}
+	shTargetSubjectsOf                  = IRI{str: shNS + "targetSubjectsOf"}
+	shTargetObjectsOf                   = IRI{str: shNS + "targetObjectsOf"}
+	shPath                              = IRI{str: shNS + "path"}
+	shInversePath                       = IRI{str: shNS + "inversePath"}
+	shAlternativePath                   = IRI{str: shNS + "alternativePath"}
+	shZeroOrMorePath                    = IRI{str: shNS + "zeroOrMorePath"}
+	shOneOrMorePath                     = IRI{str: shNS + "oneOrMorePath"}
+	shZeroOrOnePath                     = IRI{str: shNS + "zeroOrOnePath"}
+	shDeactivated                       = IRI{str: shNS + "deactivated"}
+	shSeverity                          = IRI{str: shNS + "severity"}
+	shMessage                           = IRI{str: shNS + "message"}
+	shViolation                         = IRI{str: shNS + "Violation"}
+	shClass                             = IRI{str: shNS + "class"}
+	shDatatype                          = IRI{str: shNS + "datatype"}
+	shNodeKind                          = IRI{str: shNS + "nodeKind"}
+	shMinCount                          = IRI{str: shNS + "minCount"}
+	shMaxCount                          = IRI{str: shNS + "maxCount"}
+	shMinExclusive                      = IRI{str: shNS + "minExclusive"}
+	shMinInclusive                      = IRI{str: shNS + "minInclusive"}
+	shMaxExclusive                      = IRI{str: shNS + "maxExclusive"}
+	shMaxInclusive                      = IRI{str: shNS + "maxInclusive"}
+	shMinLength                         = IRI{str: shNS + "minLength"}
+	shMaxLength                         = IRI{str: shNS + "maxLength"}
+	shPattern                           = IRI{str: shNS + "pattern"}
+	shFlags                             = IRI{str: shNS + "flags"}
+	shLanguageIn                        = IRI{str: shNS + "languageIn"}
+	shUniqueLang                        = IRI{str: shNS + "uniqueLang"}
+	shEquals                            = IRI{str: shNS + "equals"}
+	shDisjoint                          = IRI{str: shNS + "disjoint"}
+	shLessThan                          = IRI{str: shNS + "lessThan"}
+	shLessThanOrEquals                  = IRI{str: shNS + "lessThanOrEquals"}
+	shNot                               = IRI{str: shNS + "not"}
+	shAnd                               = IRI{str: shNS + "and"}
+	shOr                                = IRI{str: shNS + "or"}
+	shXone                              = IRI{str: shNS + "xone"}
+	shNode                              = IRI{str: shNS + "node"}
+	shProperty                          = IRI{str: shNS + "property"}
+	shQualifiedValueShape               = IRI{str: shNS + "qualifiedValueShape"}
+	shQualifiedMinCount                 = IRI{str: shNS + "qualifiedMinCount"}
+	shQualifiedMaxCount                 = IRI{str: shNS + "qualifiedMaxCount"}
+	shQualifiedValueShapesDisjoint      = IRI{str: shNS + "qualifiedValueShapesDisjoint"}
+	shClosed                            = IRI{str: shNS + "closed"}
+	shIgnoredProperties                 = IRI{str: shNS + "ignoredProperties"}
+	shHasValue                          = IRI{str: shNS + "hasValue"}
+	shIn                                = IRI{str: shNS + "in"}
+	shValidationReport                  = IRI{str: shNS + "ValidationReport"}
+	shValidationResult                  = IRI{str: shNS + "ValidationResult"}
+	shConforms                          = IRI{str: shNS + "conforms"}
+	shResult                            = IRI{str: shNS + "result"}
+	shFocusNode                         = IRI{str: shNS + "focusNode"}
+	shResultPath                        = IRI{str: shNS + "resultPath"}
+	shValue                             = IRI{str: shNS + "value"}
+	shSourceShape                       = IRI{str: shNS + "sourceShape"}
+	shSourceConstraintComponent         = IRI{str: shNS + "sourceConstraintComponent"}
+	shResultSeverity                    = IRI{str: shNS + "resultSeverity"}
+	shResultMessage                     = IRI{str: shNS + "resultMessage"}
+	shPathPredicates                    = []IRI{shInversePath, shAlternativePath, shZeroOrMorePath, shOneOrMorePath, shZeroOrOnePath}
+	shTargetPredicates                  = []IRI{shTargetNode, shTargetClass, shTargetSubjectsOf, shTargetObjectsOf}
+	shShapeReferences                   = []IRI{shNode, shProperty, shNot, shQualifiedValueShape}
+	shClassPath                    Path = PathSeq{PathLink{rdfType}, PathZeroOrMore{PathLink{rdfsSubClassOf}}}
+)
+
+// shNodeKinds are the values of sh:nodeKind, and the kinds of terms they
+// allow: IRIs, blank nodes and literals.
+var shNodeKinds = map[string][3]bool{
+	shNS + "IRI":                {true, false, false},
+	shNS + "BlankNode":          {false, true, false},
+	shNS + "Literal":            {false, false, true},
+	shNS + "BlankNodeOrIRI":     {true, true, false},
+	shNS + "BlankNodeOrLiteral": {false, true, true},
+	shNS + "IRIOrLiteral":       {true, false, true},
+}
+
+// ValidationResult is a result of SHACL validation: a focus node, or one of
+// its values, which doesn't conform to a constraint of a shape.
+type ValidationResult struct {
+	FocusNode   Term
+	Path        Term // the sh:path of a property shape, or nil
+	Value       Term // the value which doesn't conform, or nil
+	SourceShape Term
+	Component   IRI // the constraint component, e.g. sh:ClassConstraintComponent
+	Severity    IRI
+	Messages    []Literal
+}
+
+// ValidationReport is the outcome of SHACL validation. The data graph
+// conforms to the shapes graph if there are no results.
+type ValidationReport struct {
+	Conforms bool
+	Results  []ValidationResult
+
+	shapes, data *Graph
+}
+
+// Triples returns the report as an RDF graph of a sh:ValidationReport, as
+// defined in https://www.w3.org/TR/shacl/#validation-report. The paths of
+// the results are copied from the shapes graph. The report and its results
+// are blank nodes whose labels don't occur in the shapes or data graph.
+func (r *ValidationReport) Triples() []Triple {
+	n := 0
+	fresh := func() Blank {
+		for {
+			b := Blank{id: "_:vr" + strconv.Itoa(n)}
+			n++
+			if !r.uses(b) {
+				return b
+			}
+		}
+	}
+	report := fresh()
+	ts := []Triple{
+		{Subj: report, Pred: rdfType, Obj: shValidationReport},
+		{Subj: report, Pred: shConforms, Obj: Literal{str: strconv.FormatBool(r.Conforms), DataType: xsdBoolean}},
+	}
+	copied := make(map[string]bool)
+	for _, res := range r.Results {
+		b := fresh()
+		ts = append(ts,
+			Triple{Subj: report, Pred: shResult, Obj: b},
+			Triple{Subj: b, Pred: rdfType, Obj: shValidationResult},
+			Triple{Subj: b, Pred: shFocusNode, Obj: res.FocusNode.(Object)},
+		)
+		if res.Path != nil {
+			ts = append(ts, Triple{Subj: b, Pred: shResultPath, Obj: res.Path.(Object)})
+			ts = append(ts, r.copyPath(res.Path, copied)...)
+		}
+		if res.Value != nil {
+			ts = append(ts, Triple{Subj: b, Pred: shValue, Obj: res.Value.(Object)})
+		}
+		ts = append(ts,
+			Triple{Subj: b, Pred: shSourceShape, Obj: res.SourceShape.(Object)},
+			Triple{Subj: b, Pred: shSourceConstraintComponent, Obj: res.Component},
+			Triple{Subj: b, Pred: shResultSeverity, Obj: res.Severity},
+		)
+		for _, m := range res.Messages {
+			ts = append(ts, Triple{Subj: b, Pred: shResultMessage, Obj: m})
+		}
+	}
+	return ts
+}
+
+// uses reports whether the blank node occurs in the shapes or data graph.
+func (r *ValidationReport) uses(b Blank) bool {
+	for _, g := range []*Graph{r.shapes, r.data} {
+		if g == nil {
+			continue
+		}
+		if _, ok := g.lookup(b); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copyPath returns the triples of the shapes graph describing a path
+// which is a blank node, unless they are copied already.
+func (r *ValidationReport) copyPath(path Term, copied map[string]bool) []Triple {
+	var ts []Triple
+	todo := []Term{path}
+	for len(todo) > 0 {
+		n := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		b, ok := n.(Blank)
+		if !ok || copied[termKey(b)] || r.shapes == nil {
+			continue
+		}
+		copied[termKey(b)] = true
+		for _, t := range r.shapes.Match(b, nil, nil) {
+			ts = append(ts, t)
+			todo = append(todo, t.Obj)
+		}
+	}
+	return ts
+}
+
+// shFailure is a failure of a value to satisfy a constraint, reported by
+// its check.
+type shFailure struct {
+	value Term // the value which doesn't conform, or nil
+	path  Term // overrides the path of the shape, if not nil
+	msg   string
+}
+
+// shCheck checks a constraint for a focus node and its value nodes.
+type shCheck func(v *shValidator, focus Term, values []Term) []shFailure
+
+// shConstraint is a constraint of a shape, with the local name of its
+// constraint component, such as "Class" for sh:ClassConstraintComponent.
+type shConstraint struct {
+	component string
+	check     shCheck
+}
+
+// shShape is a node shape, or a property shape with a path.
+type shShape struct {
+	node        Term
+	path        Path
+	pathNode    Term
+	deactivated bool
+	severity    IRI
+	messages    []Literal
+	targets     map[IRI][]Term
+	constraints []shConstraint
+}
+
+// add adds a constraint to the shape.
+func (s *shShape) add(component string, check shCheck) {
+	s.constraints = append(s.constraints, shConstraint{component: component, check: check})
+}
+
+// Shapes is a SHACL shapes graph, for validating data graphs against the
+// constraints of the SHACL Core language; see https://www.w3.org/TR/shacl/.
+//
+// The shapes with targets select the focus nodes which are validated:
+// sh:targetNode, sh:targetClass, sh:targetSubjectsOf, sh:targetObjectsOf,
+// and shapes which are also classes, which target their instances. The
+// instances of a class are the nodes typed with the class, or one of its
+// subclasses by rdfs:subClassOf in the data graph.
+//
+// Recursive shapes are supported, in that a focus node which is already
+// being validated against a shape conforms to it.
+type Shapes struct {
+	g        *Graph
+	shapes   map[string]*shShape
+	targeted []*shShape
+}
+
+// NewShapes returns the shapes of the given shapes graph. It returns an
+// error if a shape is ill-formed; for example, if it has an invalid path
+// or regular expression, or a list parameter which is not a list.
+func NewShapes(src TripleSource) (*Shapes, error) {
+	sh := &Shapes{g: shGraph(src), shapes: make(map[string]*shShape)}
+	var nodes []Term
+	seen := make(map[string]bool)
+	addNode := func(n Term) {
+		if !seen[termKey(n)] {
+			seen[termKey(n)] = true
+			nodes = append(nodes, n)
+		}
+	}
+	for _, p := range append(shTargetPredicates, shPath) {
+		for _, t := range sh.g.Match(nil, p, nil) {
+			addNode(t.Subj)
+		}
+	}
+	for _, c := range []IRI{shNodeShape, shPropertyShape} {
+		for _, t := range sh.g.Match(nil, rdfType, c) {
+			addNode(t.Subj)
+		}
+	}
+	for _, p := range shShapeReferences {
+		for _, t := range sh.g.Match(nil, p, nil) {
+			addNode(t.Obj)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return termKey(nodes[i]) < termKey(nodes[j]) })
+	for _, n := range nodes {
+		s, err := sh.shape(n)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.targets) > 0 {
+			sh.targeted = append(sh.targeted, s)
+		}
+	}
+	return sh, nil
+}
+
+// shGraph returns the triples of the source as a Graph.
+func shGraph(src TripleSource) *Graph {
+	if g, ok := src.(*Graph); ok {
+		return g
+	}
+	if src == nil {
+		return NewGraph()
+	}
+	return NewGraph(src.Match(nil, nil, nil)...)
+}
+
+// Validate validates the data graph against the shapes. The results of the
+// report are sorted by focus node and constraint component.
+func (sh *Shapes) Validate(data TripleSource) *ValidationReport {
+	v := &shValidator{g: shGraph(data), active: make(map[string]bool)}
+	for _, s := range sh.targeted {
+		for _, focus := range s.focusNodes(v.g) {
+			v.validate(s, focus)
+		}
+	}
+	key := func(r ValidationResult) string {
+		var b strings.Builder
+		for _, t := range []Term{r.FocusNode, r.Component, r.Path, r.Value, r.SourceShape} {
+			if t != nil {
+				b.WriteString(termKey(t))
+			}
+			b.WriteByte(' ')
+		}
+		return b.String()
+	}
+	sort.SliceStable(v.results, func(i, j int) bool { return key(v.results[i]) < key(v.results[j]) })
+	return &ValidationReport{Conforms: len(v.results) == 0, Results: v.results, shapes: sh.g, data: v.g}
+}
+
+// focusNodes returns the distinct focus nodes of the targets of the shape.
+func (s *shShape) focusNodes(g *Graph) []Term {
+	var nodes []Term
+	seen := make(map[string]bool)
+	add := func(n Term) {
+		if !seen[termKey(n)] {
+			seen[termKey(n)] = true
+			nodes = append(nodes, n)
+		}
+	}
+	for _, n := range s.targets[shTargetNode] {
+		add(n)
+	}
+	for _, c := range s.targets[shTargetClass] {
+		for _, n := range pathFrom(g, shClassPath, c, false) {
+			add(n)
+		}
+	}
+	for _, p := range s.targets[shTargetSubjectsOf] {
+		if p, ok := p.(IRI); ok {
+			for _, t := range g.Match(nil, p, nil) {
+				add(t.Subj)
+			}
+		}
+	}
+	for _, p := range s.targets[shTargetObjectsOf] {
+		if p, ok := p.(IRI); ok {
+			for _, t := range g.Match(nil, p, nil) {
+				add(t.Obj)
+			}
+		}
+	}
+	return nodes
+}
+
+// shInstanceOf reports whether x is an instance of the class c in g.
+func shInstanceOf(g *Graph, x, c Term) bool {
+	for _, t := range pathFrom(g, shClassPath, x, true) {
+		if sameTerm(t, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// shapeError returns an error for an ill-formed shape.
+func shapeError(node Term, format string, args ...interface{}) error {
+	return fmt.Errorf("shape %s: %s", node.Serialize(NTriples), fmt.Sprintf(format, args...))
+}
+
+// objects returns the values of a parameter of the shape.
+func (sh *Shapes) objects(s Subject, p IRI) []Term {
+	var ts []Term
+	for _, t := range sh.g.Match(s, p, nil) {
+		ts = append(ts, t.Obj)
+	}
+	return ts
+}
+
+// boolean reports whether a parameter of the shape is true.
+func (sh *Shapes) boolean(s Subject, p IRI) bool {
+	for _, o := range sh.objects(s, p) {
+		if l, ok := o.(Literal); ok && l.DataType == xsdBoolean && l.str == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// integer returns the value of an integer parameter of the shape, if any.
+func (sh *Shapes) integer(s Subject, p IRI) (int, bool, error) {
+	objs := sh.objects(s, p)
+	if len(objs) == 0 {
+		return 0, false, nil
+	}
+	if l, ok := objs[0].(Literal); ok && len(objs) == 1 {
+		if n, err := strconv.Atoi(l.str); err == nil && n >= 0 {
+			return n, true, nil
+		}
+	}
+	return 0, false, shapeError(s, "%s must be a non-negative integer", p.Serialize(NTriples))
+}
+
+// list returns the members of an RDF list of the shapes graph.
+func (sh *Shapes) list(head Term) ([]Term, bool) {
+	var items []Term
+	seen := make(map[string]bool)
+	for n := head; !sameTerm(n, rdfNil); {
+		s, ok := nodeSubject(n.(Object))
+		if !ok || seen[termKey(s)] {
+			return nil, false
+		}
+		seen[termKey(s)] = true
+		first, rest := sh.g.Match(s, rdfFirst, nil), sh.g.Match(s, rdfRest, nil)
+		if len(first) != 1 || len(rest) != 1 {
+			return nil, false
+		}
+		items = append(items, first[0].Obj)
+		n = rest[0].Obj
+	}
+	return items, true
+}
+
+// path converts a SHACL property path to a SPARQL path.
+func (sh *Shapes) path(n Term, depth int) (Path, error) {
+	if iri, ok := n.(IRI); ok {
+		return PathLink{IRI: iri}, nil
+	}
+	b, ok := n.(Blank)
+	if !ok || depth > 64 {
+		return nil, fmt.Errorf("ill-formed path %s", n.Serialize(NTriples))
+	}
+	paths := func(items []Term) ([]Path, error) {
+		var ps []Path
+		for _, item := range items {
+			p, err := sh.path(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, p)
+		}
+		return ps, nil
+	}
+	if sh.g.Count(b, rdfFirst, nil) > 0 {
+		items, ok := sh.list(b)
+		if !ok || len(items) < 2 {
+			return nil, fmt.Errorf("ill-formed sequence path %s", n.Serialize(NTriples))
+		}
+		ps, err := paths(items)
+		if err != nil {
+			return nil, err
+		}
+		seq := ps[0]
+		for _, p := range ps[1:] {
+			seq = PathSeq{Left: seq, Right: p}
+		}
+		return seq, nil
+	}
+	for _, pred := range shPathPredicates {
+		objs := sh.objects(b, pred)
+		if len(objs) == 0 {
+			continue
+		}
+		if len(objs) > 1 || sh.g.Count(b, nil, nil) > 1 {
+			break
+		}
+		if pred == shAlternativePath {
+			items, ok := sh.list(objs[0])
+			if !ok || len(items) < 2 {
+				break
+			}
+			ps, err := paths(items)
+			if err != nil {
+				return nil, err
+			}
+			alt := ps[0]
+			for _, p := range ps[1:] {
+				alt = PathAlt{Left: alt, Right: p}
+			}
+			return alt, nil
+		}
+		p, err := sh.path(objs[0], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		switch pred {
+		case shInversePath:
+			return PathInverse{Path: p}, nil
+		case shZeroOrMorePath:
+			return PathZeroOrMore{Path: p}, nil
+		case shOneOrMorePath:
+			return PathOneOrMore{Path: p}, nil
+		}
+		return PathZeroOrOne{Path: p}, nil
+	}
+	return nil, fmt.Errorf("ill-formed path %s", n.Serialize(NTriples))
+}
+
+// shape returns the shape of the given node, parsing it if necessary.
+func (sh *Shapes) shape(node Term) (*shShape, error) {
+	if s, ok := sh.shapes[termKey(node)]; ok {
+		return s, nil
+	}
+	subj, ok := nodeSubject(node.(Object))
+	if !ok {
+		return nil, fmt.Errorf("shape %s is not an IRI or blank node", node.Serialize(NTriples))
+	}
+	s := &shShape{node: node, severity: shViolation, targets: make(map[IRI][]Term)}
+	sh.shapes[termKey(node)] = s
+
+	if paths := sh.objects(subj, shPath); len(paths) > 1 {
+		return nil, shapeError(node, "more than one sh:path")
+	} else if len(paths) == 1 {
+		p, err := sh.path(paths[0], 0)
+		if err != nil {
+			return nil, shapeError(node, "%v", err)
+		}
+		s.path, s.pathNode = p, paths[0]
+	}
+	s.deactivated = sh.boolean(subj, shDeactivated)
+	for _, o := range sh.objects(subj, shSeverity) {
+		if iri, ok := o.(IRI); ok {
+			s.severity = iri
+		}
+	}
+	for _, o := range sh.objects(subj, shMessage) {
+		if l, ok := o.(Literal); ok {
+			s.messages = append(s.messages, l)
+		}
+	}
+	for _, p := range shTargetPredicates {
+		if objs := sh.objects(subj, p); len(objs) > 0 {
+			s.targets[p] = objs
+		}
+	}
+	// A shape which is also a class targets its instances.
+	if shInstanceOf(sh.g, node, rdfsClass) {
+		s.targets[shTargetClass] = append(s.targets[shTargetClass], node)
+	}
+	if err := sh.constraints(s, subj); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// shapeList returns the shapes of the given nodes.
+func (sh *Shapes) shapeList(nodes []Term) ([]*shShape, error) {
+	var shapes []*shShape
+	for _, n := range nodes {
+		s, err := sh.shape(n)
+		if err != nil {
+			return nil, err
+		}
+		shapes = append(shapes, s)
+	}
+	return shapes, nil
+}
+
+// perValue returns a check which reports each value node for which the
+// condition doesn't hold.
+func perValue(msg string, ok func(v *shValidator, x Term) bool) shCheck {
+	return func(v *shValidator, focus Term, values []Term) []shFailure {
+		var res []shFailure
+		for _, x := range values {
+			if !ok(v, x) {
+				res = append(res, shFailure{value: x, msg: msg})
+			}
+		}
+		return res
+	}
+}
+
+// shString returns the string of an IRI or literal value, for the string
+// based constraints.
+func shString(x Term) (string, bool) {
+	switch x := x.(type) {
+	case IRI:
+		return x.str, true
+	case Literal:
+		return x.str, true
+	}
+	return "", false
+}
+
+// containsTerm reports whether ts contains t.
+func containsTerm(ts []Term, t Term) bool {
+	for _, x := range ts {
+		if sameTerm(x, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// constraints parses the constraints of the shape.
+func (sh *Shapes) constraints(s *shShape, subj Subject) error {
+	nt := func(t Term) string { return t.Serialize(NTriples) }
+	property := s.path != nil
+
+	for _, c := range sh.objects(subj, shClass) {
+		c := c
+		s.add("Class", perValue("Value is not an instance of "+nt(c), func(v *shValidator, x Term) bool {
+			return shInstanceOf(v.g, x, c)
+		}))
+	}
+	for _, o := range sh.objects(subj, shDatatype) {
+		dt, ok := o.(IRI)
+		if !ok {
+			return shapeError(s.node, "sh:datatype must be an IRI")
+		}
+		s.add("Datatype", perValue("Value does not have datatype "+nt(dt), func(v *shValidator, x Term) bool {
+			l, ok := x.(Literal)
+			if !ok || l.DataType != dt {
+				return false
+			}
+			if dt == rdfLangString {
+				return l.lang != ""
+			}
+			return validateLexical(l.str, dt) == nil
+		}))
+	}
+	for _, o := range sh.objects(subj, shNodeKind) {
+		iri, _ := o.(IRI)
+		kinds, ok := shNodeKinds[iri.str]
+		if !ok {
+			return shapeError(s.node, "invalid sh:nodeKind %s", nt(o))
+		}
+		s.add("NodeKind", perValue("Value is not of node kind "+nt(o), func(v *shValidator, x Term) bool {
+			switch x.(type) {
+			case IRI:
+				return kinds[0]
+			case Blank:
+				return kinds[1]
+			case Literal:
+				return kinds[2]
+			}
+			return false
+		}))
+	}
+	if property {
+		if n, ok, err := sh.integer(subj, shMinCount); err != nil {
+			return err
+		} else if ok {
+			s.add("MinCount", func(v *shValidator, focus Term, values []Term) []shFailure {
+				if len(values) < n {
+					return []shFailure{{msg: fmt.Sprintf("Less than %d values", n)}}
+				}
+				return nil
+			})
+		}
+		if n, ok, err := sh.integer(subj, shMaxCount); err != nil {
+			return err
+		} else if ok {
+			s.add("MaxCount", func(v *shValidator, focus Term, values []Term) []shFailure {
+				if len(values) > n {
+					return []shFailure{{msg: fmt.Sprintf("More than %d values", n)}}
+				}
+				return nil
+			})
+		}
+	}
+	for _, r := range []struct {
+		param     IRI
+		component string
+		rel       string
+		ok        func(c int) bool
+	}{
+		{shMinExclusive, "MinExclusive", ">", func(c int) bool { return c > 0 }},
+		{shMinInclusive, "MinInclusive", ">=", func(c int) bool { return c >= 0 }},
+		{shMaxExclusive, "MaxExclusive", "<", func(c int) bool { return c < 0 }},
+		{shMaxInclusive, "MaxInclusive", "<=", func(c int) bool { return c <= 0 }},
+	} {
+		r := r
+		for _, bound := range sh.objects(subj, r.param) {
+			bound := bound
+			s.add(r.component, perValue("Value is not "+r.rel+" "+nt(bound), func(v *shValidator, x Term) bool {
+				c, err := compareTerms(x, bound)
+				return err == nil && r.ok(c)
+			}))
+		}
+	}
+	if n, ok, err := sh.integer(subj, shMinLength); err != nil {
+		return err
+	} else if ok {
+		s.add("MinLength", perValue(fmt.Sprintf("Value has less than %d characters", n), func(v *shValidator, x Term) bool {
+			str, ok := shString(x)
+			return ok && utf8.RuneCountInString(str) >= n
+		}))
+	}
+	if n, ok, err := sh.integer(subj, shMaxLength); err != nil {
+		return err
+	} else if ok {
+		s.add("MaxLength", perValue(fmt.Sprintf("Value has more than %d characters", n), func(v *shValidator, x Term) bool {
+			str, ok := shString(x)
+			return ok && utf8.RuneCountInString(str) <= n
+		}))
+	}
+	for _, o := range sh.objects(subj, shPattern) {
+		l, ok := o.(Literal)
+		if !ok {
+			return shapeError(s.node, "sh:pattern must be a literal")
+		}
+		args := []Term{Literal{str: l.str, DataType: xsdString}}
+		for _, f := range sh.objects(subj, shFlags) {
+			if l, ok := f.(Literal); ok {
+				args = append(args, Literal{str: l.str, DataType: xsdString})
+			}
+		}
+		re, err := regexArg(args)
+		if err != nil {
+			return shapeError(s.node, "%v", err)
+		}
+		s.add("Pattern", perValue("Value does not match "+nt(o), func(v *shValidator, x Term) bool {
+			str, ok := shString(x)
+			return ok && re.MatchString(str)
+		}))
+	}
+	for _, o := range sh.objects(subj, shLanguageIn) {
+		ranges, ok := sh.list(o)
+		if !ok {
+			return shapeError(s.node, "sh:languageIn must be a list")
+		}
+		s.add("LanguageIn", perValue("Language of value is not allowed", func(v *shValidator, x Term) bool {
+			l, ok := x.(Literal)
+			if !ok || l.lang == "" {
+				return false
+			}
+			for _, r := range ranges {
+				if r, ok := r.(Literal); ok && langMatches(l.lang, r.str) {
+					return true
+				}
+			}
+			return false
+		}))
+	}
+	if property && sh.boolean(subj, shUniqueLang) {
+		s.add("UniqueLang", func(v *shValidator, focus Term, values []Term) []shFailure {
+			count := make(map[string]int)
+			for _, x := range values {
+				if l, ok := x.(Literal); ok && l.lang != "" {
+					count[strings.ToLower(l.lang)]++
+				}
+			}
+			var res []shFailure
+			for lang, n := range count {
+				if n > 1 {
+					res = append(res, shFailure{msg: fmt.Sprintf("Language %q used more than once", lang)})
+				}
+			}
+			sort.Slice(res, func(i, j int) bool { return res[i].msg < res[j].msg })
+			return res
+		})
+	}
+
+	// Property pair constraints, comparing the values with those of a
+	// property of the focus node.
+	type pairCheck func(p IRI, values, others []Term) []shFailure
+	less := func(rel string, ok func(c int) bool) pairCheck {
+		return func(p IRI, values, others []Term) []shFailure {
+			var res []shFailure
+			for _, x := range values {
+				for _, y := range others {
+					if c, err := compareTerms(x, y); err != nil || !ok(c) {
+						res = append(res, shFailure{value: x, msg: "Value is not " + rel + " " + nt(y)})
+					}
+				}
+			}
+			return res
+		}
+	}
+	pairs := []struct {
+		param     IRI
+		component string
+		property  bool // only for property shapes
+		check     pairCheck
+	}{
+		{shEquals, "Equals", false, func(p IRI, values, others []Term) []shFailure {
+			var res []shFailure
+			for _, x := range values {
+				if !containsTerm(others, x) {
+					res = append(res, shFailure{value: x, msg: "Value is not a value of " + nt(p)})
+				}
+			}
+			for _, y := range others {
+				if !containsTerm(values, y) {
+					res = append(res, shFailure{value: y, msg: "Value of " + nt(p) + " is missing"})
+				}
+			}
+			return res
+		}},
+		{shDisjoint, "Disjoint", false, func(p IRI, values, others []Term) []shFailure {
+			var res []shFailure
+			for _, x := range values {
+				if containsTerm(others, x) {
+					res = append(res, shFailure{value: x, msg: "Value is also a value of " + nt(p)})
+				}
+			}
+			return res
+		}},
+		{shLessThan, "LessThan", true, less("<", func(c int) bool { return c < 0 })},
+		{shLessThanOrEquals, "LessThanOrEquals", true, less("<=", func(c int) bool { return c <= 0 })},
+	}
+	for _, pair := range pairs {
+		pair := pair
+		if pair.property && !property {
+			continue
+		}
+		for _, o := range sh.objects(subj, pair.param) {
+			p, ok := o.(IRI)
+			if !ok {
+				return shapeError(s.node, "%s must be an IRI", nt(pair.param))
+			}
+			s.add(pair.component, func(v *shValidator, focus Term, values []Term) []shFailure {
+				return pair.check(p, values, v.values(PathLink{IRI: p}, focus))
+			})
+		}
+	}
+
+	// Shape-based constraints.
+	for _, o := range sh.objects(subj, shNot) {
+		other, err := sh.shape(o)
+		if err != nil {
+			return err
+		}
+		s.add("Not", perValue("Value conforms to "+nt(o), func(v *shValidator, x Term) bool {
+			return !v.conforms(other, x)
+		}))
+	}
+	for _, l := range []struct {
+		param     IRI
+		component string
+		msg       string
+		ok        func(n, all int) bool
+	}{
+		{shAnd, "And", "Value does not conform to all shapes", func(n, all int) bool { return n == all }},
+		{shOr, "Or", "Value does not conform to any shape", func(n, all int) bool { return n > 0 }},
+		{shXone, "Xone", "Value does not conform to exactly one shape", func(n, all int) bool { return n == 1 }},
+	} {
+		l := l
+		for _, o := range sh.objects(subj, l.param) {
+			members, ok := sh.list(o)
+			if !ok {
+				return shapeError(s.node, "%s must be a list", nt(l.param))
+			}
+			shapes, err := sh.shapeList(members)
+			if err != nil {
+				return err
+			}
+			s.add(l.component, perValue(l.msg, func(v *shValidator, x Term) bool {
+				n := 0
+				for _, other := range shapes {
+					if v.conforms(other, x) {
+						n++
+					}
+				}
+				return l.ok(n, len(shapes))
+			}))
+		}
+	}
+	for _, o := range sh.objects(subj, shNode) {
+		other, err := sh.shape(o)
+		if err != nil {
+			return err
+		}
+		s.add("Node", perValue("Value does not conform to "+nt(o), func(v *shValidator, x Term) bool {
+			return v.conforms(other, x)
+		}))
+	}
+	for _, o := range sh.objects(subj, shProperty) {
+		other, err := sh.shape(o)
+		if err != nil {
+			return err
+		}
+		if other.path == nil {
+			return shapeError(s.node, "sh:property %s has no sh:path", nt(o))
+		}
+		// The results of property shapes are reported as they are.
+		s.add("Property", func(v *shValidator, focus Term, values []Term) []shFailure {
+			for _, x := range values {
+				v.validate(other, x)
+			}
+			return nil
+		})
+	}
+	if property {
+		if err := sh.qualified(s, subj); err != nil {
+			return err
+		}
+	}
+	if sh.boolean(subj, shClosed) {
+		allowed := make(map[string]bool)
+		for _, o := range sh.objects(subj, shProperty) {
+			if ps, ok := o.(Subject); ok {
+				for _, p := range sh.objects(ps, shPath) {
+					if p, ok := p.(IRI); ok {
+						allowed[termKey(p)] = true
+					}
+				}
+			}
+		}
+		for _, o := range sh.objects(subj, shIgnoredProperties) {
+			ignored, ok := sh.list(o)
+			if !ok {
+				return shapeError(s.node, "sh:ignoredProperties must be a list")
+			}
+			for _, p := range ignored {
+				allowed[termKey(p)] = true
+			}
+		}
+		s.add("Closed", func(v *shValidator, focus Term, values []Term) []shFailure {
+			var res []shFailure
+			for _, x := range values {
+				xs, ok := nodeSubject(x.(Object))
+				if !ok {
+					continue
+				}
+				for _, t := range v.g.Match(xs, nil, nil) {
+					if !allowed[termKey(t.Pred)] {
+						res = append(res, shFailure{value: t.Obj, path: t.Pred, msg: "Predicate " + nt(t.Pred) + " is not allowed"})
+					}
+				}
+			}
+			return res
+		})
+	}
+	for _, o := range sh.objects(subj, shHasValue) {
+		o := o
+		s.add("HasValue", func(v *shValidator, focus Term, values []Term) []shFailure {
+			if containsTerm(values, o) {
+				return nil
+			}
+			return []shFailure{{msg: "Missing value " + nt(o)}}
+		})
+	}
+	for _, o := range sh.objects(subj, shIn) {
+		members, ok := sh.list(o)
+		if !ok {
+			return shapeError(s.node, "sh:in must be a list")
+		}
+		s.add("In", perValue("Value is not in the list of allowed values", func(v *shValidator, x Term) bool {
+			return containsTerm(members, x)
+		}))
+	}
+	return nil
+}
+
+// qualified parses the qualified value shape of a property shape, whose
+// values are counted by the qualified cardinality constraints. If the
+// qualified value shapes of the sibling property shapes are disjoint, the
+// values conforming to the sibling shapes aren't counted.
+func (sh *Shapes) qualified(s *shShape, subj Subject) error {
+	qvs := sh.objects(subj, shQualifiedValueShape)
+	if len(qvs) == 0 {
+		return nil
+	}
+	if len(qvs) > 1 {
+		return shapeError(s.node, "more than one sh:qualifiedValueShape")
+	}
+	shape, err := sh.shape(qvs[0])
+	if err != nil {
+		return err
+	}
+	var siblings []*shShape
+	if sh.boolean(subj, shQualifiedValueShapesDisjoint) {
+		for _, parent := range sh.g.Match(nil, shProperty, s.node.(Object)) {
+			for _, sib := range sh.g.Match(parent.Subj, shProperty, nil) {
+				if sameTerm(sib.Obj, s.node) {
+					continue
+				}
+				sibSubj, ok := nodeSubject(sib.Obj)
+				if !ok {
+					continue
+				}
+				for _, o := range sh.objects(sibSubj, shQualifiedValueShape) {
+					if sameTerm(o, qvs[0]) {
+						continue
+					}
+					other, err := sh.shape(o)
+					if err != nil {
+						return err
+					}
+					siblings = append(siblings, other)
+				}
+			}
+		}
+	}
+	count := func(v *shValidator, values []Term) int {
+		n := 0
+	values:
+		for _, x := range values {
+			if !v.conforms(shape, x) {
+				continue
+			}
+			for _, sib := range siblings {
+				if v.conforms(sib, x) {
+					continue values
+				}
+			}
+			n++
+		}
+		return n
+	}
+	if min, ok, err := sh.integer(subj, shQualifiedMinCount); err != nil {
+		return err
+	} else if ok {
+		s.add("QualifiedMinCount", func(v *shValidator, focus Term, values []Term) []shFailure {
+			if count(v, values) < min {
+				return []shFailure{{msg: fmt.Sprintf("Less than %d values conform to %s", min, qvs[0].Serialize(NTriples))}}
+			}
+			return nil
+		})
+	}
+	if max, ok, err := sh.integer(subj, shQualifiedMaxCount); err != nil {
+		return err
+	} else if ok {
+		s.add("QualifiedMaxCount", func(v *shValidator, focus Term, values []Term) []shFailure {
+			if count(v, values) > max {
+				return []shFailure{{msg: fmt.Sprintf("More than %d values conform to %s", max, qvs[0].Serialize(NTriples))}}
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// shValidator validates the focus nodes of a data graph, collecting the
+// results.
+type shValidator struct {
+	g       *Graph
+	results []ValidationResult
+	active  map[string]bool // shapes and focus nodes being validated
+}
+
+// values returns the distinct values of the path from the focus node.
+func (v *shValidator) values(path Path, focus Term) []Term {
+	var values []Term
+	seen := make(map[string]bool)
+	for _, x := range pathFrom(v.g, path, focus, true) {
+		if !seen[termKey(x)] {
+			seen[termKey(x)] = true
+			values = append(values, x)
+		}
+	}
+	return values
+}
+
+// validate validates the focus node against the shape, adding the results.
+func (v *shValidator) validate(s *shShape, focus Term) {
+	key := termKey(s.node) + " " + termKey(focus)
+	if s.deactivated || v.active[key] {
+		return
+	}
+	v.active[key] = true
+	defer delete(v.active, key)
+
+	values := []Term{focus}
+	if s.path != nil {
+		values = v.values(s.path, focus)
+	}
+	for _, c := range s.constraints {
+		for _, viol := range c.check(v, focus, values) {
+			res := ValidationResult{
+				FocusNode:   focus,
+				Path:        s.pathNode,
+				Value:       viol.value,
+				SourceShape: s.node,
+				Component:   IRI{str: shNS + c.component + "ConstraintComponent"},
+				Severity:    s.severity,
+				Messages:    s.messages,
+			}
+			if viol.path != nil {
+				res.Path = viol.path
+			}
+			if len(res.Messages) == 0 {
+				res.Messages = []Literal{{str: viol.msg, DataType: xsdString}}
+			}
+			v.results = append(v.results, res)
+		}
+	}
+}
+
+// conforms reports whether the focus node conforms to the shape; that is,
+// if validating it gives no results.
+func (v *shValidator) conforms(s *shShape, focus Term) bool {
+	saved := v.results
+	v.results = nil
+	v.validate(s, focus)
+	ok := len(v.results) == 0
+	v.results = saved
+	return ok
+}
@@ -0,0 +1,253 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+const shaclTestPrefixes = `
+@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+@prefix sh: <http://www.w3.org/ns/shacl#> .
+@prefix : <http://example/> .
+`
+
+// formatResults formats validation results as "focus component value",
+// with the local names of the focus, component and value.
+func formatResults(results []ValidationResult) []string {
+	local := func(t Term) string {
+		if t == nil {
+			return "-"
+		}
+		iri, ok := t.(IRI)
+		if !ok {
+			return t.Serialize(NTriples)
+		}
+		s := strings.TrimPrefix(iri.str, "http://example/")
+		return strings.TrimSuffix(strings.TrimPrefix(s, shNS), "ConstraintComponent")
+	}
+	var res []string
+	for _, r := range results {
+		res = append(res, local(r.FocusNode)+" "+local(r.Component)+" "+local(r.Value))
+	}
+	return res
+}
+
+func TestShapesValidate(t *testing.T) {
+	tests := []struct {
+		shapes string
+		data   string
+		want   []string
+	}{
+		{
+			`:S a sh:NodeShape ; sh:targetClass :Person ;
+				sh:property [ sh:path :name ; sh:minCount 1 ; sh:maxCount 1 ; sh:datatype xsd:string ] .`,
+			`:Student rdfs:subClassOf :Person .
+			:ann a :Person ; :name "Ann" .
+			:bob a :Student .
+			:cid a :Person ; :name "Cid" , "C" .
+			:dan a :Person ; :name 1 .`,
+			[]string{"bob MinCount -", "cid MaxCount -", `dan Datatype "1"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		},
+		{
+			// Implicit class target, class and node kind.
+			`:Person a rdfs:Class , sh:NodeShape ;
+				sh:property [ sh:path :knows ; sh:class :Person ; sh:nodeKind sh:IRI ] .`,
+			`:ann a :Person ; :knows :bob , _:x , "cid" . :bob a :Person . _:x a :Person .`,
+			[]string{`ann Class "cid"`, `ann NodeKind "cid"`, "ann NodeKind _:x"},
+		},
+		{
+			`:S sh:targetNode 5, 15, "x" ; sh:minInclusive 10 ; sh:maxExclusive 20 .`,
+			``,
+			[]string{`"5"^^<http://www.w3.org/2001/XMLSchema#integer> MinInclusive "5"^^<http://www.w3.org/2001/XMLSchema#integer>`, `"x" MaxExclusive "x"`, `"x" MinInclusive "x"`},
+		},
+		{
+			`:S sh:targetSubjectsOf :code ;
+				sh:property [ sh:path :code ; sh:pattern "^[a-z]+$" ; sh:flags "i" ; sh:minLength 2 ; sh:maxLength 3 ] .`,
+			`:a :code "AbC" . :b :code "a" . :c :code "ab1" . :d :code _:x .`,
+			[]string{`b MinLength "a"`, `c Pattern "ab1"`, "d MaxLength _:x", "d MinLength _:x", "d Pattern _:x"},
+		},
+		{
+			`:S sh:targetSubjectsOf :label ;
+				sh:property [ sh:path :label ; sh:languageIn ( "en" "no" ) ; sh:uniqueLang true ] .`,
+			`:a :label "a"@en-GB , "b"@no . :b :label "c"@de , "d"@EN , "e"@en .`,
+			[]string{`b LanguageIn "c"@de`, "b UniqueLang -"},
+		},
+		{
+			// Property pairs.
+			`:S sh:targetSubjectsOf :start ;
+				sh:property [ sh:path :start ; sh:lessThan :end ; sh:disjoint :other ] ;
+				sh:property [ sh:path :alias ; sh:equals :name ] .`,
+			`:a :start 1 ; :end 2 ; :name "a" ; :alias "a" .
+			:b :start 3 ; :end 2 ; :other 3 ; :name "b" .`,
+			[]string{
+				`b Disjoint "3"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`b Equals "b"`,
+				`b LessThan "3"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+			},
+		},
+		{
+			// Logical constraints.
+			`:S sh:targetNode :a, :b, :c ;
+				sh:not [ sh:class :Banned ] ;
+				sh:or ( [ sh:path :email ; sh:minCount 1 ] [ sh:path :phone ; sh:minCount 1 ] ) ;
+				sh:xone ( [ sh:class :Cat ] [ sh:class :Dog ] ) ;
+				sh:and ( [ sh:path :age ; sh:maxCount 1 ] ) .`,
+			`:a a :Cat ; :email "a@example" .
+			:b a :Cat , :Dog , :Banned ; :phone "1" .
+			:c :age 1 , 2 .`,
+			[]string{"b Not b", "b Xone b", "c And c", "c Or c", "c Xone c"},
+		},
+		{
+			// sh:node, and recursion.
+			`:Person a sh:NodeShape ; sh:targetClass :Person ;
+				sh:property [ sh:path :knows ; sh:node :Person ] ;
+				sh:property [ sh:path :name ; sh:minCount 1 ] .`,
+			`:ann a :Person ; :name "Ann" ; :knows :bob . :bob :name "Bob" ; :knows :ann , :cid . :cid :knows :bob .`,
+			[]string{"ann Node bob"},
+		},
+		{
+			// Qualified value shapes.
+			`:S sh:targetNode :hand ;
+				sh:property :thumbs , :fingers .
+			:thumbs sh:path :digit ; sh:qualifiedValueShape [ sh:class :Thumb ] ; sh:qualifiedMinCount 1 ; sh:qualifiedMaxCount 1 ; sh:qualifiedValueShapesDisjoint true .
+			:fingers sh:path :digit ; sh:qualifiedValueShape [ sh:class :Finger ] ; sh:qualifiedMinCount 4 ; sh:qualifiedMaxCount 4 ; sh:qualifiedValueShapesDisjoint true .`,
+			`:hand :digit :t1 , :f1 , :f2 , :f3 , :f4 .
+			:t1 a :Thumb , :Finger . :f1 a :Finger . :f2 a :Finger . :f3 a :Finger . :f4 a :Finger .`,
+			[]string{"hand QualifiedMinCount -"},
+		},
+		{
+			`:S sh:targetNode :hand ;
+				sh:property [ sh:path :digit ; sh:qualifiedValueShape [ sh:class :Thumb ] ; sh:qualifiedMaxCount 1 ] ;
+				sh:property [ sh:path :digit ; sh:qualifiedValueShape [ sh:class :Finger ] ; sh:qualifiedMinCount 2 ] .`,
+			`:hand :digit :t1 , :t2 . :t1 a :Thumb . :t2 a :Thumb .`,
+			[]string{"hand QualifiedMaxCount -", "hand QualifiedMinCount -"},
+		},
+		{
+			// Closed shapes.
+			`:S sh:targetClass :Point ; sh:closed true ; sh:ignoredProperties ( rdf:type ) ;
+				sh:property [ sh:path :x ] , [ sh:path :y ] .`,
+			`:p a :Point ; :x 1 ; :y 2 . :q a :Point ; :x 1 ; :z 3 .`,
+			[]string{`q Closed "3"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		},
+		{
+			`:S sh:targetSubjectsOf :color ;
+				sh:property [ sh:path :color ; sh:in ( :red :green ) ] ;
+				sh:property [ sh:path :size ; sh:hasValue "L" ] .`,
+			`:a :color :red ; :size "L" . :b :color :blue ; :size "M" .`,
+			[]string{"b HasValue -", "b In blue"},
+		},
+		{
+			// Complex paths.
+			`:S sh:targetNode :a ;
+				sh:property [ sh:path ( :p [ sh:inversePath :q ] ) ; sh:minCount 2 ] ;
+				sh:property [ sh:path [ sh:oneOrMorePath :p ] ; sh:maxCount 2 ] ;
+				sh:property [ sh:path [ sh:alternativePath ( :p [ sh:zeroOrOnePath :q ] ) ] ; sh:class :C ] .`,
+			`:a :p :b . :b :p :c . :c :p :d . :x :q :b . :a a :C . :b a :C .`,
+			[]string{"a MaxCount -", "a MinCount -"},
+		},
+		{
+			// Deactivated shapes.
+			`:S sh:targetNode :a ; sh:class :C ; sh:deactivated true .`,
+			``,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		shapes, err := NewShapes(NewGraph(mustDecodeTTL(shaclTestPrefixes + test.shapes)...))
+		if err != nil {
+			t.Errorf("NewShapes(%s) => %v", test.shapes, err)
+			continue
+		}
+		report := shapes.Validate(sliceSource(mustDecodeTTL(shaclTestPrefixes + test.data)))
+		if got := formatResults(report.Results); !equalStrings(got, test.want) {
+			t.Errorf("%s\n%s\n=>\n%v\nwant:\n%v", test.shapes, test.data, got, test.want)
+		}
+		if report.Conforms != (len(test.want) == 0) {
+			t.Errorf("%s\n=> Conforms %v", test.shapes, report.Conforms)
+		}
+	}
+}
+
+func TestValidationReport(t *testing.T) {
+	shapes, err := NewShapes(NewGraph(mustDecodeTTL(shaclTestPrefixes + `
+:S sh:targetNode :a ;
+	sh:property [ sh:path [ sh:inversePath :p ] ; sh:minCount 1 ; sh:severity sh:Warning ] ;
+	sh:property :Name .
+:Name sh:path :name ; sh:datatype xsd:string ; sh:message "Names are strings"@en .
+`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The labels of the report don't clash with those of the data.
+	report := shapes.Validate(NewGraph(mustDecodeTTL(shaclTestPrefixes + `:a :name 1 . _:vr0 :p :x .`)...))
+	want := mustDecodeTTL(shaclTestPrefixes + `
+_:report a sh:ValidationReport ; sh:conforms false ;
+	sh:result [
+		a sh:ValidationResult ;
+		sh:focusNode :a ;
+		sh:resultPath :name ;
+		sh:value 1 ;
+		sh:sourceShape :Name ;
+		sh:sourceConstraintComponent sh:DatatypeConstraintComponent ;
+		sh:resultSeverity sh:Violation ;
+		sh:resultMessage "Names are strings"@en
+	] , [
+		a sh:ValidationResult ;
+		sh:focusNode :a ;
+		sh:resultPath _:path ;
+		sh:sourceShape _:shape ;
+		sh:sourceConstraintComponent sh:MinCountConstraintComponent ;
+		sh:resultSeverity sh:Warning ;
+		sh:resultMessage "Less than 1 values"
+	] .
+_:path sh:inversePath :p .
+`)
+	got := report.Triples()
+	for _, tr := range got {
+		if tr.Subj == (Blank{id: "_:vr0"}) {
+			t.Errorf("Triples() uses the label _:vr0 of the data: %v", tr)
+		}
+	}
+	// The source shape is a blank node of the shapes graph, so it's
+	// compared separately.
+	var shape Term
+	for i, tr := range got {
+		if tr.Pred == shSourceShape {
+			if b, ok := tr.Obj.(Blank); ok {
+				shape = b
+				got[i].Obj = Blank{id: "_:shape"}
+			}
+		}
+	}
+	if shape == nil || shapes.g.Count(shape.(Blank), shPath, nil) != 1 {
+		t.Errorf("Triples() => source shape %v; want the property shape", shape)
+	}
+	if !Isomorphic(got, want) {
+		t.Errorf("Triples() =>\n%v\nwant:\n%v", sortedNT(got), sortedNT(want))
+	}
+}
+
+func TestNewShapesErrors(t *testing.T) {
+	tests := []struct {
+		shapes string
+		err    string
+	}{
+		{`:S sh:targetNode :a ; sh:property [ sh:path "p" ] .`, `ill-formed path "p"`},
+		{`:S sh:targetNode :a ; sh:path ( :p ) .`, "ill-formed sequence path"},
+		{`:S sh:targetNode :a ; sh:path [ sh:inversePath :p ; sh:zeroOrMorePath :q ] .`, "ill-formed path"},
+		{`:S sh:targetNode :a ; sh:pattern "(" .`, "missing closing )"},
+		{`:S sh:targetNode :a ; sh:path :p ; sh:minCount "x" .`, "<http://www.w3.org/ns/shacl#minCount> must be a non-negative integer"},
+		{`:S sh:targetNode :a ; sh:in :red .`, "sh:in must be a list"},
+		{`:S sh:targetNode :a ; sh:nodeKind sh:Thing .`, "invalid sh:nodeKind"},
+		{`:S sh:targetNode :a ; sh:property [ sh:class :C ] .`, "has no sh:path"},
+		{`:S sh:targetNode :a ; sh:node "S" .`, `shape "S" is not an IRI or blank node`},
+	}
+	for _, test := range tests {
+		_, err := NewShapes(NewGraph(mustDecodeTTL(shaclTestPrefixes + test.shapes)...))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("NewShapes(%s) => %v; want error containing %q", test.shapes, err, test.err)
+		}
+	}
+}
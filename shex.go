@@ -0,0 +1,987 @@
+package rdf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ShExSchema is a ShEx schema: shape expressions with labels, and an
+// optional start shape expression. Schemas are parsed from the compact
+// syntax by ParseShExC, and from the JSON syntax, ShExJ, by json.Unmarshal.
+// The types of the schema follow ShExJ; see http://shex.io/shex-semantics/.
+type ShExSchema struct {
+	Start  ShapeExpr // nil if there is no start shape expression
+	Shapes []*ShapeDecl
+
+	base IRI               // base IRI of the ShExC syntax
+	ns   map[string]string // prefixes of the ShExC syntax
+}
+
+// ShapeDecl is a shape expression with a label, an IRI or blank node.
+type ShapeDecl struct {
+	Label Term
+	Expr  ShapeExpr
+}
+
+// ShapeExpr is a shape expression: ShapeOr, ShapeAnd, ShapeNot, ShapeRef,
+// ShapeExternal, *NodeConstraint or *Shape.
+type ShapeExpr interface {
+	shapeExpr()
+}
+
+// ShapeOr is satisfied by a node which satisfies any of the expressions.
+type ShapeOr struct {
+	Exprs []ShapeExpr
+}
+
+// ShapeAnd is satisfied by a node which satisfies all of the expressions.
+type ShapeAnd struct {
+	Exprs []ShapeExpr
+}
+
+// ShapeNot is satisfied by a node which doesn't satisfy the expression.
+type ShapeNot struct {
+	Expr ShapeExpr
+}
+
+// ShapeRef refers to the shape expression with the given label.
+type ShapeRef struct {
+	Label Term
+}
+
+// ShapeExternal is a shape expression defined outside of the schema. As
+// external schemas are not loaded, no node satisfies it.
+type ShapeExternal struct{}
+
+// NodeConstraint constrains the kind, datatype or value of a node, and
+// its string or numeric facets. The zero value is satisfied by any node.
+type NodeConstraint struct {
+	NodeKind string // "iri", "bnode", "nonliteral", "literal", or "" for any
+	Datatype IRI    // the zero IRI for any
+	// Values is the value set, or nil for any value; an empty value set
+	// is satisfied by no node.
+	Values []ValueSetValue
+
+	// String facets, for IRIs and literals; nil or "" if absent.
+	Length, MinLength, MaxLength *int
+	Pattern, Flags               string
+
+	// Numeric facets, for numeric literals; nil if absent.
+	MinInclusive, MinExclusive, MaxInclusive, MaxExclusive *Literal
+	TotalDigits, FractionDigits                            *int
+}
+
+// ValueSetValue is a value of a value set: an IRI or literal, a language
+// tag, or a stem or stem range of IRIs, literals or language tags.
+type ValueSetValue struct {
+	// Type is "" for an IRI or literal, or the ShExJ type: "Language",
+	// "IriStem", "IriStemRange", "LiteralStem", "LiteralStemRange",
+	// "LanguageStem" or "LanguageStemRange".
+	Type string
+
+	Value Term   // the IRI or literal
+	Stem  string // the stem, or the language tag of "Language"
+
+	// Wildcard is true for a stem range of any value, rather than Stem.
+	Wildcard bool
+	// Exclusions are the values excluded from a stem range: IRIs, literals
+	// or languages, or stems of the same kind.
+	Exclusions []ValueSetValue
+}
+
+// Shape is a shape: it is satisfied by a node whose triples match the
+// triple expression. Triples with predicates which don't occur in the
+// expression are allowed unless the shape is closed. Triples with the
+// extra predicates may also be left unmatched.
+type Shape struct {
+	Closed bool
+	Extra  []IRI
+	Expr   TripleExpr // nil for the empty expression
+}
+
+// TripleExpr is a triple expression: *EachOf, *OneOf, *TripleConstraint
+// or Inclusion. Min and Max are the cardinality of an expression, where
+// Max is -1 for unbounded; they are 1 and 1 for a single match. An
+// expression may have a label, to be included elsewhere.
+type TripleExpr interface {
+	tripleExpr()
+}
+
+// EachOf matches triples which are split between all of its expressions.
+type EachOf struct {
+	Label    Term
+	Exprs    []TripleExpr
+	Min, Max int
+}
+
+// OneOf matches triples which match one of its expressions.
+type OneOf struct {
+	Label    Term
+	Exprs    []TripleExpr
+	Min, Max int
+}
+
+// TripleConstraint matches a triple with the predicate whose object, or
+// subject if inverse, satisfies the value expression. A nil value
+// expression is satisfied by any node.
+type TripleConstraint struct {
+	Label     Term
+	Inverse   bool
+	Predicate IRI
+	ValueExpr ShapeExpr
+	Min, Max  int
+}
+
+// Inclusion includes the triple expression with the given label.
+type Inclusion struct {
+	Label Term
+}
+
+func (ShapeOr) shapeExpr()         {}
+func (ShapeAnd) shapeExpr()        {}
+func (ShapeNot) shapeExpr()        {}
+func (ShapeRef) shapeExpr()        {}
+func (ShapeExternal) shapeExpr()   {}
+func (*NodeConstraint) shapeExpr() {}
+func (*Shape) shapeExpr()          {}
+
+func (*EachOf) tripleExpr()           {}
+func (*OneOf) tripleExpr()            {}
+func (*TripleConstraint) tripleExpr() {}
+func (Inclusion) tripleExpr()         {}
+
+// ShapeAssociation associates a node with the label of a shape expression;
+// a nil label is the start shape expression.
+type ShapeAssociation struct {
+	Node  Term
+	Shape Term
+}
+
+// ShapeMap is a list of nodes and the shapes to validate them against.
+type ShapeMap []ShapeAssociation
+
+// ShapeResult is the result of validating a node against a shape.
+type ShapeResult struct {
+	Node       Term
+	Shape      Term // nil for the start shape expression
+	Conformant bool
+	Reason     string // why the node doesn't conform
+}
+
+// String returns the result as in a result shape map, with the reason of
+// a nonconformant node, as in `<n>@<S> nonconformant: reason`.
+func (r ShapeResult) String() string {
+	shape := "START"
+	if r.Shape != nil {
+		shape = r.Shape.Serialize(NTriples)
+	}
+	s := r.Node.Serialize(NTriples) + "@" + shape
+	if r.Conformant {
+		return s + " conformant"
+	}
+	return s + " nonconformant: " + r.Reason
+}
+
+// Validate validates the nodes of the shape map against their shapes, in
+// the given data. It returns an error if the schema refers to undefined
+// labels, has invalid regular expressions or a shape which depends on
+// itself through a negation, or if the shape map refers to undefined
+// shapes.
+//
+// Recursive shapes are validated by assuming that a node conforms to a
+// shape while it is being validated against it.
+func (s *ShExSchema) Validate(data TripleSource, m ShapeMap) ([]ShapeResult, error) {
+	v, err := newShExValidator(s, data)
+	if err != nil {
+		return nil, err
+	}
+	var results []ShapeResult
+	for _, a := range m {
+		var ok bool
+		var reason string
+		switch {
+		case a.Shape != nil:
+			if _, found := v.shapes[termKey(a.Shape)]; !found {
+				return nil, fmt.Errorf("undefined shape %s", a.Shape.Serialize(NTriples))
+			}
+			ok, reason = v.ref(a.Node, a.Shape)
+		case s.Start != nil:
+			ok, reason = v.satisfies(a.Node, s.Start)
+		default:
+			return nil, fmt.Errorf("schema has no start shape expression")
+		}
+		results = append(results, ShapeResult{Node: a.Node, Shape: a.Shape, Conformant: ok, Reason: reason})
+	}
+	return results, nil
+}
+
+// shexOutcome is the outcome of validating a node against a shape.
+type shexOutcome struct {
+	ok     bool
+	reason string
+}
+
+// shexValidator validates nodes of a data graph against a schema.
+type shexValidator struct {
+	data    TripleSource
+	shapes  map[string]ShapeExpr  // shape expressions by label
+	triples map[string]TripleExpr // triple expressions by label
+	regexps map[string]*regexp.Regexp
+	tcs     map[TripleExpr][]*TripleConstraint
+
+	// Pairs of nodes and shape labels being validated, by depth, and the
+	// outcomes which don't depend on those assumed to conform.
+	active map[string]int
+	memo   map[string]shexOutcome
+	depth  int
+	lowest int // the lowest depth of the pairs assumed to conform
+}
+
+func newShExValidator(s *ShExSchema, data TripleSource) (*shexValidator, error) {
+	v := &shexValidator{
+		data:    data,
+		shapes:  make(map[string]ShapeExpr),
+		triples: make(map[string]TripleExpr),
+		regexps: make(map[string]*regexp.Regexp),
+		tcs:     make(map[TripleExpr][]*TripleConstraint),
+		active:  make(map[string]int),
+		memo:    make(map[string]shexOutcome),
+	}
+	for _, d := range s.Shapes {
+		v.shapes[termKey(d.Label)] = d.Expr
+	}
+	// Collect the labelled triple expressions, and check the references.
+	// The references between labelled expressions are kept as a graph,
+	// with the negated ones, to check that negation is stratified.
+	var refs, incls []Term
+	deps := make(map[string][]shexDep)
+	var from string
+	var neg bool
+	var walkTriples func(e TripleExpr) error
+	var walk func(e ShapeExpr) error
+	walk = func(e ShapeExpr) error {
+		switch e := e.(type) {
+		case ShapeOr:
+			for _, x := range e.Exprs {
+				if err := walk(x); err != nil {
+					return err
+				}
+			}
+		case ShapeAnd:
+			for _, x := range e.Exprs {
+				if err := walk(x); err != nil {
+					return err
+				}
+			}
+		case ShapeNot:
+			outer := neg
+			neg = true
+			err := walk(e.Expr)
+			neg = outer
+			return err
+		case ShapeRef:
+			refs = append(refs, e.Label)
+			deps[from] = append(deps[from], shexDep{"s" + termKey(e.Label), neg})
+		case *NodeConstraint:
+			if e.Pattern != "" {
+				re, err := regexArg([]Term{Literal{str: e.Pattern, DataType: xsdString}, Literal{str: e.Flags, DataType: xsdString}})
+				if err != nil {
+					return fmt.Errorf("invalid pattern /%s/%s: %v", e.Pattern, e.Flags, err)
+				}
+				v.regexps[e.Pattern+"/"+e.Flags] = re
+			}
+		case *Shape:
+			return walkTriples(e.Expr)
+		}
+		return nil
+	}
+	walkTriples = func(e TripleExpr) error {
+		var label Term
+		var exprs []TripleExpr
+		switch e := e.(type) {
+		case *EachOf:
+			label, exprs = e.Label, e.Exprs
+		case *OneOf:
+			label, exprs = e.Label, e.Exprs
+		case *TripleConstraint:
+			label = e.Label
+			if e.ValueExpr != nil {
+				if err := walk(e.ValueExpr); err != nil {
+					return err
+				}
+			}
+		case Inclusion:
+			incls = append(incls, e.Label)
+			deps[from] = append(deps[from], shexDep{"t" + termKey(e.Label), neg})
+		}
+		if label != nil {
+			v.triples[termKey(label)] = e
+			deps[from] = append(deps[from], shexDep{"t" + termKey(label), neg})
+			outerFrom, outerNeg := from, neg
+			from, neg = "t"+termKey(label), false
+			defer func() { from, neg = outerFrom, outerNeg }()
+		}
+		for _, x := range exprs {
+			if err := walkTriples(x); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s.Start != nil {
+		if err := walk(s.Start); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range s.Shapes {
+		from, neg = "s"+termKey(d.Label), false
+		if err := walk(d.Expr); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range refs {
+		if _, ok := v.shapes[termKey(l)]; !ok {
+			return nil, fmt.Errorf("undefined shape %s", l.Serialize(NTriples))
+		}
+	}
+	for _, l := range incls {
+		if _, ok := v.triples[termKey(l)]; !ok {
+			return nil, fmt.Errorf("undefined triple expression %s", l.Serialize(NTriples))
+		}
+	}
+	for _, d := range s.Shapes {
+		if l := "s" + termKey(d.Label); negatedCycle(deps, l) {
+			return nil, fmt.Errorf("shape %s depends on itself through negation", d.Label.Serialize(NTriples))
+		}
+	}
+	return v, nil
+}
+
+// shexDep is a reference from a labelled shape or triple expression to
+// another, where the keys of the labels are prefixed with "s" or "t".
+type shexDep struct {
+	to  string
+	neg bool // the reference is inside a ShapeNot
+}
+
+// negatedCycle reports whether a cycle of references from the label back
+// to itself goes through a negated reference. Such schemas are not
+// stratified, and have no well-defined typing.
+func negatedCycle(deps map[string][]shexDep, label string) bool {
+	// Search the pairs of labels and whether a negation was passed on the
+	// way from the label.
+	type state struct {
+		label string
+		neg   bool
+	}
+	seen := make(map[state]bool)
+	stack := []state{{label, false}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, d := range deps[s.label] {
+			next := state{d.to, s.neg || d.neg}
+			if next.label == label && next.neg {
+				return true
+			}
+			if !seen[next] {
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return false
+}
+
+// satisfies reports whether the node satisfies the shape expression, or
+// the reason why not.
+func (v *shexValidator) satisfies(n Term, se ShapeExpr) (bool, string) {
+	switch se := se.(type) {
+	case ShapeOr:
+		var reasons []string
+		for _, e := range se.Exprs {
+			ok, reason := v.satisfies(n, e)
+			if ok {
+				return true, ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return false, "none of the alternatives is satisfied: " + strings.Join(reasons, "; ")
+	case ShapeAnd:
+		for _, e := range se.Exprs {
+			if ok, reason := v.satisfies(n, e); !ok {
+				return false, reason
+			}
+		}
+		return true, ""
+	case ShapeNot:
+		if ok, _ := v.satisfies(n, se.Expr); ok {
+			return false, n.Serialize(NTriples) + " satisfies a negated shape expression"
+		}
+		return true, ""
+	case ShapeRef:
+		if ok, reason := v.ref(n, se.Label); !ok {
+			return false, fmt.Sprintf("%s does not conform to %s: %s", n.Serialize(NTriples), se.Label.Serialize(NTriples), reason)
+		}
+		return true, ""
+	case ShapeExternal:
+		return false, "external shapes are not supported"
+	case *NodeConstraint:
+		return v.nodeConstraint(n, se)
+	case *Shape:
+		return v.shape(n, se)
+	}
+	panic(fmt.Sprintf("unknown shape expression: %T", se))
+}
+
+// ref reports whether the node conforms to the shape expression with the
+// given label. A node being validated against the label is assumed to
+// conform; the outcomes which depend on such assumptions, other than about
+// the node and label itself, are not memoized.
+func (v *shexValidator) ref(n, label Term) (bool, string) {
+	key := termKey(n) + "@" + termKey(label)
+	if o, ok := v.memo[key]; ok {
+		return o.ok, o.reason
+	}
+	if d, ok := v.active[key]; ok {
+		if d < v.lowest {
+			v.lowest = d
+		}
+		return true, ""
+	}
+	v.depth++
+	v.active[key] = v.depth
+	lowest := v.lowest
+	v.lowest = v.depth
+	ok, reason := v.satisfies(n, v.shapes[termKey(label)])
+	if v.lowest >= v.depth {
+		v.memo[key] = shexOutcome{ok: ok, reason: reason}
+	}
+	delete(v.active, key)
+	v.depth--
+	if lowest < v.lowest {
+		v.lowest = lowest
+	}
+	return ok, reason
+}
+
+// nodeConstraint reports whether the node satisfies the node constraint, or
+// the reason why not.
+func (v *shexValidator) nodeConstraint(n Term, nc *NodeConstraint) (bool, string) {
+	fail := func(format string, args ...interface{}) (bool, string) {
+		return false, n.Serialize(NTriples) + " " + fmt.Sprintf(format, args...)
+	}
+	switch nc.NodeKind {
+	case "iri":
+		if _, ok := n.(IRI); !ok {
+			return fail("is not an IRI")
+		}
+	case "bnode":
+		if _, ok := n.(Blank); !ok {
+			return fail("is not a blank node")
+		}
+	case "literal":
+		if _, ok := n.(Literal); !ok {
+			return fail("is not a literal")
+		}
+	case "nonliteral":
+		if _, ok := n.(Literal); ok {
+			return fail("is a literal")
+		}
+	}
+	if nc.Datatype != (IRI{}) {
+		l, ok := n.(Literal)
+		if !ok || l.DataType != nc.Datatype {
+			return fail("does not have datatype %s", nc.Datatype.Serialize(NTriples))
+		}
+		if err := validateLexical(l.str, l.DataType); err != nil {
+			return fail("is ill-typed: %v", err)
+		}
+	}
+	if nc.Values != nil && !matchValueSet(n, nc.Values) {
+		return fail("is not in the value set")
+	}
+
+	if nc.Length != nil || nc.MinLength != nil || nc.MaxLength != nil || nc.Pattern != "" {
+		s, ok := shString(n)
+		if !ok {
+			return fail("is not an IRI or literal")
+		}
+		length := utf8.RuneCountInString(s)
+		switch {
+		case nc.Length != nil && length != *nc.Length:
+			return fail("does not have length %d", *nc.Length)
+		case nc.MinLength != nil && length < *nc.MinLength:
+			return fail("is shorter than %d", *nc.MinLength)
+		case nc.MaxLength != nil && length > *nc.MaxLength:
+			return fail("is longer than %d", *nc.MaxLength)
+		}
+		if nc.Pattern != "" && !v.regexps[nc.Pattern+"/"+nc.Flags].MatchString(s) {
+			return fail("does not match /%s/%s", nc.Pattern, nc.Flags)
+		}
+	}
+
+	bounds := []struct {
+		bound *Literal
+		rel   string
+		ok    func(c int) bool
+	}{
+		{nc.MinInclusive, ">=", func(c int) bool { return c >= 0 }},
+		{nc.MinExclusive, ">", func(c int) bool { return c > 0 }},
+		{nc.MaxInclusive, "<=", func(c int) bool { return c <= 0 }},
+		{nc.MaxExclusive, "<", func(c int) bool { return c < 0 }},
+	}
+	for _, b := range bounds {
+		if b.bound == nil {
+			continue
+		}
+		if c, err := compareTerms(n, *b.bound); err != nil || !b.ok(c) {
+			return fail("is not %s %s", b.rel, b.bound.str)
+		}
+	}
+	if nc.TotalDigits != nil || nc.FractionDigits != nil {
+		total, fraction, ok := decimalDigits(n)
+		switch {
+		case !ok:
+			return fail("is not an integer or decimal")
+		case nc.TotalDigits != nil && total > *nc.TotalDigits:
+			return fail("has more than %d digits", *nc.TotalDigits)
+		case nc.FractionDigits != nil && fraction > *nc.FractionDigits:
+			return fail("has more than %d fraction digits", *nc.FractionDigits)
+		}
+	}
+	return true, ""
+}
+
+// decimalDigits returns the number of digits, and fraction digits, of an
+// integer or decimal literal.
+func decimalDigits(n Term) (total, fraction int, ok bool) {
+	l, isLit := n.(Literal)
+	if !isLit {
+		return 0, 0, false
+	}
+	kind, numeric := numericDataType(l.DataType)
+	if !numeric || (kind != numInteger && kind != numDecimal) {
+		return 0, 0, false
+	}
+	s, valid := canonicalLexical(l.str, l.DataType)
+	if !valid {
+		return 0, 0, false
+	}
+	s = strings.TrimPrefix(s, "-")
+	ints, fracs := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		ints, fracs = s[:i], strings.TrimRight(s[i+1:], "0")
+	}
+	ints = strings.TrimLeft(ints, "0")
+	total = len(ints) + len(fracs)
+	if total == 0 {
+		total = 1
+	}
+	return total, len(fracs), true
+}
+
+// matchValueSet reports whether the node is in the value set.
+func matchValueSet(n Term, values []ValueSetValue) bool {
+	for _, vs := range values {
+		if matchValue(n, vs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports whether the node matches the value set value.
+func matchValue(n Term, vs ValueSetValue) bool {
+	iri, isIRI := n.(IRI)
+	lit, isLit := n.(Literal)
+	excluded := func() bool {
+		for _, x := range vs.Exclusions {
+			// Literals are excluded by their lexical forms.
+			if l, ok := x.Value.(Literal); ok && x.Type == "" {
+				if isLit && lit.str == l.str {
+					return true
+				}
+				continue
+			}
+			if matchValue(n, x) {
+				return true
+			}
+		}
+		return false
+	}
+	switch vs.Type {
+	case "":
+		if l, ok := vs.Value.(Literal); ok && isLit {
+			return l.str == lit.str && l.DataType == lit.DataType && strings.EqualFold(l.lang, lit.lang) && l.dir == lit.dir
+		}
+		return sameTerm(n, vs.Value)
+	case "IriStem":
+		return isIRI && strings.HasPrefix(iri.str, vs.Stem)
+	case "IriStemRange":
+		return isIRI && (vs.Wildcard || strings.HasPrefix(iri.str, vs.Stem)) && !excluded()
+	case "LiteralStem":
+		return isLit && strings.HasPrefix(lit.str, vs.Stem)
+	case "LiteralStemRange":
+		return isLit && (vs.Wildcard || strings.HasPrefix(lit.str, vs.Stem)) && !excluded()
+	case "Language":
+		return isLit && lit.lang != "" && strings.EqualFold(lit.lang, vs.Stem)
+	case "LanguageStem":
+		return isLit && lit.lang != "" && (vs.Stem == "" || langMatches(lit.lang, vs.Stem))
+	case "LanguageStemRange":
+		return isLit && lit.lang != "" && (vs.Wildcard || vs.Stem == "" || langMatches(lit.lang, vs.Stem)) && !excluded()
+	}
+	return false
+}
+
+// shexArc is a triple of the neighbourhood of a node: outgoing, or
+// incoming if inverse.
+type shexArc struct {
+	t       Triple
+	inverse bool
+}
+
+// other returns the node at the other end of the arc.
+func (a shexArc) other() Term {
+	if a.inverse {
+		return a.t.Subj
+	}
+	return a.t.Obj
+}
+
+// shexGroup is a number of arcs which match the same triple constraints.
+// Optional arcs, with extra predicates, may also be left unmatched.
+type shexGroup struct {
+	tcs      []*TripleConstraint
+	n        int
+	optional bool
+}
+
+// shape reports whether the node satisfies the shape, or the reason why
+// not. The triples of the node are matched with the triple constraints
+// whose value expressions they satisfy; the shape is satisfied if the
+// numbers of triples matched with each constraint match the expression.
+func (v *shexValidator) shape(n Term, sh *Shape) (bool, string) {
+	tcs := v.constraints(sh.Expr)
+	fwd, inv := make(map[string]bool), make(map[string]IRI)
+	for _, tc := range tcs {
+		if tc.Inverse {
+			inv[termKey(tc.Predicate)] = tc.Predicate
+		} else {
+			fwd[termKey(tc.Predicate)] = true
+		}
+	}
+	extra := make(map[string]bool)
+	for _, p := range sh.Extra {
+		extra[termKey(p)] = true
+	}
+
+	var arcs []shexArc
+	if s, ok := n.(Subject); ok {
+		for _, t := range v.data.Match(s, nil, nil) {
+			arcs = append(arcs, shexArc{t: t})
+		}
+	}
+	if o, ok := n.(Object); ok {
+		for _, p := range inv {
+			for _, t := range v.data.Match(nil, p, o) {
+				arcs = append(arcs, shexArc{t: t, inverse: true})
+			}
+		}
+	}
+
+	// Sort the arcs, for reasons which don't depend on the data source.
+	sort.Slice(arcs, func(i, j int) bool {
+		if arcs[i].inverse != arcs[j].inverse {
+			return arcs[j].inverse
+		}
+		return arcs[i].t.Serialize(NTriples) < arcs[j].t.Serialize(NTriples)
+	})
+
+	var groups []*shexGroup
+	byKey := make(map[string]*shexGroup)
+	for _, a := range arcs {
+		pred := termKey(a.t.Pred)
+		if !a.inverse && !fwd[pred] {
+			if sh.Closed {
+				return false, "closed shape does not allow " + strings.TrimSuffix(a.t.Serialize(NTriples), " .\n")
+			}
+			continue
+		}
+		var cands []*TripleConstraint
+		var reason string
+		key := strconv.FormatBool(!a.inverse && extra[pred])
+		for _, tc := range tcs {
+			if tc.Inverse != a.inverse || termKey(tc.Predicate) != pred {
+				continue
+			}
+			if tc.ValueExpr != nil {
+				if ok, r := v.satisfies(a.other(), tc.ValueExpr); !ok {
+					if reason == "" {
+						reason = r
+					}
+					continue
+				}
+			}
+			cands = append(cands, tc)
+			key += fmt.Sprintf(" %p", tc)
+		}
+		if len(cands) == 0 {
+			if !a.inverse && extra[pred] {
+				continue
+			}
+			return false, fmt.Sprintf("%s does not match a triple constraint: %s", strings.TrimSuffix(a.t.Serialize(NTriples), " .\n"), reason)
+		}
+		if g, ok := byKey[key]; ok {
+			g.n++
+			continue
+		}
+		g := &shexGroup{tcs: cands, n: 1, optional: !a.inverse && extra[pred]}
+		byKey[key] = g
+		groups = append(groups, g)
+	}
+
+	// Try the ways to distribute the arcs of each group between its
+	// triple constraints.
+	counts := make(map[*TripleConstraint]int)
+	var try func(i int) bool
+	try = func(i int) bool {
+		if i == len(groups) {
+			return v.matches(sh.Expr, counts)
+		}
+		g := groups[i]
+		var dist func(j, left int) bool
+		dist = func(j, left int) bool {
+			if j == len(g.tcs) {
+				return (left == 0 || g.optional) && try(i+1)
+			}
+			for k := left; k >= 0; k-- {
+				counts[g.tcs[j]] += k
+				ok := dist(j+1, left-k)
+				counts[g.tcs[j]] -= k
+				if ok {
+					return true
+				}
+			}
+			return false
+		}
+		return dist(0, g.n)
+	}
+	if try(0) {
+		return true, ""
+	}
+	return false, v.explain(sh.Expr, groups)
+}
+
+// explain returns the reason why the triples don't match the expression:
+// the triple constraints which must be matched, and whose numbers of
+// candidate triples are out of their cardinality.
+func (v *shexValidator) explain(e TripleExpr, groups []*shexGroup) string {
+	counts := make(map[*TripleConstraint]int)
+	for _, g := range groups {
+		for _, tc := range g.tcs {
+			counts[tc] += g.n
+		}
+	}
+	var reasons []string
+	var required func(e TripleExpr)
+	required = func(e TripleExpr) {
+		switch e := v.resolve(e).(type) {
+		case *EachOf:
+			if e.Min == 1 && e.Max == 1 {
+				for _, x := range e.Exprs {
+					required(x)
+				}
+			}
+		case *TripleConstraint:
+			if n := counts[e]; n < e.Min || (e.Max >= 0 && n > e.Max) {
+				pred := e.Predicate.Serialize(NTriples)
+				if e.Inverse {
+					pred = "^" + pred
+				}
+				reasons = append(reasons, fmt.Sprintf("expected %s triples with %s, found %d", cardinalityString(e.Min, e.Max), pred, n))
+			}
+		}
+	}
+	required(e)
+	if len(reasons) == 0 {
+		return "the triples do not match the triple expression"
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// cardinalityString describes a cardinality, for reasons.
+func cardinalityString(min, max int) string {
+	switch {
+	case min == max:
+		return strconv.Itoa(min)
+	case max < 0:
+		return "at least " + strconv.Itoa(min)
+	case min == 0:
+		return "at most " + strconv.Itoa(max)
+	}
+	return fmt.Sprintf("%d to %d", min, max)
+}
+
+// resolve returns the triple expression included by an inclusion, or the
+// expression itself.
+func (v *shexValidator) resolve(e TripleExpr) TripleExpr {
+	if inc, ok := e.(Inclusion); ok {
+		return v.triples[termKey(inc.Label)]
+	}
+	return e
+}
+
+// cardinality returns the cardinality of a triple expression.
+func (v *shexValidator) cardinality(e TripleExpr) (min, max int) {
+	switch e := v.resolve(e).(type) {
+	case *EachOf:
+		return e.Min, e.Max
+	case *OneOf:
+		return e.Min, e.Max
+	case *TripleConstraint:
+		return e.Min, e.Max
+	}
+	return 1, 1
+}
+
+// constraints returns the triple constraints of a triple expression.
+func (v *shexValidator) constraints(e TripleExpr) []*TripleConstraint {
+	if e == nil {
+		return nil
+	}
+	if tcs, ok := v.tcs[e]; ok {
+		return tcs
+	}
+	v.tcs[e] = nil // guards against cyclic inclusions
+	var tcs []*TripleConstraint
+	switch x := v.resolve(e).(type) {
+	case *EachOf:
+		for _, sub := range x.Exprs {
+			tcs = append(tcs, v.constraints(sub)...)
+		}
+	case *OneOf:
+		for _, sub := range x.Exprs {
+			tcs = append(tcs, v.constraints(sub)...)
+		}
+	case *TripleConstraint:
+		tcs = []*TripleConstraint{x}
+	}
+	v.tcs[e] = tcs
+	return tcs
+}
+
+// matches reports whether the numbers of triples matched with the triple
+// constraints match the triple expression.
+func (v *shexValidator) matches(e TripleExpr, counts map[*TripleConstraint]int) bool {
+	if e == nil {
+		return true
+	}
+	min, max := v.cardinality(e)
+	return v.repeat(e, counts, min, max, make(map[string]bool))
+}
+
+// repeat reports whether the counts of the triple constraints of e can be
+// split into between min and max matches of e.
+func (v *shexValidator) repeat(e TripleExpr, counts map[*TripleConstraint]int, min, max int, memo map[string]bool) bool {
+	e = v.resolve(e)
+	if tc, ok := e.(*TripleConstraint); ok {
+		n := counts[tc]
+		return n >= min && (max < 0 || n <= max)
+	}
+	tcs := v.constraints(e)
+	var key strings.Builder
+	fmt.Fprintf(&key, "%p %d %d", e, min, max)
+	empty := true
+	for _, tc := range tcs {
+		fmt.Fprintf(&key, " %d", counts[tc])
+		if counts[tc] > 0 {
+			empty = false
+		}
+	}
+	if empty {
+		return min == 0 || v.once(e, counts, memo)
+	}
+	if max == 0 {
+		return false
+	}
+	if ok, found := memo[key.String()]; found {
+		return ok
+	}
+	memo[key.String()] = false
+
+	// Try each non-empty part of the counts as one match of e, and the
+	// rest as the other matches.
+	part := make(map[*TripleConstraint]int)
+	var split func(i int, nonEmpty bool) bool
+	split = func(i int, nonEmpty bool) bool {
+		if i == len(tcs) {
+			if !nonEmpty || !v.once(e, part, memo) {
+				return false
+			}
+			rest := make(map[*TripleConstraint]int)
+			for _, tc := range tcs {
+				rest[tc] = counts[tc] - part[tc]
+			}
+			restMin, restMax := min-1, max-1
+			if restMin < 0 {
+				restMin = 0
+			}
+			if max < 0 {
+				restMax = -1
+			}
+			return v.repeat(e, rest, restMin, restMax, memo)
+		}
+		for k := counts[tcs[i]]; k >= 0; k-- {
+			part[tcs[i]] = k
+			if split(i+1, nonEmpty || k > 0) {
+				return true
+			}
+		}
+		return false
+	}
+	ok := split(0, false)
+	memo[key.String()] = ok
+	return ok
+}
+
+// once reports whether the counts of the triple constraints of e match a
+// single match of e.
+func (v *shexValidator) once(e TripleExpr, counts map[*TripleConstraint]int, memo map[string]bool) bool {
+	switch e := v.resolve(e).(type) {
+	case *EachOf:
+		for _, sub := range e.Exprs {
+			min, max := v.cardinality(sub)
+			if !v.repeat(sub, counts, min, max, memo) {
+				return false
+			}
+		}
+		return true
+	case *OneOf:
+	subs:
+		for _, sub := range e.Exprs {
+			// The other expressions match no triples.
+			own := make(map[*TripleConstraint]bool)
+			for _, tc := range v.constraints(sub) {
+				own[tc] = true
+			}
+			for _, tc := range v.constraints(e) {
+				if !own[tc] && counts[tc] > 0 {
+					continue subs
+				}
+			}
+			min, max := v.cardinality(sub)
+			if v.repeat(sub, counts, min, max, memo) {
+				return true
+			}
+		}
+		return false
+	case *TripleConstraint:
+		return counts[e] == 1
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShExValidate(t *testing.T) {
+	data := mustDecodeTTL(`
+@prefix ex: <http://example/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+ex:ann a ex:Person ; ex:name "Ann" ; ex:age 34 ; ex:knows ex:bob, ex:eve .
+ex:bob a ex:Person ; ex:name "Bob" ; ex:knows ex:ann .
+ex:eve ex:name "Eve", "Evelyn" .
+ex:joe ex:name "joe" ; ex:age -1 ; ex:born "1990-01-01"^^xsd:date .
+ex:kim ex:name "Kim" ; ex:age "x" .
+ex:doc ex:title "Titel"@de, "Title"@en-GB ; ex:code "AB12" ; ex:price 12.50 .
+ex:ann ex:child ex:kid .
+ex:cy a ex:Person ; ex:name "Cy" ; ex:knows ex:dee .
+ex:dee a ex:Person ; ex:name "Dee" ; ex:knows ex:cy .
+`)
+	tests := []struct {
+		schema string
+		shapes string
+		want   []string
+	}{
+		{
+			// Recursion, cardinalities and node constraints.
+			`PREFIX ex: <http://example/>
+			PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+			ex:Person {
+				ex:name xsd:string ;
+				ex:age xsd:integer MININCLUSIVE 0 ? ;
+				ex:knows @ex:Person *
+			}`,
+			`ex:ann@ex:Person, ex:bob@ex:Person, ex:eve@ex:Person, ex:joe@ex:Person, ex:kim@ex:Person, ex:doc@ex:Person`,
+			[]string{
+				"<http://example/ann>@<http://example/Person> nonconformant: <http://example/ann> <http://example/knows> <http://example/eve> does not match a triple constraint: <http://example/eve> does not conform to <http://example/Person>: expected 1 triples with <http://example/name>, found 2",
+				"<http://example/bob>@<http://example/Person> nonconformant: <http://example/bob> <http://example/knows> <http://example/ann> does not match a triple constraint: <http://example/ann> does not conform to <http://example/Person>: <http://example/ann> <http://example/knows> <http://example/eve> does not match a triple constraint: <http://example/eve> does not conform to <http://example/Person>: expected 1 triples with <http://example/name>, found 2",
+				"<http://example/eve>@<http://example/Person> nonconformant: expected 1 triples with <http://example/name>, found 2",
+				"<http://example/joe>@<http://example/Person> nonconformant: <http://example/joe> <http://example/age> \"-1\"^^<http://www.w3.org/2001/XMLSchema#integer> does not match a triple constraint: \"-1\"^^<http://www.w3.org/2001/XMLSchema#integer> is not >= 0",
+				"<http://example/kim>@<http://example/Person> nonconformant: <http://example/kim> <http://example/age> \"x\" does not match a triple constraint: \"x\" does not have datatype <http://www.w3.org/2001/XMLSchema#integer>",
+				"<http://example/doc>@<http://example/Person> nonconformant: expected 1 triples with <http://example/name>, found 0",
+			},
+		},
+		{
+			// Recursion without failures, and the start shape.
+			`PREFIX ex: <http://example/>
+			start = @ex:Person
+			ex:Person { a [ex:Person] ; ex:name . ; ex:age . ? ; ex:knows @ex:Person + }`,
+			`ex:cy@START, ex:eve@<http://example/Person>`,
+			[]string{
+				"<http://example/cy>@START conformant",
+				"<http://example/eve>@<http://example/Person> nonconformant: expected 1 triples with <http://www.w3.org/1999/02/22-rdf-syntax-ns#type>, found 0; expected 1 triples with <http://example/name>, found 2; expected at least 1 triples with <http://example/knows>, found 0",
+			},
+		},
+		{
+			// CLOSED, EXTRA and inverse triple constraints.
+			`PREFIX ex: <http://example/>
+			ex:Named CLOSED { ex:name LITERAL + }
+			ex:Friend EXTRA ex:knows { ex:knows [ex:bob] ; ex:name . }
+			ex:Kid { ^ex:child { a [ex:Person] } }`,
+			`ex:eve@ex:Named, ex:bob@ex:Named, ex:ann@ex:Friend, ex:bob@ex:Friend, ex:kid@ex:Kid, ex:bob@ex:Kid`,
+			[]string{
+				"<http://example/eve>@<http://example/Named> conformant",
+				"<http://example/bob>@<http://example/Named> nonconformant: closed shape does not allow <http://example/bob> <http://example/knows> <http://example/ann>",
+				"<http://example/ann>@<http://example/Friend> conformant",
+				"<http://example/bob>@<http://example/Friend> nonconformant: expected 1 triples with <http://example/knows>, found 0",
+				"<http://example/kid>@<http://example/Kid> conformant",
+				"<http://example/bob>@<http://example/Kid> nonconformant: expected 1 triples with ^<http://example/child>, found 0",
+			},
+		},
+		{
+			// OneOf, EachOf with cardinality, and inclusions.
+			`PREFIX ex: <http://example/>
+			ex:Who { ( ex:name . ; ex:age . | ex:name . ; ex:born . ) }
+			ex:Names { $ex:names ( ex:name . ){2} }
+			ex:More { &ex:names ; ex:knows . }`,
+			`ex:ann@ex:Who, ex:joe@ex:Who, ex:bob@ex:Who, ex:eve@ex:Names, ex:ann@ex:Names, ex:eve@ex:More`,
+			[]string{
+				"<http://example/ann>@<http://example/Who> conformant",
+				"<http://example/joe>@<http://example/Who> nonconformant: the triples do not match the triple expression",
+				"<http://example/bob>@<http://example/Who> nonconformant: the triples do not match the triple expression",
+				"<http://example/eve>@<http://example/Names> conformant",
+				"<http://example/ann>@<http://example/Names> nonconformant: expected 2 triples with <http://example/name>, found 1",
+				"<http://example/eve>@<http://example/More> nonconformant: expected 1 triples with <http://example/knows>, found 0",
+			},
+		},
+		{
+			// Value sets, string and numeric facets, and shape operators.
+			`PREFIX ex: <http://example/>
+			ex:Doc {
+				ex:title [@en~ @de] + ;
+				ex:code /^[a-z]{2}\d+$/i LENGTH 4 ;
+				ex:price TOTALDIGITS 3 FRACTIONDIGITS 1 MAXEXCLUSIVE 100
+			}
+			ex:NotDoc NOT @ex:Doc
+			ex:Either @ex:Doc OR IRI AND [ex:~ - ex:ann]
+			ex:Lang { ex:title [. - @en~ - @fr] + }`,
+			`ex:doc@ex:Doc, ex:doc@ex:NotDoc, ex:ann@ex:NotDoc, ex:bob@ex:Either, ex:ann@ex:Either, 1@ex:Either, ex:doc@ex:Lang`,
+			[]string{
+				"<http://example/doc>@<http://example/Doc> conformant",
+				"<http://example/doc>@<http://example/NotDoc> nonconformant: <http://example/doc> satisfies a negated shape expression",
+				"<http://example/ann>@<http://example/NotDoc> conformant",
+				"<http://example/bob>@<http://example/Either> conformant",
+				"<http://example/ann>@<http://example/Either> nonconformant: none of the alternatives is satisfied: <http://example/ann> does not conform to <http://example/Doc>: expected at least 1 triples with <http://example/title>, found 0; expected 1 triples with <http://example/code>, found 0; expected 1 triples with <http://example/price>, found 0; <http://example/ann> is not in the value set",
+				"\"1\"^^<http://www.w3.org/2001/XMLSchema#integer>@<http://example/Either> nonconformant: none of the alternatives is satisfied: \"1\"^^<http://www.w3.org/2001/XMLSchema#integer> does not conform to <http://example/Doc>: expected at least 1 triples with <http://example/title>, found 0; expected 1 triples with <http://example/code>, found 0; expected 1 triples with <http://example/price>, found 0; \"1\"^^<http://www.w3.org/2001/XMLSchema#integer> is not an IRI",
+				"<http://example/doc>@<http://example/Lang> nonconformant: <http://example/doc> <http://example/title> \"Title\"@en-GB does not match a triple constraint: \"Title\"@en-GB is not in the value set",
+			},
+		},
+	}
+	for _, test := range tests {
+		schema, err := ParseShExC(test.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := schema.ParseShapeMap(test.shapes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The data can be any TripleSource.
+		for _, src := range []TripleSource{NewGraph(data...), sliceSource(data)} {
+			results, err := schema.Validate(src, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.String())
+			}
+			if !equalStrings(got, test.want) {
+				t.Errorf("%s\n=>\n%s\nwant:\n%s", test.schema, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		}
+	}
+}
+
+func TestShExValidateErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		shapes string
+		err    string
+	}{
+		{`<S> { <p> @<T> }`, `<n>@<S>`, "undefined shape <T>"},
+		{`<S> { &<t> }`, `<n>@<S>`, "undefined triple expression <t>"},
+		{`<S> { <p> /a(/ }`, `<n>@<S>`, "invalid pattern /a(/: error parsing regexp: missing closing ): `a(`"},
+		{`<S> { }`, `<n>@<T>`, "undefined shape <T>"},
+		{`<S> { }`, `<n>@START`, "schema has no start shape expression"},
+		{`<S> NOT { <p> @<S> }`, `<n>@<S>`, "shape <S> depends on itself through negation"},
+		{`<S> { <p> @<T> } <T> NOT { <q> @<S> }`, `<n>@<S>`, "shape <S> depends on itself through negation"},
+		{`<S> { $<t> <p> NOT @<S> } <T> { &<t> }`, `<n>@<T>`, "shape <S> depends on itself through negation"},
+	}
+	for _, test := range tests {
+		schema, err := ParseShExC(test.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := schema.ParseShapeMap(test.shapes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := schema.Validate(NewGraph(), m); err == nil || err.Error() != test.err {
+			t.Errorf("%s: Validate(%s) => error %v; want %q", test.schema, test.shapes, err, test.err)
+		}
+	}
+}
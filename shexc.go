@@ -0,0 +1,584 @@
+package rdf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseShExC parses a ShEx schema in the compact syntax, ShExC. Imports,
+// semantic actions and abstract shapes are not supported; annotations are
+// parsed and discarded.
+func ParseShExC(s string) (schema *ShExSchema, err error) {
+	p := newShExParser(s)
+	defer p.recover(&err)
+	return p.parseShExSchema(), nil
+}
+
+// ParseShapeMap parses a fixed shape map: comma-separated associations of
+// nodes and shape labels, as in `<n1>@<S>, ex:n2@START`, with the prefixes
+// and base IRI of the schema.
+func (s *ShExSchema) ParseShapeMap(str string) (m ShapeMap, err error) {
+	p := newShExParser(str)
+	defer p.recover(&err)
+	p.base = s.base
+	for k, v := range s.ns {
+		p.ns[k] = v
+	}
+	if p.accept(tokenEOF) {
+		return nil, nil
+	}
+	for {
+		t := p.next()
+		var node Term
+		switch t.typ {
+		case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+			node = p.parseIRI(t)
+		case tokenBNode:
+			node = Blank{id: t.text}
+		case tokenLiteral, tokenLiteral3, tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+			node = p.parseLiteral(t)
+		default:
+			p.unexpected(t, "node")
+		}
+		p.expectOp("@")
+		a := ShapeAssociation{Node: node}
+		if t := p.next(); !isKeyword(t, "START") {
+			a.Shape = p.parseShapeLabel(t)
+		}
+		m = append(m, a)
+		if !p.accept(tokenComma) {
+			p.expect(tokenEOF, "',' or end of input")
+			return m, nil
+		}
+	}
+}
+
+func newShExParser(s string) *sparqlParser {
+	return &sparqlParser{
+		l:      newShExLexer(strings.NewReader(s)),
+		ns:     make(map[string]string),
+		bnodes: make(map[string]Var),
+	}
+}
+
+// parseShExSchema parses a complete ShExC schema.
+func (p *sparqlParser) parseShExSchema() *ShExSchema {
+	schema := &ShExSchema{}
+	labels := make(map[string]bool)
+	for {
+		p.parsePrologue()
+		t := p.next()
+		switch {
+		case t.typ == tokenEOF:
+			schema.base, schema.ns = p.base, p.ns
+			return schema
+		case isKeyword(t, "START"):
+			if schema.Start != nil {
+				p.errorf(t, "duplicate start shape expression")
+			}
+			p.expectOp("=")
+			schema.Start = p.parseShapeExpr()
+		case isKeyword(t, "IMPORT"), isKeyword(t, "ABSTRACT"):
+			p.errorf(t, "%s not supported", strings.ToUpper(t.text))
+		default:
+			label := p.parseShapeLabel(t)
+			if labels[termKey(label)] {
+				p.errorf(t, "duplicate shape label %s", label.Serialize(NTriples))
+			}
+			labels[termKey(label)] = true
+			d := &ShapeDecl{Label: label}
+			if p.acceptKeyword("EXTERNAL") {
+				d.Expr = ShapeExternal{}
+			} else {
+				d.Expr = p.parseShapeExpr()
+			}
+			schema.Shapes = append(schema.Shapes, d)
+		}
+	}
+}
+
+// parseShapeLabel parses the label of a shape or triple expression: an IRI
+// or a blank node.
+func (p *sparqlParser) parseShapeLabel(t token) Term {
+	switch t.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return p.parseIRI(t)
+	case tokenBNode:
+		return Blank{id: t.text}
+	}
+	p.unexpected(t, "shape label")
+	return nil
+}
+
+// Shape expressions:
+
+// parseShapeExpr parses a shape expression: shapeOr.
+func (p *sparqlParser) parseShapeExpr() ShapeExpr {
+	e := p.parseShapeAnd()
+	if !isKeyword(p.peek(), "OR") {
+		return e
+	}
+	or := ShapeOr{Exprs: []ShapeExpr{e}}
+	for p.acceptKeyword("OR") {
+		or.Exprs = append(or.Exprs, p.parseShapeAnd())
+	}
+	return or
+}
+
+func (p *sparqlParser) parseShapeAnd() ShapeExpr {
+	e := p.parseShapeNot()
+	if !isKeyword(p.peek(), "AND") {
+		return e
+	}
+	and := ShapeAnd{}
+	for {
+		if x, ok := e.(ShapeAnd); ok {
+			and.Exprs = append(and.Exprs, x.Exprs...)
+		} else {
+			and.Exprs = append(and.Exprs, e)
+		}
+		if !p.acceptKeyword("AND") {
+			return and
+		}
+		e = p.parseShapeNot()
+	}
+}
+
+func (p *sparqlParser) parseShapeNot() ShapeExpr {
+	if p.acceptKeyword("NOT") {
+		return ShapeNot{Expr: p.parseShapeAtom()}
+	}
+	return p.parseShapeAtom()
+}
+
+// parseShapeAtom parses a parenthesized shape expression, a shape
+// reference, a shape, or a node constraint optionally followed by a shape
+// or shape reference.
+func (p *sparqlParser) parseShapeAtom() ShapeExpr {
+	t := p.peek()
+	switch {
+	case t.typ == tokenCollectionStart:
+		p.next()
+		e := p.parseShapeExpr()
+		p.expect(tokenCollectionEnd, "')'")
+		return e
+	case p.startsShapeOrRef():
+		return p.parseShapeOrRef()
+	}
+	nc := p.parseNodeConstraint()
+	if p.startsShapeOrRef() {
+		return ShapeAnd{Exprs: []ShapeExpr{nc, p.parseShapeOrRef()}}
+	}
+	return nc
+}
+
+// startsShapeOrRef checks if the next token starts a shape or a shape
+// reference. A '{' followed by an integer is a cardinality instead.
+func (p *sparqlParser) startsShapeOrRef() bool {
+	t := p.next()
+	defer p.backup(t)
+	switch {
+	case isOp(t, "@"), isKeyword(t, "CLOSED"), isKeyword(t, "EXTRA"):
+		return true
+	case t.typ == tokenGroupStart:
+		return p.peek().typ != tokenLiteralInteger
+	}
+	return false
+}
+
+func (p *sparqlParser) parseShapeOrRef() ShapeExpr {
+	if p.acceptOp("@") {
+		return ShapeRef{Label: p.parseShapeLabel(p.next())}
+	}
+	return p.parseShape()
+}
+
+// parseNodeConstraint parses a node kind, datatype or value set, and its
+// facets, or just facets.
+func (p *sparqlParser) parseNodeConstraint() *NodeConstraint {
+	nc := &NodeConstraint{}
+	t := p.next()
+	switch {
+	case isKeyword(t, "IRI"):
+		nc.NodeKind = "iri"
+	case isKeyword(t, "BNODE"):
+		nc.NodeKind = "bnode"
+	case isKeyword(t, "NONLITERAL"):
+		nc.NodeKind = "nonliteral"
+	case isKeyword(t, "LITERAL"):
+		nc.NodeKind = "literal"
+	case t.typ == tokenIRIAbs, t.typ == tokenIRIRel, t.typ == tokenPrefixLabel:
+		nc.Datatype = p.parseIRI(t)
+	case t.typ == tokenPropertyListStart:
+		nc.Values = []ValueSetValue{}
+		for !p.accept(tokenPropertyListEnd) {
+			nc.Values = append(nc.Values, p.parseValueSetValue())
+		}
+	case t.typ == tokenAnonBNode:
+		nc.Values = []ValueSetValue{}
+	default:
+		p.backup(t)
+		if !p.parseFacets(nc) {
+			p.unexpected(t, "shape expression")
+		}
+		return nc
+	}
+	p.parseFacets(nc)
+	return nc
+}
+
+// parseFacets parses string and numeric facets, and reports whether there
+// were any.
+func (p *sparqlParser) parseFacets(nc *NodeConstraint) bool {
+	found := false
+	for ; ; found = true {
+		t := p.next()
+		switch {
+		case t.typ == tokenRegexp:
+			nc.Pattern, nc.Flags = shexRegexp(t.text)
+		case isKeyword(t, "LENGTH"):
+			nc.Length = p.parseFacetInteger()
+		case isKeyword(t, "MINLENGTH"):
+			nc.MinLength = p.parseFacetInteger()
+		case isKeyword(t, "MAXLENGTH"):
+			nc.MaxLength = p.parseFacetInteger()
+		case isKeyword(t, "TOTALDIGITS"):
+			nc.TotalDigits = p.parseFacetInteger()
+		case isKeyword(t, "FRACTIONDIGITS"):
+			nc.FractionDigits = p.parseFacetInteger()
+		case isKeyword(t, "MININCLUSIVE"):
+			nc.MinInclusive = p.parseFacetNumber()
+		case isKeyword(t, "MINEXCLUSIVE"):
+			nc.MinExclusive = p.parseFacetNumber()
+		case isKeyword(t, "MAXINCLUSIVE"):
+			nc.MaxInclusive = p.parseFacetNumber()
+		case isKeyword(t, "MAXEXCLUSIVE"):
+			nc.MaxExclusive = p.parseFacetNumber()
+		default:
+			p.backup(t)
+			return found
+		}
+	}
+}
+
+func (p *sparqlParser) parseFacetInteger() *int {
+	n := p.parseInteger()
+	return &n
+}
+
+func (p *sparqlParser) parseFacetNumber() *Literal {
+	t := p.next()
+	switch t.typ {
+	case tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteral, tokenLiteral3:
+		l := p.parseLiteral(t)
+		if _, ok := literalNumber(l); ok {
+			return &l
+		}
+	}
+	p.unexpected(t, "numeric literal")
+	return nil
+}
+
+// shexRegexp returns the pattern and flags of a ShExC regular expression,
+// given as /pattern/flags. The pattern is unescaped: '\/' is '/', and
+// '\u' and '\U' escapes are replaced by the characters, quoted.
+func shexRegexp(s string) (pattern, flags string) {
+	i := strings.LastIndexByte(s, '/')
+	s, flags = s[1:i], s[i+1:]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch n := 4; s[i+1] {
+		case '/':
+			b.WriteByte('/')
+			i++
+		case 'U':
+			n = 8
+			fallthrough
+		case 'u':
+			if i+2+n <= len(s) {
+				if r, err := strconv.ParseUint(s[i+2:i+2+n], 16, 32); err == nil {
+					b.WriteString(regexp.QuoteMeta(string(rune(r))))
+					i += 1 + n
+					continue
+				}
+			}
+			fallthrough
+		default:
+			b.WriteString(s[i : i+2])
+			i++
+		}
+	}
+	return b.String(), flags
+}
+
+// parseValueSetValue parses a value of a value set.
+func (p *sparqlParser) parseValueSetValue() ValueSetValue {
+	t := p.next()
+	if t.typ == tokenDot {
+		// A stem range of any value, given by the kind of the exclusions.
+		v := ValueSetValue{Wildcard: true, Exclusions: p.parseExclusions()}
+		if len(v.Exclusions) == 0 {
+			p.unexpected(p.peek(), "'-'")
+		}
+		v.Type = shexValueKind(v.Exclusions[0]) + "StemRange"
+		for _, x := range v.Exclusions[1:] {
+			if shexValueKind(x) != shexValueKind(v.Exclusions[0]) {
+				p.errorf(t, "exclusions of different kinds")
+			}
+		}
+		return v
+	}
+	v := p.parseValue(t)
+	if !strings.HasSuffix(v.Type, "Stem") {
+		return v
+	}
+	if v.Exclusions = p.parseExclusions(); v.Exclusions != nil {
+		v.Type += "Range"
+		for _, x := range v.Exclusions {
+			if shexValueKind(x) != shexValueKind(v) {
+				p.errorf(t, "exclusions of a different kind than %s", v.Type)
+			}
+		}
+	}
+	return v
+}
+
+// parseValue parses an IRI, literal or language tag, or a stem of those.
+func (p *sparqlParser) parseValue(t token) ValueSetValue {
+	switch t.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		iri := p.parseIRI(t)
+		if p.accept(tokenTilde) {
+			return ValueSetValue{Type: "IriStem", Stem: iri.str}
+		}
+		return ValueSetValue{Value: iri}
+	case tokenLiteral, tokenLiteral3, tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+		l := p.parseLiteral(t)
+		if p.accept(tokenTilde) {
+			return ValueSetValue{Type: "LiteralStem", Stem: l.str}
+		}
+		return ValueSetValue{Value: l}
+	case tokenOperator:
+		if t.text != "@" {
+			break
+		}
+		if p.accept(tokenTilde) {
+			return ValueSetValue{Type: "LanguageStem"}
+		}
+		tag := p.expect(tokenKeyword, "language tag").text
+		if p.accept(tokenTilde) {
+			return ValueSetValue{Type: "LanguageStem", Stem: tag}
+		}
+		return ValueSetValue{Type: "Language", Stem: tag}
+	}
+	p.unexpected(t, "value")
+	return ValueSetValue{}
+}
+
+// parseExclusions parses the exclusions of a stem range, if any.
+func (p *sparqlParser) parseExclusions() []ValueSetValue {
+	var excl []ValueSetValue
+	for p.acceptOp("-") {
+		excl = append(excl, p.parseValue(p.next()))
+	}
+	return excl
+}
+
+// shexValueKind returns the kind of a value set value: "Iri", "Literal"
+// or "Language".
+func shexValueKind(v ValueSetValue) string {
+	switch {
+	case strings.HasPrefix(v.Type, "Iri"):
+		return "Iri"
+	case strings.HasPrefix(v.Type, "Literal"):
+		return "Literal"
+	case strings.HasPrefix(v.Type, "Language"):
+		return "Language"
+	}
+	if _, ok := v.Value.(IRI); ok {
+		return "Iri"
+	}
+	return "Literal"
+}
+
+// Shapes and triple expressions:
+
+// parseShape parses a shape, with its CLOSED and EXTRA qualifiers.
+func (p *sparqlParser) parseShape() *Shape {
+	sh := &Shape{}
+	for {
+		if p.acceptKeyword("CLOSED") {
+			sh.Closed = true
+			continue
+		}
+		if t := p.peek(); isKeyword(t, "EXTRA") {
+			p.next()
+			sh.Extra = append(sh.Extra, p.parsePredicate(p.next()))
+			for p.startsPredicate(p.peek()) {
+				sh.Extra = append(sh.Extra, p.parsePredicate(p.next()))
+			}
+			continue
+		}
+		break
+	}
+	p.expect(tokenGroupStart, "'{'")
+	if !p.accept(tokenGroupEnd) {
+		sh.Expr = p.parseTripleExpr()
+		p.expect(tokenGroupEnd, "'}'")
+	}
+	p.parseAnnotations()
+	return sh
+}
+
+func (p *sparqlParser) startsPredicate(t token) bool {
+	switch t.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel, tokenRDFType:
+		return true
+	}
+	return false
+}
+
+// parsePredicate parses a predicate: an IRI or 'a'.
+func (p *sparqlParser) parsePredicate(t token) IRI {
+	if t.typ == tokenRDFType {
+		return rdfType
+	}
+	if !p.startsPredicate(t) {
+		p.unexpected(t, "predicate")
+	}
+	return p.parseIRI(t)
+}
+
+// parseTripleExpr parses a triple expression: oneOf.
+func (p *sparqlParser) parseTripleExpr() TripleExpr {
+	e := p.parseEachOf()
+	if !isOp(p.peek(), "|") {
+		return e
+	}
+	one := &OneOf{Exprs: []TripleExpr{e}, Min: 1, Max: 1}
+	for p.acceptOp("|") {
+		one.Exprs = append(one.Exprs, p.parseEachOf())
+	}
+	return one
+}
+
+func (p *sparqlParser) parseEachOf() TripleExpr {
+	e := p.parseUnaryTripleExpr()
+	var each *EachOf
+	for p.accept(tokenSemicolon) {
+		if t := p.peek(); !p.startsPredicate(t) && !isOp(t, "^") && !isOp(t, "$") && !isOp(t, "&") && t.typ != tokenCollectionStart {
+			break // trailing ';'
+		}
+		if each == nil {
+			each = &EachOf{Exprs: []TripleExpr{e}, Min: 1, Max: 1}
+		}
+		each.Exprs = append(each.Exprs, p.parseUnaryTripleExpr())
+	}
+	if each == nil {
+		return e
+	}
+	return each
+}
+
+// parseUnaryTripleExpr parses an inclusion, or a triple constraint or
+// parenthesized triple expression with an optional label and cardinality.
+func (p *sparqlParser) parseUnaryTripleExpr() TripleExpr {
+	var label Term
+	if p.acceptOp("$") {
+		label = p.parseShapeLabel(p.next())
+	}
+	t := p.next()
+	switch {
+	case isOp(t, "&") && label == nil:
+		return Inclusion{Label: p.parseShapeLabel(p.next())}
+	case t.typ == tokenCollectionStart:
+		e := p.parseTripleExpr()
+		p.expect(tokenCollectionEnd, "')'")
+		min, max := p.parseCardinality()
+		p.parseAnnotations()
+		switch x := e.(type) {
+		case *EachOf:
+			x.Label, x.Min, x.Max = label, min, max
+			return x
+		case *OneOf:
+			x.Label, x.Min, x.Max = label, min, max
+			return x
+		case *TripleConstraint:
+			if x.Min == 1 && x.Max == 1 && (label == nil || x.Label == nil) {
+				if label != nil {
+					x.Label = label
+				}
+				x.Min, x.Max = min, max
+				return x
+			}
+		}
+		if label == nil && min == 1 && max == 1 {
+			return e
+		}
+		return &EachOf{Label: label, Exprs: []TripleExpr{e}, Min: min, Max: max}
+	}
+	tc := &TripleConstraint{Label: label}
+	if isOp(t, "^") {
+		tc.Inverse = true
+		t = p.next()
+	}
+	tc.Predicate = p.parsePredicate(t)
+	if !p.accept(tokenDot) {
+		tc.ValueExpr = p.parseShapeExpr()
+	}
+	tc.Min, tc.Max = p.parseCardinality()
+	p.parseAnnotations()
+	return tc
+}
+
+// parseCardinality parses '*', '+', '?', or a repeat range {m}, {m,},
+// {m,n} or {m,*}, and returns 1 and 1 if absent.
+func (p *sparqlParser) parseCardinality() (min, max int) {
+	t := p.next()
+	switch {
+	case isOp(t, "*"):
+		return 0, -1
+	case isOp(t, "+"):
+		return 1, -1
+	case isOp(t, "?"):
+		return 0, 1
+	case t.typ == tokenGroupStart:
+		min = p.parseInteger()
+		max = min
+		if p.accept(tokenComma) {
+			switch {
+			case p.acceptOp("*"):
+				max = -1
+			case p.peek().typ == tokenLiteralInteger:
+				max = p.parseInteger()
+			default:
+				max = -1
+			}
+		}
+		p.expect(tokenGroupEnd, "'}'")
+		if max >= 0 && max < min {
+			p.errorf(t, "invalid cardinality {%d,%d}", min, max)
+		}
+		return min, max
+	}
+	p.backup(t)
+	return 1, 1
+}
+
+// parseAnnotations parses annotations, // predicate object, and discards them.
+func (p *sparqlParser) parseAnnotations() {
+	for p.acceptOp("//") {
+		p.parsePredicate(p.next())
+		switch t := p.next(); t.typ {
+		case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+			p.parseIRI(t)
+		default:
+			p.parseLiteral(t)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package rdf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseShExC(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // ShExJ, without the context
+		err   string
+	}{
+		{
+			`PREFIX ex: <http://example/>
+			BASE <http://example/base/>
+			start = @<S>
+			<S> CLOSED EXTRA a ex:q {
+				$<t> ( ex:p [ex:a "x"@en 1 ex:~ - ex:b] {2,} | ^ex:q . ? ) ;
+				a IRI /\/x./i // ex:c "n" ;
+				&<t>
+			}
+			_:b EXTERNAL`,
+			`{"shapes":[{"id":"http://example/base/S","shapeExpr":{"closed":true,"expression":{"expressions":[{"expressions":[{"max":-1,"min":2,"predicate":"http://example/p","type":"TripleConstraint","valueExpr":{"type":"NodeConstraint","values":["http://example/a",{"language":"en","value":"x"},{"type":"http://www.w3.org/2001/XMLSchema#integer","value":"1"},{"exclusions":["http://example/b"],"stem":"http://example/","type":"IriStemRange"}]}},{"inverse":true,"max":1,"min":0,"predicate":"http://example/q","type":"TripleConstraint"}],"id":"http://example/base/t","type":"OneOf"},{"predicate":"http://www.w3.org/1999/02/22-rdf-syntax-ns#type","type":"TripleConstraint","valueExpr":{"flags":"i","nodeKind":"iri","pattern":"/x.","type":"NodeConstraint"}},"http://example/base/t"],"type":"EachOf"},"extra":["http://www.w3.org/1999/02/22-rdf-syntax-ns#type","http://example/q"],"type":"Shape"},"type":"ShapeDecl"},{"id":"_:b","shapeExpr":{"type":"ShapeExternal"},"type":"ShapeDecl"}],"start":"http://example/base/S","type":"Schema"}`,
+			"",
+		},
+		{
+			`PREFIX ex: <http://example/>
+			ex:N NOT (LITERAL MINLENGTH 2 AND ex:dt MAXINCLUSIVE 5.0) OR BNODE @ex:S
+			ex:S { (ex:p .)+ ; $ex:l (ex:p .) ; ex:q [] {0,3} }
+			ex:L [@en-US @fr~ @~ - @de . - "a"~ - "b" "c"~ - "cd"]`,
+			`{"shapes":[{"id":"http://example/N","shapeExpr":{"shapeExprs":[{"shapeExpr":{"shapeExprs":[{"minlength":2,"nodeKind":"literal","type":"NodeConstraint"},{"datatype":"http://example/dt","maxinclusive":5.0,"type":"NodeConstraint"}],"type":"ShapeAnd"},"type":"ShapeNot"},{"shapeExprs":[{"nodeKind":"bnode","type":"NodeConstraint"},"http://example/S"],"type":"ShapeAnd"}],"type":"ShapeOr"},"type":"ShapeDecl"},{"id":"http://example/S","shapeExpr":{"expression":{"expressions":[{"max":-1,"min":1,"predicate":"http://example/p","type":"TripleConstraint"},{"id":"http://example/l","predicate":"http://example/p","type":"TripleConstraint"},{"max":3,"min":0,"predicate":"http://example/q","type":"TripleConstraint","valueExpr":{"type":"NodeConstraint","values":[]}}],"type":"EachOf"},"type":"Shape"},"type":"ShapeDecl"},{"id":"http://example/L","shapeExpr":{"type":"NodeConstraint","values":[{"languageTag":"en-US","type":"Language"},{"stem":"fr","type":"LanguageStem"},{"exclusions":["de"],"stem":"","type":"LanguageStemRange"},{"exclusions":[{"stem":"a","type":"LiteralStem"},"b"],"stem":{"type":"Wildcard"},"type":"LiteralStemRange"},{"exclusions":["cd"],"stem":"c","type":"LiteralStemRange"}]},"type":"ShapeDecl"}],"type":"Schema"}`,
+			"",
+		},
+		{`<S> { <p> xsd:string }`, "", "1:10: missing namespace for prefix: 'xsd'"},
+		{`<S> { <p> . } <S> { }`, "", "1:15: duplicate shape label <S>"},
+		{`<S> { <p> . {3,2} }`, "", "1:12: invalid cardinality {3,2}"},
+		{`<S> { <p> [. - <a> - "b"] }`, "", "1:11: exclusions of different kinds"},
+		{`<S> { <p> LITERAL MININCLUSIVE "x" }`, "", "1:32: unexpected literal \"x\", expected numeric literal"},
+		{`<S> { <p> . ; &<t> // <a> "x" }`, "", "1:19: unexpected \"//\", expected '}'"},
+		{`<S> { <p> }`, "", "1:10: unexpected \"}\", expected shape expression"},
+		{`IMPORT <x>`, "", "1:0: IMPORT not supported"},
+	}
+	for _, test := range tests {
+		schema, err := ParseShExC(test.input)
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("ParseShExC(%q) => error %v; want %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("ParseShExC(%q) => no error; want %q", test.input, test.err)
+			continue
+		}
+		b, err := json.Marshal(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"@context":"http://www.w3.org/ns/shex.jsonld",` + test.want[1:]; string(b) != want {
+			t.Errorf("ParseShExC(%q) =>\n%s\nwant:\n%s", test.input, b, want)
+		}
+	}
+}
+
+func TestParseShapeMap(t *testing.T) {
+	schema, err := ParseShExC(`PREFIX ex: <http://example/> BASE <http://example/b/> <S> {}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  ShapeMap
+		err   string
+	}{
+		{
+			`ex:a@<S>, <c>@START, _:x@ex:T, 1@<S>`,
+			ShapeMap{
+				{IRI{str: "http://example/a"}, IRI{str: "http://example/b/S"}},
+				{IRI{str: "http://example/b/c"}, nil},
+				{Blank{id: "_:x"}, IRI{str: "http://example/T"}},
+				{Literal{str: "1", DataType: xsdInteger}, IRI{str: "http://example/b/S"}},
+			},
+			"",
+		},
+		{``, nil, ""},
+		{`ex:a <S>`, nil, "1:6: unexpected <S>, expected '@'"},
+		{`ex:a@<S> ex:b@<S>`, nil, "1:9: unexpected \"ex:\", expected ',' or end of input"},
+	}
+	for _, test := range tests {
+		m, err := schema.ParseShapeMap(test.input)
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("ParseShapeMap(%q) => error %v; want %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("ParseShapeMap(%q) => no error; want %q", test.input, test.err)
+			continue
+		}
+		if len(m) != len(test.want) {
+			t.Errorf("ParseShapeMap(%q) => %v; want %v", test.input, m, test.want)
+			continue
+		}
+		for i, a := range m {
+			w := test.want[i]
+			if !sameTerm(a.Node, w.Node) || (a.Shape == nil) != (w.Shape == nil) || (a.Shape != nil && !sameTerm(a.Shape, w.Shape)) {
+				t.Errorf("ParseShapeMap(%q) => %v; want %v", test.input, m, test.want)
+				break
+			}
+		}
+	}
+}
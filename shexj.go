@@ -0,0 +1,499 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// shexContext is the JSON-LD context of ShExJ.
+const shexContext = "http://www.w3.org/ns/shex.jsonld"
+
+// MarshalJSON returns the schema in the JSON syntax, ShExJ.
+func (s *ShExSchema) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{"@context": shexContext, "type": "Schema"}
+	if s.Start != nil {
+		m["start"] = shexjShapeExpr(s.Start)
+	}
+	if len(s.Shapes) > 0 {
+		var shapes []interface{}
+		for _, d := range s.Shapes {
+			shapes = append(shapes, map[string]interface{}{
+				"type":      "ShapeDecl",
+				"id":        shexjLabel(d.Label),
+				"shapeExpr": shexjShapeExpr(d.Expr),
+			})
+		}
+		m["shapes"] = shapes
+	}
+	return json.Marshal(m)
+}
+
+func shexjLabel(t Term) string {
+	switch t := t.(type) {
+	case IRI:
+		return t.str
+	case Blank:
+		return t.id
+	}
+	panic(fmt.Sprintf("invalid label: %v", t))
+}
+
+func shexjShapeExpr(se ShapeExpr) interface{} {
+	switch se := se.(type) {
+	case ShapeOr:
+		return map[string]interface{}{"type": "ShapeOr", "shapeExprs": shexjShapeExprs(se.Exprs)}
+	case ShapeAnd:
+		return map[string]interface{}{"type": "ShapeAnd", "shapeExprs": shexjShapeExprs(se.Exprs)}
+	case ShapeNot:
+		return map[string]interface{}{"type": "ShapeNot", "shapeExpr": shexjShapeExpr(se.Expr)}
+	case ShapeRef:
+		return shexjLabel(se.Label)
+	case ShapeExternal:
+		return map[string]interface{}{"type": "ShapeExternal"}
+	case *NodeConstraint:
+		m := map[string]interface{}{"type": "NodeConstraint"}
+		if se.NodeKind != "" {
+			m["nodeKind"] = se.NodeKind
+		}
+		if se.Datatype != (IRI{}) {
+			m["datatype"] = se.Datatype.str
+		}
+		if se.Values != nil {
+			values := []interface{}{}
+			for _, v := range se.Values {
+				values = append(values, shexjValue(v))
+			}
+			m["values"] = values
+		}
+		for name, n := range map[string]*int{
+			"length": se.Length, "minlength": se.MinLength, "maxlength": se.MaxLength,
+			"totaldigits": se.TotalDigits, "fractiondigits": se.FractionDigits,
+		} {
+			if n != nil {
+				m[name] = *n
+			}
+		}
+		for name, l := range map[string]*Literal{
+			"mininclusive": se.MinInclusive, "minexclusive": se.MinExclusive,
+			"maxinclusive": se.MaxInclusive, "maxexclusive": se.MaxExclusive,
+		} {
+			if l != nil {
+				m[name] = shexjNumber(*l)
+			}
+		}
+		if se.Pattern != "" {
+			m["pattern"] = se.Pattern
+			if se.Flags != "" {
+				m["flags"] = se.Flags
+			}
+		}
+		return m
+	case *Shape:
+		m := map[string]interface{}{"type": "Shape"}
+		if se.Closed {
+			m["closed"] = true
+		}
+		if len(se.Extra) > 0 {
+			var extra []string
+			for _, p := range se.Extra {
+				extra = append(extra, p.str)
+			}
+			m["extra"] = extra
+		}
+		if se.Expr != nil {
+			m["expression"] = shexjTripleExpr(se.Expr)
+		}
+		return m
+	}
+	panic(fmt.Sprintf("unknown shape expression: %T", se))
+}
+
+func shexjShapeExprs(exprs []ShapeExpr) []interface{} {
+	var js []interface{}
+	for _, e := range exprs {
+		js = append(js, shexjShapeExpr(e))
+	}
+	return js
+}
+
+// shexjNumber returns a numeric literal as a JSON number, in its canonical
+// form if it has one.
+func shexjNumber(l Literal) json.Number {
+	if s, ok := canonicalLexical(l.str, l.DataType); ok {
+		return json.Number(s)
+	}
+	return json.Number(l.str)
+}
+
+func shexjValue(v ValueSetValue) interface{} {
+	switch v.Type {
+	case "":
+		switch t := v.Value.(type) {
+		case IRI:
+			return t.str
+		case Literal:
+			m := map[string]interface{}{"value": t.str}
+			switch {
+			case t.lang != "":
+				m["language"] = t.lang
+			case t.DataType != xsdString:
+				m["type"] = t.DataType.str
+			}
+			return m
+		}
+	case "Language":
+		return map[string]interface{}{"type": v.Type, "languageTag": v.Stem}
+	}
+	m := map[string]interface{}{"type": v.Type, "stem": v.Stem}
+	if v.Wildcard {
+		m["stem"] = map[string]interface{}{"type": "Wildcard"}
+	}
+	if strings.HasSuffix(v.Type, "Range") {
+		var excl []interface{}
+		for _, x := range v.Exclusions {
+			switch {
+			case x.Type == "Language":
+				excl = append(excl, x.Stem)
+			case x.Type != "":
+				excl = append(excl, map[string]interface{}{"type": x.Type, "stem": x.Stem})
+			default:
+				// IRIs and literals are given by their strings.
+				s, _ := shString(x.Value)
+				excl = append(excl, s)
+			}
+		}
+		m["exclusions"] = excl
+	}
+	return m
+}
+
+func shexjTripleExpr(e TripleExpr) interface{} {
+	var m map[string]interface{}
+	var label Term
+	var min, max int
+	switch e := e.(type) {
+	case *EachOf:
+		m = map[string]interface{}{"type": "EachOf", "expressions": shexjTripleExprs(e.Exprs)}
+		label, min, max = e.Label, e.Min, e.Max
+	case *OneOf:
+		m = map[string]interface{}{"type": "OneOf", "expressions": shexjTripleExprs(e.Exprs)}
+		label, min, max = e.Label, e.Min, e.Max
+	case *TripleConstraint:
+		m = map[string]interface{}{"type": "TripleConstraint", "predicate": e.Predicate.str}
+		if e.Inverse {
+			m["inverse"] = true
+		}
+		if e.ValueExpr != nil {
+			m["valueExpr"] = shexjShapeExpr(e.ValueExpr)
+		}
+		label, min, max = e.Label, e.Min, e.Max
+	case Inclusion:
+		return shexjLabel(e.Label)
+	default:
+		panic(fmt.Sprintf("unknown triple expression: %T", e))
+	}
+	if label != nil {
+		m["id"] = shexjLabel(label)
+	}
+	if min != 1 || max != 1 {
+		m["min"], m["max"] = min, max
+	}
+	return m
+}
+
+func shexjTripleExprs(exprs []TripleExpr) []interface{} {
+	var js []interface{}
+	for _, e := range exprs {
+		js = append(js, shexjTripleExpr(e))
+	}
+	return js
+}
+
+// UnmarshalJSON parses a schema in the JSON syntax, ShExJ. Shapes may be
+// given as shape declarations, or as shape expressions with an "id".
+func (s *ShExSchema) UnmarshalJSON(data []byte) (err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(runtime.Error); ok {
+				panic(e)
+			}
+			err = e.(error)
+		}
+	}()
+	m := shexjObject(v, "Schema")
+	*s = ShExSchema{}
+	if start, ok := m["start"]; ok {
+		s.Start = shexjParseShapeExpr(start)
+	}
+	for _, d := range shexjArray(m, "shapes") {
+		dm := shexjObject(d, "")
+		decl := &ShapeDecl{Label: shexjParseLabel(dm["id"])}
+		if dm["type"] == "ShapeDecl" {
+			decl.Expr = shexjParseShapeExpr(dm["shapeExpr"])
+		} else {
+			decl.Expr = shexjParseShapeExpr(d)
+		}
+		s.Shapes = append(s.Shapes, decl)
+	}
+	return nil
+}
+
+// shexjErrorf terminates parsing ShExJ with an error.
+func shexjErrorf(format string, args ...interface{}) {
+	panic(fmt.Errorf("ShExJ: "+format, args...))
+}
+
+// shexjObject returns the JSON value as an object, of the given type
+// unless empty.
+func shexjObject(v interface{}, typ string) map[string]interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		shexjErrorf("expected object, got %v", v)
+	}
+	if typ != "" && m["type"] != typ {
+		shexjErrorf("expected type %s, got %v", typ, m["type"])
+	}
+	return m
+}
+
+// shexjArray returns the array member of an object, or nil if absent.
+func shexjArray(m map[string]interface{}, key string) []interface{} {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		shexjErrorf("expected array for %s, got %v", key, v)
+	}
+	return a
+}
+
+// shexjString returns the string member of an object, or "" if absent.
+func shexjString(m map[string]interface{}, key string) string {
+	v, ok := m[key]
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		shexjErrorf("expected string for %s, got %v", key, v)
+	}
+	return s
+}
+
+// shexjInt returns the integer member of an object, or nil if absent.
+func shexjInt(m map[string]interface{}, key string) *int {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		shexjErrorf("expected integer for %s, got %v", key, v)
+	}
+	i, err := strconv.Atoi(n.String())
+	if err != nil {
+		shexjErrorf("expected integer for %s, got %v", key, v)
+	}
+	return &i
+}
+
+func shexjParseLabel(v interface{}) Term {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		shexjErrorf("expected label, got %v", v)
+	}
+	if strings.HasPrefix(s, "_:") {
+		return Blank{id: s}
+	}
+	return IRI{str: s}
+}
+
+func shexjParseShapeExpr(v interface{}) ShapeExpr {
+	if _, ok := v.(string); ok {
+		return ShapeRef{Label: shexjParseLabel(v)}
+	}
+	m := shexjObject(v, "")
+	switch m["type"] {
+	case "ShapeOr":
+		return ShapeOr{Exprs: shexjParseShapeExprs(m)}
+	case "ShapeAnd":
+		return ShapeAnd{Exprs: shexjParseShapeExprs(m)}
+	case "ShapeNot":
+		return ShapeNot{Expr: shexjParseShapeExpr(m["shapeExpr"])}
+	case "ShapeExternal":
+		return ShapeExternal{}
+	case "NodeConstraint":
+		nc := &NodeConstraint{NodeKind: shexjString(m, "nodeKind")}
+		switch nc.NodeKind {
+		case "", "iri", "bnode", "nonliteral", "literal":
+		default:
+			shexjErrorf("invalid nodeKind %s", nc.NodeKind)
+		}
+		if dt := shexjString(m, "datatype"); dt != "" {
+			nc.Datatype = IRI{str: dt}
+		}
+		if _, ok := m["values"]; ok {
+			nc.Values = []ValueSetValue{}
+			for _, x := range shexjArray(m, "values") {
+				nc.Values = append(nc.Values, shexjParseValue(x))
+			}
+		}
+		nc.Length, nc.MinLength, nc.MaxLength = shexjInt(m, "length"), shexjInt(m, "minlength"), shexjInt(m, "maxlength")
+		nc.TotalDigits, nc.FractionDigits = shexjInt(m, "totaldigits"), shexjInt(m, "fractiondigits")
+		nc.MinInclusive, nc.MinExclusive = shexjParseNumber(m, "mininclusive"), shexjParseNumber(m, "minexclusive")
+		nc.MaxInclusive, nc.MaxExclusive = shexjParseNumber(m, "maxinclusive"), shexjParseNumber(m, "maxexclusive")
+		nc.Pattern, nc.Flags = shexjString(m, "pattern"), shexjString(m, "flags")
+		return nc
+	case "Shape":
+		sh := &Shape{}
+		if closed, ok := m["closed"]; ok {
+			if sh.Closed, ok = closed.(bool); !ok {
+				shexjErrorf("expected boolean for closed, got %v", closed)
+			}
+		}
+		for _, p := range shexjArray(m, "extra") {
+			s, ok := p.(string)
+			if !ok {
+				shexjErrorf("expected IRI, got %v", p)
+			}
+			sh.Extra = append(sh.Extra, IRI{str: s})
+		}
+		if e, ok := m["expression"]; ok {
+			sh.Expr = shexjParseTripleExpr(e)
+		}
+		return sh
+	}
+	shexjErrorf("unknown shape expression type %v", m["type"])
+	return nil
+}
+
+func shexjParseShapeExprs(m map[string]interface{}) []ShapeExpr {
+	var exprs []ShapeExpr
+	for _, x := range shexjArray(m, "shapeExprs") {
+		exprs = append(exprs, shexjParseShapeExpr(x))
+	}
+	return exprs
+}
+
+// shexjParseNumber returns the numeric member of an object as a literal,
+// or nil if absent.
+func shexjParseNumber(m map[string]interface{}, key string) *Literal {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		shexjErrorf("expected number for %s, got %v", key, v)
+	}
+	l := Literal{str: n.String(), DataType: xsdInteger}
+	switch {
+	case strings.ContainsAny(l.str, "eE"):
+		l.DataType = xsdDouble
+	case strings.Contains(l.str, "."):
+		l.DataType = xsdDecimal
+	}
+	return &l
+}
+
+func shexjParseValue(v interface{}) ValueSetValue {
+	if s, ok := v.(string); ok {
+		return ValueSetValue{Value: IRI{str: s}}
+	}
+	m := shexjObject(v, "")
+	if _, ok := m["value"]; ok {
+		l := Literal{str: shexjString(m, "value"), DataType: xsdString}
+		if lang := shexjString(m, "language"); lang != "" {
+			l.lang, l.DataType = lang, rdfLangString
+		} else if dt := shexjString(m, "type"); dt != "" {
+			l.DataType = IRI{str: dt}
+		}
+		return ValueSetValue{Value: l.canonicalMarkup()}
+	}
+	vs := ValueSetValue{Type: shexjString(m, "type")}
+	switch vs.Type {
+	case "Language":
+		vs.Stem = shexjString(m, "languageTag")
+	case "IriStem", "LiteralStem", "LanguageStem":
+		vs.Stem = shexjString(m, "stem")
+	case "IriStemRange", "LiteralStemRange", "LanguageStemRange":
+		if stem, ok := m["stem"].(map[string]interface{}); ok && stem["type"] == "Wildcard" {
+			vs.Wildcard = true
+		} else {
+			vs.Stem = shexjString(m, "stem")
+		}
+		for _, x := range shexjArray(m, "exclusions") {
+			switch x := x.(type) {
+			case string:
+				switch vs.Type {
+				case "IriStemRange":
+					vs.Exclusions = append(vs.Exclusions, ValueSetValue{Value: IRI{str: x}})
+				case "LiteralStemRange":
+					vs.Exclusions = append(vs.Exclusions, ValueSetValue{Value: Literal{str: x, DataType: xsdString}})
+				default:
+					vs.Exclusions = append(vs.Exclusions, ValueSetValue{Type: "Language", Stem: x})
+				}
+			default:
+				xm := shexjObject(x, strings.TrimSuffix(vs.Type, "Range"))
+				vs.Exclusions = append(vs.Exclusions, ValueSetValue{Type: xm["type"].(string), Stem: shexjString(xm, "stem")})
+			}
+		}
+	default:
+		shexjErrorf("unknown value type %v", m["type"])
+	}
+	return vs
+}
+
+func shexjParseTripleExpr(v interface{}) TripleExpr {
+	if _, ok := v.(string); ok {
+		return Inclusion{Label: shexjParseLabel(v)}
+	}
+	m := shexjObject(v, "")
+	var label Term
+	if _, ok := m["id"]; ok {
+		label = shexjParseLabel(m["id"])
+	}
+	min, max := 1, 1
+	if n := shexjInt(m, "min"); n != nil {
+		min = *n
+	}
+	if n := shexjInt(m, "max"); n != nil {
+		max = *n
+	}
+	switch m["type"] {
+	case "EachOf", "OneOf":
+		var exprs []TripleExpr
+		for _, x := range shexjArray(m, "expressions") {
+			exprs = append(exprs, shexjParseTripleExpr(x))
+		}
+		if m["type"] == "OneOf" {
+			return &OneOf{Label: label, Exprs: exprs, Min: min, Max: max}
+		}
+		return &EachOf{Label: label, Exprs: exprs, Min: min, Max: max}
+	case "TripleConstraint":
+		tc := &TripleConstraint{Label: label, Predicate: IRI{str: shexjString(m, "predicate")}, Min: min, Max: max}
+		if inv, ok := m["inverse"]; ok {
+			if tc.Inverse, ok = inv.(bool); !ok {
+				shexjErrorf("expected boolean for inverse, got %v", inv)
+			}
+		}
+		if e, ok := m["valueExpr"]; ok {
+			tc.ValueExpr = shexjParseShapeExpr(e)
+		}
+		return tc
+	}
+	shexjErrorf("unknown triple expression type %v", m["type"])
+	return nil
+}
@@ -0,0 +1,113 @@
+package rdf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestShExJRoundTrip(t *testing.T) {
+	tests := []string{
+		`PREFIX ex: <http://example/>
+		start = @ex:Person
+		ex:Person CLOSED EXTRA a {
+			ex:name LITERAL /^\w+$/ MAXLENGTH 20 + ;
+			( ex:age ex:int MININCLUSIVE 0 MAXEXCLUSIVE 1.5E2 TOTALDIGITS 3 FRACTIONDIGITS 0 | ^ex:child IRI {0,} ) ;
+			$ex:k ex:knows @ex:Person OR NOT BNODE * ;
+			&ex:k
+		}
+		ex:Code [ex:~ - ex:bad - ex:x~ . - "a" "b"^^ex:dt @en ] AND NONLITERAL LENGTH 4
+		_:ext EXTERNAL`,
+	}
+	for _, test := range tests {
+		schema, err := ParseShExC(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded ShExSchema
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		b2, err := json.Marshal(&decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(b2) {
+			t.Errorf("ShExJ round trip of %s:\n%s\nwant:\n%s", test, b2, b)
+		}
+	}
+}
+
+func TestShExJValidate(t *testing.T) {
+	// ShEx 2.0 schemas give shapes with ids, rather than shape declarations.
+	var schema ShExSchema
+	err := json.Unmarshal([]byte(`{
+		"@context": "http://www.w3.org/ns/shex.jsonld",
+		"type": "Schema",
+		"shapes": [{
+			"id": "http://example/S",
+			"type": "Shape",
+			"expression": {
+				"type": "EachOf",
+				"expressions": [
+					{"type": "TripleConstraint", "predicate": "http://example/p", "valueExpr": {"type": "NodeConstraint", "datatype": "http://www.w3.org/2001/XMLSchema#integer", "mininclusive": 10}},
+					{"type": "TripleConstraint", "predicate": "http://example/q", "valueExpr": "http://example/S", "min": 0, "max": -1}
+				]
+			}
+		}]
+	}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := mustDecodeTTL(`
+@prefix ex: <http://example/> .
+ex:a ex:p 10 ; ex:q ex:b .
+ex:b ex:p 12 ; ex:q ex:a .
+ex:c ex:p 12 ; ex:q ex:d .
+ex:d ex:p 9 .
+`)
+	m, err := schema.ParseShapeMap(`<http://example/a>@<http://example/S>, <http://example/c>@<http://example/S>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := schema.Validate(NewGraph(data...), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"<http://example/a>@<http://example/S> conformant",
+		"<http://example/c>@<http://example/S> nonconformant: <http://example/c> <http://example/q> <http://example/d> does not match a triple constraint: <http://example/d> does not conform to <http://example/S>: <http://example/d> <http://example/p> \"9\"^^<http://www.w3.org/2001/XMLSchema#integer> does not match a triple constraint: \"9\"^^<http://www.w3.org/2001/XMLSchema#integer> is not >= 10",
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestShExJErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`[]`, "ShExJ: expected object, got []"},
+		{`{"type": "Shape"}`, "ShExJ: expected type Schema, got Shape"},
+		{`{"type": "Schema", "shapes": {}}`, "ShExJ: expected array for shapes, got map[]"},
+		{`{"type": "Schema", "shapes": [{"type": "ShapeDecl", "shapeExpr": "x"}]}`, "ShExJ: expected label, got <nil>"},
+		{`{"type": "Schema", "start": {"type": "Triangle"}}`, "ShExJ: unknown shape expression type Triangle"},
+		{`{"type": "Schema", "start": {"type": "NodeConstraint", "length": 1.5}}`, "ShExJ: expected integer for length, got 1.5"},
+		{`{"type": "Schema", "start": {"type": "NodeConstraint", "nodeKind": "uri"}}`, "ShExJ: invalid nodeKind uri"},
+		{`{"type": "Schema", "start": {"type": "Shape", "expression": {"type": "AllOf"}}}`, "ShExJ: unknown triple expression type AllOf"},
+	}
+	for _, test := range tests {
+		var schema ShExSchema
+		if err := json.Unmarshal([]byte(test.input), &schema); err == nil || err.Error() != test.err {
+			t.Errorf("Unmarshal(%s) => error %v; want %q", test.input, err, test.err)
+		}
+	}
+}
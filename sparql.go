@@ -0,0 +1,1483 @@
+package rdf
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// QueryForm is the form of a SPARQL query.
+type QueryForm int
+
+// SPARQL query forms.
+const (
+	QuerySelect QueryForm = iota
+	QueryConstruct
+	QueryAsk
+	QueryDescribe
+)
+
+// Query is a parsed SPARQL query.
+type Query struct {
+	Form QueryForm
+
+	// Vars are the result variables of a SELECT query.
+	Vars []Var
+
+	// Template is the template of a CONSTRUCT query. Blank nodes in the
+	// template are Blank terms, to be replaced by fresh blank nodes for
+	// each solution.
+	Template []TriplePattern
+
+	// Describe holds the IRIs and variables of a DESCRIBE query.
+	Describe []Term
+
+	// From and FromNamed are the IRIs of the dataset clauses.
+	From      []IRI
+	FromNamed []IRI
+
+	// Algebra is the query pattern and solution modifiers, translated
+	// to the SPARQL algebra.
+	Algebra Op
+}
+
+// ParseQuery parses a SPARQL 1.1 query, and translates it to the SPARQL
+// algebra. Syntax errors are reported with line and column.
+func ParseQuery(query string) (q *Query, err error) {
+	p := newSparqlParser(query)
+	defer p.recover(&err)
+	q = p.parseQuery()
+	return q, nil
+}
+
+// sparqlParser is a recursive descent parser for SPARQL queries and updates.
+type sparqlParser struct {
+	l    *lexer
+	buf  []token // tokens backed up
+	last token   // last token returned by next
+
+	base IRI               // base IRI
+	ns   map[string]string // map[prefix]namespace
+
+	varN   int            // counter for hidden variables
+	bnodeN int            // counter for blank nodes in templates
+	bnodes map[string]Var // blank node labels in patterns, to hidden variables
+
+	// template is true when parsing triples where blank nodes are kept
+	// as blank nodes, rather than being variables (CONSTRUCT templates and
+	// update data).
+	template bool
+
+	// tokens holds the tokens where the variables and blank nodes of
+	// update quads first occur, for error messages; it is nil elsewhere.
+	tokens map[Term]token
+
+	// aggs collects the aggregates of the current query level; it is nil
+	// where aggregates are not allowed.
+	aggs *[]Aggregation
+}
+
+func newSparqlParser(s string) *sparqlParser {
+	return &sparqlParser{
+		l:      newSparqlLexer(strings.NewReader(s)),
+		ns:     make(map[string]string),
+		bnodes: make(map[string]Var),
+	}
+}
+
+// Token handling:
+
+// next returns the next token.
+func (p *sparqlParser) next() token {
+	var t token
+	if n := len(p.buf); n > 0 {
+		t = p.buf[n-1]
+		p.buf = p.buf[:n-1]
+	} else {
+		t = p.l.nextToken()
+	}
+	if t.typ == tokenError {
+		p.errorf(t, "syntax error: %s", t.text)
+	}
+	if t.typ == tokenEOF {
+		// The channel is closed; use the position of the last token.
+		t.line, t.col = p.last.line, p.last.col+len(p.last.text)
+	}
+	p.last = t
+	return t
+}
+
+// peek returns but does not consume the next token.
+func (p *sparqlParser) peek() token {
+	t := p.next()
+	p.backup(t)
+	return t
+}
+
+// backup puts the token back in the input stream.
+func (p *sparqlParser) backup(t token) {
+	p.buf = append(p.buf, t)
+}
+
+// isKeyword checks if the token is the given keyword, disregarding case.
+func isKeyword(t token, kw string) bool {
+	return t.typ == tokenKeyword && strings.EqualFold(t.text, kw)
+}
+
+// isOp checks if the token is the given operator.
+func isOp(t token, op string) bool {
+	return t.typ == tokenOperator && t.text == op
+}
+
+// acceptKeyword consumes the next token if it is the given keyword.
+func (p *sparqlParser) acceptKeyword(kw string) bool {
+	if isKeyword(p.peek(), kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// acceptOp consumes the next token if it is the given operator.
+func (p *sparqlParser) acceptOp(op string) bool {
+	if isOp(p.peek(), op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// accept consumes the next token if it is of the given type.
+func (p *sparqlParser) accept(typ tokenType) bool {
+	if p.peek().typ == typ {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token, which must be of the given type.
+func (p *sparqlParser) expect(typ tokenType, context string) token {
+	t := p.next()
+	if t.typ != typ {
+		p.unexpected(t, context)
+	}
+	return t
+}
+
+// expectKeyword consumes the next token, which must be the given keyword.
+func (p *sparqlParser) expectKeyword(kw string) token {
+	t := p.next()
+	if !isKeyword(t, kw) {
+		p.unexpected(t, kw)
+	}
+	return t
+}
+
+// expectOp consumes the next token, which must be the given operator.
+func (p *sparqlParser) expectOp(op string) token {
+	t := p.next()
+	if !isOp(t, op) {
+		p.unexpected(t, "'"+op+"'")
+	}
+	return t
+}
+
+// Errors:
+
+// errorf formats the error at the position of the token and terminates parsing.
+func (p *sparqlParser) errorf(t token, format string, args ...interface{}) {
+	panic(fmt.Errorf("%d:%d: %s", t.line, t.col, fmt.Sprintf(format, args...)))
+}
+
+// unexpected complains about the given token and terminates parsing.
+func (p *sparqlParser) unexpected(t token, expected string) {
+	p.errorf(t, "unexpected %s, expected %s", describeToken(t), expected)
+}
+
+// describeToken returns a description of the token for error messages.
+func describeToken(t token) string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of input"
+	case tokenVariable:
+		return "variable ?" + t.text
+	case tokenPrefixLabel:
+		return fmt.Sprintf("%q", t.text+":")
+	case tokenIRIAbs, tokenIRIRel:
+		return "<" + t.text + ">"
+	case tokenLiteral, tokenLiteral3:
+		return "literal " + strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// recover catches non-runtime panics and binds the panic error
+// to the given error pointer.
+func (p *sparqlParser) recover(errp *error) {
+	e := recover()
+	if e != nil {
+		if _, ok := e.(runtime.Error); ok {
+			// Don't recover from runtime errors.
+			panic(e)
+		}
+		*errp = e.(error)
+		// Let the lexer run to completion, so that its goroutine terminates.
+		go func(l *lexer) {
+			for range l.tokens {
+			}
+		}(p.l)
+	}
+}
+
+// Prologue and queries:
+
+// parsePrologue parses BASE and PREFIX declarations.
+func (p *sparqlParser) parsePrologue() {
+	for {
+		switch p.peek().typ {
+		case tokenSparqlBase:
+			p.next()
+			p.base = p.parseIRI(p.next())
+		case tokenSparqlPrefix:
+			p.next()
+			label := p.expect(tokenPrefixLabel, "prefix label")
+			p.ns[label.text] = p.parseIRIRef(p.next()).str
+		default:
+			return
+		}
+	}
+}
+
+// parseQuery parses a complete query.
+func (p *sparqlParser) parseQuery() *Query {
+	p.parsePrologue()
+	q := &Query{}
+	t := p.next()
+	switch {
+	case isKeyword(t, "SELECT"):
+		q.Form = QuerySelect
+		q.Algebra, q.Vars = p.parseSelect(q)
+	case isKeyword(t, "CONSTRUCT"):
+		q.Form = QueryConstruct
+		p.parseConstruct(q)
+	case isKeyword(t, "DESCRIBE"):
+		q.Form = QueryDescribe
+		p.parseDescribe(q)
+	case isKeyword(t, "ASK"):
+		q.Form = QueryAsk
+		p.parseDatasetClauses(q)
+		p.acceptKeyword("WHERE")
+		where := p.parseGroupGraphPattern()
+		q.Algebra = p.parseModifiers(where, nil)
+	default:
+		p.unexpected(t, "SELECT, CONSTRUCT, DESCRIBE or ASK")
+	}
+	p.expect(tokenEOF, "end of query")
+	return q
+}
+
+// parseDatasetClauses parses FROM and FROM NAMED clauses.
+func (p *sparqlParser) parseDatasetClauses(q *Query) {
+	for p.acceptKeyword("FROM") {
+		if p.acceptKeyword("NAMED") {
+			q.FromNamed = append(q.FromNamed, p.parseIRI(p.next()))
+		} else {
+			q.From = append(q.From, p.parseIRI(p.next()))
+		}
+	}
+}
+
+// projection is the SELECT clause of a query.
+type projection struct {
+	distinct bool
+	reduced  bool
+	star     bool
+	vars     []Var    // all the projected variables, in order
+	toks     []token  // the tokens of the variables, for error positions
+	exprs    []Extend // (expr AS ?var), in order
+}
+
+// parseSelect parses a SELECT query, after the SELECT keyword. If q is nil, it
+// is a subquery, which doesn't have dataset clauses. It returns the algebra
+// and the result variables.
+func (p *sparqlParser) parseSelect(q *Query) (Op, []Var) {
+	outerAggs := p.aggs
+	aggs := []Aggregation{}
+	p.aggs = &aggs
+	defer func() { p.aggs = outerAggs }()
+
+	proj := &projection{}
+	if p.acceptKeyword("DISTINCT") {
+		proj.distinct = true
+	} else if p.acceptKeyword("REDUCED") {
+		proj.reduced = true
+	}
+	if p.acceptOp("*") {
+		proj.star = true
+	} else {
+		for {
+			t := p.peek()
+			if t.typ == tokenVariable {
+				p.next()
+				p.addProjected(proj, t, Var(t.text))
+				continue
+			}
+			if t.typ != tokenCollectionStart {
+				break
+			}
+			p.next()
+			e := p.parseExpression()
+			p.expectKeyword("AS")
+			vt := p.expect(tokenVariable, "variable")
+			p.expect(tokenCollectionEnd, "')'")
+			p.addProjected(proj, vt, Var(vt.text))
+			proj.exprs = append(proj.exprs, Extend{Var: Var(vt.text), Expr: e})
+		}
+		if len(proj.vars) == 0 {
+			p.unexpected(p.peek(), "variable, expression or '*'")
+		}
+	}
+
+	if q != nil {
+		p.parseDatasetClauses(q)
+	}
+	p.acceptKeyword("WHERE")
+	p.aggs = nil
+	where := p.parseGroupGraphPattern()
+	p.aggs = &aggs
+
+	return p.parseModifiers(where, proj), proj.vars
+}
+
+// addProjected adds a variable to the SELECT clause.
+func (p *sparqlParser) addProjected(proj *projection, t token, v Var) {
+	for _, x := range proj.vars {
+		if x == v {
+			p.errorf(t, "variable ?%s already projected", v)
+		}
+	}
+	proj.vars = append(proj.vars, v)
+	proj.toks = append(proj.toks, t)
+}
+
+// parseConstruct parses a CONSTRUCT query, after the CONSTRUCT keyword.
+func (p *sparqlParser) parseConstruct(q *Query) {
+	if p.peek().typ == tokenGroupStart {
+		p.next()
+		q.Template = p.parseTemplate()
+		p.parseDatasetClauses(q)
+		p.acceptKeyword("WHERE")
+		q.Algebra = p.parseModifiers(p.parseGroupGraphPattern(), nil)
+		return
+	}
+
+	// Short form: CONSTRUCT WHERE { triples }
+	p.parseDatasetClauses(q)
+	p.expectKeyword("WHERE")
+	p.expect(tokenGroupStart, "'{'")
+	q.Template = p.parseTemplate()
+	for _, tp := range q.Template {
+		for _, t := range []Term{tp.Subj, tp.Obj} {
+			if t.Type() == TermBlank {
+				p.errorf(p.last, "blank nodes not allowed in CONSTRUCT WHERE")
+			}
+		}
+	}
+	q.Algebra = p.parseModifiers(BGP{Patterns: q.Template}, nil)
+}
+
+// parseTemplate parses the triples of a template, after the opening '{'.
+func (p *sparqlParser) parseTemplate() []TriplePattern {
+	p.template = true
+	defer func() { p.template = false }()
+	var elems []interface{}
+	for !p.accept(tokenGroupEnd) {
+		elems = p.parseTriplesSameSubject(elems)
+		if !p.accept(tokenDot) {
+			p.expect(tokenGroupEnd, "'}'")
+			break
+		}
+	}
+	tps := make([]TriplePattern, len(elems))
+	for i, e := range elems {
+		tps[i] = e.(TriplePattern)
+	}
+	return tps
+}
+
+// parseDescribe parses a DESCRIBE query, after the DESCRIBE keyword.
+func (p *sparqlParser) parseDescribe(q *Query) {
+	star := p.acceptOp("*")
+	if !star {
+		for {
+			t := p.peek()
+			if t.typ == tokenVariable {
+				p.next()
+				q.Describe = append(q.Describe, Var(t.text))
+			} else if t.typ == tokenIRIAbs || t.typ == tokenIRIRel || t.typ == tokenPrefixLabel {
+				q.Describe = append(q.Describe, p.parseIRI(p.next()))
+			} else {
+				break
+			}
+		}
+		if len(q.Describe) == 0 {
+			p.unexpected(p.peek(), "variable, IRI or '*'")
+		}
+	}
+	p.parseDatasetClauses(q)
+	var where Op = BGP{}
+	if p.acceptKeyword("WHERE") || p.peek().typ == tokenGroupStart {
+		where = p.parseGroupGraphPattern()
+	}
+	q.Algebra = p.parseModifiers(where, nil)
+	if star {
+		for _, v := range visibleVars(where) {
+			q.Describe = append(q.Describe, v)
+		}
+	}
+}
+
+// parseModifiers parses the solution modifiers and the VALUES clause
+// following the WHERE clause, and translates them together with the query
+// pattern and the SELECT clause, if any, to the algebra.
+func (p *sparqlParser) parseModifiers(where Op, proj *projection) Op {
+	aggs := []Aggregation{}
+	if p.aggs == nil {
+		p.aggs = &aggs
+		defer func() { p.aggs = nil }()
+	}
+
+	var keys []GroupKey
+	if p.acceptKeyword("GROUP") {
+		p.expectKeyword("BY")
+		outer := p.aggs
+		p.aggs = nil // no aggregates in GROUP BY
+		for {
+			t := p.peek()
+			if t.typ == tokenVariable {
+				p.next()
+				keys = append(keys, GroupKey{Expr: TermExpr{Term: Var(t.text)}})
+			} else if t.typ == tokenCollectionStart {
+				p.next()
+				k := GroupKey{Expr: p.parseExpression()}
+				if p.acceptKeyword("AS") {
+					k.Var = Var(p.expect(tokenVariable, "variable").text)
+				}
+				p.expect(tokenCollectionEnd, "')'")
+				keys = append(keys, k)
+			} else if e, ok := p.tryParseCall(); ok {
+				keys = append(keys, GroupKey{Expr: e})
+			} else {
+				break
+			}
+		}
+		if len(keys) == 0 {
+			p.unexpected(p.peek(), "group condition")
+		}
+		p.aggs = outer
+	}
+
+	var having []Expr
+	if p.acceptKeyword("HAVING") {
+		for {
+			e, ok := p.tryParseConstraint()
+			if !ok {
+				break
+			}
+			having = append(having, e)
+		}
+		if len(having) == 0 {
+			p.unexpected(p.peek(), "HAVING condition")
+		}
+	}
+
+	var order []OrderCond
+	if p.acceptKeyword("ORDER") {
+		p.expectKeyword("BY")
+		for {
+			t := p.peek()
+			if isKeyword(t, "ASC") || isKeyword(t, "DESC") {
+				p.next()
+				order = append(order, OrderCond{Expr: p.parseBrackettedExpression(), Desc: isKeyword(t, "DESC")})
+			} else if t.typ == tokenVariable {
+				p.next()
+				order = append(order, OrderCond{Expr: TermExpr{Term: Var(t.text)}})
+			} else if e, ok := p.tryParseConstraint(); ok {
+				order = append(order, OrderCond{Expr: e})
+			} else {
+				break
+			}
+		}
+		if len(order) == 0 {
+			p.unexpected(p.peek(), "order condition")
+		}
+	}
+
+	offset, limit := 0, -1
+	for i := 0; i < 2; i++ {
+		if p.acceptKeyword("LIMIT") {
+			limit = p.parseInteger()
+		} else if p.acceptKeyword("OFFSET") {
+			offset = p.parseInteger()
+		}
+	}
+
+	var values Op
+	if p.acceptKeyword("VALUES") {
+		values = p.parseDataBlock()
+	}
+
+	// Translate to algebra:
+	op := where
+	if len(keys) > 0 || len(*p.aggs) > 0 {
+		if proj != nil && proj.star {
+			p.errorf(p.last, "SELECT * not allowed with GROUP BY")
+		}
+		op = Group{Keys: keys, Aggregates: *p.aggs, Sub: op}
+		if proj != nil {
+			p.checkGrouped(keys, proj)
+		}
+	}
+	if len(having) > 0 {
+		op = Filter{Expr: conjunction(having), Sub: op}
+	}
+	if proj != nil {
+		for _, e := range proj.exprs {
+			for _, v := range visibleVars(op) {
+				if v == e.Var {
+					p.errorf(p.last, "variable ?%s already in scope", v)
+				}
+			}
+			op = Extend{Sub: op, Var: e.Var, Expr: e.Expr}
+		}
+	}
+	if values != nil {
+		op = join(op, values)
+	}
+	if len(order) > 0 {
+		op = OrderBy{Conds: order, Sub: op}
+	}
+	if proj != nil {
+		if proj.star {
+			proj.vars = visibleVars(op)
+		}
+		op = Project{Vars: proj.vars, Sub: op}
+		if proj.distinct {
+			op = Distinct{Sub: op}
+		} else if proj.reduced {
+			op = Reduced{Sub: op}
+		}
+	}
+	if offset > 0 || limit >= 0 {
+		op = Slice{Offset: offset, Limit: limit, Sub: op}
+	}
+	return op
+}
+
+// checkGrouped checks that the SELECT clause of a grouped query only refers
+// to group keys and aggregates.
+func (p *sparqlParser) checkGrouped(keys []GroupKey, proj *projection) {
+	ok := make(map[Var]bool)
+	for _, k := range keys {
+		if k.Var != "" {
+			ok[k.Var] = true
+		} else if e, isVar := k.Expr.(TermExpr); isVar {
+			if v, isVar := e.Term.(Var); isVar {
+				ok[v] = true
+			}
+		}
+	}
+	exprs := make(map[Var]Expr)
+	for _, e := range proj.exprs {
+		exprs[e.Var] = e.Expr
+	}
+	for i, v := range proj.vars {
+		e, isExpr := exprs[v]
+		if !isExpr {
+			if !ok[v] {
+				p.errorf(proj.toks[i], "variable ?%s in SELECT is not a group key", v)
+			}
+			continue
+		}
+		for _, x := range exprVars(e) {
+			if !ok[x] && !x.hidden() {
+				p.errorf(proj.toks[i], "variable ?%s in SELECT expression is not a group key", x)
+			}
+		}
+		ok[v] = true
+	}
+}
+
+// exprVars returns the variables of the expression, except those
+// inside EXISTS.
+func exprVars(e Expr) []Var {
+	var vars []Var
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case TermExpr:
+			if v, ok := e.Term.(Var); ok {
+				vars = append(vars, v)
+			}
+		case UnaryExpr:
+			walk(e.Arg)
+		case BinaryExpr:
+			walk(e.Left)
+			walk(e.Right)
+		case InExpr:
+			walk(e.Arg)
+			for _, x := range e.List {
+				walk(x)
+			}
+		case FuncExpr:
+			for _, x := range e.Args {
+				walk(x)
+			}
+		}
+	}
+	walk(e)
+	return vars
+}
+
+// conjunction returns the exprs combined with '&&'.
+func conjunction(exprs []Expr) Expr {
+	e := exprs[0]
+	for _, x := range exprs[1:] {
+		e = BinaryExpr{Op: "&&", Left: e, Right: x}
+	}
+	return e
+}
+
+// join returns the join of a and b, where the empty BGP is the identity.
+func join(a, b Op) Op {
+	if isEmptyBGP(a) {
+		return b
+	}
+	if isEmptyBGP(b) {
+		return a
+	}
+	return Join{Left: a, Right: b}
+}
+
+func isEmptyBGP(o Op) bool {
+	bgp, ok := o.(BGP)
+	return ok && len(bgp.Patterns) == 0
+}
+
+// parseInteger parses a non-negative integer, as given to LIMIT and OFFSET.
+func (p *sparqlParser) parseInteger() int {
+	t := p.expect(tokenLiteralInteger, "integer")
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 {
+		p.errorf(t, "invalid integer: %s", t.text)
+	}
+	return n
+}
+
+// parseDataBlock parses the data of VALUES.
+func (p *sparqlParser) parseDataBlock() Table {
+	var tbl Table
+	single := false
+	if t := p.peek(); t.typ == tokenVariable {
+		p.next()
+		tbl.Vars = []Var{Var(t.text)}
+		single = true
+	} else {
+		p.expect(tokenCollectionStart, "variable or '('")
+		for p.peek().typ == tokenVariable {
+			tbl.Vars = append(tbl.Vars, Var(p.next().text))
+		}
+		p.expect(tokenCollectionEnd, "')'")
+	}
+	p.expect(tokenGroupStart, "'{'")
+	for !p.accept(tokenGroupEnd) {
+		row := make(Binding)
+		if single {
+			if t := p.parseDataValue(); t != nil {
+				row[tbl.Vars[0]] = t
+			}
+		} else {
+			open := p.expect(tokenCollectionStart, "'('")
+			var vals []Term
+			for !p.accept(tokenCollectionEnd) {
+				vals = append(vals, p.parseDataValue())
+			}
+			if len(vals) != len(tbl.Vars) {
+				p.errorf(open, "wrong number of values in VALUES row: %d, expected %d", len(vals), len(tbl.Vars))
+			}
+			for i, t := range vals {
+				if t != nil {
+					row[tbl.Vars[i]] = t
+				}
+			}
+		}
+		tbl.Rows = append(tbl.Rows, row)
+	}
+	return tbl
+}
+
+// parseDataValue parses a value in VALUES, returning nil for UNDEF.
+func (p *sparqlParser) parseDataValue() Term {
+	t := p.next()
+	if isKeyword(t, "UNDEF") {
+		return nil
+	}
+	switch t.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return p.parseIRI(t)
+	case tokenLiteral, tokenLiteral3, tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+		return p.parseLiteral(t)
+	}
+	p.unexpected(t, "IRI, literal or UNDEF")
+	return nil
+}
+
+// Graph patterns:
+
+// parseGroupGraphPattern parses a group graph pattern: { ... }, and translates
+// it to the algebra.
+func (p *sparqlParser) parseGroupGraphPattern() Op {
+	p.expect(tokenGroupStart, "'{'")
+	if isKeyword(p.peek(), "SELECT") {
+		p.next()
+		op, _ := p.parseSelect(nil)
+		p.expect(tokenGroupEnd, "'}'")
+		return op
+	}
+
+	var filters []Expr
+	var g Op = BGP{}
+	for {
+		t := p.peek()
+		switch {
+		case t.typ == tokenGroupEnd:
+			p.next()
+			if len(filters) > 0 {
+				g = Filter{Expr: conjunction(filters), Sub: g}
+			}
+			return g
+		case t.typ == tokenDot:
+			// optional dot after a pattern which is not a triples block
+			p.next()
+		case isKeyword(t, "OPTIONAL"):
+			p.next()
+			a := p.parseGroupGraphPattern()
+			if f, ok := a.(Filter); ok {
+				g = LeftJoin{Left: g, Right: f.Sub, Expr: f.Expr}
+			} else {
+				g = LeftJoin{Left: g, Right: a}
+			}
+		case isKeyword(t, "MINUS"):
+			p.next()
+			g = Minus{Left: g, Right: p.parseGroupGraphPattern()}
+		case isKeyword(t, "FILTER"):
+			p.next()
+			e, ok := p.tryParseConstraint()
+			if !ok {
+				p.unexpected(p.peek(), "filter constraint")
+			}
+			filters = append(filters, e)
+		case isKeyword(t, "BIND"):
+			p.next()
+			p.expect(tokenCollectionStart, "'('")
+			e := p.parseExpression()
+			p.expectKeyword("AS")
+			vt := p.expect(tokenVariable, "variable")
+			p.expect(tokenCollectionEnd, "')'")
+			for _, v := range visibleVars(g) {
+				if v == Var(vt.text) {
+					p.errorf(vt, "variable ?%s already in scope", v)
+				}
+			}
+			g = Extend{Sub: g, Var: Var(vt.text), Expr: e}
+		case isKeyword(t, "VALUES"):
+			p.next()
+			g = join(g, p.parseDataBlock())
+		case isKeyword(t, "GRAPH"):
+			p.next()
+			name := p.parseVarOrIRI()
+			g = join(g, GraphPattern{Name: name, Sub: p.parseGroupGraphPattern()})
+		case isKeyword(t, "SERVICE"):
+			p.errorf(t, "SERVICE is not supported")
+		case t.typ == tokenGroupStart:
+			a := p.parseGroupGraphPattern()
+			for isKeyword(p.peek(), "UNION") {
+				p.next()
+				a = Union{Left: a, Right: p.parseGroupGraphPattern()}
+			}
+			g = join(g, a)
+		default:
+			g = join(g, p.parseTriplesBlock())
+		}
+	}
+}
+
+// parseVarOrIRI parses a variable or an IRI.
+func (p *sparqlParser) parseVarOrIRI() Term {
+	t := p.next()
+	if t.typ == tokenVariable {
+		return p.mark(Var(t.text), t)
+	}
+	return p.parseIRI(t)
+}
+
+// parseTriplesBlock parses triple patterns, including property paths, and
+// translates them to BGPs and path patterns.
+func (p *sparqlParser) parseTriplesBlock() Op {
+	var elems []interface{}
+	for {
+		elems = p.parseTriplesSameSubject(elems)
+		if !p.accept(tokenDot) || !p.startsTriples(p.peek()) {
+			break
+		}
+	}
+
+	var op Op = BGP{}
+	var bgp BGP
+	for _, e := range elems {
+		switch e := e.(type) {
+		case TriplePattern:
+			bgp.Patterns = append(bgp.Patterns, e)
+		case PathPattern:
+			if len(bgp.Patterns) > 0 {
+				op = join(op, bgp)
+				bgp = BGP{}
+			}
+			op = join(op, e)
+		}
+	}
+	if len(bgp.Patterns) > 0 {
+		op = join(op, bgp)
+	}
+	return op
+}
+
+// startsTriples checks if the token can start a triple pattern.
+func (p *sparqlParser) startsTriples(t token) bool {
+	switch t.typ {
+	case tokenVariable, tokenIRIAbs, tokenIRIRel, tokenPrefixLabel, tokenBNode, tokenAnonBNode,
+		tokenPropertyListStart, tokenCollectionStart, tokenLiteral, tokenLiteral3,
+		tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+		return true
+	}
+	return false
+}
+
+// parseTriplesSameSubject parses the triples with a subject and a property
+// list, and appends the triple and path patterns to elems.
+func (p *sparqlParser) parseTriplesSameSubject(elems []interface{}) []interface{} {
+	t := p.peek()
+	if !p.startsTriples(t) {
+		p.unexpected(t, "triple pattern")
+	}
+	var subj Term
+	subj, elems = p.parseNode(elems)
+	if t.typ == tokenPropertyListStart || (t.typ == tokenCollectionStart && subj != rdfNil) {
+		// The property list is optional after a blank node property list
+		// or a collection.
+		if !startsVerb(p.peek()) {
+			return elems
+		}
+	}
+	return p.parsePropertyList(subj, elems)
+}
+
+// parsePropertyList parses a non-empty property list for the given subject.
+func (p *sparqlParser) parsePropertyList(subj Term, elems []interface{}) []interface{} {
+	for {
+		var verb interface{} // Var or Path
+		t := p.peek()
+		if t.typ == tokenVariable {
+			p.next()
+			verb = p.mark(Var(t.text), t)
+		} else {
+			verb = p.parsePath()
+			if l, ok := verb.(PathLink); !ok && p.template {
+				p.errorf(t, "property paths not allowed here")
+			} else if ok {
+				verb = l.IRI
+			}
+		}
+		for {
+			var obj Term
+			obj, elems = p.parseNode(elems)
+			switch v := verb.(type) {
+			case Var:
+				elems = append(elems, TriplePattern{Subj: subj, Pred: v, Obj: obj})
+			case IRI:
+				elems = append(elems, TriplePattern{Subj: subj, Pred: v, Obj: obj})
+			case Path:
+				elems = p.translatePath(subj, v, obj, elems)
+			}
+			if !p.accept(tokenComma) {
+				break
+			}
+		}
+		if !p.accept(tokenSemicolon) {
+			return elems
+		}
+		for p.accept(tokenSemicolon) {
+		}
+		if !startsVerb(p.peek()) {
+			return elems
+		}
+	}
+}
+
+// startsVerb checks if the token can start a predicate or property path.
+func startsVerb(t token) bool {
+	switch t.typ {
+	case tokenVariable, tokenIRIAbs, tokenIRIRel, tokenPrefixLabel, tokenRDFType, tokenCollectionStart:
+		return true
+	}
+	return isOp(t, "^") || isOp(t, "!")
+}
+
+// translatePath translates a property path pattern, following the rules
+// of the specification: links become triple patterns, inverse links are
+// reversed triple patterns, and sequences are split with a new variable.
+func (p *sparqlParser) translatePath(subj Term, path Path, obj Term, elems []interface{}) []interface{} {
+	switch path := path.(type) {
+	case PathLink:
+		return append(elems, TriplePattern{Subj: subj, Pred: path.IRI, Obj: obj})
+	case PathInverse:
+		if l, ok := path.Path.(PathLink); ok {
+			return append(elems, TriplePattern{Subj: obj, Pred: l.IRI, Obj: subj})
+		}
+	case PathSeq:
+		v := p.newVar()
+		elems = p.translatePath(subj, path.Left, v, elems)
+		return p.translatePath(v, path.Right, obj, elems)
+	}
+	return append(elems, PathPattern{Subj: subj, Path: path, Obj: obj})
+}
+
+// newVar returns a new hidden variable.
+func (p *sparqlParser) newVar() Var {
+	p.varN++
+	return Var(fmt.Sprintf(".%d", p.varN))
+}
+
+// newBlank returns a new blank node, for templates.
+func (p *sparqlParser) newBlank() Blank {
+	p.bnodeN++
+	return Blank{id: fmt.Sprintf("_:b%d", p.bnodeN)}
+}
+
+// mark records the token where the variable or blank node occurs, if
+// the parser is collecting update quads.
+func (p *sparqlParser) mark(x Term, t token) Term {
+	if _, ok := p.tokens[x]; !ok && p.tokens != nil {
+		p.tokens[x] = t
+	}
+	return x
+}
+
+// anon returns a node for an anonymous blank node.
+func (p *sparqlParser) anon() Term {
+	if p.template {
+		return p.newBlank()
+	}
+	return p.newVar()
+}
+
+// parseNode parses a term, blank node property list or collection, and
+// appends any triples it implies to elems.
+func (p *sparqlParser) parseNode(elems []interface{}) (Term, []interface{}) {
+	t := p.next()
+	switch t.typ {
+	case tokenVariable:
+		return p.mark(Var(t.text), t), elems
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return p.parseIRI(t), elems
+	case tokenRDFType:
+		p.unexpected(t, "subject or object")
+	case tokenLiteral, tokenLiteral3, tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+		return p.parseLiteral(t), elems
+	case tokenBNode:
+		if p.template {
+			return p.mark(Blank{id: t.text}, t), elems
+		}
+		v, ok := p.bnodes[t.text]
+		if !ok {
+			v = p.newVar()
+			p.bnodes[t.text] = v
+		}
+		return v, elems
+	case tokenAnonBNode:
+		return p.mark(p.anon(), t), elems
+	case tokenPropertyListStart:
+		node := p.mark(p.anon(), t)
+		elems = p.parsePropertyList(node, elems)
+		p.expect(tokenPropertyListEnd, "']'")
+		return node, elems
+	case tokenCollectionStart:
+		if p.accept(tokenCollectionEnd) {
+			return rdfNil, elems
+		}
+		var items []Term
+		for !p.accept(tokenCollectionEnd) {
+			var item Term
+			item, elems = p.parseNode(elems)
+			items = append(items, item)
+		}
+		head := p.mark(p.anon(), t)
+		node := head
+		for i, item := range items {
+			elems = append(elems, TriplePattern{Subj: node, Pred: rdfFirst, Obj: item})
+			var rest Term = rdfNil
+			if i < len(items)-1 {
+				rest = p.mark(p.anon(), t)
+			}
+			elems = append(elems, TriplePattern{Subj: node, Pred: rdfRest, Obj: rest})
+			node = rest
+		}
+		return head, elems
+	}
+	p.unexpected(t, "subject or object")
+	return nil, nil
+}
+
+// Property paths:
+
+// parsePath parses a property path: PathAlternative.
+func (p *sparqlParser) parsePath() Path {
+	path := p.parsePathSequence()
+	for p.acceptOp("|") {
+		path = PathAlt{Left: path, Right: p.parsePathSequence()}
+	}
+	return path
+}
+
+// parsePathSequence parses a sequence path.
+func (p *sparqlParser) parsePathSequence() Path {
+	path := p.parsePathEltOrInverse()
+	for p.acceptOp("/") {
+		path = PathSeq{Left: path, Right: p.parsePathEltOrInverse()}
+	}
+	return path
+}
+
+// parsePathEltOrInverse parses a path element, possibly inverted with '^'.
+func (p *sparqlParser) parsePathEltOrInverse() Path {
+	if p.acceptOp("^") {
+		return PathInverse{Path: p.parsePathElt()}
+	}
+	return p.parsePathElt()
+}
+
+// parsePathElt parses a path primary, with an optional modifier.
+func (p *sparqlParser) parsePathElt() Path {
+	var path Path
+	t := p.next()
+	switch {
+	case t.typ == tokenRDFType:
+		path = PathLink{IRI: rdfType}
+	case t.typ == tokenIRIAbs || t.typ == tokenIRIRel || t.typ == tokenPrefixLabel:
+		path = PathLink{IRI: p.parseIRI(t)}
+	case isOp(t, "!"):
+		path = p.parseNegatedPropertySet()
+	case t.typ == tokenCollectionStart:
+		path = p.parsePath()
+		p.expect(tokenCollectionEnd, "')'")
+	default:
+		p.unexpected(t, "predicate or property path")
+	}
+	switch {
+	case p.acceptOp("*"):
+		path = PathZeroOrMore{Path: path}
+	case p.acceptOp("+"):
+		path = PathOneOrMore{Path: path}
+	case p.acceptOp("?"):
+		path = PathZeroOrOne{Path: path}
+	}
+	return path
+}
+
+// parseNegatedPropertySet parses a negated property set, after '!'.
+func (p *sparqlParser) parseNegatedPropertySet() Path {
+	var neg PathNegated
+	one := func() {
+		inv := p.acceptOp("^")
+		t := p.next()
+		var iri IRI
+		if t.typ == tokenRDFType {
+			iri = rdfType
+		} else {
+			iri = p.parseIRI(t)
+		}
+		if inv {
+			neg.Inv = append(neg.Inv, iri)
+		} else {
+			neg.Fwd = append(neg.Fwd, iri)
+		}
+	}
+	if !p.accept(tokenCollectionStart) {
+		one()
+		return neg
+	}
+	if p.accept(tokenCollectionEnd) {
+		return neg
+	}
+	one()
+	for p.acceptOp("|") {
+		one()
+	}
+	p.expect(tokenCollectionEnd, "')'")
+	return neg
+}
+
+// Terms:
+
+// parseIRIRef parses an IRI given as <...>.
+func (p *sparqlParser) parseIRIRef(t token) IRI {
+	switch t.typ {
+	case tokenIRIAbs:
+		return IRI{str: t.text}
+	case tokenIRIRel:
+		return IRI{str: p.base.str + t.text}
+	}
+	p.unexpected(t, "IRI")
+	return IRI{}
+}
+
+// parseIRI parses an IRI, given as <...> or as a prefixed name.
+func (p *sparqlParser) parseIRI(t token) IRI {
+	if t.typ != tokenPrefixLabel {
+		return p.parseIRIRef(t)
+	}
+	ns, ok := p.ns[t.text]
+	if !ok {
+		p.errorf(t, "missing namespace for prefix: '%s'", t.text)
+	}
+	suf := p.expect(tokenIRISuffix, "IRI suffix")
+	return IRI{str: ns + suf.text}
+}
+
+// parseLiteral parses a literal, starting with the given token.
+func (p *sparqlParser) parseLiteral(t token) Literal {
+	switch t.typ {
+	case tokenLiteralDouble:
+		return Literal{str: t.text, DataType: xsdDouble}
+	case tokenLiteralDecimal:
+		return Literal{str: t.text, DataType: xsdDecimal}
+	case tokenLiteralInteger:
+		return Literal{str: t.text, DataType: xsdInteger}
+	case tokenLiteralBoolean:
+		return Literal{str: strings.ToLower(t.text), DataType: xsdBoolean}
+	}
+	l := Literal{str: t.text, DataType: xsdString}
+	switch p.peek().typ {
+	case tokenLangMarker:
+		p.next()
+		l.lang = p.expect(tokenLang, "language tag").text
+		l.DataType = rdfLangString
+		if p.peek().typ == tokenDir {
+			l.dir = p.next().text
+			l.DataType = rdfDirLangString
+		}
+	case tokenDataTypeMarker:
+		p.next()
+		l.DataType = p.parseIRI(p.next())
+		l = l.canonicalMarkup()
+	}
+	return l
+}
+
+// Expressions:
+
+// parseExpression parses an expression: ConditionalOrExpression.
+func (p *sparqlParser) parseExpression() Expr {
+	e := p.parseAndExpression()
+	for p.acceptOp("||") {
+		e = BinaryExpr{Op: "||", Left: e, Right: p.parseAndExpression()}
+	}
+	return e
+}
+
+func (p *sparqlParser) parseAndExpression() Expr {
+	e := p.parseRelationalExpression()
+	for p.acceptOp("&&") {
+		e = BinaryExpr{Op: "&&", Left: e, Right: p.parseRelationalExpression()}
+	}
+	return e
+}
+
+func (p *sparqlParser) parseRelationalExpression() Expr {
+	e := p.parseAdditiveExpression()
+	t := p.peek()
+	switch {
+	case t.typ == tokenOperator:
+		switch t.text {
+		case "=", "!=", "<", ">", "<=", ">=":
+			p.next()
+			return BinaryExpr{Op: t.text, Left: e, Right: p.parseAdditiveExpression()}
+		}
+	case isKeyword(t, "IN"):
+		p.next()
+		return InExpr{Arg: e, List: p.parseExpressionList()}
+	case isKeyword(t, "NOT"):
+		p.next()
+		p.expectKeyword("IN")
+		return InExpr{Arg: e, List: p.parseExpressionList(), Not: true}
+	}
+	return e
+}
+
+// parseExpressionList parses a parenthesized, comma-separated list of expressions.
+func (p *sparqlParser) parseExpressionList() []Expr {
+	p.expect(tokenCollectionStart, "'('")
+	var list []Expr
+	if p.accept(tokenCollectionEnd) {
+		return list
+	}
+	for {
+		list = append(list, p.parseExpression())
+		if !p.accept(tokenComma) {
+			break
+		}
+	}
+	p.expect(tokenCollectionEnd, "')'")
+	return list
+}
+
+func (p *sparqlParser) parseAdditiveExpression() Expr {
+	e := p.parseMultiplicativeExpression()
+	for {
+		t := p.peek()
+		switch {
+		case isOp(t, "+"), isOp(t, "-"):
+			p.next()
+			e = BinaryExpr{Op: t.text, Left: e, Right: p.parseMultiplicativeExpression()}
+		case isSignedNumber(t):
+			// A signed number is the right operand of '+' or '-',
+			// as in ?x -1.
+			p.next()
+			op := t.text[:1]
+			t.text = t.text[1:]
+			var right Expr = TermExpr{Term: p.parseLiteral(t)}
+			for {
+				if p.acceptOp("*") {
+					right = BinaryExpr{Op: "*", Left: right, Right: p.parseUnaryExpression()}
+				} else if p.acceptOp("/") {
+					right = BinaryExpr{Op: "/", Left: right, Right: p.parseUnaryExpression()}
+				} else {
+					break
+				}
+			}
+			e = BinaryExpr{Op: op, Left: e, Right: right}
+		default:
+			return e
+		}
+	}
+}
+
+// isSignedNumber checks if the token is a number with an explicit sign.
+func isSignedNumber(t token) bool {
+	switch t.typ {
+	case tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble:
+		return t.text[0] == '+' || t.text[0] == '-'
+	}
+	return false
+}
+
+func (p *sparqlParser) parseMultiplicativeExpression() Expr {
+	e := p.parseUnaryExpression()
+	for {
+		if p.acceptOp("*") {
+			e = BinaryExpr{Op: "*", Left: e, Right: p.parseUnaryExpression()}
+		} else if p.acceptOp("/") {
+			e = BinaryExpr{Op: "/", Left: e, Right: p.parseUnaryExpression()}
+		} else {
+			return e
+		}
+	}
+}
+
+func (p *sparqlParser) parseUnaryExpression() Expr {
+	t := p.peek()
+	if isOp(t, "!") || isOp(t, "+") || isOp(t, "-") {
+		p.next()
+		return UnaryExpr{Op: t.text, Arg: p.parsePrimaryExpression()}
+	}
+	return p.parsePrimaryExpression()
+}
+
+func (p *sparqlParser) parsePrimaryExpression() Expr {
+	t := p.peek()
+	switch t.typ {
+	case tokenCollectionStart:
+		return p.parseBrackettedExpression()
+	case tokenVariable:
+		p.next()
+		return TermExpr{Term: Var(t.text)}
+	case tokenLiteral, tokenLiteral3, tokenLiteralInteger, tokenLiteralDecimal, tokenLiteralDouble, tokenLiteralBoolean:
+		p.next()
+		return TermExpr{Term: p.parseLiteral(t)}
+	}
+	if e, ok := p.tryParseCall(); ok {
+		return e
+	}
+	if t.typ == tokenIRIAbs || t.typ == tokenIRIRel || t.typ == tokenPrefixLabel {
+		p.next()
+		return TermExpr{Term: p.parseIRI(t)}
+	}
+	if t.typ == tokenKeyword {
+		p.errorf(t, "unknown function: %s", t.text)
+	}
+	p.unexpected(p.next(), "expression")
+	return nil
+}
+
+// parseBrackettedExpression parses '(' Expression ')'.
+func (p *sparqlParser) parseBrackettedExpression() Expr {
+	p.expect(tokenCollectionStart, "'('")
+	e := p.parseExpression()
+	p.expect(tokenCollectionEnd, "')'")
+	return e
+}
+
+// tryParseConstraint parses a bracketted expression, or a built-in or
+// function call, if one follows.
+func (p *sparqlParser) tryParseConstraint() (Expr, bool) {
+	if p.peek().typ == tokenCollectionStart {
+		return p.parseBrackettedExpression(), true
+	}
+	return p.tryParseCall()
+}
+
+// builtins maps the names of built-in functions to their minimum and maximum
+// number of arguments, where -1 means any number.
+var builtins = map[string][2]int{
+	"STR": {1, 1}, "LANG": {1, 1}, "LANGMATCHES": {2, 2}, "DATATYPE": {1, 1},
+	"BOUND": {1, 1}, "IRI": {1, 1}, "URI": {1, 1}, "BNODE": {0, 1}, "RAND": {0, 0},
+	"ABS": {1, 1}, "CEIL": {1, 1}, "FLOOR": {1, 1}, "ROUND": {1, 1},
+	"CONCAT": {0, -1}, "SUBSTR": {2, 3}, "STRLEN": {1, 1}, "REPLACE": {3, 4},
+	"UCASE": {1, 1}, "LCASE": {1, 1}, "ENCODE_FOR_URI": {1, 1}, "CONTAINS": {2, 2},
+	"STRSTARTS": {2, 2}, "STRENDS": {2, 2}, "STRBEFORE": {2, 2}, "STRAFTER": {2, 2},
+	"YEAR": {1, 1}, "MONTH": {1, 1}, "DAY": {1, 1}, "HOURS": {1, 1}, "MINUTES": {1, 1},
+	"SECONDS": {1, 1}, "TIMEZONE": {1, 1}, "TZ": {1, 1}, "NOW": {0, 0},
+	"UUID": {0, 0}, "STRUUID": {0, 0},
+	"MD5": {1, 1}, "SHA1": {1, 1}, "SHA256": {1, 1}, "SHA384": {1, 1}, "SHA512": {1, 1},
+	"COALESCE": {0, -1}, "IF": {3, 3}, "STRLANG": {2, 2}, "STRDT": {2, 2},
+	"SAMETERM": {2, 2}, "ISIRI": {1, 1}, "ISURI": {1, 1}, "ISBLANK": {1, 1},
+	"ISLITERAL": {1, 1}, "ISNUMERIC": {1, 1}, "REGEX": {2, 3},
+}
+
+// aggregates are the names of the aggregate functions.
+var aggregates = map[string]bool{
+	"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true,
+	"SAMPLE": true, "GROUP_CONCAT": true,
+}
+
+// tryParseCall parses a built-in call, aggregate, or extension function
+// call, if one follows.
+func (p *sparqlParser) tryParseCall() (Expr, bool) {
+	t := p.peek()
+	switch t.typ {
+	case tokenKeyword:
+		name := strings.ToUpper(t.text)
+		switch {
+		case name == "EXISTS", name == "NOT":
+			p.next()
+			if name == "NOT" {
+				p.expectKeyword("EXISTS")
+			}
+			outer := p.aggs
+			p.aggs = nil // no aggregates in graph patterns
+			e := ExistsExpr{Pattern: p.parseGroupGraphPattern(), Not: name == "NOT"}
+			p.aggs = outer
+			return e, true
+		case aggregates[name]:
+			p.next()
+			return p.parseAggregate(t, name), true
+		}
+		arity, ok := builtins[name]
+		if !ok {
+			return nil, false
+		}
+		p.next()
+		args := p.parseArgs()
+		if len(args) < arity[0] || (arity[1] >= 0 && len(args) > arity[1]) {
+			p.errorf(t, "wrong number of arguments to %s: %d", name, len(args))
+		}
+		switch name {
+		case "URI":
+			name = "IRI"
+		case "ISURI":
+			name = "ISIRI"
+		case "BOUND":
+			if e, ok := args[0].(TermExpr); !ok || e.Term.Type() != TermVariable {
+				p.errorf(t, "argument to BOUND must be a variable")
+			}
+		}
+		return FuncExpr{Name: name, Args: args}, true
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		// Look ahead for '(', past the IRI, which is two tokens if it is
+		// a prefixed name.
+		toks := []token{p.next()}
+		if t.typ == tokenPrefixLabel {
+			toks = append(toks, p.next())
+		}
+		call := p.peek().typ == tokenCollectionStart
+		for i := len(toks) - 1; i >= 0; i-- {
+			p.backup(toks[i])
+		}
+		if !call {
+			return nil, false
+		}
+		iri := p.parseIRI(p.next())
+		return FuncExpr{IRI: iri, Args: p.parseArgs()}, true
+	}
+	return nil, false
+}
+
+// parseArgs parses the arguments of a function call.
+func (p *sparqlParser) parseArgs() []Expr {
+	return p.parseExpressionList()
+}
+
+// parseAggregate parses an aggregate call, after the name, and returns the
+// hidden variable it is bound to.
+func (p *sparqlParser) parseAggregate(t token, name string) Expr {
+	if p.aggs == nil {
+		p.errorf(t, "aggregate %s not allowed here", name)
+	}
+	outer := p.aggs
+	p.aggs = nil // aggregates can't be nested
+	a := Aggregation{Name: name}
+	p.expect(tokenCollectionStart, "'('")
+	a.Distinct = p.acceptKeyword("DISTINCT")
+	if name == "COUNT" && p.acceptOp("*") {
+		// COUNT(*)
+	} else {
+		a.Arg = p.parseExpression()
+	}
+	if name == "GROUP_CONCAT" {
+		a.Separator = " "
+		if p.accept(tokenSemicolon) {
+			p.expectKeyword("SEPARATOR")
+			p.expectOp("=")
+			st := p.next()
+			if st.typ != tokenLiteral && st.typ != tokenLiteral3 {
+				p.unexpected(st, "separator string")
+			}
+			a.Separator = st.text
+		}
+	}
+	p.expect(tokenCollectionEnd, "')'")
+	p.aggs = outer
+
+	// Identical aggregates share the variable.
+	for _, x := range *p.aggs {
+		if x.Name == a.Name && x.Distinct == a.Distinct && x.Separator == a.Separator &&
+			fmt.Sprint(x.Arg) == fmt.Sprint(a.Arg) {
+			return TermExpr{Term: x.Var}
+		}
+	}
+	a.Var = Var(fmt.Sprintf(".agg%d", len(*p.aggs)))
+	*p.aggs = append(*p.aggs, a)
+	return TermExpr{Term: a.Var}
+}
@@ -0,0 +1,113 @@
+package rdf
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		vars  string
+		want  string
+	}{
+		{
+			`PREFIX : <http://e/> SELECT * WHERE { ?s :p ?o }`,
+			"[s o]",
+			`(project (?s ?o) (bgp (triple ?s <http://e/p> ?o)))`,
+		},
+		{
+			`PREFIX : <http://e/>
+SELECT ?s (COUNT(DISTINCT ?o) AS ?n)
+WHERE {
+	?s :p/:q ?o ; ^:r ?z .
+	OPTIONAL { ?o :p* ?y FILTER(?y > 1) }
+	FILTER(?o != -1 && ?z -2 = 3)
+}
+GROUP BY ?s HAVING (COUNT(DISTINCT ?o) > 1)
+ORDER BY DESC(?n) LIMIT 5 OFFSET 2`,
+			"[s n]",
+			`(slice 2 5 (project (?s ?n) (order ((desc ?n)) (extend ((?n ?.agg0)) (filter (> ?.agg0 1) (group (?s) ((?.agg0 (count distinct ?o))) (filter (&& (!= ?o -1) (= (- ?z 2) 3)) (leftjoin (bgp (triple ?s <http://e/p> ?.1) (triple ?.1 <http://e/q> ?o) (triple ?z <http://e/r> ?s)) (path ?o (path* <http://e/p>) ?y) (> ?y 1)))))))))`,
+		},
+		{
+			`PREFIX : <http://e/>
+CONSTRUCT { ?s :q ?o }
+WHERE {
+	{ ?s :p ?o } UNION { ?s :q ?o }
+	MINUS { ?s a :C }
+	BIND(STR(?o) AS ?x)
+	VALUES (?s ?o) { (:a 1) (UNDEF "x"@en) }
+}`,
+			"[]",
+			`(join (extend ((?x (str ?o))) (minus (union (bgp (triple ?s <http://e/p> ?o)) (bgp (triple ?s <http://e/q> ?o))) (bgp (triple ?s <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://e/C>)))) (table (vars ?s ?o) (row [?s <http://e/a>] [?o 1]) (row [?o "x"@en])))`,
+		},
+		{
+			`PREFIX : <http://e/>
+ASK {
+	GRAPH ?g { ?s ?p ( 1 2 ) }
+	FILTER NOT EXISTS { ?s !(:p|^:q) ?o }
+	FILTER(?s IN (<a>, <b>))
+	FILTER(<fn>(?s, 2))
+}`,
+			"[]",
+			`(filter (&& (&& (notexists (path ?s (notoneof <http://e/p> (reverse <http://e/q>)) ?o)) (in ?s <a> <b>)) (<fn> ?s 2)) (graph ?g (bgp (triple ?.1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> 1) (triple ?.1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> ?.2) (triple ?.2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> 2) (triple ?.2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil>) (triple ?s ?p ?.1))))`,
+		},
+		{
+			`SELECT ?x WHERE { { SELECT ?x (MAX(?y) AS ?m) { ?x <p> ?y } GROUP BY ?x } } VALUES ?x { <a> }`,
+			"[x]",
+			`(project (?x) (join (project (?x ?m) (extend ((?m ?.agg0)) (group (?x) ((?.agg0 (max ?y))) (bgp (triple ?x <p> ?y))))) (table (vars ?x) (row [?x <a>]))))`,
+		},
+		{
+			`DESCRIBE * WHERE { ?x <p> ?y }`,
+			"[]",
+			`(bgp (triple ?x <p> ?y))`,
+		},
+		{
+			`SELECT ?x WHERE { ?x <p> ?y ; }`,
+			"[x]",
+			`(project (?x) (bgp (triple ?x <p> ?y)))`,
+		},
+		{
+			`PREFIX : <http://e/> SELECT (GROUP_CONCAT(?x; SEPARATOR=", ") AS ?c) { ?x :p "a"^^:t }`,
+			"[c]",
+			`(project (?c) (extend ((?c ?.agg0)) (group () ((?.agg0 (group_concat (separator ", ") ?x))) (bgp (triple ?x <http://e/p> "a"^^<http://e/t>)))))`,
+		},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if got := fmt.Sprint(q.Vars); got != tt.vars {
+			t.Errorf("ParseQuery(%q) vars => %s, want %s", tt.query, got, tt.vars)
+		}
+		if got := q.Algebra.String(); got != tt.want {
+			t.Errorf("ParseQuery(%q) =>\n%s\nwant:\n%s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT ?y WHERE { ?x <p> ?y } GROUP BY ?x`, "1:8: variable ?y in SELECT is not a group key"},
+		{`SELECT ?x WHERE { ?x <p> ?y FILTER(?y < 3 }`, `1:42: unexpected "}", expected ')'`},
+		{`SELECT ?x WHERE { ?x ex:p ?y }`, "1:21: missing namespace for prefix: 'ex'"},
+		{`SELECT ?x WHERE { ?x <p> ?y FILTER(FOO(?y)) }`, "1:35: unknown function: FOO"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil {
+			t.Errorf("ParseQuery(%q) succeeded, want error %q", tt.query, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("ParseQuery(%q) error => %q, want %q", tt.query, err.Error(), tt.want)
+		}
+	}
+}
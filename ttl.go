@@ -18,6 +18,9 @@ type ttlDecoder struct {
 	tokens    [3]token          // 3 token lookahead
 	peekCount int               // number of tokens peeked at (position in tokens lookahead array)
 	current   ctxTriple         // the current triple beeing parsed
+	validate  bool              // validate literals
+	star      bool              // RDF-star mode
+	reifier   Subject           // reifier given with '~' for the current triple, if any
 
 	// ctxStack keeps track of current and parent triple contexts,
 	// needed for parsing recursive structures (list/collections).
@@ -46,8 +49,20 @@ func (d *ttlDecoder) SetOption(o ParseOption, v interface{}) error {
 			return fmt.Errorf("ParseOption \"Base\" must be an IRI.")
 		}
 		d.base = iri
+	case ValidateLiterals:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"ValidateLiterals\" must be a bool.")
+		}
+		d.validate = b
+	case RDFStar:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("ParseOption \"RDFStar\" must be a bool.")
+		}
+		d.star = b
 	default:
-		return fmt.Errorf("RDF/XML decoder doesn't support option: %v", o)
+		return fmt.Errorf("Turtle decoder doesn't support option: %v", o)
 	}
 	return nil
 }
@@ -107,27 +122,27 @@ func parseStart(d *ttlDecoder) parseFn {
 		tok := d.expectAs("prefix IRI", tokenIRIAbs, tokenIRIRel)
 		if tok.typ == tokenIRIRel {
 			// Resolve against document base IRI
-			d.ns[label.text] = d.base.str + tok.text
+			d.ns[label.text] = resolveIRI(d.base.str, tok.text)
 		} else {
 			d.ns[label.text] = tok.text
 		}
 		d.expect1As("directive trailing dot", tokenDot)
 	case tokenSparqlPrefix:
 		label := d.expect1As("prefix label", tokenPrefixLabel)
-		uri := d.expect1As("prefix IRI", tokenIRIAbs)
-		d.ns[label.text] = uri.text
+		uri := d.expectAs("prefix IRI", tokenIRIAbs, tokenIRIRel)
+		d.ns[label.text] = resolveIRI(d.base.str, uri.text)
 	case tokenBase:
 		tok := d.expectAs("base IRI", tokenIRIAbs, tokenIRIRel)
 		if tok.typ == tokenIRIRel {
 			// Resolve against document base IRI
-			d.base.str = d.base.str + tok.text
+			d.base.str = resolveIRI(d.base.str, tok.text)
 		} else {
 			d.base.str = tok.text
 		}
 		d.expect1As("directive trailing dot", tokenDot)
 	case tokenSparqlBase:
-		uri := d.expect1As("base IRI", tokenIRIAbs)
-		d.base.str = uri.text
+		uri := d.expectAs("base IRI", tokenIRIAbs, tokenIRIRel)
+		d.base.str = resolveIRI(d.base.str, uri.text)
 	case tokenEOF:
 		return nil
 	default:
@@ -192,6 +207,35 @@ func parseEnd(d *ttlDecoder) parseFn {
 		}
 		// Collection was object, need to check for more closing collection.
 		return parseEnd
+	case tokenTilde:
+		// Reifier of the current triple
+		d.reifier = d.parseReifier()
+		d.emitReifies(d.reifier, d.current.Triple)
+		return parseEnd
+	case tokenAnnotationStart:
+		// Annotation of the current triple. The annotation is a predicate-object
+		// list, with the reifier (or in RDF-star mode, the triple term) as subject.
+		subj := d.reifier
+		d.reifier = nil
+		if subj == nil {
+			if d.star {
+				subj = TripleTerm{Triple: d.current.Triple}
+			} else {
+				subj = d.newBlank()
+				d.emitReifies(subj, d.current.Triple)
+			}
+		}
+		d.pushContext()
+		d.current.Subj = subj
+		d.current.Pred = nil
+		d.current.Obj = nil
+		d.current.Ctx = ctxAnnotation
+		d.pushContext()
+		return nil
+	case tokenAnnotationEnd:
+		// Restore the annotated triple
+		d.popContext()
+		return parseEnd
 	case tokenDot:
 		if d.current.Ctx == ctxColl {
 			return parseEnd
@@ -237,7 +281,7 @@ func parseSubject(d *ttlDecoder) parseFn {
 	case tokenIRIAbs:
 		d.current.Subj = IRI{str: tok.text}
 	case tokenIRIRel:
-		d.current.Subj = IRI{str: d.base.str + tok.text}
+		d.current.Subj = IRI{str: resolveIRI(d.base.str, tok.text)}
 	case tokenBNode:
 		d.current.Subj = Blank{id: tok.text}
 	case tokenAnonBNode:
@@ -268,6 +312,8 @@ func parseSubject(d *ttlDecoder) parseFn {
 		d.current.Pred = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"}
 		d.current.Ctx = ctxColl
 		return parseObject
+	case tokenReifiedTripleStart, tokenTripleTermStart:
+		d.current.Subj = d.parseTripleTermAsSubject(tok)
 	case tokenError:
 		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
 	default:
@@ -286,7 +332,7 @@ func parsePredicate(d *ttlDecoder) parseFn {
 	case tokenIRIAbs:
 		d.current.Pred = IRI{str: tok.text}
 	case tokenIRIRel:
-		d.current.Pred = IRI{str: d.base.str + tok.text}
+		d.current.Pred = IRI{str: resolveIRI(d.base.str, tok.text)}
 	case tokenRDFType:
 		d.current.Pred = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"}
 	case tokenPrefixLabel:
@@ -311,61 +357,14 @@ func parseObject(d *ttlDecoder) parseFn {
 	case tokenIRIAbs:
 		d.current.Obj = IRI{str: tok.text}
 	case tokenIRIRel:
-		d.current.Obj = IRI{str: d.base.str + tok.text}
+		d.current.Obj = IRI{str: resolveIRI(d.base.str, tok.text)}
 	case tokenBNode:
 		d.current.Obj = Blank{id: tok.text}
 	case tokenAnonBNode:
 		d.bnodeN++
 		d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
-	case tokenLiteral, tokenLiteral3:
-		val := tok.text
-		l := Literal{
-			str:      val,
-			DataType: xsdString,
-		}
-		p := d.peek()
-		switch p.typ {
-		case tokenLangMarker:
-			d.next() // consume peeked token
-			tok = d.expect1As("literal language", tokenLang)
-			l.lang = tok.text
-			l.DataType = rdfLangString
-		case tokenDataTypeMarker:
-			d.next() // consume peeked token
-			tok = d.expectAs("literal datatype", tokenIRIAbs, tokenPrefixLabel)
-			switch tok.typ {
-			case tokenIRIAbs:
-				l.DataType = IRI{str: tok.text}
-			case tokenPrefixLabel:
-				ns, ok := d.ns[tok.text]
-				if !ok {
-					d.errorf("missing namespace for prefix: '%s'", tok.text)
-				}
-				tok2 := d.expect1As("IRI suffix", tokenIRISuffix)
-				l.DataType = IRI{str: ns + tok2.text}
-			}
-		}
-		d.current.Obj = l
-	case tokenLiteralDouble:
-		d.current.Obj = Literal{
-			str:      tok.text,
-			DataType: xsdDouble,
-		}
-	case tokenLiteralDecimal:
-		d.current.Obj = Literal{
-			str:      tok.text,
-			DataType: xsdDecimal,
-		}
-	case tokenLiteralInteger:
-		d.current.Obj = Literal{
-			str:      tok.text,
-			DataType: xsdInteger,
-		}
-	case tokenLiteralBoolean:
-		d.current.Obj = Literal{
-			str:      tok.text,
-			DataType: xsdBoolean,
-		}
+	case tokenLiteral, tokenLiteral3, tokenLiteralDouble, tokenLiteralDecimal, tokenLiteralInteger, tokenLiteralBoolean:
+		d.current.Obj = d.parseLiteral(tok)
 	case tokenPrefixLabel:
 		ns, ok := d.ns[tok.text]
 		if !ok {
@@ -376,10 +375,9 @@ func parseObject(d *ttlDecoder) parseFn {
 	case tokenPropertyListStart:
 		// Blank node is object of current triple
 		// Save current context, to be restored after the list ends
-		d.pushContext()
-
 		d.bnodeN++
 		d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+		d.pushContext()
 		d.emit()
 
 		// Set blank node as subject of the next triple. Push to stack and return.
@@ -398,10 +396,9 @@ func parseObject(d *ttlDecoder) parseFn {
 		}
 		// Blank node is object of current triple
 		// Save current context, to be restored after the collection ends
-		d.pushContext()
-
 		d.bnodeN++
 		d.current.Obj = Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+		d.pushContext()
 		d.emit()
 		d.current.Subj = d.current.Obj.(Subject)
 		d.current.Pred = IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"}
@@ -409,6 +406,8 @@ func parseObject(d *ttlDecoder) parseFn {
 		d.current.Ctx = ctxColl
 		d.pushContext()
 		return nil
+	case tokenReifiedTripleStart, tokenTripleTermStart:
+		d.current.Obj = d.parseTripleTermAsObject(tok)
 	case tokenError:
 		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
 	default:
@@ -416,11 +415,211 @@ func parseObject(d *ttlDecoder) parseFn {
 	}
 
 	// We now have a full tripe, emit it.
+	d.reifier = nil
 	d.emit()
 
 	return parseEnd
 }
 
+// parseLiteral parses a literal, starting with the given token.
+func (d *ttlDecoder) parseLiteral(tok token) Literal {
+	switch tok.typ {
+	case tokenLiteralDouble:
+		return Literal{str: tok.text, DataType: xsdDouble}
+	case tokenLiteralDecimal:
+		return Literal{str: tok.text, DataType: xsdDecimal}
+	case tokenLiteralInteger:
+		return Literal{str: tok.text, DataType: xsdInteger}
+	case tokenLiteralBoolean:
+		return Literal{str: tok.text, DataType: xsdBoolean}
+	}
+	val := tok.text
+	line, col := tok.line, tok.col
+	l := Literal{
+		str:      val,
+		DataType: xsdString,
+	}
+	p := d.peek()
+	switch p.typ {
+	case tokenLangMarker:
+		d.next() // consume peeked token
+		tok = d.expect1As("literal language", tokenLang)
+		l.lang = tok.text
+		l.DataType = rdfLangString
+		if d.peek().typ == tokenDir {
+			l.dir = d.next().text
+			l.DataType = rdfDirLangString
+		}
+	case tokenDataTypeMarker:
+		d.next() // consume peeked token
+		tok = d.expectAs("literal datatype", tokenIRIAbs, tokenPrefixLabel)
+		switch tok.typ {
+		case tokenIRIAbs:
+			l.DataType = IRI{str: tok.text}
+		case tokenPrefixLabel:
+			ns, ok := d.ns[tok.text]
+			if !ok {
+				d.errorf("missing namespace for prefix: '%s'", tok.text)
+			}
+			tok2 := d.expect1As("IRI suffix", tokenIRISuffix)
+			l.DataType = IRI{str: ns + tok2.text}
+		}
+		if d.validate {
+			if err := checkLiteral(l, line, col); err != nil {
+				panic(err)
+			}
+		}
+		l = l.canonicalMarkup()
+	}
+	return l
+}
+
+// parseIRI parses an IRI, starting with the given token, which must be
+// an absolute or relative IRI, or a prefix label.
+func (d *ttlDecoder) parseIRI(tok token) IRI {
+	switch tok.typ {
+	case tokenIRIAbs:
+		return IRI{str: tok.text}
+	case tokenIRIRel:
+		return IRI{str: resolveIRI(d.base.str, tok.text)}
+	default:
+		ns, ok := d.ns[tok.text]
+		if !ok {
+			d.errorf("missing namespace for prefix: '%s'", tok.text)
+		}
+		suf := d.expect1As("IRI suffix", tokenIRISuffix)
+		return IRI{str: ns + suf.text}
+	}
+}
+
+// newBlank returns a new, unique blank node.
+func (d *ttlDecoder) newBlank() Blank {
+	d.bnodeN++
+	return Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+}
+
+// parseReifier parses the reifier following '~'. If no reifier is given,
+// a new blank node is returned.
+func (d *ttlDecoder) parseReifier() Subject {
+	switch d.peek().typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return d.parseIRI(d.next())
+	case tokenBNode:
+		return Blank{id: d.next().text}
+	}
+	return d.newBlank()
+}
+
+// emitReifies emits the triple: reifier rdf:reifies <<( t )>>
+func (d *ttlDecoder) emitReifies(reifier Subject, t Triple) {
+	d.triples = append(d.triples, Triple{Subj: reifier, Pred: rdfReifies, Obj: TripleTerm{Triple: t}})
+}
+
+// parseTripleTermAsSubject parses a reified triple or triple term in subject position.
+func (d *ttlDecoder) parseTripleTermAsSubject(open token) Subject {
+	if open.typ == tokenTripleTermStart && !d.star {
+		d.errorf("%d:%d: triple term not allowed as subject", open.line, open.col)
+	}
+	return d.parseTripleTermAsObject(open).(Subject)
+}
+
+// parseTripleTermAsObject parses a reified triple or triple term in object position.
+//
+// A triple term <<( s p o )>> is returned as a TripleTerm. A reified triple
+// << s p o ~ reifier >> is returned as its reifier, after emitting the triple
+// reifier rdf:reifies <<( s p o )>>. In RDF-star mode, the reified triple is
+// returned as a TripleTerm.
+func (d *ttlDecoder) parseTripleTermAsObject(open token) Object {
+	var t Triple
+	t.Subj = d.parseTripleTermSubject(open)
+	tok := d.next()
+	switch tok.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		t.Pred = d.parseIRI(tok)
+	case tokenRDFType:
+		t.Pred = rdfType
+	default:
+		d.unexpected(tok, "predicate")
+	}
+	t.Obj = d.parseTripleTermObject(open)
+
+	if open.typ == tokenTripleTermStart {
+		d.expect1As("triple term end", tokenTripleTermEnd)
+		return TripleTerm{Triple: t}
+	}
+	var reifier Subject
+	if d.peek().typ == tokenTilde {
+		if d.star {
+			d.errorf("%d:%d: reifier not allowed in RDF-star mode", open.line, open.col)
+		}
+		d.next()
+		reifier = d.parseReifier()
+	}
+	d.expect1As("reified triple end", tokenReifiedTripleEnd)
+	if d.star {
+		return TripleTerm{Triple: t}
+	}
+	if reifier == nil {
+		reifier = d.newBlank()
+	}
+	d.emitReifies(reifier, t)
+	return reifier.(Object)
+}
+
+// parseTripleTermSubject parses the subject of a reified triple or triple term,
+// opened by the given token.
+func (d *ttlDecoder) parseTripleTermSubject(open token) Subject {
+	tok := d.next()
+	d.checkNested(open, tok)
+	switch tok.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return d.parseIRI(tok)
+	case tokenBNode:
+		return Blank{id: tok.text}
+	case tokenAnonBNode:
+		return d.newBlank()
+	case tokenReifiedTripleStart, tokenTripleTermStart:
+		return d.parseTripleTermAsSubject(tok)
+	case tokenError:
+		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
+	default:
+		d.unexpected(tok, "subject")
+	}
+	return nil
+}
+
+// parseTripleTermObject parses the object of a reified triple or triple term,
+// opened by the given token.
+func (d *ttlDecoder) parseTripleTermObject(open token) Object {
+	tok := d.next()
+	d.checkNested(open, tok)
+	switch tok.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return d.parseIRI(tok)
+	case tokenBNode:
+		return Blank{id: tok.text}
+	case tokenAnonBNode:
+		return d.newBlank()
+	case tokenLiteral, tokenLiteral3, tokenLiteralDouble, tokenLiteralDecimal, tokenLiteralInteger, tokenLiteralBoolean:
+		return d.parseLiteral(tok)
+	case tokenReifiedTripleStart, tokenTripleTermStart:
+		return d.parseTripleTermAsObject(tok)
+	case tokenError:
+		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
+	default:
+		d.unexpected(tok, "object")
+	}
+	return nil
+}
+
+// checkNested fails if tok opens a reified triple inside a triple term, which
+// is not allowed in RDF 1.2. In RDF-star mode, both denote triple terms.
+func (d *ttlDecoder) checkNested(open, tok token) {
+	if open.typ == tokenTripleTermStart && tok.typ == tokenReifiedTripleStart && !d.star {
+		d.errorf("%d:%d: reified triple not allowed in triple term", tok.line, tok.col)
+	}
+}
+
 // pushContext pushes the current triple and context to the context stack.
 func (d *ttlDecoder) pushContext() {
 	d.ctxStack = append(d.ctxStack, d.current)
@@ -554,12 +753,10 @@ func parseLiteral(val, datatype string) (interface{}, error) {
 	case xsdString.str:
 		return val, nil
 	case xsdInteger.str:
-		i, err := strconv.Atoi(val)
-		if err != nil {
-			return nil, err
-		}
-		return i, nil
-	case xsdFloat.str, xsdDouble.str, xsdDecimal.str:
+		return parseInteger(val)
+	case xsdDecimal.str:
+		return parseDecimal(val)
+	case xsdFloat.str, xsdDouble.str:
 		f, err := strconv.ParseFloat(val, 64)
 		if err != nil {
 			return nil, err
@@ -603,6 +800,7 @@ const (
 	ctxTop context = iota
 	ctxColl
 	ctxList
+	ctxAnnotation
 	ctxBag // TODO ctxColl?  why need to differentiate?
 	ctxSeq // ctxColl?
 )
@@ -616,6 +814,8 @@ func (ctx context) String() string {
 		return "list"
 	case ctxColl:
 		return "collection"
+	case ctxAnnotation:
+		return "annotation"
 
 	default:
 		return "unknown context"
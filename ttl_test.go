@@ -4152,7 +4152,7 @@ x @a Item .`, "unrecognized directive", []Triple{}},
 
 	{`# Bad lang tag
 <http://www.w3.org/2013/TurtleTests/s> <http://www.w3.org/2013/TurtleTests/p> "string"@1 .`,
-		"bad literal: invalid language tag", []Triple{}},
+		"bad literal: invalid language tag: invalid primary language subtag: \"1\"", []Triple{}},
 
 	//<#turtle-syntax-bad-esc-01> rdf:type rdft:TestTurtleNegativeSyntax ;
 	//   mf:name    "turtle-syntax-bad-esc-01" ;
@@ -4207,7 +4207,7 @@ x @a Item .`, "unrecognized directive", []Triple{}},
 
 	{`# ~ must be escaped.
 @prefix : <http://www.w3.org/2013/TurtleTests/> .
-:a~b :p :o .`, "unexpected character: '~'", []Triple{}},
+:a~b :p :o .`, "unexpected Reifier marker as predicate", []Triple{}},
 
 	//<#turtle-syntax-bad-pname-02> rdf:type rdft:TestTurtleNegativeSyntax ;
 	//   mf:name    "turtle-syntax-bad-pname-02" ;
@@ -5712,3 +5712,100 @@ _:b.0 :p :o . # Contains dot, ends with digit`, "", []Triple{
 		},
 	}},
 }
+
+func TestTTLTripleTerms(t *testing.T) {
+	s := IRI{str: "http://example/s"}
+	p := IRI{str: "http://example/p"}
+	o := IRI{str: "http://example/o"}
+	q := IRI{str: "http://example/q"}
+	r := IRI{str: "http://example/r"}
+	one := Literal{str: "1", DataType: xsdInteger}
+	spo := TripleTerm{Triple: Triple{Subj: s, Pred: p, Obj: o}}
+	b1 := Blank{id: "_:b1"}
+
+	tests := []struct {
+		input string
+		star  bool
+		want  []Triple
+	}{
+		{`PREFIX : <http://example/>
+:s :p <<( :s :p :o )>> .`, false, []Triple{
+			{Subj: s, Pred: p, Obj: spo},
+		}},
+		{`PREFIX : <http://example/>
+<< :s :p :o >> :q 1 .`, false, []Triple{
+			{Subj: b1, Pred: rdfReifies, Obj: spo},
+			{Subj: b1, Pred: q, Obj: one},
+		}},
+		{`PREFIX : <http://example/>
+:x :q << :s :p :o ~ :r >> .`, false, []Triple{
+			{Subj: r, Pred: rdfReifies, Obj: spo},
+			{Subj: IRI{str: "http://example/x"}, Pred: q, Obj: r},
+		}},
+		{`PREFIX : <http://example/>
+:s :p :o {| :q 1 |} .`, false, []Triple{
+			{Subj: s, Pred: p, Obj: o},
+			{Subj: b1, Pred: rdfReifies, Obj: spo},
+			{Subj: b1, Pred: q, Obj: one},
+		}},
+		{`PREFIX : <http://example/>
+:s :p :o ~:r {| :q 1 ; :q 2 |}, 3 .`, false, []Triple{
+			{Subj: s, Pred: p, Obj: o},
+			{Subj: r, Pred: rdfReifies, Obj: spo},
+			{Subj: r, Pred: q, Obj: one},
+			{Subj: r, Pred: q, Obj: Literal{str: "2", DataType: xsdInteger}},
+			{Subj: s, Pred: p, Obj: Literal{str: "3", DataType: xsdInteger}},
+		}},
+		{`PREFIX : <http://example/>
+<< :s :p :o >> :q << <<( :s :p :o )>> :r "x"@en >> .`, true, []Triple{
+			{Subj: spo, Pred: q, Obj: TripleTerm{Triple: Triple{Subj: spo, Pred: r, Obj: Literal{str: "x", lang: "en", DataType: rdfLangString}}}},
+		}},
+		{`PREFIX : <http://example/>
+:s :p :o {| :q 1 |} .`, true, []Triple{
+			{Subj: s, Pred: p, Obj: o},
+			{Subj: spo, Pred: q, Obj: one},
+		}},
+	}
+
+	for _, tt := range tests {
+		dec := NewTripleDecoder(bytes.NewBufferString(tt.input), Turtle)
+		dec.SetOption(RDFStar, tt.star)
+		ts, err := dec.DecodeAll()
+		if err != nil {
+			t.Errorf("ParseTTL(%s) failed with %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(ts, tt.want) {
+			t.Errorf("ParseTTL(%s) =>\n%v\nwant:\n%v", tt.input, ts, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		input string
+		star  bool
+		want  string
+	}{
+		{`<<( <http://example/s> <http://example/p> <http://example/o> )>> <http://example/q> 1 .`, false, "triple term not allowed as subject"},
+		{`<< <http://example/s> <http://example/p> <http://example/o> ~ <http://example/r> >> <http://example/q> 1 .`, true, "reifier not allowed in RDF-star mode"},
+		{`<http://example/a> <http://example/q> <<( << <http://example/s> <http://example/p> <http://example/o> >> <http://example/p> 1 )>> .`, false, "reified triple not allowed in triple term"},
+		{`<http://example/a> <http://example/q> <<( <http://example/s> <http://example/p> << <http://example/s> <http://example/p> <http://example/o> >> )>> .`, false, "reified triple not allowed in triple term"},
+	}
+	for _, tt := range errTests {
+		dec := NewTripleDecoder(bytes.NewBufferString(tt.input), Turtle)
+		dec.SetOption(RDFStar, tt.star)
+		_, err := dec.DecodeAll()
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("ParseTTL(%s) => %v; want %q", tt.input, err, tt.want)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := NewTripleEncoder(&b, Turtle)
+	enc.Encode(Triple{Subj: s, Pred: p, Obj: spo})
+	enc.Close()
+	dec := NewTripleDecoder(&b, Turtle)
+	ts, err := dec.DecodeAll()
+	if err != nil || len(ts) != 1 || !TriplesEqual(ts[0], Triple{Subj: s, Pred: p, Obj: spo}) {
+		t.Errorf("Decode/Encode roundtrip of triple term => %v, %v", ts, err)
+	}
+}
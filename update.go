@@ -0,0 +1,638 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SPARQL 1.1 Update, as defined in http://www.w3.org/TR/sparql11-update/
+
+// Update is a parsed SPARQL Update request; a sequence of operations.
+type Update struct {
+	Ops []UpdateOp
+}
+
+// UpdateOp is an update operation: one of InsertData, DeleteData, Modify,
+// Load, Clear, Drop, Create or Transfer.
+type UpdateOp interface {
+	updateOp()
+}
+
+// QuadPattern is a triple pattern in a graph, as found in update templates.
+// Graph is nil for the default graph, or an IRI or a Var.
+type QuadPattern struct {
+	TriplePattern
+	Graph Term
+}
+
+// InsertData is INSERT DATA; it adds the quads, which don't have variables.
+// Blank nodes in the quads are replaced with fresh blank nodes.
+type InsertData struct {
+	Quads []QuadPattern
+}
+
+// DeleteData is DELETE DATA; it removes the quads, which don't have variables
+// nor blank nodes.
+type DeleteData struct {
+	Quads []QuadPattern
+}
+
+// Modify is DELETE/INSERT ... WHERE, and DELETE WHERE. For each solution of
+// the pattern, the Delete templates are instantiated and removed, then the
+// Insert templates are instantiated and added.
+type Modify struct {
+	// With is the graph of WITH, or nil. It is the default graph of the
+	// templates, and of the pattern if there are no USING clauses.
+	With Context
+
+	Delete []QuadPattern
+	Insert []QuadPattern
+
+	// Using and UsingNamed are the IRIs of the USING clauses, which give
+	// the dataset of the pattern.
+	Using      []IRI
+	UsingNamed []IRI
+
+	Where Op
+}
+
+// Load is LOAD; it adds the triples of the document at Source to the graph
+// Into, or the default graph if it is nil.
+type Load struct {
+	Silent bool
+	Source IRI
+	Into   Context
+}
+
+// GraphScope is the scope of a CLEAR or DROP operation.
+type GraphScope int
+
+// Graph scopes of CLEAR and DROP.
+const (
+	ScopeGraph   GraphScope = iota // a named graph
+	ScopeDefault                   // the default graph
+	ScopeNamed                     // all named graphs
+	ScopeAll                       // all graphs
+)
+
+// Clear is CLEAR; it removes all the triples of the graphs in scope.
+type Clear struct {
+	Silent bool
+	Scope  GraphScope
+	Graph  IRI // if Scope is ScopeGraph
+}
+
+// Drop is DROP; it removes the graphs in scope. Dropping the default graph
+// clears it.
+type Drop struct {
+	Silent bool
+	Scope  GraphScope
+	Graph  IRI // if Scope is ScopeGraph
+}
+
+// Create is CREATE; it creates an empty named graph.
+type Create struct {
+	Silent bool
+	Graph  IRI
+}
+
+// Transfer is ADD, COPY or MOVE, from a graph to another; nil is the
+// default graph. ADD adds the triples of From to To, COPY replaces
+// the triples of To with those of From, and MOVE also drops From.
+type Transfer struct {
+	Op       string // "ADD", "COPY" or "MOVE"
+	Silent   bool
+	From, To Context
+}
+
+func (InsertData) updateOp() {}
+func (DeleteData) updateOp() {}
+func (Modify) updateOp()     {}
+func (Load) updateOp()       {}
+func (Clear) updateOp()      {}
+func (Drop) updateOp()       {}
+func (Create) updateOp()     {}
+func (Transfer) updateOp()   {}
+
+// ParseUpdate parses a SPARQL 1.1 Update request. Syntax errors are reported
+// with line and column.
+func ParseUpdate(update string) (u *Update, err error) {
+	p := newSparqlParser(update)
+	defer p.recover(&err)
+	u = p.parseUpdate()
+	return u, nil
+}
+
+// parseUpdate parses a complete update request.
+func (p *sparqlParser) parseUpdate() *Update {
+	u := &Update{}
+	for {
+		p.parsePrologue()
+		if p.peek().typ == tokenEOF {
+			break
+		}
+		u.Ops = append(u.Ops, p.parseUpdateOp())
+		if !p.accept(tokenSemicolon) {
+			break
+		}
+	}
+	p.expect(tokenEOF, "end of update")
+	return u
+}
+
+// parseUpdateOp parses an update operation.
+func (p *sparqlParser) parseUpdateOp() UpdateOp {
+	t := p.next()
+	switch {
+	case isKeyword(t, "LOAD"):
+		op := Load{Silent: p.acceptKeyword("SILENT")}
+		op.Source = p.parseIRI(p.next())
+		if p.acceptKeyword("INTO") {
+			p.expectKeyword("GRAPH")
+			op.Into = p.parseIRI(p.next())
+		}
+		return op
+	case isKeyword(t, "CLEAR"), isKeyword(t, "DROP"):
+		silent := p.acceptKeyword("SILENT")
+		scope, graph := p.parseGraphRefAll()
+		if isKeyword(t, "CLEAR") {
+			return Clear{Silent: silent, Scope: scope, Graph: graph}
+		}
+		return Drop{Silent: silent, Scope: scope, Graph: graph}
+	case isKeyword(t, "CREATE"):
+		silent := p.acceptKeyword("SILENT")
+		p.expectKeyword("GRAPH")
+		return Create{Silent: silent, Graph: p.parseIRI(p.next())}
+	case isKeyword(t, "ADD"), isKeyword(t, "COPY"), isKeyword(t, "MOVE"):
+		op := Transfer{Op: strings.ToUpper(t.text), Silent: p.acceptKeyword("SILENT")}
+		op.From = p.parseGraphOrDefault()
+		p.expectKeyword("TO")
+		op.To = p.parseGraphOrDefault()
+		return op
+	case isKeyword(t, "INSERT"):
+		if p.acceptKeyword("DATA") {
+			qps := p.parseQuads()
+			p.checkQuads(t, qps, false, true)
+			return InsertData{Quads: qps}
+		}
+		p.backup(t)
+		return p.parseModify(nil)
+	case isKeyword(t, "DELETE"):
+		if p.acceptKeyword("DATA") {
+			qps := p.parseQuads()
+			p.checkQuads(t, qps, false, false)
+			return DeleteData{Quads: qps}
+		}
+		if p.acceptKeyword("WHERE") {
+			qps := p.parseQuads()
+			p.checkQuads(t, qps, true, false)
+			return Modify{Delete: qps, Where: quadsAlgebra(qps)}
+		}
+		p.backup(t)
+		return p.parseModify(nil)
+	case isKeyword(t, "WITH"):
+		return p.parseModify(p.parseIRI(p.next()))
+	}
+	p.unexpected(t, "update operation")
+	return nil
+}
+
+// parseGraphRefAll parses the graphs of CLEAR and DROP.
+func (p *sparqlParser) parseGraphRefAll() (GraphScope, IRI) {
+	switch {
+	case p.acceptKeyword("DEFAULT"):
+		return ScopeDefault, IRI{}
+	case p.acceptKeyword("NAMED"):
+		return ScopeNamed, IRI{}
+	case p.acceptKeyword("ALL"):
+		return ScopeAll, IRI{}
+	}
+	p.expectKeyword("GRAPH")
+	return ScopeGraph, p.parseIRI(p.next())
+}
+
+// parseGraphOrDefault parses the graphs of ADD, COPY and MOVE; it returns
+// nil for the default graph.
+func (p *sparqlParser) parseGraphOrDefault() Context {
+	if p.acceptKeyword("DEFAULT") {
+		return nil
+	}
+	p.acceptKeyword("GRAPH")
+	return p.parseIRI(p.next())
+}
+
+// parseModify parses DELETE/INSERT ... WHERE, after WITH, if any.
+func (p *sparqlParser) parseModify(with Context) Modify {
+	op := Modify{With: with}
+	t := p.peek()
+	del := p.acceptKeyword("DELETE")
+	if del {
+		op.Delete = p.parseQuads()
+		p.checkQuads(t, op.Delete, true, false)
+	}
+	if t := p.peek(); p.acceptKeyword("INSERT") {
+		op.Insert = p.parseQuads()
+		p.checkQuads(t, op.Insert, true, true)
+	} else if !del {
+		p.unexpected(p.peek(), "DELETE or INSERT")
+	}
+	for p.acceptKeyword("USING") {
+		if p.acceptKeyword("NAMED") {
+			op.UsingNamed = append(op.UsingNamed, p.parseIRI(p.next()))
+		} else {
+			op.Using = append(op.Using, p.parseIRI(p.next()))
+		}
+	}
+	p.expectKeyword("WHERE")
+	op.Where = p.parseGroupGraphPattern()
+	return op
+}
+
+// parseQuads parses quad data or a quad template: { triples GRAPH g { triples } ... }.
+// Blank nodes are kept as blank nodes.
+func (p *sparqlParser) parseQuads() []QuadPattern {
+	p.expect(tokenGroupStart, "'{'")
+	p.tokens = make(map[Term]token)
+	var qps []QuadPattern
+	add := func(tps []TriplePattern, graph Term) {
+		for _, tp := range tps {
+			qps = append(qps, QuadPattern{TriplePattern: tp, Graph: graph})
+		}
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.typ == tokenGroupEnd:
+			p.next()
+			return qps
+		case isKeyword(t, "GRAPH"):
+			p.next()
+			graph := p.parseVarOrIRI()
+			p.expect(tokenGroupStart, "'{'")
+			add(p.parseTemplate(), graph)
+			p.accept(tokenDot)
+		default:
+			p.template = true
+			elems := p.parseTriplesSameSubject(nil)
+			p.template = false
+			tps := make([]TriplePattern, len(elems))
+			for i, e := range elems {
+				tps[i] = e.(TriplePattern)
+			}
+			add(tps, nil)
+			if !p.accept(tokenDot) {
+				if t := p.peek(); t.typ != tokenGroupEnd && !isKeyword(t, "GRAPH") {
+					p.unexpected(t, "'.', GRAPH or '}'")
+				}
+			}
+		}
+	}
+}
+
+// checkQuads checks that the quads of the operation starting with the
+// token only have variables and blank nodes where allowed. Errors are
+// reported at the offending term.
+func (p *sparqlParser) checkQuads(t token, qps []QuadPattern, vars, blanks bool) {
+	tokens := p.tokens
+	p.tokens = nil
+	for _, qp := range qps {
+		for _, x := range []Term{qp.Subj, qp.Pred, qp.Obj, qp.Graph} {
+			if x == nil {
+				continue
+			}
+			at, ok := tokens[x]
+			if !ok {
+				at = t
+			}
+			switch x.Type() {
+			case TermVariable:
+				if !vars {
+					p.errorf(at, "variables not allowed in %s DATA", strings.ToUpper(t.text))
+				}
+			case TermBlank:
+				if !blanks {
+					p.errorf(at, "blank nodes not allowed in %s", strings.ToUpper(t.text))
+				}
+			}
+		}
+	}
+}
+
+// quadsAlgebra translates the quad pattern of DELETE WHERE to the algebra.
+func quadsAlgebra(qps []QuadPattern) Op {
+	var op Op = BGP{}
+	var def BGP
+	var names []Term
+	graphs := make(map[string]*BGP)
+	for _, qp := range qps {
+		if qp.Graph == nil {
+			def.Patterns = append(def.Patterns, qp.TriplePattern)
+			continue
+		}
+		key := termKey(qp.Graph)
+		if graphs[key] == nil {
+			graphs[key] = &BGP{}
+			names = append(names, qp.Graph)
+		}
+		graphs[key].Patterns = append(graphs[key].Patterns, qp.TriplePattern)
+	}
+	if len(def.Patterns) > 0 {
+		op = def
+	}
+	for _, name := range names {
+		op = join(op, GraphPattern{Name: name, Sub: *graphs[termKey(name)]})
+	}
+	return op
+}
+
+// Loader opens the document at the source IRI of a LOAD operation, and
+// returns its contents and serialization format.
+type Loader func(source IRI) (io.ReadCloser, Format, error)
+
+// UpdateResult reports the changes made by an update request.
+type UpdateResult struct {
+	Added   int // number of quads added
+	Removed int // number of quads removed
+}
+
+// Exec applies the update request to the dataset. The request is atomic:
+// if an operation fails, the changes made by the previous operations are
+// undone, and the error is returned. Operations which are SILENT never fail;
+// they are skipped instead.
+//
+// The load function is called to read the documents of LOAD operations;
+// it may be nil if the request has none.
+func (u *Update) Exec(ds *Dataset, load Loader) (UpdateResult, error) {
+	tx := &updateTx{ds: ds, load: load}
+	for _, op := range u.Ops {
+		if err := tx.exec(op); err != nil {
+			tx.rollback()
+			return UpdateResult{}, err
+		}
+	}
+	return tx.res, nil
+}
+
+// updateTx applies the operations of an update request, keeping a log
+// of the changes so they can be undone.
+type updateTx struct {
+	ds   *Dataset
+	load Loader
+	res  UpdateResult
+	undo []func()
+}
+
+// rollback undoes the changes, in reverse order.
+func (tx *updateTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+// add adds a quad, creating its graph if needed.
+func (tx *updateTx) add(q Quad) {
+	if q.Ctx != nil {
+		tx.create(q.Ctx)
+	}
+	if tx.ds.Add(q) {
+		tx.res.Added++
+		tx.undo = append(tx.undo, func() { tx.ds.Remove(q) })
+	}
+}
+
+// remove removes a quad.
+func (tx *updateTx) remove(q Quad) {
+	if tx.ds.Remove(q) {
+		tx.res.Removed++
+		tx.undo = append(tx.undo, func() { tx.ds.Add(q) })
+	}
+}
+
+// create creates a named graph, if it doesn't exist.
+func (tx *updateTx) create(name Context) {
+	if tx.ds.Graph(name) == nil {
+		tx.ds.CreateGraph(name)
+		tx.undo = append(tx.undo, func() { tx.ds.DropGraph(name) })
+	}
+}
+
+// clear removes the triples of a graph, where nil is the default graph.
+func (tx *updateTx) clear(name Context) {
+	g := tx.ds.def
+	if name != nil {
+		g = tx.ds.Graph(name)
+	}
+	for _, t := range g.Triples() {
+		tx.remove(Quad{Triple: t, Ctx: name})
+	}
+}
+
+// drop removes a named graph.
+func (tx *updateTx) drop(name Context) {
+	tx.clear(name)
+	tx.ds.DropGraph(name)
+	tx.undo = append(tx.undo, func() { tx.ds.CreateGraph(name) })
+}
+
+// exists checks that a graph exists; nil is the default graph, which always does.
+func (tx *updateTx) exists(name Context) error {
+	if name != nil && tx.ds.Graph(name) == nil {
+		return fmt.Errorf("graph %s does not exist", name.Serialize(NTriples))
+	}
+	return nil
+}
+
+// exec applies an operation.
+func (tx *updateTx) exec(op UpdateOp) error {
+	switch op := op.(type) {
+	case InsertData:
+		e := newEvaluator(tx.ds)
+		bnodes := make(map[string]Blank)
+		for _, qp := range op.Quads {
+			t, _ := e.instantiate(qp.TriplePattern, nil, bnodes)
+			tx.add(Quad{Triple: t, Ctx: graphContext(qp.Graph, nil)})
+		}
+	case DeleteData:
+		for _, qp := range op.Quads {
+			tx.remove(Quad{Triple: Triple{
+				Subj: qp.Subj.(Subject),
+				Pred: qp.Pred.(Predicate),
+				Obj:  qp.Obj.(Object),
+			}, Ctx: graphContext(qp.Graph, nil)})
+		}
+	case Modify:
+		return tx.modify(op)
+	case Load:
+		if err := tx.loadGraph(op); err != nil && !op.Silent {
+			return err
+		}
+	case Clear:
+		if err := tx.clearScope(op.Scope, op.Graph, false); err != nil && !op.Silent {
+			return err
+		}
+	case Drop:
+		if err := tx.clearScope(op.Scope, op.Graph, true); err != nil && !op.Silent {
+			return err
+		}
+	case Create:
+		if tx.ds.Graph(op.Graph) != nil {
+			if op.Silent {
+				return nil
+			}
+			return fmt.Errorf("graph %s already exists", op.Graph.Serialize(NTriples))
+		}
+		tx.create(op.Graph)
+	case Transfer:
+		if err := tx.exists(op.From); err != nil {
+			if op.Silent {
+				return nil
+			}
+			return err
+		}
+		tx.transfer(op)
+	default:
+		return fmt.Errorf("unknown update operation: %T", op)
+	}
+	return nil
+}
+
+// graphContext returns the graph of a quad pattern, whose variable, if any,
+// is substituted, where nil stands for the graph def.
+func graphContext(graph Term, def Context) Context {
+	if graph == nil {
+		return def
+	}
+	return graph.(Context)
+}
+
+// modify applies DELETE/INSERT ... WHERE.
+func (tx *updateTx) modify(op Modify) error {
+	ds := datasetView(tx.ds, op.Using, op.UsingNamed)
+	if op.With != nil && len(op.Using) == 0 && len(op.UsingNamed) == 0 {
+		g := tx.ds.Graph(op.With)
+		if g == nil {
+			g = NewGraph()
+		}
+		ds = &Dataset{def: g, named: tx.ds.named, names: tx.ds.names}
+	}
+	// All the solutions are found before the changes are made.
+	solutions := collectBindings(newEvaluator(ds).eval(op.Where, nil))
+
+	e := newEvaluator(tx.ds)
+	inst := func(qp QuadPattern, b Binding, bnodes map[string]Blank) (Quad, bool) {
+		t, ok := e.instantiate(qp.TriplePattern, b, bnodes)
+		if !ok {
+			return Quad{}, false
+		}
+		graph := qp.Graph
+		if v, ok := graph.(Var); ok {
+			graph = b[v]
+			if _, ok := graph.(IRI); !ok {
+				return Quad{}, false
+			}
+		}
+		return Quad{Triple: t, Ctx: graphContext(graph, op.With)}, true
+	}
+	for _, b := range solutions {
+		for _, qp := range op.Delete {
+			if q, ok := inst(qp, b, nil); ok {
+				tx.remove(q)
+			}
+		}
+	}
+	for _, b := range solutions {
+		bnodes := make(map[string]Blank)
+		for _, qp := range op.Insert {
+			if q, ok := inst(qp, b, bnodes); ok {
+				tx.add(q)
+			}
+		}
+	}
+	return nil
+}
+
+// loadGraph applies LOAD.
+func (tx *updateTx) loadGraph(op Load) error {
+	if tx.load == nil {
+		return errors.New("LOAD is not supported without a loader")
+	}
+	r, f, err := tx.load(op.Source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	ts, err := NewTripleDecoder(r, f).DecodeAll()
+	if err != nil {
+		return fmt.Errorf("loading %s: %v", op.Source.Serialize(NTriples), err)
+	}
+	if op.Into != nil {
+		tx.create(op.Into)
+	}
+	for _, t := range ts {
+		tx.add(Quad{Triple: t, Ctx: op.Into})
+	}
+	return nil
+}
+
+// clearScope applies CLEAR, or DROP if drop is true.
+func (tx *updateTx) clearScope(scope GraphScope, graph IRI, drop bool) error {
+	var names []Context
+	switch scope {
+	case ScopeGraph:
+		if err := tx.exists(graph); err != nil {
+			return err
+		}
+		names = []Context{graph}
+	case ScopeDefault:
+		tx.clear(nil)
+	case ScopeNamed:
+		names = tx.ds.Names()
+	case ScopeAll:
+		tx.clear(nil)
+		names = tx.ds.Names()
+	}
+	for _, name := range names {
+		if drop {
+			tx.drop(name)
+		} else {
+			tx.clear(name)
+		}
+	}
+	return nil
+}
+
+// transfer applies ADD, COPY or MOVE, after checking that the source
+// graph exists.
+func (tx *updateTx) transfer(op Transfer) {
+	if op.From == nil && op.To == nil || op.From != nil && op.To != nil && termKey(op.From) == termKey(op.To) {
+		// Transferring a graph to itself does nothing.
+		return
+	}
+	from := tx.ds.def
+	if op.From != nil {
+		from = tx.ds.Graph(op.From)
+	}
+	ts := from.Triples()
+	if op.Op != "ADD" {
+		if op.To == nil {
+			tx.clear(nil)
+		} else if tx.ds.Graph(op.To) != nil {
+			tx.clear(op.To)
+		}
+	}
+	if op.To != nil {
+		tx.create(op.To)
+	}
+	for _, t := range ts {
+		tx.add(Quad{Triple: t, Ctx: op.To})
+	}
+	if op.Op == "MOVE" {
+		if op.From == nil {
+			tx.clear(nil)
+		} else {
+			tx.drop(op.From)
+		}
+	}
+}
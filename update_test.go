@@ -0,0 +1,179 @@
+package rdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// formatQuads returns the quads of the dataset in N-Quads format, sorted.
+func formatQuads(ds *Dataset) []string {
+	var res []string
+	for _, q := range ds.Quads() {
+		s := strings.TrimSuffix(q.Triple.Serialize(NQuads), " .\n")
+		if q.Ctx != nil {
+			s += " " + q.Ctx.Serialize(NQuads)
+		}
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestUpdate(t *testing.T) {
+	loader := func(source IRI) (io.ReadCloser, Format, error) {
+		if source.str != "http://example/doc.ttl" {
+			return nil, 0, errors.New("not found")
+		}
+		return ioutil.NopCloser(bytes.NewBufferString(`<http://example/d> <http://example/p> "loaded" .`)), Turtle, nil
+	}
+
+	tests := []struct {
+		update  string
+		want    []string
+		added   int
+		removed int
+	}{
+		{
+			`PREFIX : <http://example/>
+INSERT DATA { :a :p 1 . GRAPH :g { :a :p 2 } }`,
+			[]string{
+				`<http://example/a> <http://example/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`<http://example/a> <http://example/p> "2"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/g>`,
+			},
+			2, 0,
+		},
+		{
+			`PREFIX : <http://example/>
+INSERT DATA { :a :p 1 , 2 } ;
+DELETE DATA { :a :p 1 } ;
+DELETE { ?s :p ?o } INSERT { ?s :q ?n } WHERE { ?s :p ?o BIND(?o + 1 AS ?n) }`,
+			[]string{
+				`<http://example/a> <http://example/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+			},
+			3, 2,
+		},
+		{
+			`PREFIX : <http://example/>
+INSERT DATA { GRAPH :g { :a :p 1 , 2 } } ;
+WITH :g DELETE { ?s :p 1 } INSERT { ?s :p [ :q ?s ] } WHERE { ?s :p 1 }`,
+			[]string{
+				`<http://example/a> <http://example/p> "2"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/g>`,
+				`<http://example/a> <http://example/p> _:g1 <http://example/g>`,
+				`_:g1 <http://example/q> <http://example/a> <http://example/g>`,
+			},
+			4, 1,
+		},
+		{
+			`PREFIX : <http://example/>
+INSERT DATA { :a :p 1 . GRAPH :g { :a :p 2 } GRAPH :h { :b :p 3 } } ;
+DELETE WHERE { GRAPH ?g { :a :p ?o } }`,
+			[]string{
+				`<http://example/a> <http://example/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+				`<http://example/b> <http://example/p> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/h>`,
+			},
+			3, 1,
+		},
+		{
+			`PREFIX : <http://example/>
+INSERT DATA { :a :p 1 . GRAPH :g { :b :p 2 } } ;
+COPY DEFAULT TO :h ;
+MOVE :g TO DEFAULT ;
+ADD :h TO GRAPH :i ;
+CREATE GRAPH :j ;
+DROP SILENT GRAPH :nope ;
+CLEAR GRAPH :i`,
+			[]string{
+				`<http://example/a> <http://example/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example/h>`,
+				`<http://example/b> <http://example/p> "2"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+			},
+			5, 3,
+		},
+		{
+			`LOAD <http://example/doc.ttl> INTO GRAPH <http://example/g> ;
+LOAD SILENT <http://example/missing>`,
+			[]string{
+				`<http://example/d> <http://example/p> "loaded" <http://example/g>`,
+			},
+			1, 0,
+		},
+	}
+
+	for _, tt := range tests {
+		u, err := ParseUpdate(tt.update)
+		if err != nil {
+			t.Errorf("ParseUpdate(%q) failed: %v", tt.update, err)
+			continue
+		}
+		ds := NewDataset()
+		res, err := u.Exec(ds, loader)
+		if err != nil {
+			t.Errorf("Exec(%q) failed: %v", tt.update, err)
+			continue
+		}
+		if res.Added != tt.added || res.Removed != tt.removed {
+			t.Errorf("Exec(%q) => %+v, want added %d, removed %d", tt.update, res, tt.added, tt.removed)
+		}
+		if got := formatQuads(ds); !equalStrings(got, tt.want) {
+			t.Errorf("Exec(%q) =>\n%q\nwant:\n%q", tt.update, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateAtomic(t *testing.T) {
+	ds := NewDataset()
+	u, err := ParseUpdate(`INSERT DATA { <http://example/a> <http://example/p> 1 }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Exec(ds, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := formatQuads(ds)
+
+	u, err = ParseUpdate(`
+DELETE DATA { <http://example/a> <http://example/p> 1 } ;
+INSERT DATA { GRAPH <http://example/g> { <http://example/a> <http://example/p> 2 } } ;
+CREATE GRAPH <http://example/g>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := u.Exec(ds, nil)
+	if err == nil || err.Error() != "graph <http://example/g> already exists" {
+		t.Errorf("Exec => %v, want error", err)
+	}
+	if res != (UpdateResult{}) {
+		t.Errorf("Exec of failed update => %+v, want no changes", res)
+	}
+	if got := formatQuads(ds); !equalStrings(got, want) || len(ds.Names()) != 0 {
+		t.Errorf("dataset after failed update =>\n%q %v\nwant:\n%q", got, ds.Names(), want)
+	}
+}
+
+func TestParseUpdateErrors(t *testing.T) {
+	tests := []struct {
+		update string
+		want   string
+	}{
+		{`INSERT DATA { ?s <p> 1 }`, "1:15: variables not allowed in INSERT DATA"},
+		{"INSERT DATA {\n  <a> <p> 1 .\n  GRAPH ?g { <a> <p> 2 }\n}", "3:9: variables not allowed in INSERT DATA"},
+		{`DELETE DATA { <a> <p> _:b }`, "1:22: blank nodes not allowed in DELETE"},
+		{`DELETE DATA { <a> <p> (1 2) }`, "1:22: blank nodes not allowed in DELETE"},
+		{`DELETE { ?s <p> [] } WHERE { ?s <p> ?o }`, "1:16: blank nodes not allowed in DELETE"},
+		{`DELETE WHERE { ?s <p> [ <q> 1 ] }`, "1:22: blank nodes not allowed in DELETE"},
+		{`INSERT { ?s <p> 1 }`, `1:19: unexpected end of input, expected WHERE`},
+		{`CLEAR <g>`, `1:7: unexpected <g>, expected GRAPH`},
+		{`INSERT DATA { <a> <p> 1 } DROP ALL`, `1:26: unexpected "DROP", expected end of update`},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseUpdate(tt.update)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseUpdate(%q) => %v, want %q", tt.update, err, tt.want)
+		}
+	}
+}
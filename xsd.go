@@ -0,0 +1,440 @@
+package rdf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Helpers for the lexical and value spaces of the XML schema datatypes.
+
+// isIntegerLexical checks if s is in the lexical space of xsd:integer: [+-]?[0-9]+
+func isIntegerLexical(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDecimalLexical checks if s is in the lexical space of xsd:decimal:
+// [+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)
+func isDecimalLexical(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	digits := 0
+	dot := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			digits++
+		case s[i] == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// parseInteger parses the lexical form of an xsd:integer. It returns an int
+// if the value fits, otherwise a *big.Int.
+func parseInteger(s string) (interface{}, error) {
+	if !isIntegerLexical(s) {
+		return nil, fmt.Errorf("invalid xsd:integer: %q", s)
+	}
+	i, err := strconv.Atoi(s)
+	if err == nil {
+		return i, nil
+	}
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid xsd:integer: %q", s)
+	}
+	return b, nil
+}
+
+// parseDecimal parses the lexical form of an xsd:decimal into an exact *big.Rat.
+func parseDecimal(s string) (*big.Rat, error) {
+	if !isDecimalLexical(s) {
+		return nil, fmt.Errorf("invalid xsd:decimal: %q", s)
+	}
+	if strings.HasSuffix(s, ".") {
+		// big.Rat doesn't accept a trailing dot
+		s += "0"
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid xsd:decimal: %q", s)
+	}
+	return r, nil
+}
+
+// decimalString returns the canonical lexical form of r as an xsd:decimal.
+// The canonical form has no leading '+', no superfluous leading or trailing
+// zeros, and always at least one digit on both sides of the decimal point,
+// e.g. "-1.0" or "0.25".
+//
+// An error is returned if r has no finite decimal representation (e.g. 1/3).
+func decimalString(r *big.Rat) (string, error) {
+	// A fraction has a finite decimal expansion only if the denominator,
+	// in lowest terms, has no other prime factors than 2 and 5.
+	d := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	two, five := big.NewInt(2), big.NewInt(5)
+	m := new(big.Int)
+	for {
+		if q, rem := new(big.Int).QuoRem(d, two, m); rem.Sign() == 0 {
+			d = q
+			twos++
+			continue
+		}
+		break
+	}
+	for {
+		if q, rem := new(big.Int).QuoRem(d, five, m); rem.Sign() == 0 {
+			d = q
+			fives++
+			continue
+		}
+		break
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return "", fmt.Errorf("%s has no finite decimal representation", r.String())
+	}
+	prec := twos
+	if fives > prec {
+		prec = fives
+	}
+	if prec == 0 {
+		prec = 1
+	}
+	s := r.FloatString(prec)
+	s = strings.TrimRight(s, "0")
+	if strings.HasSuffix(s, ".") {
+		s += "0"
+	}
+	return s, nil
+}
+
+// floatDecimal converts a *big.Float to an exact *big.Rat.
+func floatDecimal(f *big.Float) (*big.Rat, error) {
+	if f.IsInf() {
+		return nil, errors.New("infinity cannot be represented as xsd:decimal")
+	}
+	r, _ := f.Rat(nil)
+	return r, nil
+}
+
+// isDoubleLexical checks if s is in the lexical space of xsd:double and xsd:float:
+// [+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([Ee][+-]?[0-9]+)?|[+-]?INF|NaN
+func isDoubleLexical(s string) bool {
+	switch s {
+	case "INF", "+INF", "-INF", "NaN":
+		return true
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if !isDecimalLexical(s[:i]) {
+			return false
+		}
+		return isIntegerLexical(s[i+1:])
+	}
+	return isDecimalLexical(s)
+}
+
+// isBooleanLexical checks if s is in the lexical space of xsd:boolean.
+func isBooleanLexical(s string) bool {
+	switch s {
+	case "true", "false", "1", "0":
+		return true
+	}
+	return false
+}
+
+// parseBoolean parses the lexical form of an xsd:boolean.
+func parseBoolean(s string) (bool, error) {
+	switch s {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid xsd:boolean: %q", s)
+}
+
+// parseDouble parses the lexical form of an xsd:double (bitSize 64)
+// or an xsd:float (bitSize 32).
+func parseDouble(s string, bitSize int) (float64, error) {
+	if !isDoubleLexical(s) {
+		if bitSize == 32 {
+			return 0, fmt.Errorf("invalid xsd:float: %q", s)
+		}
+		return 0, fmt.Errorf("invalid xsd:double: %q", s)
+	}
+	switch s {
+	case "INF", "+INF":
+		return math.Inf(1), nil
+	case "-INF":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, err
+	}
+	// Out of range values are rounded to ±INF or 0, as mandated by XSD.
+	return f, nil
+}
+
+// doubleString returns the canonical lexical form of an xsd:double (bitSize 64)
+// or xsd:float (bitSize 32), e.g. "1.5E2" or "-1.0E-3".
+func doubleString(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	s := strconv.FormatFloat(f, 'E', -1, bitSize)
+	i := strings.IndexByte(s, 'E')
+	mantissa, exp := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
+
+// dateTime layouts, with and without timezone. Go's time package accepts
+// fractional seconds when parsing, even if the layout lacks them.
+const (
+	dateTimeLayoutTZ   = "2006-01-02T15:04:05.999999999Z07:00"
+	dateTimeLayoutNoTZ = "2006-01-02T15:04:05.999999999"
+)
+
+// parseDateTime parses the lexical form of an xsd:dateTime. The returned bool
+// reports whether the value has a timezone; values without timezone are
+// returned in UTC.
+func parseDateTime(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateTimeLayoutTZ, s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(dateTimeLayoutNoTZ, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid xsd:dateTime: %q", s)
+	}
+	return t, false, nil
+}
+
+// dateTimeString returns the canonical lexical form of an xsd:dateTime. Timezoned
+// values are normalized to UTC.
+func dateTimeString(t time.Time, hasTZ bool) string {
+	if hasTZ {
+		return t.UTC().Format(dateTimeLayoutTZ)
+	}
+	return t.Format(dateTimeLayoutNoTZ)
+}
+
+// numKind is the kind of a numeric literal, in order of SPARQL's numeric
+// type promotion: integer < decimal < float < double.
+type numKind int
+
+const (
+	numInteger numKind = iota
+	numDecimal
+	numFloat
+	numDouble
+)
+
+// number is the value of a numeric literal. For integers and decimals the
+// exact value is stored in rat, for floats and doubles in f.
+type number struct {
+	kind numKind
+	rat  *big.Rat
+	f    float64
+}
+
+// float returns the number as a float64.
+func (n number) float() float64 {
+	if n.kind >= numFloat {
+		return n.f
+	}
+	f, _ := n.rat.Float64()
+	return f
+}
+
+// cmp compares two numbers after promoting them to a common type. It returns -1, 0
+// or +1, and false if the numbers cannot be ordered (i.e. one of them is NaN).
+func (n number) cmp(o number) (int, bool) {
+	if n.kind <= numDecimal && o.kind <= numDecimal {
+		return n.rat.Cmp(o.rat), true
+	}
+	a, b := n.float(), o.float()
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return 0, false
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	}
+	return 0, true
+}
+
+// numericDataType returns the numeric kind of the given datatype, or false
+// if it is not a numeric datatype.
+func numericDataType(dt IRI) (numKind, bool) {
+	switch dt {
+	case xsdInteger, xsdInt:
+		return numInteger, true
+	case xsdDecimal:
+		return numDecimal, true
+	case xsdFloat:
+		return numFloat, true
+	case xsdDouble:
+		return numDouble, true
+	}
+	return 0, false
+}
+
+// literalNumber returns the numeric value of a literal, or false if the
+// literal is not numeric or is ill-typed.
+func literalNumber(l Literal) (number, bool) {
+	kind, ok := numericDataType(l.DataType)
+	if !ok {
+		return number{}, false
+	}
+	switch kind {
+	case numInteger:
+		if !isIntegerLexical(l.str) {
+			return number{}, false
+		}
+		r, ok := new(big.Rat).SetString(l.str)
+		return number{kind: kind, rat: r}, ok
+	case numDecimal:
+		r, err := parseDecimal(l.str)
+		if err != nil {
+			return number{}, false
+		}
+		return number{kind: kind, rat: r}, true
+	case numFloat:
+		f, err := parseDouble(l.str, 32)
+		return number{kind: kind, f: f}, err == nil
+	default:
+		f, err := parseDouble(l.str, 64)
+		return number{kind: kind, f: f}, err == nil
+	}
+}
+
+// canonicalLexical returns the canonical lexical form of s for the given
+// datatype, or false if the datatype isn't supported or s is ill-typed.
+func canonicalLexical(s string, dt IRI) (string, bool) {
+	switch dt {
+	case xsdInteger, xsdInt:
+		if !isIntegerLexical(s) {
+			return "", false
+		}
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return "", false
+		}
+		return i.String(), true
+	case xsdDecimal:
+		r, err := parseDecimal(s)
+		if err != nil {
+			return "", false
+		}
+		c, err := decimalString(r)
+		return c, err == nil
+	case xsdDouble, xsdFloat:
+		bitSize := 64
+		if dt == xsdFloat {
+			bitSize = 32
+		}
+		f, err := parseDouble(s, bitSize)
+		if err != nil {
+			return "", false
+		}
+		return doubleString(f, bitSize), true
+	case xsdBoolean:
+		b, err := parseBoolean(s)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case xsdDateTime:
+		t, tz, err := parseDateTime(s)
+		if err != nil {
+			return "", false
+		}
+		return dateTimeString(t, tz), true
+	case xmlLiteral:
+		c, err := canonicalXML(s)
+		return c, err == nil
+	case rdfHTML:
+		return parseHTML(s).String(), true
+	case rdfJSON:
+		c, err := canonicalJSON(s)
+		return c, err == nil
+	}
+	return "", false
+}
+
+// validateLexical checks that s is in the lexical space of the given datatype.
+// Datatypes which are not supported are assumed to be valid.
+func validateLexical(s string, dt IRI) error {
+	switch dt {
+	case xsdInteger:
+		if !isIntegerLexical(s) {
+			return fmt.Errorf("invalid xsd:integer: %q", s)
+		}
+	case xsdInt:
+		if !isIntegerLexical(s) {
+			return fmt.Errorf("invalid xsd:int: %q", s)
+		}
+		if _, err := strconv.ParseInt(s, 10, 32); err != nil {
+			return fmt.Errorf("xsd:int out of range: %q", s)
+		}
+	case xsdDecimal:
+		if !isDecimalLexical(s) {
+			return fmt.Errorf("invalid xsd:decimal: %q", s)
+		}
+	case xsdDouble:
+		if !isDoubleLexical(s) {
+			return fmt.Errorf("invalid xsd:double: %q", s)
+		}
+	case xsdFloat:
+		if !isDoubleLexical(s) {
+			return fmt.Errorf("invalid xsd:float: %q", s)
+		}
+	case xsdBoolean:
+		if !isBooleanLexical(s) {
+			return fmt.Errorf("invalid xsd:boolean: %q", s)
+		}
+	case xsdDateTime:
+		if _, _, err := parseDateTime(s); err != nil {
+			return err
+		}
+	case rdfJSON:
+		if !json.Valid([]byte(s)) {
+			return fmt.Errorf("invalid rdf:JSON: %q", s)
+		}
+	}
+	return nil
+}